@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -34,6 +35,7 @@ type ABI struct {
 	Constructor Method
 	Methods     map[string]Method
 	Events      map[string]Event
+	Errors      map[string]Error
 
 	// Additional "special" functions introduced in solidity v0.6.0.
 	// It's separated from the original default fallback. Each contract
@@ -157,6 +159,7 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 	}
 	abi.Methods = make(map[string]Method)
 	abi.Events = make(map[string]Event)
+	abi.Errors = make(map[string]Error)
 	for _, field := range fields {
 		switch field.Type {
 		case "constructor":
@@ -184,6 +187,9 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 		case "event":
 			name := abi.overloadedEventName(field.Name)
 			abi.Events[name] = NewEvent(name, field.Name, field.Anonymous, field.Inputs)
+		case "error":
+			name := abi.overloadedErrorName(field.Name)
+			abi.Errors[name] = NewError(name, field.Name, field.Inputs)
 		default:
 			return fmt.Errorf("abi: could not recognize type %v of field %v", field.Type, field.Name)
 		}
@@ -221,6 +227,21 @@ func (abi *ABI) overloadedEventName(rawName string) string {
 	return name
 }
 
+// overloadedErrorName returns the next available name for a given error.
+// Needed since solidity allows for error overload.
+//
+// e.g. if the abi contains errors failed, failed0
+// overloadedErrorName would return failed1 for input failed.
+func (abi *ABI) overloadedErrorName(rawName string) string {
+	name := rawName
+	_, ok := abi.Errors[name]
+	for idx := 0; ok; idx++ {
+		name = fmt.Sprintf("%s%d", rawName, idx)
+		_, ok = abi.Errors[name]
+	}
+	return name
+}
+
 // MethodById looks up a method by the 4-byte id,
 // returns nil if none found.
 func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
@@ -246,6 +267,17 @@ func (abi *ABI) EventByID(topic common.Hash) (*Event, error) {
 	return nil, fmt.Errorf("no event with id: %#x", topic.Hex())
 }
 
+// ErrorByID looks up an error by the 4-byte id,
+// returns nil if none found.
+func (abi *ABI) ErrorByID(sigdata [4]byte) (*Error, error) {
+	for _, errABI := range abi.Errors {
+		if bytes.Equal(errABI.ID[:4], sigdata[:]) {
+			return &errABI, nil
+		}
+	}
+	return nil, fmt.Errorf("no error with id: %#x", sigdata[:])
+}
+
 // HasFallback returns an indicator whether a fallback function is included.
 func (abi *ABI) HasFallback() bool {
 	return abi.Fallback.Type == Fallback
@@ -256,8 +288,13 @@ func (abi *ABI) HasReceive() bool {
 	return abi.Receive.Type == Receive
 }
 
-// revertSelector is a special function selector for revert reason unpacking.
-var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+var (
+	// revertSelector is a special function selector for revert reason unpacking.
+	revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+	// panicSelector is a special function selector for panic code unpacking.
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
 
 // UnpackRevert resolves the abi-encoded revert reason. According to the solidity
 // spec https://solidity.readthedocs.io/en/latest/control-structures.html#revert,
@@ -277,3 +314,40 @@ func UnpackRevert(data []byte) (string, error) {
 	}
 	return unpacked[0].(string), nil
 }
+
+// DecodeRevert decodes the return data of a reverted contract call into a typed
+// error: *RevertError for reverts with a reason string, *PanicError for failed
+// assertions and other panics and *CustomError for custom errors declared in
+// the ABI. Nil is returned if the data does not match any of them.
+func (abi *ABI) DecodeRevert(data []byte) error {
+	if len(data) < 4 {
+		return nil
+	}
+	switch {
+	case bytes.Equal(data[:4], revertSelector):
+		reason, err := UnpackRevert(data)
+		if err != nil {
+			return nil
+		}
+		return &RevertError{Reason: reason}
+
+	case bytes.Equal(data[:4], panicSelector):
+		typ, _ := NewType("uint256", "", nil)
+		unpacked, err := (Arguments{{Type: typ}}).Unpack(data[4:])
+		if err != nil {
+			return nil
+		}
+		return &PanicError{Code: unpacked[0].(*big.Int)}
+	}
+	var id [4]byte
+	copy(id[:], data)
+	def, err := abi.ErrorByID(id)
+	if err != nil {
+		return nil
+	}
+	values, err := def.Unpack(data)
+	if err != nil {
+		return nil
+	}
+	return &CustomError{Def: def, Values: values}
+}
@@ -1146,3 +1146,51 @@ func TestUnpackRevert(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeRevert(t *testing.T) {
+	t.Parallel()
+
+	abi, err := JSON(strings.NewReader(`[
+		{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]},
+		{"type":"error","name":"Unauthorized","inputs":[]},
+		{"type":"error","name":"Unauthorized","inputs":[{"name":"","type":"address"}]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(abi.Errors) != 3 {
+		t.Fatalf("error count mismatch: have %d, want 3", len(abi.Errors))
+	}
+	if sig := abi.Errors["Unauthorized0"].Sig; sig != "Unauthorized(address)" {
+		t.Fatalf("overloaded error signature mismatch: have %s, want Unauthorized(address)", sig)
+	}
+	var cases = []struct {
+		input  string
+		expect string
+	}{
+		{"", ""},
+		{"deadbeef", ""},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000", "execution reverted: revert reason"},
+		{"4e487b710000000000000000000000000000000000000000000000000000000000000011", "execution reverted: panic: arithmetic underflow or overflow (0x11)"},
+		{"4e487b7100000000000000000000000000000000000000000000000000000000000000ff", "execution reverted: panic: unknown code 0xff"},
+		{"cf47918100000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002", "execution reverted: InsufficientBalance(1, 2)"},
+		{"cf479181", ""},
+	}
+	for index, c := range cases {
+		t.Run(fmt.Sprintf("case %d", index), func(t *testing.T) {
+			err := abi.DecodeRevert(common.Hex2Bytes(c.input))
+			if c.expect == "" {
+				if err != nil {
+					t.Fatalf("Expected undecodable data, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected decoded error %q, got nil", c.expect)
+			}
+			if err.Error() != c.expect {
+				t.Fatalf("Output mismatch, want %v, got %v", c.expect, err)
+			}
+		})
+	}
+}
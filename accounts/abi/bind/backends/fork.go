@@ -0,0 +1,365 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// emptyCodeHash is the code hash of accounts without code.
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// ForkSource is the remote chain a forked simulated backend retrieves its state
+// from, such as an *rpc.Client connected to a live node. The source needs to
+// serve eth_getBlockByNumber, eth_getProof and eth_getCode.
+type ForkSource interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// NewForkedBackend creates a simulated backend starting from the state of the
+// given block of a remote chain (nil for the latest block). Accounts, storage
+// slots and code are retrieved lazily when first accessed and stored into the
+// local database, so each of them is fetched from the source only once.
+//
+// The forked chain uses its own block numbering, starting with a genesis block
+// holding the state of the remote block.
+func NewForkedBackend(source ForkSource, blockNumber *big.Int, gasLimit uint64) (*SimulatedBackend, error) {
+	ctx := context.Background()
+
+	var head *types.Header
+	if err := source.CallContext(ctx, &head, "eth_getBlockByNumber", toBlockNumArg(blockNumber), false); err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, ethereum.NotFound
+	}
+	database := rawdb.NewMemoryDatabase()
+	fork := newForkState(source, head.Number, database)
+
+	// Retrieve the root of the forked state, along with the coinbase account
+	// the rewards of all simulated blocks are credited to.
+	if _, err := fork.fetch(ctx, map[common.Address][]common.Hash{{}: nil}); err != nil {
+		return nil, err
+	}
+	config := params.AllEthashProtocolChanges
+	genesis := types.NewBlock(&types.Header{
+		Number:     new(big.Int),
+		Time:       head.Time,
+		GasLimit:   gasLimit,
+		Difficulty: params.GenesisDifficulty,
+		Root:       head.Root,
+	}, nil, nil, nil, trie.NewStackTrie(nil))
+
+	rawdb.WriteTd(database, genesis.Hash(), genesis.NumberU64(), genesis.Difficulty())
+	rawdb.WriteBlock(database, genesis)
+	rawdb.WriteReceipts(database, genesis.Hash(), genesis.NumberU64(), nil)
+	rawdb.WriteCanonicalHash(database, genesis.Hash(), genesis.NumberU64())
+	rawdb.WriteHeadBlockHash(database, genesis.Hash())
+	rawdb.WriteHeadFastBlockHash(database, genesis.Hash())
+	rawdb.WriteHeadHeaderHash(database, genesis.Hash())
+	rawdb.WriteChainConfig(database, genesis.Hash(), config)
+
+	// The state is only partially available locally, so snapshots can't be
+	// generated. Keep all states around to allow reverting to any of them.
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:    256,
+		TrieDirtyDisabled: true,
+		TrieTimeLimit:     5 * time.Minute,
+	}
+	blockchain, err := core.NewBlockChain(database, cacheConfig, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	backend := newSimulatedBackend(database, blockchain, config)
+	backend.fork = fork
+	return backend, nil
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
+
+// forkState tracks the parts of the remote state already retrieved into the
+// local database.
+type forkState struct {
+	source ForkSource
+	number *big.Int // Remote block the state is retrieved from
+	db     ethdb.Database
+
+	accounts map[common.Address]struct{}                 // Accounts already retrieved
+	slots    map[common.Address]map[common.Hash]struct{} // Storage slots already retrieved
+}
+
+func newForkState(source ForkSource, number *big.Int, db ethdb.Database) *forkState {
+	return &forkState{
+		source:   source,
+		number:   number,
+		db:       db,
+		accounts: make(map[common.Address]struct{}),
+		slots:    make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// proofResult is the subset of the eth_getProof response needed to reconstruct
+// the remote state locally.
+type proofResult struct {
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	StorageProof []struct {
+		Proof []hexutil.Bytes `json:"proof"`
+	} `json:"storageProof"`
+}
+
+// fetch retrieves the given accounts and storage slots from the remote source,
+// unless already done before, and stores the trie nodes proving them and the
+// code of the accounts into the local database. The returned flag reports
+// whether anything new had to be retrieved.
+func (f *forkState) fetch(ctx context.Context, keys map[common.Address][]common.Hash) (bool, error) {
+	var fetched bool
+	for addr, slots := range keys {
+		var missing []common.Hash
+		for _, slot := range slots {
+			if _, ok := f.slots[addr][slot]; !ok {
+				missing = append(missing, slot)
+			}
+		}
+		if _, ok := f.accounts[addr]; ok && len(missing) == 0 {
+			continue
+		}
+		var proof proofResult
+		if err := f.source.CallContext(ctx, &proof, "eth_getProof", addr, missing, toBlockNumArg(f.number)); err != nil {
+			return fetched, fmt.Errorf("failed to retrieve forked account %x: %w", addr, err)
+		}
+		for _, node := range proof.AccountProof {
+			rawdb.WriteTrieNode(f.db, crypto.Keccak256Hash(node), node)
+		}
+		for _, result := range proof.StorageProof {
+			for _, node := range result.Proof {
+				rawdb.WriteTrieNode(f.db, crypto.Keccak256Hash(node), node)
+			}
+		}
+		if _, ok := f.accounts[addr]; !ok && proof.CodeHash != (common.Hash{}) && proof.CodeHash != emptyCodeHash {
+			var code hexutil.Bytes
+			if err := f.source.CallContext(ctx, &code, "eth_getCode", addr, toBlockNumArg(f.number)); err != nil {
+				return fetched, fmt.Errorf("failed to retrieve forked code of %x: %w", addr, err)
+			}
+			if hash := crypto.Keccak256Hash(code); hash != proof.CodeHash {
+				return fetched, fmt.Errorf("forked code of %x mismatch: have %x, want %x", addr, hash, proof.CodeHash)
+			}
+			rawdb.WriteCode(f.db, proof.CodeHash, code)
+		}
+		f.accounts[addr] = struct{}{}
+		if len(missing) > 0 && f.slots[addr] == nil {
+			f.slots[addr] = make(map[common.Hash]struct{})
+		}
+		for _, slot := range missing {
+			f.slots[addr][slot] = struct{}{}
+		}
+		fetched = true
+	}
+	return fetched, nil
+}
+
+// forkAccount ensures that the given account and storage slots are available
+// locally if the backend was forked off a remote chain.
+func (b *SimulatedBackend) forkAccount(ctx context.Context, account common.Address, slots ...common.Hash) error {
+	if b.fork == nil {
+		return nil
+	}
+	fetched, err := b.fork.fetch(ctx, map[common.Address][]common.Hash{account: slots})
+	if err != nil {
+		return err
+	}
+	if fetched {
+		b.pendingState, _ = state.New(b.pendingBlock.Root(), b.blockchain.StateCache(), nil)
+	}
+	return nil
+}
+
+// forkCall ensures that all the state accessed by executing the given call on
+// top of the given block is available locally if the backend was forked off a
+// remote chain. As the accessed state is only known after execution and may
+// depend on the retrieved values, the call is repeated until it doesn't access
+// any new state anymore.
+func (b *SimulatedBackend) forkCall(ctx context.Context, call ethereum.CallMsg, block *types.Block) error {
+	if b.fork == nil {
+		return nil
+	}
+	if call.Gas == 0 {
+		call.Gas = 50000000
+	}
+	if call.Value == nil {
+		call.Value = new(big.Int)
+	}
+	var refresh bool
+	for {
+		stateDB, err := state.New(block.Root(), b.blockchain.StateCache(), nil)
+		if err != nil {
+			return err
+		}
+		recorder := &accessRecorder{StateDB: stateDB, accessed: make(map[common.Address][]common.Hash)}
+		recorder.SetBalance(call.From, math.MaxBig256)
+		recorder.account(call.From)
+
+		// Execution errors are irrelevant, only the accessed state is of interest
+		msg := types.NewMessage(call.From, call.To, 0, call.Value, call.Gas, new(big.Int), call.Data, call.AccessList, false)
+		vmEnv := vm.NewEVM(core.NewEVMBlockContext(block.Header(), b.blockchain, nil), core.NewEVMTxContext(msg), recorder, b.config, vm.Config{})
+		core.ApplyMessage(vmEnv, msg, new(core.GasPool).AddGas(math.MaxUint64))
+		stateDB.IntermediateRoot(b.config.IsEIP158(block.Number()))
+
+		fetched, err := b.fork.fetch(ctx, recorder.accessed)
+		if err != nil {
+			return err
+		}
+		if !fetched {
+			// Everything accessed is available, unless updating the state needs
+			// trie nodes not covered by any proof (e.g. siblings of deleted ones)
+			if err := stateDB.Error(); err != nil {
+				return fmt.Errorf("forked state unavailable: %w", err)
+			}
+			break
+		}
+		refresh = true
+	}
+	if refresh {
+		b.pendingState, _ = state.New(b.pendingBlock.Root(), b.blockchain.StateCache(), nil)
+	}
+	return nil
+}
+
+// accessRecorder wraps a state database, tracking all the accounts and storage
+// slots accessed through it.
+type accessRecorder struct {
+	*state.StateDB
+	accessed map[common.Address][]common.Hash
+}
+
+func (r *accessRecorder) account(addr common.Address) {
+	if _, ok := r.accessed[addr]; !ok {
+		r.accessed[addr] = nil
+	}
+}
+
+func (r *accessRecorder) slot(addr common.Address, key common.Hash) {
+	r.accessed[addr] = append(r.accessed[addr], key)
+}
+
+func (r *accessRecorder) CreateAccount(addr common.Address) {
+	r.account(addr)
+	r.StateDB.CreateAccount(addr)
+}
+
+func (r *accessRecorder) SubBalance(addr common.Address, amount *big.Int) {
+	r.account(addr)
+	r.StateDB.SubBalance(addr, amount)
+}
+
+func (r *accessRecorder) AddBalance(addr common.Address, amount *big.Int) {
+	r.account(addr)
+	r.StateDB.AddBalance(addr, amount)
+}
+
+func (r *accessRecorder) GetBalance(addr common.Address) *big.Int {
+	r.account(addr)
+	return r.StateDB.GetBalance(addr)
+}
+
+func (r *accessRecorder) GetNonce(addr common.Address) uint64 {
+	r.account(addr)
+	return r.StateDB.GetNonce(addr)
+}
+
+func (r *accessRecorder) SetNonce(addr common.Address, nonce uint64) {
+	r.account(addr)
+	r.StateDB.SetNonce(addr, nonce)
+}
+
+func (r *accessRecorder) GetCodeHash(addr common.Address) common.Hash {
+	r.account(addr)
+	return r.StateDB.GetCodeHash(addr)
+}
+
+func (r *accessRecorder) GetCode(addr common.Address) []byte {
+	r.account(addr)
+	return r.StateDB.GetCode(addr)
+}
+
+func (r *accessRecorder) SetCode(addr common.Address, code []byte) {
+	r.account(addr)
+	r.StateDB.SetCode(addr, code)
+}
+
+func (r *accessRecorder) GetCodeSize(addr common.Address) int {
+	r.account(addr)
+	return r.StateDB.GetCodeSize(addr)
+}
+
+func (r *accessRecorder) GetCommittedState(addr common.Address, key common.Hash) common.Hash {
+	r.slot(addr, key)
+	return r.StateDB.GetCommittedState(addr, key)
+}
+
+func (r *accessRecorder) GetState(addr common.Address, key common.Hash) common.Hash {
+	r.slot(addr, key)
+	return r.StateDB.GetState(addr, key)
+}
+
+func (r *accessRecorder) SetState(addr common.Address, key, value common.Hash) {
+	r.slot(addr, key)
+	r.StateDB.SetState(addr, key, value)
+}
+
+func (r *accessRecorder) Suicide(addr common.Address) bool {
+	r.account(addr)
+	return r.StateDB.Suicide(addr)
+}
+
+func (r *accessRecorder) HasSuicided(addr common.Address) bool {
+	r.account(addr)
+	return r.StateDB.HasSuicided(addr)
+}
+
+func (r *accessRecorder) Exist(addr common.Address) bool {
+	r.account(addr)
+	return r.StateDB.Exist(addr)
+}
+
+func (r *accessRecorder) Empty(addr common.Address) bool {
+	r.account(addr)
+	return r.StateDB.Empty(addr)
+}
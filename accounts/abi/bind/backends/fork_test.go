@@ -0,0 +1,164 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testForkSource is a local stand-in for a remote node, serving the requests
+// of a forked backend from the latest state of another simulated backend.
+type testForkSource struct {
+	sim   *SimulatedBackend
+	calls map[string]int
+}
+
+func (s *testForkSource) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	s.calls[method]++
+
+	statedb, err := s.sim.blockchain.State()
+	if err != nil {
+		return err
+	}
+	var res interface{}
+	switch method {
+	case "eth_getBlockByNumber":
+		res = s.sim.blockchain.CurrentHeader()
+
+	case "eth_getCode":
+		res = hexutil.Bytes(statedb.GetCode(args[0].(common.Address)))
+
+	case "eth_getProof":
+		addr := args[0].(common.Address)
+		proof, err := statedb.GetProof(addr)
+		if err != nil {
+			return err
+		}
+		type storageResult struct {
+			Proof []hexutil.Bytes `json:"proof"`
+		}
+		var storage []storageResult
+		for _, key := range args[1].([]common.Hash) {
+			nodes, err := statedb.GetStorageProof(addr, key)
+			if err != nil {
+				return err
+			}
+			storage = append(storage, storageResult{Proof: toHexSlice(nodes)})
+		}
+		res = map[string]interface{}{
+			"accountProof": toHexSlice(proof),
+			"codeHash":     statedb.GetCodeHash(addr),
+			"storageProof": storage,
+		}
+	default:
+		return fmt.Errorf("method %s not supported", method)
+	}
+	blob, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(blob, result)
+}
+
+func toHexSlice(b [][]byte) []hexutil.Bytes {
+	r := make([]hexutil.Bytes, len(b))
+	for i := range b {
+		r[i] = b[i]
+	}
+	return r
+}
+
+func TestForkedBackend(t *testing.T) {
+	var (
+		bgCtx    = context.Background()
+		testAddr = crypto.PubkeyToAddress(testKey.PublicKey)
+		contract = common.HexToAddress("0xc0ffee")
+		slot     = common.Hash{}
+	)
+	// Runtime code incrementing the first storage slot, returning the new value
+	code := common.FromHex("60016000540160005560005460005260206000f3")
+
+	remote := NewSimulatedBackend(core.GenesisAlloc{
+		testAddr: {Balance: big.NewInt(10000000000)},
+		contract: {Balance: new(big.Int), Code: code, Storage: map[common.Hash]common.Hash{slot: common.BigToHash(big.NewInt(41))}},
+	}, 10000000)
+	defer remote.Close()
+	remote.Commit()
+
+	source := &testForkSource{sim: remote, calls: make(map[string]int)}
+	sim, err := NewForkedBackend(source, nil, 10000000)
+	if err != nil {
+		t.Fatalf("failed to fork: %v", err)
+	}
+	defer sim.Close()
+
+	if balance, err := sim.BalanceAt(bgCtx, testAddr, nil); err != nil || balance.Cmp(big.NewInt(10000000000)) != 0 {
+		t.Errorf("forked balance mismatch: have %v, want %v (err %v)", balance, 10000000000, err)
+	}
+	res, err := sim.CallContract(bgCtx, ethereum.CallMsg{From: testAddr, To: &contract}, nil)
+	if err != nil {
+		t.Fatalf("failed to call forked contract: %v", err)
+	}
+	if have := new(big.Int).SetBytes(res); have.Int64() != 42 {
+		t.Errorf("forked call result mismatch: have %v, want 42", have)
+	}
+	// Accessing the same state again should be served locally
+	calls := source.calls["eth_getProof"] + source.calls["eth_getCode"]
+	if _, err := sim.CallContract(bgCtx, ethereum.CallMsg{From: testAddr, To: &contract}, nil); err != nil {
+		t.Fatalf("failed to call forked contract: %v", err)
+	}
+	if have := source.calls["eth_getProof"] + source.calls["eth_getCode"]; have != calls {
+		t.Errorf("forked state retrieved again: have %d requests, want %d", have, calls)
+	}
+	// Modify the forked state, impersonating the remote account
+	id := sim.Snapshot()
+
+	bound := bind.NewBoundContract(contract, abi.ABI{}, sim, sim, sim)
+	if _, err := bound.RawTransact(sim.Impersonate(testAddr), nil); err != nil {
+		t.Fatalf("failed to transact: %v", err)
+	}
+	sim.Commit()
+
+	if val, _ := sim.StorageAt(bgCtx, contract, slot, nil); new(big.Int).SetBytes(val).Int64() != 42 {
+		t.Errorf("storage mismatch after transaction: have %x, want 42", val)
+	}
+	if val, _ := remote.StorageAt(bgCtx, contract, slot, nil); new(big.Int).SetBytes(val).Int64() != 41 {
+		t.Errorf("remote storage modified: have %x, want 41", val)
+	}
+	// Revert the transaction
+	if err := sim.Revert(id); err != nil {
+		t.Fatalf("failed to revert: %v", err)
+	}
+	if val, _ := sim.StorageAt(bgCtx, contract, slot, nil); new(big.Int).SetBytes(val).Int64() != 41 {
+		t.Errorf("storage mismatch after revert: have %x, want 41", val)
+	}
+	if nonce, _ := sim.PendingNonceAt(bgCtx, testAddr); nonce != 0 {
+		t.Errorf("nonce mismatch after revert: have %d, want 0", nonce)
+	}
+}
@@ -18,6 +18,7 @@ package backends
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -48,6 +49,9 @@ import (
 // This nil assignment ensures at compile time that SimulatedBackend implements bind.ContractBackend.
 var _ bind.ContractBackend = (*SimulatedBackend)(nil)
 
+// This nil assignment ensures at compile time that SimulatedBackend implements bind.BatchCaller.
+var _ bind.BatchCaller = (*SimulatedBackend)(nil)
+
 var (
 	errBlockNumberUnsupported  = errors.New("simulatedBackend cannot access blocks other than the latest block")
 	errBlockDoesNotExist       = errors.New("block does not exist in blockchain")
@@ -69,6 +73,10 @@ type SimulatedBackend struct {
 
 	events *filters.EventSystem // Event system for filtering log events live
 
+	snapshots   []common.Hash // Chain heads recorded to revert to later on
+	fork        *forkState    // Remote state retrieval if forked off a live chain
+	pendingTime uint64        // Timestamp of the pending block set by SetTime, zero if unset
+
 	config *params.ChainConfig
 }
 
@@ -80,10 +88,16 @@ func NewSimulatedBackendWithDatabase(database ethdb.Database, alloc core.Genesis
 	genesis.MustCommit(database)
 	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
 
+	return newSimulatedBackend(database, blockchain, genesis.Config)
+}
+
+// newSimulatedBackend creates a binding backend on top of an already set up
+// simulated blockchain.
+func newSimulatedBackend(database ethdb.Database, blockchain *core.BlockChain, config *params.ChainConfig) *SimulatedBackend {
 	backend := &SimulatedBackend{
 		database:   database,
 		blockchain: blockchain,
-		config:     genesis.Config,
+		config:     config,
 		events:     filters.NewEventSystem(&filterBackend{database, blockchain}, false),
 	}
 	backend.rollback()
@@ -127,6 +141,7 @@ func (b *SimulatedBackend) rollback() {
 	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
 
 	b.pendingBlock = blocks[0]
+	b.pendingTime = 0
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.blockchain.StateCache(), nil)
 }
 
@@ -147,6 +162,9 @@ func (b *SimulatedBackend) CodeAt(ctx context.Context, contract common.Address,
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.forkAccount(ctx, contract); err != nil {
+		return nil, err
+	}
 	stateDB, err := b.stateByBlockNumber(ctx, blockNumber)
 	if err != nil {
 		return nil, err
@@ -160,6 +178,9 @@ func (b *SimulatedBackend) BalanceAt(ctx context.Context, contract common.Addres
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.forkAccount(ctx, contract); err != nil {
+		return nil, err
+	}
 	stateDB, err := b.stateByBlockNumber(ctx, blockNumber)
 	if err != nil {
 		return nil, err
@@ -173,6 +194,9 @@ func (b *SimulatedBackend) NonceAt(ctx context.Context, contract common.Address,
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.forkAccount(ctx, contract); err != nil {
+		return 0, err
+	}
 	stateDB, err := b.stateByBlockNumber(ctx, blockNumber)
 	if err != nil {
 		return 0, err
@@ -186,6 +210,9 @@ func (b *SimulatedBackend) StorageAt(ctx context.Context, contract common.Addres
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.forkAccount(ctx, contract, key); err != nil {
+		return nil, err
+	}
 	stateDB, err := b.stateByBlockNumber(ctx, blockNumber)
 	if err != nil {
 		return nil, err
@@ -343,6 +370,9 @@ func (b *SimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Ad
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.forkAccount(ctx, contract); err != nil {
+		return nil, err
+	}
 	return b.pendingState.GetCode(contract), nil
 }
 
@@ -384,6 +414,9 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallM
 	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
 		return nil, errBlockNumberUnsupported
 	}
+	if err := b.forkCall(ctx, call, b.blockchain.CurrentBlock()); err != nil {
+		return nil, err
+	}
 	stateDB, err := b.blockchain.State()
 	if err != nil {
 		return nil, err
@@ -403,6 +436,10 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallM
 func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereum.CallMsg) ([]byte, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+
+	if err := b.forkCall(ctx, call, b.pendingBlock); err != nil {
+		return nil, err
+	}
 	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
 
 	res, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
@@ -416,12 +453,72 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereu
 	return res.Return(), res.Err
 }
 
+// BatchCallContext implements bind.BatchCaller, executing the eth_call requests
+// of a batch one by one against the simulated chain. Failures are reported in
+// the individual batch elements, other methods are not supported.
+func (b *SimulatedBackend) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	for i := range batch {
+		elem := &batch[i]
+		if elem.Method != "eth_call" || len(elem.Args) != 2 {
+			elem.Error = fmt.Errorf("unsupported batch method %s", elem.Method)
+			continue
+		}
+		// Round trip the arguments through JSON, just as a remote node would see them
+		var (
+			args struct {
+				From common.Address  `json:"from"`
+				To   *common.Address `json:"to"`
+				Data hexutil.Bytes   `json:"data"`
+			}
+			block rpc.BlockNumber
+		)
+		blob, err := json.Marshal(elem.Args[0])
+		if err == nil {
+			err = json.Unmarshal(blob, &args)
+		}
+		if err == nil {
+			blob, err = json.Marshal(elem.Args[1])
+		}
+		if err == nil {
+			err = json.Unmarshal(blob, &block)
+		}
+		if err != nil {
+			elem.Error = err
+			continue
+		}
+		var (
+			call = ethereum.CallMsg{From: args.From, To: args.To, Data: args.Data}
+			out  []byte
+		)
+		switch block {
+		case rpc.PendingBlockNumber:
+			out, err = b.PendingCallContract(ctx, call)
+		case rpc.LatestBlockNumber:
+			out, err = b.CallContract(ctx, call, nil)
+		default:
+			out, err = b.CallContract(ctx, call, big.NewInt(block.Int64()))
+		}
+		if err != nil {
+			elem.Error = err
+			continue
+		}
+		if blob, err = json.Marshal(hexutil.Bytes(out)); err == nil {
+			err = json.Unmarshal(blob, elem.Result)
+		}
+		elem.Error = err
+	}
+	return nil
+}
+
 // PendingNonceAt implements PendingStateReader.PendingNonceAt, retrieving
 // the nonce currently pending for the account.
 func (b *SimulatedBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.forkAccount(ctx, account); err != nil {
+		return 0, err
+	}
 	return b.pendingState.GetOrNewStateObject(account).Nonce(), nil
 }
 
@@ -437,6 +534,9 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.forkCall(ctx, call, b.pendingBlock); err != nil {
+		return 0, err
+	}
 	// Determine the lowest and highest possible gas limits to binary search in between
 	var (
 		lo  uint64 = params.TxGas - 1
@@ -566,13 +666,24 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 	if err != nil {
 		panic(fmt.Errorf("invalid transaction: %v", err))
 	}
+	call := ethereum.CallMsg{From: sender, To: tx.To(), Gas: tx.Gas(), Value: tx.Value(), Data: tx.Data(), AccessList: tx.AccessList()}
+	if err := b.forkCall(ctx, call, b.pendingBlock); err != nil {
+		return err
+	}
 	nonce := b.pendingState.GetNonce(sender)
 	if tx.Nonce() != nonce {
 		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
 	}
 
-	// Include tx in chain.
+	// Include tx in chain, retaining any timestamp explicitly set.
+	var offset int64
+	if b.pendingTime != 0 {
+		offset = int64(b.pendingTime) - int64(block.Time()+10)
+	}
 	blocks, _ := core.GenerateChain(b.config, block, ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+		if offset != 0 {
+			block.OffsetTime(offset)
+		}
 		for _, tx := range b.pendingBlock.Transactions() {
 			block.AddTxWithChain(b.blockchain, tx)
 		}
@@ -701,6 +812,96 @@ func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 	return nil
 }
 
+// SetTime sets the timestamp of the pending block. It can only be called on
+// empty blocks and the time needs to be later than the latest block's. Unlike
+// with AdjustTime, the timestamp is retained when transactions are added.
+func (b *SimulatedBackend) SetTime(t time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pendingBlock.Transactions()) != 0 {
+		return errors.New("could not set time on non-empty block")
+	}
+	parent := b.blockchain.CurrentBlock()
+	if t.Unix() <= int64(parent.Time()) {
+		return fmt.Errorf("time %d not after latest block time %d", t.Unix(), parent.Time())
+	}
+	blocks, _ := core.GenerateChain(b.config, parent, ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+		block.OffsetTime(t.Unix() - int64(parent.Time()+10))
+	})
+	stateDB, _ := b.blockchain.State()
+
+	b.pendingBlock = blocks[0]
+	b.pendingState, _ = state.New(b.pendingBlock.Root(), stateDB.Database(), nil)
+	b.pendingTime = uint64(t.Unix())
+
+	return nil
+}
+
+// Impersonate creates a transactor sending transactions on behalf of the given
+// account without needing its private key. The created transactions are left
+// unsigned and are only valid within this simulated backend.
+func (b *SimulatedBackend) Impersonate(account common.Address) *bind.TransactOpts {
+	signer := impersonatedSigner{Signer: types.LatestSigner(b.config), from: account}
+	return &bind.TransactOpts{
+		From: account,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != account {
+				return nil, bind.ErrNotAuthorized
+			}
+			// Cache the impersonated sender within the transaction
+			if _, err := types.Sender(signer, tx); err != nil {
+				return nil, err
+			}
+			return tx, nil
+		},
+		Context: context.Background(),
+	}
+}
+
+// impersonatedSigner is a transaction signer that attributes all transactions
+// to a fixed sender. As it considers itself equal to the chain's signer, the
+// cached sender is not derived again from the transaction's signature.
+type impersonatedSigner struct {
+	types.Signer
+	from common.Address
+}
+
+// Sender implements types.Signer, returning the impersonated account.
+func (s impersonatedSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	return s.from, nil
+}
+
+// Snapshot records the current head of the chain, returning an identifier to
+// revert to it later on.
+func (b *SimulatedBackend) Snapshot() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.snapshots = append(b.snapshots, b.blockchain.CurrentBlock().Hash())
+	return len(b.snapshots) - 1
+}
+
+// Revert rewinds the chain to the head recorded by the given snapshot, dropping
+// all pending transactions. The snapshot, along with all the ones taken after
+// it, can not be reverted to anymore.
+func (b *SimulatedBackend) Revert(id int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if id < 0 || id >= len(b.snapshots) {
+		return fmt.Errorf("unknown snapshot %d", id)
+	}
+	head := b.blockchain.GetHeaderByHash(b.snapshots[id])
+	b.snapshots = b.snapshots[:id]
+
+	if err := b.blockchain.SetHead(head.Number.Uint64()); err != nil {
+		return err
+	}
+	b.rollback()
+	return nil
+}
+
 // Blockchain returns the underlying blockchain.
 func (b *SimulatedBackend) Blockchain() *core.BlockChain {
 	return b.blockchain
@@ -788,6 +989,9 @@ func (fb *filterBackend) SubscribePendingLogsEvent(ch chan<- []*types.Log) event
 
 func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
 
+func (fb *filterBackend) RPCLogBlockRange() uint64 { return 0 }
+func (fb *filterBackend) RPCLogResultCap() uint64  { return 0 }
+
 func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.MatcherSession) {
 	panic("not supported")
 }
@@ -30,10 +30,12 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 func TestSimulatedBackend(t *testing.T) {
@@ -191,6 +193,106 @@ func TestNewSimulatedBackend_AdjustTimeFail(t *testing.T) {
 	}
 }
 
+func TestSimulatedBackend_SetTime(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+
+	parent := sim.blockchain.CurrentBlock().Time()
+	if err := sim.SetTime(time.Unix(int64(parent), 0)); err == nil {
+		t.Error("expected error setting time not after the latest block")
+	}
+	target := time.Unix(int64(parent)+3600, 0)
+	if err := sim.SetTime(target); err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTransaction(0, testAddr, big.NewInt(1000), params.TxGas, big.NewInt(1), nil)
+	signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, testKey)
+	if err != nil {
+		t.Fatalf("could not sign tx: %v", err)
+	}
+	sim.SendTransaction(context.Background(), signedTx)
+	if err := sim.SetTime(target.Add(time.Hour)); err == nil {
+		t.Error("expected error setting time on non-empty block")
+	}
+	sim.Commit()
+
+	if have := sim.blockchain.CurrentBlock().Time(); have != uint64(target.Unix()) {
+		t.Errorf("block time mismatch: have %d, want %d", have, target.Unix())
+	}
+}
+
+func TestSimulatedBackend_Impersonate(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+
+	bgCtx := context.Background()
+	recipient := common.HexToAddress("0xdeadbeef")
+	contract := bind.NewBoundContract(recipient, abi.ABI{}, sim, sim, sim)
+
+	opts := sim.Impersonate(testAddr)
+	opts.Value = big.NewInt(1000)
+	opts.GasLimit = params.TxGas
+	tx, err := contract.Transfer(opts)
+	if err != nil {
+		t.Fatalf("could not send impersonated transaction: %v", err)
+	}
+	sim.Commit()
+
+	receipt, err := sim.TransactionReceipt(bgCtx, tx.Hash())
+	if err != nil || receipt == nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("impersonated transaction failed: %v", err)
+	}
+	if balance, _ := sim.BalanceAt(bgCtx, recipient, nil); balance.Cmp(opts.Value) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want %v", balance, opts.Value)
+	}
+	if nonce, _ := sim.NonceAt(bgCtx, testAddr, nil); nonce != 1 {
+		t.Errorf("impersonated account nonce mismatch: have %d, want 1", nonce)
+	}
+	if _, err := opts.Signer(recipient, tx); err != bind.ErrNotAuthorized {
+		t.Errorf("signing for other account: have %v, want %v", err, bind.ErrNotAuthorized)
+	}
+}
+
+func TestSimulatedBackend_SnapshotRevert(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+
+	bgCtx := context.Background()
+	sim.Commit()
+	id := sim.Snapshot()
+	head := sim.blockchain.CurrentBlock()
+
+	for i := 0; i < 3; i++ {
+		tx := types.NewTransaction(uint64(i), testAddr, big.NewInt(1000), params.TxGas, big.NewInt(1), nil)
+		signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, testKey)
+		if err != nil {
+			t.Fatalf("could not sign tx: %v", err)
+		}
+		sim.SendTransaction(bgCtx, signedTx)
+		sim.Commit()
+	}
+	later := sim.Snapshot()
+
+	if err := sim.Revert(id); err != nil {
+		t.Fatalf("failed to revert: %v", err)
+	}
+	if current := sim.blockchain.CurrentBlock(); current.Hash() != head.Hash() {
+		t.Errorf("head mismatch after revert: have %d, want %d", current.NumberU64(), head.NumberU64())
+	}
+	if nonce, _ := sim.PendingNonceAt(bgCtx, testAddr); nonce != 0 {
+		t.Errorf("nonce mismatch after revert: have %d, want 0", nonce)
+	}
+	if err := sim.Revert(later); err == nil {
+		t.Error("expected error reverting to discarded snapshot")
+	}
+	if err := sim.Revert(id); err == nil {
+		t.Error("expected error reverting to the same snapshot twice")
+	}
+}
+
 func TestSimulatedBackend_BalanceAt(t *testing.T) {
 	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
 	expectedBal := big.NewInt(10000000000)
@@ -1005,6 +1107,64 @@ func TestSimulatedBackend_PendingAndCallContract(t *testing.T) {
 	}
 }
 
+func TestSimulatedBackend_BatchCallContext(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		t.Fatalf("could not parse abi: %v", err)
+	}
+	contractAuth, _ := bind.NewKeyedTransactorWithChainID(testKey, big.NewInt(1337))
+	addr, _, contract, err := bind.DeployContract(contractAuth, parsed, common.FromHex(abiBin), sim)
+	if err != nil {
+		t.Fatalf("could not deploy contract: %v", err)
+	}
+	// The contract is only deployed in the pending state
+	batch := bind.NewCallBatch(sim)
+	pending := contract.BatchCall(batch, "receive", []byte("X"))
+	if err := batch.Execute(&bind.CallOpts{Pending: true, From: testAddr}); err != nil {
+		t.Fatalf("could not execute pending batch: %v", err)
+	}
+	if res, err := pending.Results(); err != nil || res[0].(string) != "hello world" {
+		t.Errorf("pending batch call result mismatch: have %v (%v), want hello world", res, err)
+	}
+	batch = bind.NewCallBatch(sim)
+	latest := contract.BatchCall(batch, "receive", []byte("X"))
+	if err := batch.Execute(nil); err != nil {
+		t.Fatalf("could not execute batch: %v", err)
+	}
+	if _, err := latest.Results(); err != bind.ErrNoCode {
+		t.Errorf("undeployed batch call error mismatch: have %v, want %v", err, bind.ErrNoCode)
+	}
+	sim.Commit()
+
+	// Once mined, the call succeeds on the latest state, other requests fail individually
+	input, err := parsed.Pack("receive", []byte("X"))
+	if err != nil {
+		t.Fatalf("could not pack receive function on contract: %v", err)
+	}
+	var out hexutil.Bytes
+	reqs := []rpc.BatchElem{
+		{Method: "eth_call", Args: []interface{}{map[string]interface{}{"to": addr, "data": hexutil.Bytes(input)}, "latest"}, Result: &out},
+		{Method: "eth_chainId", Result: new(hexutil.Big)},
+		{Method: "eth_call", Args: []interface{}{map[string]interface{}{"to": addr}, "0x64"}, Result: new(hexutil.Bytes)},
+	}
+	if err := sim.BatchCallContext(context.Background(), reqs); err != nil {
+		t.Fatalf("could not execute batch: %v", err)
+	}
+	if reqs[0].Error != nil || !bytes.Equal(out, expectedReturn) {
+		t.Errorf("batched call result mismatch: have %x (%v), want %x", out, reqs[0].Error, expectedReturn)
+	}
+	if reqs[1].Error == nil {
+		t.Errorf("unsupported batched method succeeded")
+	}
+	if reqs[2].Error != errBlockNumberUnsupported {
+		t.Errorf("historical batched call error mismatch: have %v, want %v", reqs[2].Error, errBlockNumberUnsupported)
+	}
+}
+
 // This test is based on the following contract:
 /*
 contract Reverter {
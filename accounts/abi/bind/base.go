@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
@@ -83,6 +84,8 @@ type BoundContract struct {
 	caller     ContractCaller     // Read interface to interact with the blockchain
 	transactor ContractTransactor // Write interface to interact with the blockchain
 	filterer   ContractFilterer   // Event filtering to interact with the blockchain
+
+	errors map[string]func() error // Constructors of typed Go errors for custom contract errors
 }
 
 // NewBoundContract creates a low level contract interface through which calls
@@ -94,9 +97,18 @@ func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller
 		caller:     caller,
 		transactor: transactor,
 		filterer:   filterer,
+		errors:     make(map[string]func() error),
 	}
 }
 
+// RegisterError associates a custom error declared in the contract ABI with a
+// typed Go error. When a call or gas estimation reverts with the custom error,
+// the error values are copied into a fresh instance created by the constructor
+// which is returned instead of a generic *abi.CustomError.
+func (c *BoundContract) RegisterError(name string, constructor func() error) {
+	c.errors[name] = constructor
+}
+
 // DeployContract deploys a contract onto the Ethereum blockchain and binds the
 // deployment address with a Go wrapper.
 func DeployContract(opts *TransactOpts, abi abi.ABI, bytecode []byte, backend ContractBackend, params ...interface{}) (common.Address, *types.Transaction, *BoundContract, error) {
@@ -144,7 +156,10 @@ func (c *BoundContract) Call(opts *CallOpts, results *[]interface{}, method stri
 			return ErrNoPendingState
 		}
 		output, err = pb.PendingCallContract(ctx, msg)
-		if err == nil && len(output) == 0 {
+		if err != nil {
+			return c.unpackError(err)
+		}
+		if len(output) == 0 {
 			// Make sure we have a contract to operate on, and bail out otherwise.
 			if code, err = pb.PendingCodeAt(ctx, c.address); err != nil {
 				return err
@@ -155,7 +170,7 @@ func (c *BoundContract) Call(opts *CallOpts, results *[]interface{}, method stri
 	} else {
 		output, err = c.caller.CallContract(ctx, msg, opts.BlockNumber)
 		if err != nil {
-			return err
+			return c.unpackError(err)
 		}
 		if len(output) == 0 {
 			// Make sure we have a contract to operate on, and bail out otherwise.
@@ -245,7 +260,7 @@ func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, i
 		msg := ethereum.CallMsg{From: opts.From, To: contract, GasPrice: gasPrice, Value: value, Data: input}
 		gasLimit, err = c.transactor.EstimateGas(ensureContext(opts.Context), msg)
 		if err != nil {
-			return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
+			return nil, fmt.Errorf("failed to estimate gas needed: %w", c.unpackError(err))
 		}
 	}
 	// Create the transaction, sign it and schedule it for execution
@@ -383,6 +398,51 @@ func (c *BoundContract) UnpackLogIntoMap(out map[string]interface{}, event strin
 	return abi.ParseTopicsIntoMap(out, indexed, log.Topics[1:])
 }
 
+// dataError is the interface of errors carrying additional data, such as the
+// return data of reverted calls returned by the RPC API and the simulator.
+type dataError interface {
+	error
+	ErrorData() interface{}
+}
+
+// unpackError converts the revert data carried by the error of a failed call
+// into a typed error. Reverts with a reason string or panics are returned as
+// *abi.RevertError and *abi.PanicError, custom errors as the typed Go errors
+// registered for them or *abi.CustomError otherwise. If the error carries no
+// decodable revert data, it is returned unmodified.
+func (c *BoundContract) unpackError(err error) error {
+	var derr dataError
+	if !errors.As(err, &derr) {
+		return err
+	}
+	var data []byte
+	switch raw := derr.ErrorData().(type) {
+	case string:
+		blob, decErr := hexutil.Decode(raw)
+		if decErr != nil {
+			return err
+		}
+		data = blob
+	case []byte:
+		data = raw
+	default:
+		return err
+	}
+	decoded := c.abi.DecodeRevert(data)
+	if decoded == nil {
+		return err
+	}
+	if custom, ok := decoded.(*abi.CustomError); ok {
+		if constructor, ok := c.errors[custom.Def.Name]; ok {
+			typed := constructor()
+			if custom.Def.Inputs.Copy(typed, custom.Values) == nil {
+				return typed
+			}
+		}
+	}
+	return decoded
+}
+
 // ensureContext is a helper method to ensure a context is not nil, even if the
 // user specified it as such.
 func ensureContext(ctx context.Context) context.Context {
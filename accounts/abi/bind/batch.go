@@ -0,0 +1,148 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrBatchNotExecuted is returned when trying to retrieve the results of a
+// batched call before the batch it belongs to has been executed.
+var ErrBatchNotExecuted = errors.New("batch not executed")
+
+// BatchCaller defines the methods needed to execute several contract calls in
+// a single round trip, such as an *rpc.Client.
+type BatchCaller interface {
+	// BatchCallContext sends all given requests as a single batch and waits
+	// for the server to return a response for all of them.
+	BatchCallContext(ctx context.Context, b []rpc.BatchElem) error
+}
+
+// CallBatch accumulates (constant) contract calls, possibly across multiple
+// contracts, to be executed in a single JSON-RPC batch request against the
+// same block.
+type CallBatch struct {
+	caller BatchCaller
+	calls  []*BatchedCall
+}
+
+// NewCallBatch creates an empty batch of contract calls, executed through the
+// given batch capable client.
+func NewCallBatch(caller BatchCaller) *CallBatch {
+	return &CallBatch{caller: caller}
+}
+
+// Len returns the number of calls enqueued into the batch.
+func (b *CallBatch) Len() int {
+	return len(b.calls)
+}
+
+// Execute runs all the enqueued calls in a single batch request on the state
+// selected by opts. Failures of individual calls are reported by their result
+// accessors, the returned error only signals a failure of the batch itself.
+func (b *CallBatch) Execute(opts *CallOpts) error {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(CallOpts)
+	}
+	block := "latest"
+	if opts.Pending {
+		block = "pending"
+	} else if opts.BlockNumber != nil {
+		block = hexutil.EncodeBig(opts.BlockNumber)
+	}
+	var (
+		reqs  = make([]rpc.BatchElem, 0, len(b.calls))
+		calls = make([]*BatchedCall, 0, len(b.calls))
+	)
+	for _, call := range b.calls {
+		// Calls which failed to pack are never sent, but marked as done
+		if call.err != nil {
+			call.done = true
+			continue
+		}
+		arg := map[string]interface{}{
+			"from": opts.From,
+			"to":   call.contract.address,
+			"data": hexutil.Bytes(call.input),
+		}
+		reqs = append(reqs, rpc.BatchElem{
+			Method: "eth_call",
+			Args:   []interface{}{arg, block},
+			Result: &call.output,
+		})
+		calls = append(calls, call)
+	}
+	if len(reqs) > 0 {
+		if err := b.caller.BatchCallContext(ensureContext(opts.Context), reqs); err != nil {
+			return err
+		}
+	}
+	for i, call := range calls {
+		if reqs[i].Error != nil {
+			call.err = call.contract.unpackError(reqs[i].Error)
+		}
+		call.done = true
+	}
+	return nil
+}
+
+// BatchedCall is a contract call enqueued into a batch, whose results become
+// available once the batch has been executed.
+type BatchedCall struct {
+	contract *BoundContract
+	method   string
+	input    []byte
+
+	output hexutil.Bytes // Raw return data of the call
+	err    error         // Failure packing the input or executing the call
+	done   bool          // Whether the batch containing the call was executed
+}
+
+// BatchCall enqueues the invocation of the (constant) contract method with
+// params as input values into the given batch. The returned handle provides
+// access to the results after the batch has been executed.
+func (c *BoundContract) BatchCall(batch *CallBatch, method string, params ...interface{}) *BatchedCall {
+	call := &BatchedCall{
+		contract: c,
+		method:   method,
+	}
+	call.input, call.err = c.abi.Pack(method, params...)
+	batch.calls = append(batch.calls, call)
+	return call
+}
+
+// Results unpacks the return values of the call. The result is a slice of
+// interfaces, to be converted into the method's output types similarly to the
+// results of BoundContract.Call.
+func (call *BatchedCall) Results() ([]interface{}, error) {
+	if !call.done {
+		return nil, ErrBatchNotExecuted
+	}
+	if call.err != nil {
+		return nil, call.err
+	}
+	if len(call.output) == 0 && len(call.contract.abi.Methods[call.method].Outputs) > 0 {
+		// There is no way to check for code within the batch, assume its absence
+		return nil, ErrNoCode
+	}
+	return call.contract.abi.Unpack(call.method, call.output)
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const batchTestABI = `[{"inputs":[{"name":"x","type":"uint256"}],"name":"double","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+// batchTestService is a mock eth namespace doubling the argument of the called
+// method, reverting on zero.
+type batchTestService struct {
+	abi    abi.ABI
+	blocks []string
+}
+
+type batchTestRevert struct{ data string }
+
+func (e *batchTestRevert) Error() string          { return "execution reverted" }
+func (e *batchTestRevert) ErrorCode() int         { return 3 }
+func (e *batchTestRevert) ErrorData() interface{} { return e.data }
+
+func (s *batchTestService) Call(ctx context.Context, args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	s.blocks = append(s.blocks, block)
+
+	input, err := hexutil.Decode(args["data"].(string))
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.abi.Methods["double"].Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, err
+	}
+	x := values[0].(*big.Int)
+	if x.Sign() == 0 {
+		reason, _ := abi.Arguments{{Type: mustType("string")}}.Pack("zero")
+		return nil, &batchTestRevert{data: hexutil.Encode(append(crypto.Keccak256([]byte("Error(string)"))[:4], reason...))}
+	}
+	return s.abi.Methods["double"].Outputs.Pack(new(big.Int).Lsh(x, 1))
+}
+
+func mustType(name string) abi.Type {
+	typ, err := abi.NewType(name, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return typ
+}
+
+func TestCallBatch(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(batchTestABI))
+
+	service := &batchTestService{abi: parsed}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// Enqueue a few calls, including a reverting one, and execute them
+	var (
+		contract = bind.NewBoundContract(common.Address{0x01}, parsed, nil, nil, nil)
+		batch    = bind.NewCallBatch(client)
+		calls    []*bind.BatchedCall
+	)
+	for i := 0; i < 4; i++ {
+		calls = append(calls, contract.BatchCall(batch, "double", big.NewInt(int64(i))))
+	}
+	invalid := contract.BatchCall(batch, "double", "not a number")
+
+	if _, err := calls[1].Results(); err != bind.ErrBatchNotExecuted {
+		t.Fatalf("unexecuted result error mismatch: have %v, want %v", err, bind.ErrBatchNotExecuted)
+	}
+	if err := batch.Execute(&bind.CallOpts{BlockNumber: big.NewInt(42)}); err != nil {
+		t.Fatalf("failed to execute batch: %v", err)
+	}
+	if len(service.blocks) != 4 {
+		t.Fatalf("executed call count mismatch: have %d, want 4", len(service.blocks))
+	}
+	for i, block := range service.blocks {
+		if block != "0x2a" {
+			t.Errorf("call %d: block mismatch: have %s, want 0x2a", i, block)
+		}
+	}
+	// Check the results of the individual calls
+	var revert *abi.RevertError
+	if _, err := calls[0].Results(); !errors.As(err, &revert) || revert.Reason != "zero" {
+		t.Errorf("reverted call error mismatch: have %v", err)
+	}
+	for i := 1; i < len(calls); i++ {
+		out, err := calls[i].Results()
+		if err != nil {
+			t.Errorf("call %d: failed to retrieve results: %v", i, err)
+			continue
+		}
+		if have := out[0].(*big.Int); have.Int64() != int64(2*i) {
+			t.Errorf("call %d: result mismatch: have %v, want %d", i, have, 2*i)
+		}
+	}
+	if _, err := invalid.Results(); err == nil {
+		t.Errorf("invalid call succeeded")
+	}
+}
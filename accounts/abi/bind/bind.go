@@ -43,6 +43,15 @@ const (
 	LangObjC
 )
 
+// reservedSuffixes are the names appended to the contract type to name the helper
+// types and variables of a binding, which generated error types must not clash with.
+var reservedSuffixes = []string{
+	"ABI", "Bin", "FuncSigs",
+	"Caller", "Transactor", "Filterer",
+	"Session", "CallerSession", "TransactorSession",
+	"Raw", "CallerRaw", "TransactorRaw",
+}
+
 // Bind generates a Go wrapper around a contract ABI. This wrapper isn't meant
 // to be used as is in client code, but rather as an intermediate struct which
 // enforces compile time type safety and naming convention opposed to having to
@@ -74,11 +83,12 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 
 		// Extract the call and transact methods; events, struct definitions; and sort them alphabetically
 		var (
-			calls     = make(map[string]*tmplMethod)
-			transacts = make(map[string]*tmplMethod)
-			events    = make(map[string]*tmplEvent)
-			fallback  *tmplMethod
-			receive   *tmplMethod
+			calls      = make(map[string]*tmplMethod)
+			transacts  = make(map[string]*tmplMethod)
+			events     = make(map[string]*tmplEvent)
+			customErrs = make(map[string]*tmplError)
+			fallback   *tmplMethod
+			receive    *tmplMethod
 
 			// identifiers are used to detect duplicated identifiers of functions
 			// and events. For all calls, transacts and events, abigen will generate
@@ -87,6 +97,7 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			callIdentifiers     = make(map[string]bool)
 			transactIdentifiers = make(map[string]bool)
 			eventIdentifiers    = make(map[string]bool)
+			errorIdentifiers    = make(map[string]bool)
 		)
 		for _, original := range evmABI.Methods {
 			// Normalize the method for capital cases and non-anonymous inputs/outputs
@@ -129,6 +140,16 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 				transacts[original.Name] = &tmplMethod{Original: original, Normalized: normalized, Structured: structured(original.Outputs)}
 			}
 		}
+		// Go bindings also generate Batch prefixed methods for the calls, ensure
+		// these don't collide with any other method either
+		if lang == LangGo {
+			for _, original := range evmABI.Methods {
+				normalizedName := methodNormalizer[lang](alias(aliases, original.Name))
+				if strings.HasPrefix(normalizedName, "Batch") && callIdentifiers[strings.TrimPrefix(normalizedName, "Batch")] {
+					return "", fmt.Errorf("duplicated identifier \"%s\"(normalized \"%s\"), use --alias for renaming", original.Name, normalizedName)
+				}
+			}
+		}
 		for _, original := range evmABI.Events {
 			// Skip anonymous events as they don't support explicit filtering
 			if original.Anonymous {
@@ -158,6 +179,42 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			// Append the event to the accumulator list
 			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
 		}
+		// Generated error types share the namespace with event types and with the
+		// helper types and variables of the contract, collect all of those
+		reservedIdentifiers := make(map[string]bool)
+		for _, suffix := range reservedSuffixes {
+			reservedIdentifiers[suffix] = true
+		}
+		for name := range eventIdentifiers {
+			reservedIdentifiers[name] = true
+			reservedIdentifiers[name+"Iterator"] = true
+		}
+		for _, original := range evmABI.Errors {
+			// Normalize the error for capital cases and non-anonymous inputs. Since
+			// generated error types share the namespace with other generated types,
+			// ensure there is no collision with those either.
+			normalized := original
+
+			normalizedName := methodNormalizer[lang](alias(aliases, original.Name))
+			if errorIdentifiers[normalizedName] || reservedIdentifiers[normalizedName] {
+				return "", fmt.Errorf("duplicated identifier \"%s\"(normalized \"%s\"), use --alias for renaming", original.Name, normalizedName)
+			}
+			errorIdentifiers[normalizedName] = true
+			normalized.Name = normalizedName
+
+			normalized.Inputs = make([]abi.Argument, len(original.Inputs))
+			copy(normalized.Inputs, original.Inputs)
+			for j, input := range normalized.Inputs {
+				if input.Name == "" {
+					normalized.Inputs[j].Name = fmt.Sprintf("arg%d", j)
+				}
+				if hasStruct(input.Type) {
+					bindStructType[lang](input.Type, structs)
+				}
+			}
+			// Append the error to the accumulator list
+			customErrs[original.Name] = &tmplError{Original: original, Normalized: normalized}
+		}
 		// Add two special fallback functions if they exist
 		if evmABI.HasFallback() {
 			fallback = &tmplMethod{Original: evmABI.Fallback}
@@ -180,6 +237,7 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			Fallback:    fallback,
 			Receive:     receive,
 			Events:      events,
+			Errors:      customErrs,
 			Libraries:   make(map[string]string),
 		}
 		// Function 4-byte signatures are stored in the same sequence
@@ -1785,6 +1785,174 @@ var bindTests = []struct {
 		nil,
 		nil,
 	},
+	// Tests that custom errors are bound to typed Go errors and that overloaded
+	// events get distinct bindings.
+	{
+		`CustomErrors`,
+		`
+		pragma solidity ^0.8.4;
+
+		// The bytecode is hand assembled, reverting any call with
+		// InsufficientBalance(1, 2).
+		contract CustomErrors {
+			error InsufficientBalance(uint256 available, uint256 required);
+
+			event Transfer(address indexed to);
+			event Transfer(address indexed to, uint256 value);
+
+			function balance() public view returns (uint256) {
+				revert InsufficientBalance(1, 2);
+			}
+			function withdraw(uint256 amount) public {
+				revert InsufficientBalance(1, 2);
+			}
+		}
+		`,
+		[]string{`601a80600b6000396000f363cf47918160e01b6000526001600452600260245260446000fd`},
+		[]string{`[{"inputs":[{"internalType":"uint256","name":"available","type":"uint256"},{"internalType":"uint256","name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"to","type":"address"}],"name":"Transfer","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"value","type":"uint256"}],"name":"Transfer","type":"event"},{"inputs":[],"name":"balance","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"amount","type":"uint256"}],"name":"withdraw","outputs":[],"stateMutability":"nonpayable","type":"function"}]`},
+		`
+			"errors"
+			"math/big"
+
+			"github.com/ethereum/go-ethereum/accounts/abi/bind"
+			"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+			"github.com/ethereum/go-ethereum/core"
+			"github.com/ethereum/go-ethereum/crypto"
+		`,
+		`
+			// Generate a new random account and a funded simulator
+			key, _ := crypto.GenerateKey()
+			auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+
+			sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(10000000000)}}, 10000000)
+			defer sim.Close()
+
+			_, _, contract, err := DeployCustomErrors(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy contract: %v", err)
+			}
+			sim.Commit()
+
+			// Calls reverting with a custom error should return the typed error
+			_, err = contract.Balance(nil)
+
+			var insufficient *CustomErrorsInsufficientBalance
+			if !errors.As(err, &insufficient) {
+				t.Fatalf("Call error type mismatch: have %T (%v), want %T", err, err, insufficient)
+			}
+			if insufficient.Available.Cmp(big.NewInt(1)) != 0 || insufficient.Required.Cmp(big.NewInt(2)) != 0 {
+				t.Fatalf("Call error values mismatch: have %+v, want {Available:1 Required:2}", insufficient)
+			}
+			// Transactions failing gas estimation should return the typed error too
+			_, err = contract.Withdraw(auth, big.NewInt(1))
+
+			insufficient = nil
+			if !errors.As(err, &insufficient) {
+				t.Fatalf("Transact error type mismatch: have %T (%v), want %T", err, err, insufficient)
+			}
+			// Overloaded events should get distinct bindings
+			var (
+				_ *CustomErrorsTransfer
+				_ *CustomErrorsTransfer0
+			)
+		`,
+		nil,
+		nil,
+		nil,
+		nil,
+	},
+	// Tests that constant calls can be batched through all the binding flavours
+	{
+		`Batcher`,
+		`
+			contract BatchedGetter {
+				function getter() constant returns (string, int, bytes32) {
+					return ("Hi", 1, sha3(""));
+				}
+			}
+			contract BatchedTupler {
+				function tuple() constant returns (string a, int b, bytes32 c) {
+					return ("Hi", 1, sha3(""));
+				}
+			}
+		`,
+		[]string{`606060405260dc8060106000396000f3606060405260e060020a6000350463993a04b78114601a575b005b600060605260c0604052600260809081527f486900000000000000000000000000000000000000000000000000000000000060a05260017fc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a47060e0829052610100819052606060c0908152600261012081905281906101409060a09080838184600060046012f1505081517fffff000000000000000000000000000000000000000000000000000000000000169091525050604051610160819003945092505050f3`, `606060405260dc8060106000396000f3606060405260e060020a60003504633175aae28114601a575b005b600060605260c0604052600260809081527f486900000000000000000000000000000000000000000000000000000000000060a05260017fc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a47060e0829052610100819052606060c0908152600261012081905281906101409060a09080838184600060046012f1505081517fffff000000000000000000000000000000000000000000000000000000000000169091525050604051610160819003945092505050f3`},
+		[]string{`[{"constant":true,"inputs":[],"name":"getter","outputs":[{"name":"","type":"string"},{"name":"","type":"int256"},{"name":"","type":"bytes32"}],"type":"function"}]`, `[{"constant":true,"inputs":[],"name":"tuple","outputs":[{"name":"a","type":"string"},{"name":"b","type":"int256"},{"name":"c","type":"bytes32"}],"type":"function"}]`},
+		`
+			"math/big"
+
+			"github.com/ethereum/go-ethereum/accounts/abi/bind"
+			"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+			"github.com/ethereum/go-ethereum/core"
+			"github.com/ethereum/go-ethereum/crypto"
+		`,
+		`
+			// Generate a new random account and a funded simulator
+			key, _ := crypto.GenerateKey()
+			auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+
+			sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(10000000000)}}, 10000000)
+			defer sim.Close()
+
+			// Deploy the getter contracts
+			_, _, getter, err := DeployBatchedGetter(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy getter contract: %v", err)
+			}
+			tuplerAddr, _, tupler, err := DeployBatchedTupler(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy tupler contract: %v", err)
+			}
+			sim.Commit()
+
+			caller, err := NewBatchedTuplerCaller(tuplerAddr, sim)
+			if err != nil {
+				t.Fatalf("Failed to bind tupler caller: %v", err)
+			}
+			// Enqueue the calls through every binding flavour and execute them at once
+			batch := bind.NewCallBatch(sim)
+
+			anonymous := []func() (string, *big.Int, [32]byte, error){
+				getter.BatchGetter(batch),
+				getter.BatchedGetterCaller.BatchGetter(batch),
+				(&BatchedGetterSession{Contract: getter}).BatchGetter(batch),
+				(&BatchedGetterCallerSession{Contract: &getter.BatchedGetterCaller}).BatchGetter(batch),
+			}
+			structured := []func() (struct{ A string; B *big.Int; C [32]byte }, error){
+				tupler.BatchTuple(batch),
+				caller.BatchTuple(batch),
+				(&BatchedTuplerSession{Contract: tupler, TransactOpts: *auth}).BatchTuple(batch),
+				(&BatchedTuplerCallerSession{Contract: caller}).BatchTuple(batch),
+			}
+			if _, _, _, err := anonymous[0](); err != bind.ErrBatchNotExecuted {
+				t.Fatalf("Unexecuted batch error mismatch: have %v, want %v", err, bind.ErrBatchNotExecuted)
+			}
+			if batch.Len() != len(anonymous)+len(structured) {
+				t.Fatalf("Batch length mismatch: have %d, want %d", batch.Len(), len(anonymous)+len(structured))
+			}
+			if err := batch.Execute(nil); err != nil {
+				t.Fatalf("Failed to execute batch: %v", err)
+			}
+			for i, result := range anonymous {
+				if str, num, _, err := result(); err != nil {
+					t.Fatalf("Call %d: failed to retrieve anonymous results: %v", i, err)
+				} else if str != "Hi" || num.Cmp(big.NewInt(1)) != 0 {
+					t.Fatalf("Call %d: retrieved value mismatch: have %v/%v, want %v/%v", i, str, num, "Hi", 1)
+				}
+			}
+			for i, result := range structured {
+				if res, err := result(); err != nil {
+					t.Fatalf("Call %d: failed to retrieve structured results: %v", i, err)
+				} else if res.A != "Hi" || res.B.Cmp(big.NewInt(1)) != 0 {
+					t.Fatalf("Call %d: retrieved value mismatch: have %v/%v, want %v/%v", i, res.A, res.B, "Hi", 1)
+				}
+			}
+		`,
+		nil,
+		nil,
+		nil,
+		[]string{"BatchedGetter", "BatchedTupler"},
+	},
 }
 
 // Tests that packages generated by the binder can be successfully compiled and
@@ -2268,3 +2436,38 @@ public class Test {
 		}
 	}
 }
+
+// Tests that custom errors clashing with any other generated type are rejected,
+// unless aliased.
+func TestBindErrorCollisions(t *testing.T) {
+	errorABI := func(name string) string {
+		return `[{"inputs":[],"name":"` + name + `","type":"error"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"to","type":"address"}],"name":"Transfer","type":"event"}]`
+	}
+	for _, name := range []string{"Caller", "Transactor", "Filterer", "Session", "TransactorSession", "Raw", "CallerRaw", "ABI", "TransferIterator"} {
+		if _, err := Bind([]string{"Token"}, []string{errorABI(name)}, []string{""}, nil, "bindtest", LangGo, nil, nil); err == nil || !strings.Contains(err.Error(), "duplicated identifier") {
+			t.Errorf("error %s: collision not detected: %v", name, err)
+		}
+		if _, err := Bind([]string{"Token"}, []string{errorABI(name)}, []string{""}, nil, "bindtest", LangGo, nil, map[string]string{name: name + "Error"}); err != nil {
+			t.Errorf("error %s: aliased binding failed: %v", name, err)
+		}
+	}
+	if _, err := Bind([]string{"Token"}, []string{errorABI("Insufficient")}, []string{""}, nil, "bindtest", LangGo, nil, nil); err != nil {
+		t.Errorf("non-colliding error rejected: %v", err)
+	}
+}
+
+// Tests that methods clashing with the generated batched calls are rejected,
+// unless aliased.
+func TestBindBatchCollisions(t *testing.T) {
+	batchABI := func(mutability string) string {
+		return `[{"inputs":[],"name":"foo","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"batchFoo","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"` + mutability + `","type":"function"}]`
+	}
+	for _, mutability := range []string{"view", "nonpayable", "payable"} {
+		if _, err := Bind([]string{"C"}, []string{batchABI(mutability)}, []string{""}, nil, "bindtest", LangGo, nil, nil); err == nil || !strings.Contains(err.Error(), "duplicated identifier") {
+			t.Errorf("%s batchFoo: collision not detected: %v", mutability, err)
+		}
+		if _, err := Bind([]string{"C"}, []string{batchABI(mutability)}, []string{""}, nil, "bindtest", LangGo, nil, map[string]string{"batchFoo": "fooBatch"}); err != nil {
+			t.Errorf("%s batchFoo: aliased binding failed: %v", mutability, err)
+		}
+	}
+}
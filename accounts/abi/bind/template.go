@@ -38,6 +38,7 @@ type tmplContract struct {
 	Fallback    *tmplMethod            // Additional special fallback function
 	Receive     *tmplMethod            // Additional special receive function
 	Events      map[string]*tmplEvent  // Contract events accessors
+	Errors      map[string]*tmplError  // Contract custom errors
 	Libraries   map[string]string      // Same as tmplData, but filtered to only keep what the contract needs
 	Library     bool                   // Indicator whether the contract is a library
 }
@@ -57,6 +58,13 @@ type tmplEvent struct {
 	Normalized abi.Event // Normalized version of the parsed fields
 }
 
+// tmplError is a wrapper around an abi.Error that contains a few preprocessed
+// and cached data fields.
+type tmplError struct {
+	Original   abi.Error // Original error as parsed by the abi package
+	Normalized abi.Error // Normalized version of the parsed fields
+}
+
 // tmplField is a wrapper around a struct field with binding language
 // struct type definition and relative filed name.
 type tmplField struct {
@@ -88,6 +96,7 @@ const tmplSourceGo = `
 package {{.Package}}
 
 import (
+	"fmt"
 	"math/big"
 	"strings"
 
@@ -101,6 +110,7 @@ import (
 
 // Reference imports to suppress errors if they are not otherwise used.
 var (
+	_ = fmt.Sprintf
 	_ = big.NewInt
 	_ = strings.NewReader
 	_ = ethereum.NotFound
@@ -149,6 +159,7 @@ var (
 		  if err != nil {
 		    return common.Address{}, nil, nil, err
 		  }
+		  register{{.Type}}Errors(contract)
 		  return address, tx, &{{.Type}}{ {{.Type}}Caller: {{.Type}}Caller{contract: contract}, {{.Type}}Transactor: {{.Type}}Transactor{contract: contract}, {{.Type}}Filterer: {{.Type}}Filterer{contract: contract} }, nil
 		}
 	{{end}}
@@ -254,7 +265,16 @@ var (
 	  if err != nil {
 	    return nil, err
 	  }
-	  return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+	  contract := bind.NewBoundContract(address, parsed, caller, transactor, filterer)
+	  register{{.Type}}Errors(contract)
+	  return contract, nil
+	}
+
+	// register{{.Type}}Errors associates the custom errors of the contract with their
+	// typed Go bindings, returned when a call reverts with them.
+	func register{{.Type}}Errors(contract *bind.BoundContract) {
+	  {{range .Errors}}contract.RegisterError("{{.Original.Name}}", func() error { return new({{$contract.Type}}{{.Normalized.Name}}) })
+	  {{end}}
 	}
 
 	// Call invokes the (constant) contract method with params as input values and
@@ -335,6 +355,59 @@ var (
 		func (_{{$contract.Type}} *{{$contract.Type}}CallerSession) {{.Normalized.Name}}({{range $i, $_ := .Normalized.Inputs}}{{if ne $i 0}},{{end}} {{.Name}} {{bindtype .Type $structs}} {{end}}) ({{if .Structured}}struct{ {{range .Normalized.Outputs}}{{.Name}} {{bindtype .Type $structs}};{{end}} }, {{else}} {{range .Normalized.Outputs}}{{bindtype .Type $structs}},{{end}} {{end}} error) {
 		  return _{{$contract.Type}}.Contract.{{.Normalized.Name}}(&_{{$contract.Type}}.CallOpts {{range .Normalized.Inputs}}, {{.Name}}{{end}})
 		}
+
+		// Batch{{.Normalized.Name}} enqueues a free data retrieval call binding the contract method 0x{{printf "%x" .Original.ID}}
+		// into the batch, returning an accessor for its results once the batch has been executed.
+		//
+		// Solidity: {{.Original.String}}
+		func (_{{$contract.Type}} *{{$contract.Type}}Caller) Batch{{.Normalized.Name}}(batch *bind.CallBatch {{range .Normalized.Inputs}}, {{.Name}} {{bindtype .Type $structs}} {{end}}) func() ({{if .Structured}}struct{ {{range .Normalized.Outputs}}{{.Name}} {{bindtype .Type $structs}};{{end}} },{{else}}{{range .Normalized.Outputs}}{{bindtype .Type $structs}},{{end}}{{end}} error) {
+			call := _{{$contract.Type}}.contract.BatchCall(batch, "{{.Original.Name}}" {{range .Normalized.Inputs}}, {{.Name}}{{end}})
+			return func() ({{if .Structured}}struct{ {{range .Normalized.Outputs}}{{.Name}} {{bindtype .Type $structs}};{{end}} },{{else}}{{range .Normalized.Outputs}}{{bindtype .Type $structs}},{{end}}{{end}} error) {
+				{{if .Normalized.Outputs}}out, err := call.Results(){{else}}_, err := call.Results(){{end}}
+				{{if .Structured}}
+				outstruct := new(struct{ {{range .Normalized.Outputs}} {{.Name}} {{bindtype .Type $structs}}; {{end}} })
+				if err != nil {
+					return *outstruct, err
+				}
+				{{range $i, $t := .Normalized.Outputs}} 
+				outstruct.{{.Name}} = *abi.ConvertType(out[{{$i}}], new({{bindtype .Type $structs}})).(*{{bindtype .Type $structs}}){{end}}
+
+				return *outstruct, err
+				{{else}}
+				if err != nil {
+					return {{range $i, $_ := .Normalized.Outputs}}*new({{bindtype .Type $structs}}), {{end}} err
+				}
+				{{range $i, $t := .Normalized.Outputs}}
+				out{{$i}} := *abi.ConvertType(out[{{$i}}], new({{bindtype .Type $structs}})).(*{{bindtype .Type $structs}}){{end}}
+
+				return {{range $i, $t := .Normalized.Outputs}}out{{$i}}, {{end}} err
+				{{end}}
+			}
+		}
+
+		// Batch{{.Normalized.Name}} enqueues a free data retrieval call binding the contract method 0x{{printf "%x" .Original.ID}}
+		// into the batch, returning an accessor for its results once the batch has been executed.
+		//
+		// Solidity: {{.Original.String}}
+		func (_{{$contract.Type}} *{{$contract.Type}}) Batch{{.Normalized.Name}}(batch *bind.CallBatch {{range .Normalized.Inputs}}, {{.Name}} {{bindtype .Type $structs}} {{end}}) func() ({{if .Structured}}struct{ {{range .Normalized.Outputs}}{{.Name}} {{bindtype .Type $structs}};{{end}} },{{else}}{{range .Normalized.Outputs}}{{bindtype .Type $structs}},{{end}}{{end}} error) {
+			return _{{$contract.Type}}.{{$contract.Type}}Caller.Batch{{.Normalized.Name}}(batch {{range .Normalized.Inputs}}, {{.Name}}{{end}})
+		}
+
+		// Batch{{.Normalized.Name}} enqueues a free data retrieval call binding the contract method 0x{{printf "%x" .Original.ID}}
+		// into the batch, returning an accessor for its results once the batch has been executed.
+		//
+		// Solidity: {{.Original.String}}
+		func (_{{$contract.Type}} *{{$contract.Type}}Session) Batch{{.Normalized.Name}}(batch *bind.CallBatch {{range .Normalized.Inputs}}, {{.Name}} {{bindtype .Type $structs}} {{end}}) func() ({{if .Structured}}struct{ {{range .Normalized.Outputs}}{{.Name}} {{bindtype .Type $structs}};{{end}} },{{else}}{{range .Normalized.Outputs}}{{bindtype .Type $structs}},{{end}}{{end}} error) {
+			return _{{$contract.Type}}.Contract.Batch{{.Normalized.Name}}(batch {{range .Normalized.Inputs}}, {{.Name}}{{end}})
+		}
+
+		// Batch{{.Normalized.Name}} enqueues a free data retrieval call binding the contract method 0x{{printf "%x" .Original.ID}}
+		// into the batch, returning an accessor for its results once the batch has been executed.
+		//
+		// Solidity: {{.Original.String}}
+		func (_{{$contract.Type}} *{{$contract.Type}}CallerSession) Batch{{.Normalized.Name}}(batch *bind.CallBatch {{range .Normalized.Inputs}}, {{.Name}} {{bindtype .Type $structs}} {{end}}) func() ({{if .Structured}}struct{ {{range .Normalized.Outputs}}{{.Name}} {{bindtype .Type $structs}};{{end}} },{{else}}{{range .Normalized.Outputs}}{{bindtype .Type $structs}},{{end}}{{end}} error) {
+			return _{{$contract.Type}}.Contract.Batch{{.Normalized.Name}}(batch {{range .Normalized.Inputs}}, {{.Name}}{{end}})
+		}
 	{{end}}
 
 	{{range .Transacts}}
@@ -549,6 +622,21 @@ var (
 		}
 
  	{{end}}
+
+	{{range .Errors}}
+		// {{$contract.Type}}{{.Normalized.Name}} represents a {{.Normalized.Name}} error raised by the {{$contract.Type}} contract.
+		// It is returned by calls and gas estimations reverting with it, and can be matched with errors.As.
+		type {{$contract.Type}}{{.Normalized.Name}} struct { {{range .Normalized.Inputs}}
+			{{capitalise .Name}} {{bindtype .Type $structs}}; {{end}}
+		}
+
+		// Error implements the error interface.
+		//
+		// Solidity: {{.Original.String}}
+		func (e *{{$contract.Type}}{{.Normalized.Name}}) Error() string {
+			return fmt.Sprintf("execution reverted: {{.Original.RawName}}%+v", *e)
+		}
+	{{end}}
 {{end}}
 `
 
@@ -17,15 +17,149 @@
 package abi
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 var (
 	errBadBool = errors.New("abi: improperly encoded boolean value")
 )
 
+// Error is a custom error declared in a contract ABI, which a contract may
+// revert with. Its return data is abi-encoded similarly to a method call, the
+// first four bytes of the signature hash followed by the packed inputs.
+type Error struct {
+	// Name is the error name used for internal representation. It's derived from
+	// the raw name and a suffix will be added in the case of an error overload.
+	Name string
+	// RawName is the raw error name parsed from ABI.
+	RawName string
+	Inputs  Arguments
+	str     string
+	// Sig contains the string signature according to the ABI spec.
+	// e.g.	 error foo(uint32 a, int b) = "foo(uint32,int256)"
+	// Please note that "int" is substitute for its canonical representation "int256"
+	Sig string
+	// ID returns the canonical representation of the error's signature used by the
+	// abi definition to identify error names and types.
+	ID common.Hash
+}
+
+// NewError creates a new Error.
+// It sanitizes the input arguments to remove unnamed arguments.
+// It also precomputes the id, signature and string representation
+// of the error.
+func NewError(name, rawName string, inputs Arguments) Error {
+	names := make([]string, len(inputs))
+	types := make([]string, len(inputs))
+	for i, input := range inputs {
+		if input.Name == "" {
+			inputs[i] = Argument{
+				Name: fmt.Sprintf("arg%d", i),
+				Type: input.Type,
+			}
+		} else {
+			inputs[i] = input
+		}
+		// string representation
+		names[i] = fmt.Sprintf("%v %v", input.Type, inputs[i].Name)
+
+		// sig representation
+		types[i] = input.Type.String()
+	}
+
+	str := fmt.Sprintf("error %v(%v)", rawName, strings.Join(names, ", "))
+	sig := fmt.Sprintf("%v(%v)", rawName, strings.Join(types, ","))
+	id := common.BytesToHash(crypto.Keccak256([]byte(sig)))
+
+	return Error{
+		Name:    name,
+		RawName: rawName,
+		Inputs:  inputs,
+		str:     str,
+		Sig:     sig,
+		ID:      id,
+	}
+}
+
+func (e Error) String() string {
+	return e.str
+}
+
+// Unpack decodes the return data of a call reverted with this error into the
+// values of the error's inputs.
+func (e *Error) Unpack(data []byte) ([]interface{}, error) {
+	if len(data) < 4 {
+		return nil, errors.New("invalid data for unpacking")
+	}
+	if !bytes.Equal(data[:4], e.ID[:4]) {
+		return nil, errors.New("invalid data for unpacking")
+	}
+	return e.Inputs.Unpack(data[4:])
+}
+
+// RevertError is the error returned by a contract call reverted with a reason
+// string, i.e. with the builtin `Error(string)` error.
+type RevertError struct {
+	Reason string
+}
+
+func (e *RevertError) Error() string {
+	return "execution reverted: " + e.Reason
+}
+
+// panicReasons maps the codes of the builtin `Panic(uint256)` error to their
+// meaning, as per https://docs.soliditylang.org/en/latest/control-structures.html#panic-via-assert-and-error-via-require
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// PanicError is the error returned by a contract call that failed with the
+// builtin `Panic(uint256)` error, e.g. on a failed assertion or an overflow.
+type PanicError struct {
+	Code *big.Int
+}
+
+func (e *PanicError) Error() string {
+	if e.Code.IsUint64() {
+		if reason, ok := panicReasons[e.Code.Uint64()]; ok {
+			return fmt.Sprintf("execution reverted: panic: %s (%#x)", reason, e.Code)
+		}
+	}
+	return fmt.Sprintf("execution reverted: panic: unknown code %#x", e.Code)
+}
+
+// CustomError is the error returned by a contract call reverted with one of the
+// custom errors declared in the contract ABI.
+type CustomError struct {
+	Def    *Error        // ABI definition of the error reverted with
+	Values []interface{} // Unpacked values of the error inputs
+}
+
+func (e *CustomError) Error() string {
+	values := make([]string, len(e.Values))
+	for i, value := range e.Values {
+		values[i] = fmt.Sprintf("%v", value)
+	}
+	return fmt.Sprintf("execution reverted: %s(%s)", e.Def.RawName, strings.Join(values, ", "))
+}
+
 // formatSliceString formats the reflection kind with the given slice size
 // and returns a formatted string representation.
 func formatSliceString(kind reflect.Kind, sliceSize int) string {
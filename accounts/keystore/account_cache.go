@@ -69,6 +69,7 @@ type accountCache struct {
 	mu       sync.Mutex
 	all      accountsByURL
 	byAddr   map[common.Address][]accounts.Account
+	hdPaths  map[string]struct{} // Paths of the HD wallet files in keydir
 	throttle *time.Timer
 	notify   chan struct{}
 	fileC    fileCache
@@ -76,10 +77,11 @@ type accountCache struct {
 
 func newAccountCache(keydir string) (*accountCache, chan struct{}) {
 	ac := &accountCache{
-		keydir: keydir,
-		byAddr: make(map[common.Address][]accounts.Account),
-		notify: make(chan struct{}, 1),
-		fileC:  fileCache{all: mapset.NewThreadUnsafeSet()},
+		keydir:  keydir,
+		byAddr:  make(map[common.Address][]accounts.Account),
+		hdPaths: make(map[string]struct{}),
+		notify:  make(chan struct{}, 1),
+		fileC:   fileCache{all: mapset.NewThreadUnsafeSet()},
 	}
 	ac.watcher = newWatcher(ac)
 	return ac, ac.notify
@@ -101,6 +103,33 @@ func (ac *accountCache) hasAddress(addr common.Address) bool {
 	return len(ac.byAddr[addr]) > 0
 }
 
+// hdWallets returns the sorted paths of the HD wallet files in the keystore.
+func (ac *accountCache) hdWallets() []string {
+	ac.maybeReload()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	paths := make([]string, 0, len(ac.hdPaths))
+	for path := range ac.hdPaths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// addHDWallet registers an HD wallet file without waiting for the next reload.
+func (ac *accountCache) addHDWallet(path string) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.hdPaths[path] = struct{}{}
+}
+
+// deleteHDWallet removes an HD wallet file from the cache.
+func (ac *accountCache) deleteHDWallet(path string) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	delete(ac.hdPaths, path)
+}
+
 func (ac *accountCache) add(newAccount accounts.Account) {
 	ac.mu.Lock()
 	defer ac.mu.Unlock()
@@ -276,15 +305,28 @@ func (ac *accountCache) scanAccounts() error {
 	start := time.Now()
 
 	for _, p := range creates.ToSlice() {
-		if a := readAccount(p.(string)); a != nil {
+		path := p.(string)
+		if isHDWalletFile(path) {
+			ac.addHDWallet(path)
+			continue
+		}
+		if a := readAccount(path); a != nil {
 			ac.add(*a)
 		}
 	}
 	for _, p := range deletes.ToSlice() {
-		ac.deleteByFile(p.(string))
+		path := p.(string)
+		if isHDWalletFile(path) {
+			ac.deleteHDWallet(path)
+			continue
+		}
+		ac.deleteByFile(path)
 	}
 	for _, p := range updates.ToSlice() {
 		path := p.(string)
+		if isHDWalletFile(path) {
+			continue // Open wallets keep their own state, e.g. pinned accounts
+		}
 		ac.deleteByFile(path)
 		if a := readAccount(path); a != nil {
 			ac.add(*a)
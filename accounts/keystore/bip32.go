@@ -0,0 +1,91 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// errInvalidChildKey is returned in the astronomically unlikely case that a
+// BIP-32 derivation step yields an invalid key.
+var errInvalidChildKey = errors.New("invalid derived key, use the next index")
+
+// hardenedKeyStart is the first index of hardened child keys.
+const hardenedKeyStart = 0x80000000
+
+// secp256k1N is the order of the secp256k1 curve.
+var secp256k1N = crypto.S256().Params().N
+
+// deriveHDKey derives the private key at the given path from a BIP-39 seed,
+// according to BIP-32.
+func deriveHDKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key, chain := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	if key.Sign() == 0 || key.Cmp(secp256k1N) >= 0 {
+		return nil, errors.New("invalid master key")
+	}
+	for _, index := range path {
+		var err error
+		if key, chain, err = deriveChildKey(key, chain, index); err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// deriveChildKey derives the private child key and chain code at the given
+// index from the parent's.
+func deriveChildKey(key *big.Int, chain []byte, index uint32) (*big.Int, []byte, error) {
+	data := make([]byte, 37)
+	if index >= hardenedKeyStart {
+		math.ReadBits(key, data[1:33])
+	} else {
+		priv, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+		if err != nil {
+			return nil, nil, err
+		}
+		copy(data, crypto.CompressPubkey(&priv.PublicKey))
+	}
+	binary.BigEndian.PutUint32(data[33:], index)
+
+	mac := hmac.New(sha512.New, chain)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(secp256k1N) >= 0 {
+		return nil, nil, errInvalidChildKey
+	}
+	child := tweak.Add(tweak, key)
+	child.Mod(child, secp256k1N)
+	if child.Sign() == 0 {
+		return nil, nil, errInvalidChildKey
+	}
+	return child, sum[32:], nil
+}
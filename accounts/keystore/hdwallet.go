@@ -0,0 +1,484 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// hdWalletVersion is the version of the HD wallet file format.
+const hdWalletVersion = 1
+
+// hdWalletTag is the part of the file name identifying HD wallet files in the
+// keystore directory.
+const hdWalletTag = "--hd--"
+
+// isHDWalletFile reports whether the file at path holds an HD wallet rather
+// than a single key.
+func isHDWalletFile(path string) bool {
+	return strings.Contains(filepath.Base(path), hdWalletTag)
+}
+
+// ErrInvalidMnemonic is returned when importing an invalid BIP-39 mnemonic.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// hdWalletJSON is the on-disk format of an HD wallet. The BIP-39 seed is
+// encrypted the same way as the private keys of key files, the derived accounts
+// are stored in plain so they can be listed without the passphrase.
+type hdWalletJSON struct {
+	Version  int             `json:"version"`
+	Id       string          `json:"id"`
+	Crypto   CryptoJSON      `json:"crypto"`
+	Accounts []hdAccountJSON `json:"accounts"`
+}
+
+type hdAccountJSON struct {
+	Address string `json:"address"`
+	Path    string `json:"path"`
+}
+
+// hdWalletFileName implements the naming convention for HD wallet files:
+// UTC--<created_at UTC ISO8601>--hd--<first address hex>
+func hdWalletFileName(addr common.Address) string {
+	return fmt.Sprintf("UTC--%s%s%s", toISO8601(time.Now().UTC()), hdWalletTag, hex.EncodeToString(addr[:]))
+}
+
+// hdWallet is a hierarchical deterministic wallet backed by a BIP-39 seed that
+// is stored encrypted in the keystore directory.
+type hdWallet struct {
+	url  accounts.URL
+	file hdWalletJSON
+
+	accounts []accounts.Account                         // Derived accounts pinned in the wallet
+	paths    map[common.Address]accounts.DerivationPath // Derivation paths of the pinned accounts
+	seed     []byte                                     // Decrypted seed while the wallet is open
+
+	deriveBases []accounts.DerivationPath // Base paths to self-derive accounts from
+	deriveChain ethereum.ChainStateReader // Chain to check account usage with during self-derivation
+
+	lock sync.Mutex
+}
+
+// loadHDWallet reads an HD wallet from a file.
+func loadHDWallet(path string) (*hdWallet, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &hdWallet{url: accounts.URL{Scheme: KeyStoreScheme, Path: path}}
+	if err := json.Unmarshal(blob, &w.file); err != nil {
+		return nil, err
+	}
+	if w.file.Version != hdWalletVersion {
+		return nil, fmt.Errorf("unsupported HD wallet version %d", w.file.Version)
+	}
+	w.paths = make(map[common.Address]accounts.DerivationPath)
+	for _, acc := range w.file.Accounts {
+		path, err := accounts.ParseDerivationPath(acc.Path)
+		if err != nil {
+			return nil, err
+		}
+		w.pin(common.HexToAddress(acc.Address), path)
+	}
+	return w, nil
+}
+
+// pin adds a derived account to the wallet, returning whether it's new. The
+// caller must hold the lock or have exclusive access.
+func (w *hdWallet) pin(addr common.Address, path accounts.DerivationPath) bool {
+	if _, ok := w.paths[addr]; ok {
+		return false
+	}
+	w.paths[addr] = append(accounts.DerivationPath{}, path...)
+	w.accounts = append(w.accounts, accounts.Account{Address: addr, URL: w.url})
+	return true
+}
+
+// store persists the wallet. The caller must hold the lock or have exclusive
+// access.
+func (w *hdWallet) store() error {
+	w.file.Accounts = w.file.Accounts[:0]
+	for _, acc := range w.accounts {
+		w.file.Accounts = append(w.file.Accounts, hdAccountJSON{
+			Address: hex.EncodeToString(acc.Address[:]),
+			Path:    w.paths[acc.Address].String(),
+		})
+	}
+	blob, err := json.Marshal(w.file)
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(w.url.Path, blob)
+}
+
+// decrypt returns the BIP-39 seed of the wallet.
+func (w *hdWallet) decrypt(passphrase string) ([]byte, error) {
+	seed, err := DecryptDataV3(w.file.Crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// URL implements accounts.Wallet, returning the path of the wallet file.
+func (w *hdWallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning whether the seed of the wallet
+// is decrypted or not.
+func (w *hdWallet) Status() (string, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.seed != nil {
+		return "Unlocked", nil
+	}
+	return "Locked", nil
+}
+
+// Open implements accounts.Wallet, decrypting the seed of the wallet so that
+// accounts can be derived and used for signing without the passphrase.
+func (w *hdWallet) Open(passphrase string) error {
+	seed, err := w.decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	w.lock.Lock()
+	if w.seed != nil {
+		w.lock.Unlock()
+		return accounts.ErrWalletAlreadyOpen
+	}
+	w.seed = seed
+	derive := w.deriveChain != nil
+	w.lock.Unlock()
+
+	if derive {
+		go w.selfDerive()
+	}
+	return nil
+}
+
+// Close implements accounts.Wallet, dropping the decrypted seed from memory.
+func (w *hdWallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for i := range w.seed {
+		w.seed[i] = 0
+	}
+	w.seed = nil
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the accounts pinned in the
+// wallet.
+func (w *hdWallet) Accounts() []accounts.Account {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not pinned into this wallet instance.
+func (w *hdWallet) Contains(account accounts.Account) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_, ok := w.paths[account.Address]
+	return ok && (account.URL == (accounts.URL{}) || account.URL == w.url)
+}
+
+// Derive implements accounts.Wallet, deriving a new account at the specific
+// derivation path. If pin is set to true, the account will be added to the list
+// of tracked accounts and persisted into the wallet file.
+func (w *hdWallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.seed == nil {
+		return accounts.Account{}, accounts.ErrWalletClosed
+	}
+	key, err := deriveHDKey(w.seed, path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	zeroKey(key)
+
+	if pin && w.pin(addr, path) {
+		if err := w.store(); err != nil {
+			return accounts.Account{}, err
+		}
+	}
+	return accounts.Account{Address: addr, URL: w.url}, nil
+}
+
+// SelfDerive implements accounts.Wallet, discovering the used accounts starting
+// at the base paths, and pinning them along with the first unused account on
+// each. Discovery happens whenever the wallet is opened.
+func (w *hdWallet) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {
+	w.lock.Lock()
+	w.deriveBases = make([]accounts.DerivationPath, len(bases))
+	for i, base := range bases {
+		w.deriveBases[i] = make(accounts.DerivationPath, len(base))
+		copy(w.deriveBases[i][:], base[:])
+	}
+	w.deriveChain = chain
+	open := w.seed != nil
+	w.lock.Unlock()
+
+	if open && chain != nil {
+		go w.selfDerive()
+	}
+}
+
+// selfDerive runs a single account discovery pass over the base paths.
+func (w *hdWallet) selfDerive() {
+	w.lock.Lock()
+	bases, chain := w.deriveBases, w.deriveChain
+	w.lock.Unlock()
+
+	ctx := context.Background()
+	for _, base := range bases {
+		path := make(accounts.DerivationPath, len(base))
+		copy(path, base)
+		for {
+			account, err := w.Derive(path, false)
+			if err != nil {
+				log.Warn("HD wallet self-derivation failed", "url", w.url, "err", err)
+				return
+			}
+			balance, err := chain.BalanceAt(ctx, account.Address, nil)
+			if err != nil {
+				log.Warn("HD wallet balance retrieval failed", "err", err)
+				return
+			}
+			nonce, err := chain.NonceAt(ctx, account.Address, nil)
+			if err != nil {
+				log.Warn("HD wallet nonce retrieval failed", "err", err)
+				return
+			}
+			// Pin the account, stopping at the first unused one
+			if _, err := w.Derive(path, true); err != nil {
+				log.Warn("HD wallet account pinning failed", "err", err)
+				return
+			}
+			if balance.Sign() == 0 && nonce == 0 {
+				break
+			}
+			log.Info("HD wallet discovered used account", "address", account.Address, "path", path)
+			path[len(path)-1]++
+		}
+	}
+}
+
+// key derives the private key of a pinned account from the seed.
+func (w *hdWallet) key(account accounts.Account, seed []byte) (*ecdsa.PrivateKey, error) {
+	path, ok := w.paths[account.Address]
+	if !ok || (account.URL != (accounts.URL{}) && account.URL != w.url) {
+		return nil, accounts.ErrUnknownAccount
+	}
+	return deriveHDKey(seed, path)
+}
+
+// unlockedKey derives the private key of an account of the open wallet.
+func (w *hdWallet) unlockedKey(account accounts.Account) (*ecdsa.PrivateKey, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.seed == nil {
+		return nil, ErrLocked
+	}
+	return w.key(account, w.seed)
+}
+
+// passphraseKey derives the private key of an account, decrypting the seed with
+// the passphrase.
+func (w *hdWallet) passphraseKey(account accounts.Account, passphrase string) (*ecdsa.PrivateKey, error) {
+	seed, err := w.decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range seed {
+			seed[i] = 0
+		}
+	}()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.key(account, seed)
+}
+
+// signHash signs the hash with the key, zeroing the key afterwards.
+func signHash(key *ecdsa.PrivateKey, err error, hash []byte) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return crypto.Sign(hash, key)
+}
+
+// signTx signs the transaction with the key, zeroing the key afterwards.
+func signTx(key *ecdsa.PrivateKey, err error, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), key)
+}
+
+// SignData implements accounts.Wallet, signing keccak256(data) with the account
+// of the open wallet.
+func (w *hdWallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	key, err := w.unlockedKey(account)
+	return signHash(key, err, crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase implements accounts.Wallet, signing keccak256(data)
+// with the account after decrypting the seed with the passphrase.
+func (w *hdWallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	key, err := w.passphraseKey(account, passphrase)
+	return signHash(key, err, crypto.Keccak256(data))
+}
+
+// SignText implements accounts.Wallet, signing the EIP-191 hash of the text
+// with the account of the open wallet.
+func (w *hdWallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	key, err := w.unlockedKey(account)
+	return signHash(key, err, accounts.TextHash(text))
+}
+
+// SignTextWithPassphrase implements accounts.Wallet, signing the EIP-191 hash
+// of the text with the account after decrypting the seed with the passphrase.
+func (w *hdWallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	key, err := w.passphraseKey(account, passphrase)
+	return signHash(key, err, accounts.TextHash(text))
+}
+
+// SignTx implements accounts.Wallet, signing the transaction with the account
+// of the open wallet.
+func (w *hdWallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.unlockedKey(account)
+	return signTx(key, err, tx, chainID)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, signing the transaction with
+// the account after decrypting the seed with the passphrase.
+func (w *hdWallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.passphraseKey(account, passphrase)
+	return signTx(key, err, tx, chainID)
+}
+
+// NewHDWallet generates a new BIP-39 mnemonic and stores the HD wallet derived
+// from it in the keystore directory, encrypted with the passphrase. The first
+// account on the default derivation path is pinned. The mnemonic is returned
+// so it can be backed up, it's not retrievable afterwards.
+func (ks *KeyStore) NewHDWallet(passphrase string) (accounts.Wallet, string, error) {
+	entropy, err := bip39.NewEntropy(256)
+	if err != nil {
+		return nil, "", err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, "", err
+	}
+	wallet, err := ks.ImportMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return wallet, mnemonic, nil
+}
+
+// ImportMnemonic stores the HD wallet derived from a BIP-39 mnemonic in the
+// keystore directory, encrypted with the passphrase. The first account on the
+// default derivation path is pinned.
+func (ks *KeyStore) ImportMnemonic(mnemonic, passphrase string) (accounts.Wallet, error) {
+	storage, ok := ks.storage.(*keyStorePassphrase)
+	if !ok {
+		return nil, errors.New("HD wallets require an encrypted keystore")
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, ErrInvalidMnemonic
+	}
+	defer func() {
+		for i := range seed {
+			seed[i] = 0
+		}
+	}()
+	key, err := deriveHDKey(seed, accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		return nil, err
+	}
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	zeroKey(key)
+
+	ks.importMu.Lock()
+	defer ks.importMu.Unlock()
+
+	for _, w := range ks.Wallets() {
+		if _, ok := w.(*hdWallet); ok && w.Contains(accounts.Account{Address: addr}) {
+			return nil, ErrAccountAlreadyExists
+		}
+	}
+	cryptoStruct, err := EncryptDataV3(seed, []byte(passphrase), storage.scryptN, storage.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	w := &hdWallet{
+		url:   accounts.URL{Scheme: KeyStoreScheme, Path: storage.JoinPath(hdWalletFileName(addr))},
+		file:  hdWalletJSON{Version: hdWalletVersion, Id: id.String(), Crypto: cryptoStruct},
+		paths: make(map[common.Address]accounts.DerivationPath),
+	}
+	w.pin(addr, accounts.DefaultBaseDerivationPath)
+	if err := w.store(); err != nil {
+		return nil, err
+	}
+	ks.cache.addHDWallet(w.url.Path)
+	ks.refreshWallets()
+	return w, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests BIP-32 key derivation against the official test vector 1.
+func TestDeriveHDKey(t *testing.T) {
+	seed := common.FromHex("000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		path string
+		key  string
+	}{
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0'/1/2'", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+		{"m/0'/1/2'/2/1000000000", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+	}
+	for _, tt := range tests {
+		path, err := accounts.ParseDerivationPath(tt.path)
+		if err != nil {
+			t.Fatalf("%s: invalid path: %v", tt.path, err)
+		}
+		key, err := deriveHDKey(seed, path)
+		if err != nil {
+			t.Fatalf("%s: derivation failed: %v", tt.path, err)
+		}
+		if have := common.Bytes2Hex(crypto.FromECDSA(key)); have != tt.key {
+			t.Errorf("%s: key mismatch: have %s, want %s", tt.path, have, tt.key)
+		}
+	}
+}
+
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func TestHDWallet(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	if _, err := ks.ImportMnemonic("test test test", "pass"); err != ErrInvalidMnemonic {
+		t.Fatalf("invalid mnemonic error mismatch: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+	wallet, err := ks.ImportMnemonic(testMnemonic, "pass")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if _, err := ks.ImportMnemonic(testMnemonic, "pass"); err != ErrAccountAlreadyExists {
+		t.Fatalf("duplicate import error mismatch: have %v, want %v", err, ErrAccountAlreadyExists)
+	}
+	first := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	if accs := wallet.Accounts(); len(accs) != 1 || accs[0].Address != first {
+		t.Fatalf("default account mismatch: have %v, want %x", accs, first)
+	}
+	if wallets := ks.Wallets(); len(wallets) != 1 || wallets[0].URL() != wallet.URL() {
+		t.Fatalf("HD wallet not listed by keystore")
+	}
+	// Derivation and signing without the passphrase need the wallet open
+	path, _ := accounts.ParseDerivationPath("m/44'/60'/0'/0/1")
+	if _, err := wallet.Derive(path, true); err != accounts.ErrWalletClosed {
+		t.Fatalf("closed wallet derivation error mismatch: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	if _, err := wallet.SignText(accounts.Account{Address: first}, []byte("hi")); err != ErrLocked {
+		t.Fatalf("locked signing error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	if err := wallet.Open("wrong"); err != ErrDecrypt {
+		t.Fatalf("wrong passphrase error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	if err := wallet.Open("pass"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	second, err := wallet.Derive(path, true)
+	if err != nil {
+		t.Fatalf("failed to derive account: %v", err)
+	}
+	if want := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"); second.Address != want {
+		t.Fatalf("derived account mismatch: have %x, want %x", second.Address, want)
+	}
+	sig, err := wallet.SignText(second, []byte("hi"))
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if pub, err := crypto.SigToPub(accounts.TextHash([]byte("hi")), sig); err != nil || crypto.PubkeyToAddress(*pub) != second.Address {
+		t.Fatalf("signer mismatch")
+	}
+	wallet.Close()
+
+	// Pinned accounts are persisted, and usable with the passphrase
+	reopened := NewKeyStore(dir, veryLightScryptN, veryLightScryptP)
+	wallets := reopened.Wallets()
+	if len(wallets) != 1 || len(wallets[0].Accounts()) != 2 {
+		t.Fatalf("HD wallet not restored: %v", wallets)
+	}
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := wallets[0].SignTxWithPassphrase(second, "pass", tx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if from, _ := types.Sender(signer, signed); from != second.Address {
+		t.Fatalf("transaction sender mismatch: have %x, want %x", from, second.Address)
+	}
+	if _, err := wallets[0].SignTxWithPassphrase(accounts.Account{Address: common.Address{1}}, "pass", tx, big.NewInt(1)); err != accounts.ErrUnknownAccount {
+		t.Fatalf("unknown account error mismatch: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+// Tests that HD wallet files added to or removed from the keystore directory are
+// picked up through the account cache, without being mistaken for keys.
+func TestHDWalletCache(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	wallet, err := ks.ImportMnemonic(testMnemonic, "pass")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	blob, err := ioutil.ReadFile(wallet.URL().Path)
+	if err != nil {
+		t.Fatalf("failed to read wallet file: %v", err)
+	}
+	// Drop the wallet file into a directory watched by another keystore
+	other := t.TempDir()
+	watched := NewKeyStore(other, veryLightScryptN, veryLightScryptP)
+	if wallets := watched.Wallets(); len(wallets) != 0 {
+		t.Fatalf("initial wallet list not empty: %v", wallets)
+	}
+	time.Sleep(time.Second) // Ensure the watcher is started before adding the file
+
+	path := filepath.Join(other, filepath.Base(wallet.URL().Path))
+	if err := ioutil.WriteFile(path, blob, 0600); err != nil {
+		t.Fatalf("failed to write wallet file: %v", err)
+	}
+	waitForWallets := func(want int) []accounts.Wallet {
+		var wallets []accounts.Wallet
+		for d := 200 * time.Millisecond; d < 8*time.Second; d *= 2 {
+			if wallets = watched.Wallets(); len(wallets) == want {
+				return wallets
+			}
+			time.Sleep(d)
+		}
+		t.Fatalf("wallet count mismatch: have %d, want %d", len(wallets), want)
+		return nil
+	}
+	if wallets := waitForWallets(1); wallets[0].URL().Path != path {
+		t.Fatalf("wallet path mismatch: have %s, want %s", wallets[0].URL().Path, path)
+	}
+	if accs := watched.Accounts(); len(accs) != 0 {
+		t.Fatalf("HD wallet file loaded as key: %v", accs)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove wallet file: %v", err)
+	}
+	waitForWallets(0)
+}
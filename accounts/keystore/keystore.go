@@ -29,6 +29,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 
@@ -37,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 var (
@@ -66,6 +68,7 @@ type KeyStore struct {
 	unlocked map[common.Address]*unlocked // Currently unlocked account (decrypted private keys)
 
 	wallets     []accounts.Wallet       // Wallet wrappers around the individual key files
+	hdwallets   []*hdWallet             // HD wallets stored in the keystore directory
 	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
 	updateScope event.SubscriptionScope // Subscription scope tracking current live listeners
 	updating    bool                    // Whether the event notification loop is running
@@ -117,10 +120,17 @@ func (ks *KeyStore) init(keydir string) {
 	for i := 0; i < len(accs); i++ {
 		ks.wallets[i] = &keystoreWallet{account: accs[i], keystore: ks}
 	}
+	for _, path := range ks.cache.hdWallets() {
+		if wallet, err := loadHDWallet(path); err != nil {
+			log.Warn("Failed to load HD wallet", "path", path, "err", err)
+		} else {
+			ks.hdwallets = append(ks.hdwallets, wallet)
+		}
+	}
 }
 
-// Wallets implements accounts.Backend, returning all single-key wallets from the
-// keystore directory.
+// Wallets implements accounts.Backend, returning all single-key and HD wallets
+// from the keystore directory.
 func (ks *KeyStore) Wallets() []accounts.Wallet {
 	// Make sure the list of wallets is in sync with the account cache
 	ks.refreshWallets()
@@ -128,8 +138,12 @@ func (ks *KeyStore) Wallets() []accounts.Wallet {
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
-	cpy := make([]accounts.Wallet, len(ks.wallets))
+	cpy := make([]accounts.Wallet, len(ks.wallets), len(ks.wallets)+len(ks.hdwallets))
 	copy(cpy, ks.wallets)
+	for _, wallet := range ks.hdwallets {
+		cpy = append(cpy, wallet)
+	}
+	sort.Sort(accounts.WalletsByURL(cpy))
 	return cpy
 }
 
@@ -172,6 +186,33 @@ func (ks *KeyStore) refreshWallets() {
 		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletDropped})
 	}
 	ks.wallets = wallets
+
+	// Sync the HD wallets with the files in the keystore directory
+	var (
+		hdwallets []*hdWallet
+		known     = make(map[string]*hdWallet)
+	)
+	for _, wallet := range ks.hdwallets {
+		known[wallet.url.Path] = wallet
+	}
+	for _, path := range ks.cache.hdWallets() {
+		if wallet, ok := known[path]; ok {
+			hdwallets = append(hdwallets, wallet)
+			delete(known, path)
+			continue
+		}
+		wallet, err := loadHDWallet(path)
+		if err != nil {
+			log.Debug("Failed to load HD wallet", "path", path, "err", err)
+			continue
+		}
+		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletArrived})
+		hdwallets = append(hdwallets, wallet)
+	}
+	for _, wallet := range known {
+		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletDropped})
+	}
+	ks.hdwallets = hdwallets
 	ks.mu.Unlock()
 
 	// Fire all wallet events and return
@@ -0,0 +1,128 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package kms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The signing protocol spoken with the key management service is a minimal
+// JSON over HTTP protocol, which is easy to put in front of any service that
+// can sign secp256k1 digests:
+//
+//   GET  <endpoint>/keys -> {"keys": [{"id": "..", "publicKey": "0x.."}]}
+//   POST <endpoint>/sign  {"keyId": "..", "digest": "0x.."} -> {"signature": "0x.."}
+//
+// Public keys are either raw (compressed or uncompressed) or DER encoded, the
+// signatures are DER encoded. Requests are authenticated with a bearer token
+// if one is configured.
+
+// KeyInfo describes a signing key held by the service.
+type KeyInfo struct {
+	ID        string        `json:"id"`
+	PublicKey hexutil.Bytes `json:"publicKey"`
+}
+
+// KeysResponse is the reply to a key listing.
+type KeysResponse struct {
+	Keys []KeyInfo `json:"keys"`
+}
+
+// SignRequest is a request to sign a 32 byte digest with a key.
+type SignRequest struct {
+	KeyID  string        `json:"keyId"`
+	Digest hexutil.Bytes `json:"digest"`
+}
+
+// SignResponse is the reply to a signing request.
+type SignResponse struct {
+	Signature hexutil.Bytes `json:"signature"` // DER encoded ECDSA signature
+}
+
+// client speaks the signing protocol with a key management service.
+type client struct {
+	endpoint string
+	token    string
+	http     *http.Client
+}
+
+func newClient(endpoint, token string) *client {
+	return &client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    token,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// keys lists the signing keys held by the service.
+func (c *client) keys() ([]KeyInfo, error) {
+	var res KeysResponse
+	if err := c.do(http.MethodGet, "/keys", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Keys, nil
+}
+
+// sign requests the service to sign the digest, returning a DER signature.
+func (c *client) sign(keyID string, digest []byte) ([]byte, error) {
+	var res SignResponse
+	if err := c.do(http.MethodPost, "/sign", &SignRequest{KeyID: keyID, Digest: digest}, &res); err != nil {
+		return nil, err
+	}
+	return res.Signature, nil
+}
+
+func (c *client) do(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		blob, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(blob)
+	}
+	req, err := http.NewRequest(method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("kms %s failed: %s: %s", path, res.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
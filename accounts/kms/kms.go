@@ -0,0 +1,275 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package kms implements an accounts backend delegating secp256k1 signing to a
+// remote key management service, so the keys never leave the service.
+package kms
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Scheme is the URL scheme of the accounts held by key management services.
+const Scheme = "kms"
+
+// keyRefreshCycle is the maximum age of the cached key list before the service
+// is asked for it again.
+const keyRefreshCycle = time.Minute
+
+// Backend is an accounts.Backend exposing the keys of a key management service
+// as a single wallet.
+type Backend struct {
+	wallets []accounts.Wallet
+}
+
+// NewBackend creates a backend for the key management service at endpoint,
+// authenticating with the bearer token if it's not empty. The service must be
+// reachable.
+func NewBackend(endpoint, token string) (*Backend, error) {
+	wallet := &Wallet{client: newClient(endpoint, token)}
+	if _, err := wallet.refresh(); err != nil {
+		return nil, err
+	}
+	return &Backend{wallets: []accounts.Wallet{wallet}}, nil
+}
+
+// Wallets implements accounts.Backend, returning the wallet of the service.
+func (b *Backend) Wallets() []accounts.Wallet {
+	return b.wallets
+}
+
+// Subscribe implements accounts.Backend. The wallet of the service is static,
+// so no events are ever fired.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// remoteKey is a signing key held by the service.
+type remoteKey struct {
+	id     string
+	pubkey *ecdsa.PublicKey
+}
+
+// Wallet is an accounts.Wallet whose accounts are the keys of a key management
+// service. Passphrases are not used, the service authenticates the wallet.
+type Wallet struct {
+	client *client
+
+	keys     map[common.Address]remoteKey // Keys of the service, nil until first listed
+	accounts []accounts.Account           // Accounts of the keys, in listing order
+	listed   time.Time                    // Time the keys were last listed, zero to force a relisting
+	lock     sync.RWMutex
+}
+
+// refresh lists the keys of the service, updating the cache.
+func (w *Wallet) refresh() ([]accounts.Account, error) {
+	infos, err := w.client.keys()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		keys  = make(map[common.Address]remoteKey)
+		accts = make([]accounts.Account, 0, len(infos))
+	)
+	for _, info := range infos {
+		pubkey, err := ParsePublicKey(info.PublicKey)
+		if err != nil {
+			log.Warn("Skipping invalid KMS key", "id", info.ID, "err", err)
+			continue
+		}
+		addr := crypto.PubkeyToAddress(*pubkey)
+		keys[addr] = remoteKey{id: info.ID, pubkey: pubkey}
+		accts = append(accts, accounts.Account{
+			Address: addr,
+			URL:     accounts.URL{Scheme: Scheme, Path: w.client.endpoint + "/" + info.ID},
+		})
+	}
+	w.lock.Lock()
+	w.keys, w.accounts, w.listed = keys, accts, time.Now()
+	w.lock.Unlock()
+
+	return append([]accounts.Account{}, accts...), nil
+}
+
+// fresh reports whether the cached key list is recent enough to be used. The
+// caller must hold the lock.
+func (w *Wallet) fresh() bool {
+	return !w.listed.IsZero() && time.Since(w.listed) < keyRefreshCycle
+}
+
+// invalidate forces the keys to be listed again on next access.
+func (w *Wallet) invalidate() {
+	w.lock.Lock()
+	w.listed = time.Time{}
+	w.lock.Unlock()
+}
+
+// cachedAccounts returns the accounts of the service, only listing the keys if
+// the cache is stale.
+func (w *Wallet) cachedAccounts() ([]accounts.Account, error) {
+	w.lock.RLock()
+	if w.fresh() {
+		accts := append([]accounts.Account{}, w.accounts...)
+		w.lock.RUnlock()
+		return accts, nil
+	}
+	w.lock.RUnlock()
+	return w.refresh()
+}
+
+// key returns the key of an account, listing the keys if the cache is stale.
+func (w *Wallet) key(account accounts.Account) (remoteKey, error) {
+	w.lock.RLock()
+	fresh := w.fresh()
+	key, ok := w.keys[account.Address]
+	w.lock.RUnlock()
+
+	if !ok && !fresh {
+		if _, err := w.refresh(); err != nil {
+			return remoteKey{}, err
+		}
+		w.lock.RLock()
+		key, ok = w.keys[account.Address]
+		w.lock.RUnlock()
+	}
+	if !ok {
+		return remoteKey{}, accounts.ErrUnknownAccount
+	}
+	return key, nil
+}
+
+// URL implements accounts.Wallet, returning the endpoint of the service.
+func (w *Wallet) URL() accounts.URL {
+	return accounts.URL{Scheme: Scheme, Path: w.client.endpoint}
+}
+
+// Status implements accounts.Wallet, checking whether the service was reachable
+// when the keys were last listed.
+func (w *Wallet) Status() (string, error) {
+	accts, err := w.cachedAccounts()
+	if err != nil {
+		return "Unreachable", err
+	}
+	return fmt.Sprintf("Online [keys=%d]", len(accts)), nil
+}
+
+// Open implements accounts.Wallet. The service needs no opening, so this is a
+// noop.
+func (w *Wallet) Open(passphrase string) error { return nil }
+
+// Close implements accounts.Wallet. The service needs no closing, so this is a
+// noop.
+func (w *Wallet) Close() error { return nil }
+
+// Accounts implements accounts.Wallet, returning the cached keys of the service.
+// The keys are listed again once the cache expires or the service errors.
+func (w *Wallet) Accounts() []accounts.Account {
+	accts, err := w.cachedAccounts()
+	if err != nil {
+		log.Error("KMS key listing failed", "err", err)
+		return nil
+	}
+	return accts
+}
+
+// Contains implements accounts.Wallet, returning whether the account is held by
+// the service.
+func (w *Wallet) Contains(account accounts.Account) bool {
+	if account.URL != (accounts.URL{}) && account.URL.Scheme != Scheme {
+		return false
+	}
+	_, err := w.key(account)
+	return err == nil
+}
+
+// Derive implements accounts.Wallet, but key management services don't support
+// hierarchical derivation.
+func (w *Wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but key management services don't
+// support hierarchical derivation.
+func (w *Wallet) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {
+	log.Error("Operation SelfDerive not supported on KMS wallets")
+}
+
+// signHash has the service sign the hash, returning the signature in the
+// [R || S || V] format where V is 0 or 1.
+func (w *Wallet) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	key, err := w.key(account)
+	if err != nil {
+		return nil, err
+	}
+	der, err := w.client.sign(key.id, hash)
+	if err != nil {
+		// The key might have been removed from the service, relist next time
+		w.invalidate()
+		return nil, err
+	}
+	return DERToRecoverable(der, hash, key.pubkey)
+}
+
+// SignData implements accounts.Wallet, signing keccak256(data).
+func (w *Wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return w.signHash(account, crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase implements accounts.Wallet. Passphrases are ignored.
+func (w *Wallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.SignData(account, mimeType, data)
+}
+
+// SignText implements accounts.Wallet, signing the hash of the given text
+// according to EIP-191.
+func (w *Wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.signHash(account, accounts.TextHash(text))
+}
+
+// SignTextWithPassphrase implements accounts.Wallet. Passphrases are ignored.
+func (w *Wallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.SignText(account, text)
+}
+
+// SignTx implements accounts.Wallet, signing the transaction for the chain.
+func (w *Wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	sig, err := w.signHash(account, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet. Passphrases are ignored.
+func (w *Wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
@@ -0,0 +1,235 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package kms
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	oidECPublicKey = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSecp256k1   = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// testService is a local stand-in for a key management service, returning DER
+// signatures with alternating low and high S values like real services do.
+type testService struct {
+	keys  map[string]*ecdsa.PrivateKey
+	token string
+	signs int
+	lists int
+}
+
+func (s *testService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/keys":
+		s.lists++
+		var res KeysResponse
+		for id, key := range s.keys {
+			// Hand out one key in DER format, the other compressed
+			var pubkey []byte
+			if id == "der" {
+				params, _ := asn1.Marshal(oidSecp256k1)
+				raw := crypto.FromECDSAPub(&key.PublicKey)
+				pubkey, _ = asn1.Marshal(subjectPublicKeyInfo{
+					Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidECPublicKey, Parameters: asn1.RawValue{FullBytes: params}},
+					PublicKey: asn1.BitString{Bytes: raw, BitLength: 8 * len(raw)},
+				})
+			} else {
+				pubkey = crypto.CompressPubkey(&key.PublicKey)
+			}
+			res.Keys = append(res.Keys, KeyInfo{ID: id, PublicKey: pubkey})
+		}
+		json.NewEncoder(w).Encode(res)
+
+	case "/sign":
+		var req SignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key, ok := s.keys[req.KeyID]
+		if !ok {
+			http.Error(w, "unknown key", http.StatusNotFound)
+			return
+		}
+		sig, err := crypto.Sign(req.Digest, key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		der := derSignature{R: new(big.Int).SetBytes(sig[:32]), S: new(big.Int).SetBytes(sig[32:64])}
+		if s.signs++; s.signs%2 == 0 {
+			der.S.Sub(secp256k1N, der.S)
+		}
+		blob, _ := asn1.Marshal(der)
+		json.NewEncoder(w).Encode(SignResponse{Signature: blob})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestService(t *testing.T) (*testService, *httptest.Server) {
+	service := &testService{keys: make(map[string]*ecdsa.PrivateKey), token: "secret"}
+	for _, id := range []string{"der", "raw"} {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.keys[id] = key
+	}
+	server := httptest.NewServer(service)
+	t.Cleanup(server.Close)
+	return service, server
+}
+
+func TestWalletSigning(t *testing.T) {
+	service, server := newTestService(t)
+
+	if _, err := NewBackend(server.URL, "wrong"); err == nil {
+		t.Fatalf("unauthenticated backend created")
+	}
+	backend, err := NewBackend(server.URL, service.token)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallet := backend.Wallets()[0]
+	accts := wallet.Accounts()
+	if len(accts) != 2 {
+		t.Fatalf("account count mismatch: have %d, want 2", len(accts))
+	}
+	for _, account := range accts {
+		if !wallet.Contains(accounts.Account{Address: account.Address}) {
+			t.Errorf("account %x not contained", account.Address)
+		}
+		// Sign a few transactions, both signatures with high and low S need
+		// to be accepted by the signer
+		signer := types.LatestSignerForChainID(big.NewInt(1))
+		for i := 0; i < 2; i++ {
+			tx := types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+			signed, err := wallet.SignTxWithPassphrase(account, "", tx, big.NewInt(1))
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			if from, err := types.Sender(signer, signed); err != nil || from != account.Address {
+				t.Errorf("sender mismatch: have %x (%v), want %x", from, err, account.Address)
+			}
+		}
+		sig, err := wallet.SignText(account, []byte("hello"))
+		if err != nil {
+			t.Fatalf("failed to sign text: %v", err)
+		}
+		pubkey, err := crypto.SigToPub(accounts.TextHash([]byte("hello")), sig)
+		if err != nil || crypto.PubkeyToAddress(*pubkey) != account.Address {
+			t.Errorf("text signer mismatch")
+		}
+	}
+	if _, err := wallet.SignData(accounts.Account{Address: common.HexToAddress("0x1")}, accounts.MimetypeTextPlain, nil); err != accounts.ErrUnknownAccount {
+		t.Errorf("unknown account error mismatch: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+func TestKeyListCache(t *testing.T) {
+	service, server := newTestService(t)
+
+	backend, err := NewBackend(server.URL, service.token)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallet := backend.Wallets()[0]
+	accts := wallet.Accounts()
+	if len(accts) != 2 {
+		t.Fatalf("account count mismatch: have %d, want 2", len(accts))
+	}
+	// Listing, status and lookups are served from the cache
+	for i := 0; i < 3; i++ {
+		wallet.Accounts()
+		if _, err := wallet.Status(); err != nil {
+			t.Fatalf("status failed: %v", err)
+		}
+		wallet.Contains(accounts.Account{Address: common.HexToAddress("0x1")})
+	}
+	if service.lists != 1 {
+		t.Fatalf("key listing count mismatch: have %d, want 1", service.lists)
+	}
+	// Remove a key from the service, signing with it fails and forces a relisting
+	var removed accounts.Account
+	for id, key := range service.keys {
+		removed = accounts.Account{Address: crypto.PubkeyToAddress(key.PublicKey)}
+		delete(service.keys, id)
+		break
+	}
+	if _, err := wallet.SignText(removed, []byte("hello")); err == nil {
+		t.Fatalf("signed with removed key")
+	}
+	if accts := wallet.Accounts(); len(accts) != 1 {
+		t.Fatalf("account count mismatch after removal: have %d, want 1", len(accts))
+	}
+	if service.lists != 2 {
+		t.Fatalf("key listing count mismatch: have %d, want 2", service.lists)
+	}
+	if wallet.Contains(removed) {
+		t.Fatalf("removed account still contained")
+	}
+}
+
+func TestDERToRecoverable(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	digest := crypto.Keccak256([]byte("digest"))
+
+	sig, _ := crypto.Sign(digest, key)
+	der, _ := asn1.Marshal(derSignature{R: new(big.Int).SetBytes(sig[:32]), S: new(big.Int).SetBytes(sig[32:64])})
+
+	rsv, err := DERToRecoverable(der, digest, &key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to convert signature: %v", err)
+	}
+	if string(rsv) != string(sig) {
+		t.Errorf("signature mismatch: have %x, want %x", rsv, sig)
+	}
+	if _, err := DERToRecoverable(der, digest, &other.PublicKey); err == nil {
+		t.Errorf("signature of other key accepted")
+	}
+	if _, err := DERToRecoverable(der[:len(der)-1], digest, &key.PublicKey); err == nil {
+		t.Errorf("truncated signature accepted")
+	}
+	if _, err := DERToRecoverable(append(der, 0), digest, &key.PublicKey); err == nil {
+		t.Errorf("signature with trailing data accepted")
+	}
+	zero, _ := asn1.Marshal(derSignature{R: big.NewInt(0), S: big.NewInt(1)})
+	if _, err := DERToRecoverable(zero, digest, &key.PublicKey); err == nil {
+		t.Errorf("zero R accepted")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package kms
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	secp256k1N     = crypto.S256().Params().N
+	secp256k1halfN = new(big.Int).Rsh(secp256k1N, 1)
+)
+
+// derSignature is the ASN.1 structure of a DER encoded ECDSA signature.
+type derSignature struct {
+	R, S *big.Int
+}
+
+// subjectPublicKeyInfo is the ASN.1 structure of a DER encoded public key, as
+// returned by most key management services.
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// ParsePublicKey decodes a secp256k1 public key in either uncompressed (65
+// bytes), compressed (33 bytes) or DER encoded SubjectPublicKeyInfo format.
+func ParsePublicKey(blob []byte) (*ecdsa.PublicKey, error) {
+	switch {
+	case len(blob) == 65 && blob[0] == 4:
+		return crypto.UnmarshalPubkey(blob)
+	case len(blob) == 33 && (blob[0] == 2 || blob[0] == 3):
+		return crypto.DecompressPubkey(blob)
+	}
+	var spki subjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(blob, &spki)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("invalid public key: trailing data")
+	}
+	return ParsePublicKey(spki.PublicKey.RightAlign())
+}
+
+// DERToRecoverable converts a DER encoded ECDSA signature of the digest into
+// the 65 byte [R || S || V] format used by Ethereum, where V is 0 or 1. As key
+// management services don't return the recovery id, it is calculated by trying
+// to recover the public key of the signer. High S values are normalized, since
+// they are not allowed by Homestead.
+func DERToRecoverable(der []byte, digest []byte, pubkey *ecdsa.PublicKey) ([]byte, error) {
+	var sig derSignature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DER signature: %v", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("invalid DER signature: trailing data")
+	}
+	if sig.R == nil || sig.S == nil || sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.Cmp(secp256k1N) >= 0 || sig.S.Cmp(secp256k1N) >= 0 {
+		return nil, errors.New("invalid DER signature: values out of range")
+	}
+	s := sig.S
+	if s.Cmp(secp256k1halfN) > 0 {
+		s = new(big.Int).Sub(secp256k1N, s)
+	}
+	rsv := make([]byte, crypto.SignatureLength)
+	math.ReadBits(sig.R, rsv[:32])
+	math.ReadBits(s, rsv[32:64])
+
+	want := crypto.FromECDSAPub(pubkey)
+	for v := byte(0); v < 2; v++ {
+		rsv[crypto.RecoveryIDOffset] = v
+		if recovered, err := crypto.Ecrecover(digest, rsv); err == nil && bytes.Equal(recovered, want) {
+			return rsv, nil
+		}
+	}
+	return nil, errors.New("signature does not match the public key")
+}
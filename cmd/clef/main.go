@@ -23,6 +23,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -37,6 +38,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/kms"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -125,6 +127,39 @@ var (
 		Name:  "rules",
 		Usage: "Path to the rule file to auto-authorize requests with",
 	}
+	policyFlag = cli.StringFlag{
+		Name:  "policy",
+		Usage: "Path to the declarative JSON or YAML policy file to auto-authorize or reject requests with",
+	}
+	policyExplainFlag = cli.BoolFlag{
+		Name:  "policy-explain",
+		Usage: "Show the policy rule deciding each request",
+	}
+	attestPolicyFlag = cli.BoolFlag{
+		Name:  "policy",
+		Usage: "Attest a policy file instead of a rule file",
+	}
+	approvalFlag = cli.StringFlag{
+		Name:  "approval",
+		Usage: "Path to the JSON config of the accounts whose requests need the approval of multiple approvers",
+	}
+	approvalPortFlag = cli.IntFlag{
+		Name:  "approval.port",
+		Usage: "HTTP-RPC server listening port of the approver API",
+		Value: node.DefaultHTTPPort + 6,
+	}
+	attestApprovalFlag = cli.BoolFlag{
+		Name:  "approval",
+		Usage: "Attest an approval config instead of a rule file",
+	}
+	kmsFlag = cli.StringFlag{
+		Name:  "kms",
+		Usage: "Endpoint of a remote key management service to sign with",
+	}
+	kmsTokenFlag = cli.StringFlag{
+		Name:  "kms.tokenfile",
+		Usage: "File containing the bearer token authenticating to the key management service",
+	}
 	stdiouiFlag = cli.BoolFlag{
 		Name: "stdio-ui",
 		Usage: "Use STDIN/STDOUT as a channel for an external UI. " +
@@ -152,18 +187,21 @@ the rule-engine to work.`,
 	attestCommand = cli.Command{
 		Action:    utils.MigrateFlags(attestFile),
 		Name:      "attest",
-		Usage:     "Attest that a js-file is to be used",
+		Usage:     "Attest that a js-file, policy file or approval config is to be used",
 		ArgsUsage: "<sha256sum>",
 		Flags: []cli.Flag{
 			logLevelFlag,
 			configdirFlag,
 			signerSecretFlag,
+			attestPolicyFlag,
+			attestApprovalFlag,
 		},
 		Description: `
 The attest command stores the sha256 of the rule.js-file that you want to use for automatic processing of
-incoming requests.
+incoming requests. With --policy or --approval, it stores the sha256 of the policy file or the approval
+config instead.
 
-Whenever you make an edit to the rule file, you need to use attestation to tell
+Whenever you make an edit to the rule, policy or approval file, you need to use attestation to tell
 Clef that the file is 'safe' to execute.`,
 	}
 	setCredentialCommand = cli.Command{
@@ -208,6 +246,37 @@ The newaccount command creates a new keystore-backed account. It is a convenienc
 which can be used in lieu of an external UI.`,
 	}
 
+	newHDCommand = cli.Command{
+		Action:    utils.MigrateFlags(newHDWallet),
+		Name:      "newhd",
+		Usage:     "Create a new hierarchical deterministic wallet",
+		ArgsUsage: "",
+		Flags: []cli.Flag{
+			logLevelFlag,
+			keystoreFlag,
+			utils.LightKDFFlag,
+			acceptFlag,
+		},
+		Description: `
+The newhd command creates a new BIP-39 mnemonic backed wallet in the keystore, and
+prints the mnemonic. Write it down, it is the only way to restore the wallet.`,
+	}
+	importHDCommand = cli.Command{
+		Action:    utils.MigrateFlags(importHDWallet),
+		Name:      "importhd",
+		Usage:     "Import a BIP-39 mnemonic into a new hierarchical deterministic wallet",
+		ArgsUsage: "<mnemonicfile>",
+		Flags: []cli.Flag{
+			logLevelFlag,
+			keystoreFlag,
+			utils.LightKDFFlag,
+			acceptFlag,
+		},
+		Description: `
+The importhd command imports the BIP-39 mnemonic stored in the given file into a
+new wallet in the keystore.`,
+	}
+
 	gendocCommand = cli.Command{
 		Action: GenDoc,
 		Name:   "gendoc",
@@ -239,6 +308,12 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			customDBFlag,
 			auditLogFlag,
 			ruleFlag,
+			policyFlag,
+			policyExplainFlag,
+			approvalFlag,
+			approvalPortFlag,
+			kmsFlag,
+			kmsTokenFlag,
 			stdiouiFlag,
 			testFlag,
 			advancedMode,
@@ -268,6 +343,12 @@ func init() {
 		customDBFlag,
 		auditLogFlag,
 		ruleFlag,
+		policyFlag,
+		policyExplainFlag,
+		approvalFlag,
+		approvalPortFlag,
+		kmsFlag,
+		kmsTokenFlag,
 		stdiouiFlag,
 		testFlag,
 		advancedMode,
@@ -279,6 +360,8 @@ func init() {
 		setCredentialCommand,
 		delCredentialCommand,
 		newAccountCommand,
+		newHDCommand,
+		importHDCommand,
 		gendocCommand}
 	cli.CommandHelpTemplate = flags.CommandHelpTemplate
 	// Override the default app help template
@@ -410,6 +493,16 @@ func attestFile(ctx *cli.Context) error {
 	// Initialize the encrypted storages
 	configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confKey)
 	val := ctx.Args().First()
+	if ctx.Bool(attestPolicyFlag.Name) {
+		configStorage.Put("policy_sha256", val)
+		log.Info("Policy attestation updated", "sha256", val)
+		return nil
+	}
+	if ctx.Bool(attestApprovalFlag.Name) {
+		configStorage.Put("approval_sha256", val)
+		log.Info("Approval config attestation updated", "sha256", val)
+		return nil
+	}
 	configStorage.Put("ruleset_sha256", val)
 	log.Info("Ruleset attestation updated", "sha256", val)
 	return nil
@@ -498,6 +591,90 @@ func newAccount(c *cli.Context) error {
 	return err
 }
 
+func newHDWallet(c *cli.Context) error {
+	ks, ui, err := openHDKeyStore(c)
+	if err != nil {
+		return err
+	}
+	password, err := hdWalletPassword(ui)
+	if err != nil {
+		return err
+	}
+	wallet, mnemonic, err := ks.NewHDWallet(password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Generated wallet with account %v\n", wallet.Accounts()[0].Address.String())
+	fmt.Printf("Mnemonic: %s\n", mnemonic)
+	log.Warn("Please backup your mnemonic, it is the only way to restore the wallet!")
+	log.Warn("Please remember your password!")
+	return nil
+}
+
+func importHDWallet(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	mnemonic, err := ioutil.ReadFile(c.Args().First())
+	if err != nil {
+		return err
+	}
+	ks, ui, err := openHDKeyStore(c)
+	if err != nil {
+		return err
+	}
+	password, err := hdWalletPassword(ui)
+	if err != nil {
+		return err
+	}
+	wallet, err := ks.ImportMnemonic(string(mnemonic), password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported wallet with account %v\n", wallet.Accounts()[0].Address.String())
+	return nil
+}
+
+// openHDKeyStore opens the keystore to create HD wallets in, along with the CLI
+// UI to query the password with.
+func openHDKeyStore(c *cli.Context) (*keystore.KeyStore, core.UIClientAPI, error) {
+	if err := initialize(c); err != nil {
+		return nil, nil, err
+	}
+	var (
+		ksLoc    = c.GlobalString(keystoreFlag.Name)
+		lightKdf = c.GlobalBool(utils.LightKDFFlag.Name)
+	)
+	log.Info("Starting clef", "keystore", ksLoc, "light-kdf", lightKdf)
+	am := core.StartClefAccountManager(ksLoc, true, lightKdf, "")
+	backends := am.Backends(keystore.KeyStoreType)
+	if len(backends) == 0 {
+		return nil, nil, errors.New("password based accounts not supported")
+	}
+	return backends[0].(*keystore.KeyStore), core.NewCommandlineUI(), nil
+}
+
+// hdWalletPassword queries the password of a new HD wallet, giving the user
+// three attempts to satisfy the password requirements.
+func hdWalletPassword(ui core.UIClientAPI) (string, error) {
+	for i := 0; i < 3; i++ {
+		resp, err := ui.OnInputRequired(core.UserInputRequest{
+			Title:      "New wallet password",
+			Prompt:     fmt.Sprintf("Please enter a password for the new wallet to be created (attempt %d of 3)", i),
+			IsPassword: true,
+		})
+		if err != nil {
+			return "", err
+		}
+		if err := core.ValidatePasswordFormat(resp.Text); err != nil {
+			ui.ShowError(fmt.Sprintf("Wallet creation attempt #%d failed due to password requirements: %v", i+1, err))
+			continue
+		}
+		return resp.Text, nil
+	}
+	return "", errors.New("wallet creation failed")
+}
+
 func initialize(c *cli.Context) error {
 	// Set up the logger to print everything
 	logOutput := os.Stdout
@@ -573,10 +750,14 @@ func signer(c *cli.Context) error {
 
 	var (
 		api       core.ExternalAPI
+		approvals *core.MultiApprovalUI
 		pwStorage storage.Storage = &storage.NoStorage{}
 	)
 	configDir := c.GlobalString(configdirFlag.Name)
 	if stretchedKey, err := readMasterKey(c, ui); err != nil {
+		if c.GlobalString(approvalFlag.Name) != "" {
+			utils.Fatalf("Multi-party approval requires the master seed: %v", err)
+		}
 		log.Warn("Failed to open master, rules disabled", "err", err)
 	} else {
 		vaultLocation := filepath.Join(configDir, common.Bytes2Hex(crypto.Keccak256([]byte("vault"), stretchedKey)[:10]))
@@ -614,6 +795,66 @@ func signer(c *cli.Context) error {
 				}
 			}
 		}
+		// Do we have a policy file?
+		if policyFile := c.GlobalString(policyFlag.Name); policyFile != "" {
+			policyJSON, err := ioutil.ReadFile(policyFile)
+			if err != nil {
+				log.Warn("Could not load policy, disabling", "file", policyFile, "err", err)
+			} else {
+				shasum := sha256.Sum256(policyJSON)
+				foundShaSum := hex.EncodeToString(shasum[:])
+				storedShasum, _ := configStorage.Get("policy_sha256")
+				if storedShasum != foundShaSum {
+					log.Warn("Policy hash not attested, disabling", "hash", foundShaSum, "attested", storedShasum)
+				} else {
+					policy, err := core.ParsePolicy(policyJSON)
+					if err != nil {
+						utils.Fatalf("Invalid policy file: %v", err)
+					}
+					ledgerkey := crypto.Keccak256([]byte("ledger"), stretchedKey)
+					ledger := storage.NewSpendLedger(storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "ledger.json"), ledgerkey))
+
+					engine, err := core.NewPolicyEngine(policy, ledger, db)
+					if err != nil {
+						utils.Fatalf(err.Error())
+					}
+					ui = core.NewPolicyUI(ui, engine, c.GlobalBool(policyExplainFlag.Name))
+					log.Info("Policy engine configured", "file", policyFile)
+				}
+			}
+		}
+		// Do we need multi-party approval? It wraps the rules and policy, so
+		// that they can't bypass the approvers.
+		if approvalFile := c.GlobalString(approvalFlag.Name); approvalFile != "" {
+			approvalJSON, err := ioutil.ReadFile(approvalFile)
+			if err != nil {
+				utils.Fatalf("Could not load approval config: %v", err)
+			}
+			shasum := sha256.Sum256(approvalJSON)
+			foundShaSum := hex.EncodeToString(shasum[:])
+			if storedShasum, _ := configStorage.Get("approval_sha256"); storedShasum != foundShaSum {
+				utils.Fatalf("Approval config hash %s not attested (attested %q)", foundShaSum, storedShasum)
+			}
+			config, err := core.ParseApprovalConfig(approvalJSON)
+			if err != nil {
+				utils.Fatalf("Invalid approval config: %v", err)
+			}
+			var auditor core.ApprovalAuditor
+			if logfile := c.GlobalString(auditLogFlag.Name); logfile != "" {
+				if auditor, err = core.NewApprovalAuditLogger(logfile); err != nil {
+					utils.Fatalf(err.Error())
+				}
+			}
+			approvalkey := crypto.Keccak256([]byte("approvals"), stretchedKey)
+			approvalStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "approvals.json"), approvalkey)
+
+			if approvals, err = core.NewMultiApprovalUI(ui, config, approvalStorage, auditor); err != nil {
+				utils.Fatalf(err.Error())
+			}
+			ui = approvals
+			log.Info("Multi-party approval configured", "file", approvalFile,
+				"accounts", len(config.Accounts), "threshold", config.Threshold, "approvers", len(config.Approvers))
+		}
 	}
 	var (
 		chainId  = c.GlobalInt64(chainIdFlag.Name)
@@ -625,7 +866,24 @@ func signer(c *cli.Context) error {
 	)
 	log.Info("Starting signer", "chainid", chainId, "keystore", ksLoc,
 		"light-kdf", lightKdf, "advanced", advanced)
-	am := core.StartClefAccountManager(ksLoc, nousb, lightKdf, scpath)
+	var backends []accounts.Backend
+	if endpoint := c.GlobalString(kmsFlag.Name); endpoint != "" {
+		var token string
+		if tokenFile := c.GlobalString(kmsTokenFlag.Name); tokenFile != "" {
+			blob, err := ioutil.ReadFile(tokenFile)
+			if err != nil {
+				utils.Fatalf("Could not read KMS token: %v", err)
+			}
+			token = strings.TrimSpace(string(blob))
+		}
+		backend, err := kms.NewBackend(endpoint, token)
+		if err != nil {
+			utils.Fatalf("Could not connect to KMS: %v", err)
+		}
+		backends = append(backends, backend)
+		log.Info("KMS signing configured", "endpoint", endpoint)
+	}
+	am := core.StartClefAccountManager(ksLoc, nousb, lightKdf, scpath, backends...)
 	apiImpl := core.NewSignerAPI(am, chainId, nousb, ui, db, advanced, pwStorage)
 
 	// Establish the bidirectional communication, by creating a new UI backend and registering
@@ -644,6 +902,7 @@ func signer(c *cli.Context) error {
 	var (
 		extapiURL = "n/a"
 		ipcapiURL = "n/a"
+		appapiURL = "n/a"
 	)
 	rpcAPI := []rpc.API{
 		{
@@ -681,6 +940,30 @@ func signer(c *cli.Context) error {
 			log.Info("HTTP endpoint closed", "url", extapiURL)
 		}()
 	}
+	if approvals != nil {
+		// The approver API lives on its own endpoint, so it can be exposed to the
+		// approvers without exposing the external API.
+		srv := rpc.NewServer()
+		if err := srv.RegisterName("approver", core.NewApproverAPI(approvals)); err != nil {
+			utils.Fatalf("Could not register approver API: %v", err)
+		}
+		vhosts := utils.SplitAndTrim(c.GlobalString(utils.HTTPVirtualHostsFlag.Name))
+		cors := utils.SplitAndTrim(c.GlobalString(utils.HTTPCORSDomainFlag.Name))
+		handler := node.NewHTTPHandlerStack(srv, cors, vhosts)
+
+		httpEndpoint := fmt.Sprintf("%s:%d", c.GlobalString(utils.HTTPListenAddrFlag.Name), c.Int(approvalPortFlag.Name))
+		httpServer, addr, err := node.StartHTTPEndpoint(httpEndpoint, rpc.DefaultHTTPTimeouts, handler)
+		if err != nil {
+			utils.Fatalf("Could not start approver api: %v", err)
+		}
+		appapiURL = fmt.Sprintf("http://%v/", addr)
+		log.Info("Approver endpoint opened", "url", appapiURL)
+
+		defer func() {
+			httpServer.Shutdown(context.Background())
+			log.Info("Approver endpoint closed", "url", appapiURL)
+		}()
+	}
 	if !c.GlobalBool(utils.IPCDisabledFlag.Name) {
 		givenPath := c.GlobalString(utils.IPCPathFlag.Name)
 		ipcapiURL = ipcEndpoint(filepath.Join(givenPath, "clef.ipc"), configDir)
@@ -705,6 +988,7 @@ func signer(c *cli.Context) error {
 			"extapi_version": core.ExternalAPIVersion,
 			"extapi_http":    extapiURL,
 			"extapi_ipc":     ipcapiURL,
+			"approverapi":    appapiURL,
 		},
 	})
 
@@ -0,0 +1,119 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/tests"
+
+	"gopkg.in/urfave/cli.v1"
+)
+
+var blockTestCommand = cli.Command{
+	Action:    blockTestCmd,
+	Name:      "blocktest",
+	Usage:     "executes the given blockchain tests",
+	ArgsUsage: "<file|dir|glob>...",
+	Flags:     testRunnerFlags,
+}
+
+// BlocktestResult contains the execution status after running a blockchain test
+// and any error that might have occurred.
+type BlocktestResult struct {
+	Name  string `json:"name"`
+	Pass  bool   `json:"pass"`
+	Fork  string `json:"fork"`
+	Error string `json:"error,omitempty"`
+}
+
+func blockTestCmd(ctx *cli.Context) error {
+	files, err := collectTestFiles(ctx.Args())
+	if err != nil {
+		return err
+	}
+	filter, err := newTestFilter(ctx)
+	if err != nil {
+		return err
+	}
+	setupTestLogger(ctx)
+
+	start := time.Now()
+	results := runTestFiles(files, ctx.Int(WorkersFlag.Name), func(file string) []testResult {
+		return runBlockTestFile(file, filter)
+	})
+	printTestOutputs(results)
+	return writeTestReports(ctx, "blocktest", results, time.Since(start))
+}
+
+// runBlockTestFile runs all the selected blockchain tests of a test file.
+func runBlockTestFile(file string, filter *testFilter) []testResult {
+	// Load the test content from the input file
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return []testResult{blockTestLoadFailure(file, err)}
+	}
+	var tests map[string]tests.BlockTest
+	if err = json.Unmarshal(src, &tests); err != nil {
+		return []testResult{blockTestLoadFailure(file, err)}
+	}
+	keys := make([]string, 0, len(tests))
+	for key := range tests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Iterate over all the tests, run them and aggregate the results
+	var results []testResult
+	for _, key := range keys {
+		test := tests[key]
+		if !filter.match(key, test.Network()) {
+			continue
+		}
+		result := &BlocktestResult{Name: key, Fork: test.Network(), Pass: true}
+
+		start := time.Now()
+		if err := runGuarded(func() error { return test.Run(false) }); err != nil {
+			result.Pass, result.Error = false, err.Error()
+		}
+		results = append(results, testResult{
+			File:     file,
+			Name:     key,
+			Fork:     result.Fork,
+			Pass:     result.Pass,
+			Error:    result.Error,
+			Duration: time.Since(start),
+			Output:   result,
+		})
+	}
+	return results
+}
+
+// blockTestLoadFailure creates the failed result of a blockchain test file which
+// couldn't be loaded.
+func blockTestLoadFailure(file string, err error) testResult {
+	return testResult{
+		File:   file,
+		Name:   filepath.Base(file),
+		Error:  err.Error(),
+		Output: &BlocktestResult{Name: filepath.Base(file), Error: err.Error()},
+	}
+}
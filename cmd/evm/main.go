@@ -192,6 +192,7 @@ func init() {
 		disasmCommand,
 		runCommand,
 		stateTestCommand,
+		blockTestCommand,
 		stateTransitionCommand,
 	}
 	cli.CommandHelpTemplate = flags.OriginCommandHelpTemplate
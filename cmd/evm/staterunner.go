@@ -18,14 +18,15 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
-	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/tests"
 
 	"gopkg.in/urfave/cli.v1"
@@ -35,7 +36,8 @@ var stateTestCommand = cli.Command{
 	Action:    stateTestCmd,
 	Name:      "statetest",
 	Usage:     "executes the given state tests",
-	ArgsUsage: "<file>",
+	ArgsUsage: "<file|dir|glob>...",
+	Flags:     testRunnerFlags,
 }
 
 // StatetestResult contains the execution status after running a state test, any
@@ -49,13 +51,15 @@ type StatetestResult struct {
 }
 
 func stateTestCmd(ctx *cli.Context) error {
-	if len(ctx.Args().First()) == 0 {
-		return errors.New("path-to-test argument required")
+	files, err := collectTestFiles(ctx.Args())
+	if err != nil {
+		return err
 	}
-	// Configure the go-ethereum logger
-	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
-	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
-	log.Root().SetHandler(glogger)
+	filter, err := newTestFilter(ctx)
+	if err != nil {
+		return err
+	}
+	setupTestLogger(ctx)
 
 	// Configure the EVM logger
 	config := &vm.LogConfig{
@@ -64,66 +68,120 @@ func stateTestCmd(ctx *cli.Context) error {
 		DisableStorage:    ctx.GlobalBool(DisableStorageFlag.Name),
 		DisableReturnData: ctx.GlobalBool(DisableReturnDataFlag.Name),
 	}
-	var (
-		tracer   vm.Tracer
-		debugger *vm.StructLogger
-	)
-	switch {
-	case ctx.GlobalBool(MachineFlag.Name):
-		tracer = vm.NewJSONLogger(config, os.Stderr)
-
-	case ctx.GlobalBool(DebugFlag.Name):
-		debugger = vm.NewStructLogger(config)
-		tracer = debugger
-
-	default:
-		debugger = vm.NewStructLogger(config)
+	// Traces are written out as the tests run, avoid interleaving them
+	workers := ctx.Int(WorkersFlag.Name)
+	if ctx.GlobalBool(MachineFlag.Name) || ctx.GlobalBool(DebugFlag.Name) {
+		workers = 1
 	}
+	start := time.Now()
+	results := runTestFiles(files, workers, func(file string) []testResult {
+		return runStateTestFile(ctx, file, filter, config)
+	})
+	printTestOutputs(results)
+	return writeTestReports(ctx, "statetest", results, time.Since(start))
+}
+
+// runStateTestFile runs all the selected state tests of a test file.
+func runStateTestFile(ctx *cli.Context, file string, filter *testFilter, config *vm.LogConfig) []testResult {
 	// Load the test content from the input file
-	src, err := ioutil.ReadFile(ctx.Args().First())
+	src, err := ioutil.ReadFile(file)
 	if err != nil {
-		return err
+		return []testResult{stateTestLoadFailure(file, err)}
 	}
 	var tests map[string]tests.StateTest
 	if err = json.Unmarshal(src, &tests); err != nil {
-		return err
+		return []testResult{stateTestLoadFailure(file, err)}
 	}
-	// Iterate over all the tests, run them and aggregate the results
-	cfg := vm.Config{
-		Tracer: tracer,
-		Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+	keys := make([]string, 0, len(tests))
+	for key := range tests {
+		keys = append(keys, key)
 	}
-	results := make([]StatetestResult, 0, len(tests))
-	for key, test := range tests {
-		for _, st := range test.Subtests() {
+	sort.Strings(keys)
+
+	// Iterate over all the tests, run them and aggregate the results
+	var results []testResult
+	for _, key := range keys {
+		test := tests[key]
+
+		// Subtests are listed in random order, keep the results stable
+		subtests := test.Subtests()
+		sort.Slice(subtests, func(i, j int) bool {
+			if subtests[i].Fork != subtests[j].Fork {
+				return subtests[i].Fork < subtests[j].Fork
+			}
+			return subtests[i].Index < subtests[j].Index
+		})
+		for _, st := range subtests {
+			if !filter.match(key, st.Fork) {
+				continue
+			}
+			var (
+				tracer   vm.Tracer
+				debugger *vm.StructLogger
+			)
+			switch {
+			case ctx.GlobalBool(MachineFlag.Name):
+				tracer = vm.NewJSONLogger(config, os.Stderr)
+
+			case ctx.GlobalBool(DebugFlag.Name):
+				debugger = vm.NewStructLogger(config)
+				tracer = debugger
+			}
+			cfg := vm.Config{
+				Tracer: tracer,
+				Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+			}
 			// Run the test and aggregate the result
 			result := &StatetestResult{Name: key, Fork: st.Fork, Pass: true}
-			_, state, err := test.Run(st, cfg, false)
+
+			var (
+				start   = time.Now()
+				statedb *state.StateDB
+			)
+			err := runGuarded(func() (err error) {
+				_, statedb, err = test.Run(st, cfg, false)
+				return err
+			})
+			elapsed := time.Since(start)
+
 			// print state root for evmlab tracing
-			if ctx.GlobalBool(MachineFlag.Name) && state != nil {
-				fmt.Fprintf(os.Stderr, "{\"stateRoot\": \"%x\"}\n", state.IntermediateRoot(false))
+			if ctx.GlobalBool(MachineFlag.Name) && statedb != nil {
+				fmt.Fprintf(os.Stderr, "{\"stateRoot\": \"%x\"}\n", statedb.IntermediateRoot(false))
 			}
 			if err != nil {
 				// Test failed, mark as so and dump any state to aid debugging
 				result.Pass, result.Error = false, err.Error()
-				if ctx.GlobalBool(DumpFlag.Name) && state != nil {
-					dump := state.RawDump(false, false, true)
+				if ctx.GlobalBool(DumpFlag.Name) && statedb != nil {
+					dump := statedb.RawDump(false, false, true)
 					result.State = &dump
 				}
 			}
-
-			results = append(results, *result)
-
+			results = append(results, testResult{
+				File:     file,
+				Name:     key,
+				Fork:     st.Fork,
+				Pass:     result.Pass,
+				Error:    result.Error,
+				Duration: elapsed,
+				Output:   result,
+			})
 			// Print any structured logs collected
-			if ctx.GlobalBool(DebugFlag.Name) {
-				if debugger != nil {
-					fmt.Fprintln(os.Stderr, "#### TRACE ####")
-					vm.WriteTrace(os.Stderr, debugger.StructLogs())
-				}
+			if debugger != nil {
+				fmt.Fprintln(os.Stderr, "#### TRACE ####")
+				vm.WriteTrace(os.Stderr, debugger.StructLogs())
 			}
 		}
 	}
-	out, _ := json.MarshalIndent(results, "", "  ")
-	fmt.Println(string(out))
-	return nil
+	return results
+}
+
+// stateTestLoadFailure creates the failed result of a state test file which
+// couldn't be loaded.
+func stateTestLoadFailure(file string, err error) testResult {
+	return testResult{
+		File:   file,
+		Name:   filepath.Base(file),
+		Error:  err.Error(),
+		Output: &StatetestResult{Name: filepath.Base(file), Error: err.Error()},
+	}
 }
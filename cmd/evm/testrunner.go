@@ -0,0 +1,372 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	WorkersFlag = cli.IntFlag{
+		Name:  "workers",
+		Usage: "Number of test files to run in parallel",
+		Value: runtime.NumCPU(),
+	}
+	ForkFilterFlag = cli.StringFlag{
+		Name:  "fork",
+		Usage: "Comma separated list of forks to run the tests of (default = all)",
+	}
+	RunFilterFlag = cli.StringFlag{
+		Name:  "run",
+		Usage: "Regular expression selecting the tests to run by name",
+	}
+	JSONReportFlag = cli.StringFlag{
+		Name:  "report.json",
+		Usage: "File to write a JSON summary of the test results to",
+	}
+	JUnitReportFlag = cli.StringFlag{
+		Name:  "report.junit",
+		Usage: "File to write a JUnit XML report of the test results to",
+	}
+	ExitCodeFlag = cli.BoolFlag{
+		Name:  "exitcode",
+		Usage: "Exit with a non-zero status if any of the tests fail (default = exit zero, check the results)",
+	}
+)
+
+// testRunnerFlags are the flags shared by the test running commands.
+var testRunnerFlags = []cli.Flag{
+	WorkersFlag,
+	ForkFilterFlag,
+	RunFilterFlag,
+	JSONReportFlag,
+	JUnitReportFlag,
+	ExitCodeFlag,
+}
+
+// testResult is the outcome of a single test case, as aggregated in the reports.
+type testResult struct {
+	File     string
+	Name     string
+	Fork     string
+	Pass     bool
+	Error    string
+	Duration time.Duration
+
+	Output interface{} // Command specific result printed to stdout
+}
+
+// setupTestLogger configures the go-ethereum logger of the test commands.
+func setupTestLogger(ctx *cli.Context) {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+}
+
+// testFilter selects the test cases to run by name and fork.
+type testFilter struct {
+	forks map[string]bool
+	name  *regexp.Regexp
+}
+
+// newTestFilter creates a test case filter from the command line flags.
+func newTestFilter(ctx *cli.Context) (*testFilter, error) {
+	filter := new(testFilter)
+	if forks := ctx.String(ForkFilterFlag.Name); forks != "" {
+		filter.forks = make(map[string]bool)
+		for _, fork := range strings.Split(forks, ",") {
+			filter.forks[strings.TrimSpace(fork)] = true
+		}
+	}
+	if pattern := ctx.String(RunFilterFlag.Name); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid test name filter: %v", err)
+		}
+		filter.name = re
+	}
+	return filter, nil
+}
+
+// match reports whether the test case with the given name and fork is selected.
+func (f *testFilter) match(name, fork string) bool {
+	if f.forks != nil && !f.forks[fork] {
+		return false
+	}
+	return f.name == nil || f.name.MatchString(name)
+}
+
+// collectTestFiles expands the given paths into the list of test files to run.
+// Paths may be files, directories (searched recursively for JSON files) or glob
+// patterns.
+func collectTestFiles(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("path-to-test argument required")
+	}
+	var (
+		files []string
+		seen  = make(map[string]bool)
+	)
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, path := range paths {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid test path %q: %v", path, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no tests found at %q", path)
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+			err = filepath.Walk(match, func(file string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+					add(file)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// runTestFiles runs the given function over all the test files, with the given
+// number of files processed in parallel. The results are returned in the order
+// of the files.
+func runTestFiles(files []string, workers int, run func(file string) []testResult) []testResult {
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		results = make([][]testResult, len(files))
+		tasks   = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				results[task] = run(files[task])
+			}
+		}()
+	}
+	for i := range files {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+
+	var flat []testResult
+	for _, fileResults := range results {
+		flat = append(flat, fileResults...)
+	}
+	return flat
+}
+
+// runGuarded runs a single test case, turning any panic into a failure instead
+// of aborting the whole test run.
+func runGuarded(run func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("test panicked: %v", r)
+		}
+	}()
+	return run()
+}
+
+// printTestOutputs prints the command specific results of the tests to stdout.
+func printTestOutputs(results []testResult) {
+	outputs := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		outputs = append(outputs, result.Output)
+	}
+	out, _ := json.MarshalIndent(outputs, "", "  ")
+	fmt.Println(string(out))
+}
+
+// jsonReport is the machine readable summary of a test run.
+type jsonReport struct {
+	Total    int              `json:"total"`
+	Passed   int              `json:"passed"`
+	Failed   int              `json:"failed"`
+	Duration float64          `json:"duration"`
+	Results  []jsonReportCase `json:"results"`
+}
+
+type jsonReportCase struct {
+	File     string  `json:"file"`
+	Name     string  `json:"name"`
+	Fork     string  `json:"fork"`
+	Pass     bool    `json:"pass"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration"`
+}
+
+// junitSuites is the root of a JUnit XML report, with a test suite per file.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+
+	elapsed time.Duration
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeTestReports writes the requested reports of the test results. If requested
+// by the exit code flag, it returns an error if any of the tests failed.
+func writeTestReports(ctx *cli.Context, suite string, results []testResult, elapsed time.Duration) error {
+	failed := 0
+	for _, result := range results {
+		if !result.Pass {
+			failed++
+		}
+	}
+	if path := ctx.String(JSONReportFlag.Name); path != "" {
+		report := &jsonReport{
+			Total:    len(results),
+			Passed:   len(results) - failed,
+			Failed:   failed,
+			Duration: elapsed.Seconds(),
+			Results:  make([]jsonReportCase, 0, len(results)),
+		}
+		for _, result := range results {
+			report.Results = append(report.Results, jsonReportCase{
+				File:     result.File,
+				Name:     result.Name,
+				Fork:     result.Fork,
+				Pass:     result.Pass,
+				Error:    result.Error,
+				Duration: result.Duration.Seconds(),
+			})
+		}
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, out, 0644); err != nil {
+			return fmt.Errorf("failed to write JSON report: %v", err)
+		}
+	}
+	if path := ctx.String(JUnitReportFlag.Name); path != "" {
+		report := &junitSuites{
+			Name:     suite,
+			Tests:    len(results),
+			Failures: failed,
+			Time:     junitTime(elapsed),
+		}
+		// Group the test cases into a suite per file, in the order of the files
+		suites := make(map[string]int)
+		for _, result := range results {
+			index, ok := suites[result.File]
+			if !ok {
+				index = len(report.Suites)
+				report.Suites = append(report.Suites, junitSuite{Name: result.File})
+				suites[result.File] = index
+			}
+			s := &report.Suites[index]
+			tc := junitCase{
+				Name:      result.Name,
+				Classname: result.File,
+				Time:      junitTime(result.Duration),
+			}
+			if result.Fork != "" {
+				tc.Name = fmt.Sprintf("%s/%s", result.Name, result.Fork)
+			}
+			if !result.Pass {
+				tc.Failure = &junitFailure{Message: "test failed", Text: result.Error}
+				s.Failures++
+			}
+			s.Cases = append(s.Cases, tc)
+			s.Tests++
+			s.elapsed += result.Duration
+		}
+		for i := range report.Suites {
+			report.Suites[i].Time = junitTime(report.Suites[i].elapsed)
+		}
+		out, err := xml.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, append([]byte(xml.Header), out...), 0644); err != nil {
+			return fmt.Errorf("failed to write JUnit report: %v", err)
+		}
+	}
+	if failed > 0 && ctx.Bool(ExitCodeFlag.Name) {
+		return fmt.Errorf("%d of %d tests failed", failed, len(results))
+	}
+	return nil
+}
+
+// junitTime formats a duration in seconds, as expected in JUnit reports.
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
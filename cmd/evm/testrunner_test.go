@@ -0,0 +1,279 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/ethereum/go-ethereum/internal/cmdtest"
+)
+
+type testEvm struct {
+	*cmdtest.TestCmd
+}
+
+// spawns evm with the given command line args.
+func runEvm(t *testing.T, args ...string) *testEvm {
+	tt := new(testEvm)
+	tt.TestCmd = cmdtest.NewTestCmd(t, tt)
+	tt.Run("evm-test", args...)
+	return tt
+}
+
+func TestMain(m *testing.M) {
+	// Run the app if we've been exec'd as "evm-test" in runEvm.
+	reexec.Register("evm-test", func() {
+		if err := app.Run(os.Args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	})
+	// check if we have been reexec'd
+	if reexec.Init() {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// Tests that test paths are expanded from files, directories and globs, without
+// duplicates and in the order given.
+func TestCollectTestFiles(t *testing.T) {
+	var (
+		stateTest = filepath.Join("testdata", "statetest", "transfer.json")
+		blockTest = filepath.Join("testdata", "blocktest", "transfers.json")
+	)
+	tests := []struct {
+		paths []string
+		files []string
+	}{
+		{[]string{stateTest}, []string{stateTest}},
+		{[]string{filepath.Join("testdata", "statetest")}, []string{stateTest}},
+		{[]string{filepath.Join("testdata", "*test")}, []string{blockTest, stateTest}},
+		{[]string{filepath.Join("testdata", "*test", "*.json")}, []string{blockTest, stateTest}},
+		{[]string{stateTest, filepath.Join("testdata", "statetest"), blockTest}, []string{stateTest, blockTest}},
+		// Directories are searched for JSON files only
+		{[]string{filepath.Join("testdata", "2")}, []string{
+			filepath.Join("testdata", "2", "alloc.json"),
+			filepath.Join("testdata", "2", "env.json"),
+			filepath.Join("testdata", "2", "txs.json"),
+		}},
+	}
+	for i, tt := range tests {
+		files, err := collectTestFiles(tt.paths)
+		if err != nil {
+			t.Errorf("test %d: failed to collect test files: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(files, tt.files) {
+			t.Errorf("test %d: files mismatch: have %v, want %v", i, files, tt.files)
+		}
+	}
+	if _, err := collectTestFiles(nil); err == nil {
+		t.Errorf("missing paths accepted")
+	}
+	if _, err := collectTestFiles([]string{filepath.Join("testdata", "missing*")}); err == nil {
+		t.Errorf("unmatched path accepted")
+	}
+	if _, err := collectTestFiles([]string{"["}); err == nil {
+		t.Errorf("malformed glob accepted")
+	}
+}
+
+// corruptFixture copies a test fixture into a temporary directory, replacing
+// the given expected value to make the test fail.
+func corruptFixture(t *testing.T, fixture, old, new string) string {
+	blob, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(blob), old) {
+		t.Fatalf("fixture %s doesn't contain %s", fixture, old)
+	}
+	path := filepath.Join(t.TempDir(), filepath.Base(fixture))
+	if err := ioutil.WriteFile(path, []byte(strings.ReplaceAll(string(blob), old, new)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readJSONReport parses a JSON report written by a test command.
+func readJSONReport(t *testing.T, path string) *jsonReport {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read JSON report: %v", err)
+	}
+	report := new(jsonReport)
+	if err := json.Unmarshal(blob, report); err != nil {
+		t.Fatalf("failed to parse JSON report: %v", err)
+	}
+	return report
+}
+
+// readJUnitReport parses a JUnit report written by a test command.
+func readJUnitReport(t *testing.T, path string) *junitSuites {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read JUnit report: %v", err)
+	}
+	report := new(junitSuites)
+	if err := xml.Unmarshal(blob, report); err != nil {
+		t.Fatalf("failed to parse JUnit report: %v", err)
+	}
+	return report
+}
+
+func TestStateTestCommand(t *testing.T) {
+	var (
+		dir       = t.TempDir()
+		jsonPath  = filepath.Join(dir, "report.json")
+		junitPath = filepath.Join(dir, "report.xml")
+		fixture   = filepath.Join("testdata", "statetest", "transfer.json")
+	)
+	// Run all the state tests of the fixture directory, writing both reports
+	evm := runEvm(t, "statetest", "--report.json", jsonPath, "--report.junit", junitPath, filepath.Join("testdata", "statetest"))
+	evm.Expect(`
+[
+  {
+    "name": "transfer",
+    "pass": true,
+    "fork": "Berlin"
+  },
+  {
+    "name": "transfer",
+    "pass": true,
+    "fork": "Istanbul"
+  }
+]
+`)
+	evm.ExpectExit()
+	if status := evm.ExitStatus(); status != 0 {
+		t.Fatalf("exit status mismatch: have %d, want 0", status)
+	}
+	report := readJSONReport(t, jsonPath)
+	if report.Total != 2 || report.Passed != 2 || report.Failed != 0 {
+		t.Errorf("JSON report totals mismatch: have %d/%d/%d, want 2/2/0", report.Total, report.Passed, report.Failed)
+	}
+	for i, fork := range []string{"Berlin", "Istanbul"} {
+		if res := report.Results[i]; res.File != fixture || res.Name != "transfer" || res.Fork != fork || !res.Pass {
+			t.Errorf("JSON report result %d mismatch: %+v", i, res)
+		}
+	}
+	junit := readJUnitReport(t, junitPath)
+	if junit.Name != "statetest" || junit.Tests != 2 || junit.Failures != 0 || len(junit.Suites) != 1 {
+		t.Fatalf("JUnit report mismatch: %+v", junit)
+	}
+	if suite := junit.Suites[0]; suite.Name != fixture || len(suite.Cases) != 2 || suite.Cases[0].Name != "transfer/Berlin" || suite.Cases[1].Name != "transfer/Istanbul" {
+		t.Errorf("JUnit suite mismatch: %+v", suite)
+	}
+	// Filter the tests by fork and by name
+	evm = runEvm(t, "statetest", "--fork", "Istanbul", fixture)
+	evm.Expect(`
+[
+  {
+    "name": "transfer",
+    "pass": true,
+    "fork": "Istanbul"
+  }
+]
+`)
+	evm.ExpectExit()
+
+	evm = runEvm(t, "statetest", "--run", "^nomatch$", fixture)
+	evm.Expect(`
+[]
+`)
+	evm.ExpectExit()
+
+	// Fail a subtest, the command only exits non-zero if requested
+	failing := corruptFixture(t, fixture, "853ef6e3e2e96725fcbcc49f003674ac7636669043f79b74db40429fd932b00c", "0000000000000000000000000000000000000000000000000000000000000000")
+	evm = runEvm(t, "statetest", "--report.junit", junitPath, failing)
+	evm.ExpectRegexp(`"fork": "Berlin"\s*},\s*{\s*"name": "transfer",\s*"pass": false,\s*"fork": "Istanbul",\s*"error": "post state root mismatch[\s\S]*`)
+	evm.ExpectExit()
+	if status := evm.ExitStatus(); status != 0 {
+		t.Fatalf("exit status mismatch: have %d, want 0", status)
+	}
+	junit = readJUnitReport(t, junitPath)
+	if junit.Failures != 1 || junit.Suites[0].Cases[0].Failure != nil || junit.Suites[0].Cases[1].Failure == nil {
+		t.Errorf("JUnit failures mismatch: %+v", junit)
+	}
+	evm = runEvm(t, "statetest", "--exitcode", failing)
+	evm.ExpectRegexp(`"pass": false[\s\S]*`)
+	evm.ExpectExit()
+	if status := evm.ExitStatus(); status != 1 {
+		t.Fatalf("exit status mismatch: have %d, want 1", status)
+	}
+}
+
+func TestBlockTestCommand(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		jsonPath = filepath.Join(dir, "report.json")
+		fixture  = filepath.Join("testdata", "blocktest", "transfers.json")
+	)
+	// Run the blockchain tests matched by a glob
+	evm := runEvm(t, "blocktest", "--report.json", jsonPath, filepath.Join("testdata", "block*"))
+	evm.Expect(`
+[
+  {
+    "name": "transfers",
+    "pass": true,
+    "fork": "Istanbul"
+  }
+]
+`)
+	evm.ExpectExit()
+	if status := evm.ExitStatus(); status != 0 {
+		t.Fatalf("exit status mismatch: have %d, want 0", status)
+	}
+	report := readJSONReport(t, jsonPath)
+	if report.Total != 1 || report.Passed != 1 || len(report.Results) != 1 || report.Results[0].File != fixture {
+		t.Errorf("JSON report mismatch: %+v", report)
+	}
+	// Filter out the only test by fork
+	evm = runEvm(t, "blocktest", "--fork", "Berlin", fixture)
+	evm.Expect(`
+[]
+`)
+	evm.ExpectExit()
+
+	// Fail the test by expecting a different head
+	failing := corruptFixture(t, fixture, "d6e19565fe087c197727c0d1accbc5bcd7eb0e7bdba8dfedc5666e28d069798f", "0000000000000000000000000000000000000000000000000000000000000000")
+	evm = runEvm(t, "blocktest", "--report.json", jsonPath, "--exitcode", failing)
+	evm.ExpectRegexp(`"pass": false,\s*"fork": "Istanbul",\s*"error": "last block hash validation mismatch[\s\S]*`)
+	evm.ExpectExit()
+	if status := evm.ExitStatus(); status != 1 {
+		t.Fatalf("exit status mismatch: have %d, want 1", status)
+	}
+	report = readJSONReport(t, jsonPath)
+	if report.Total != 1 || report.Failed != 1 || report.Results[0].Error == "" {
+		t.Errorf("JSON report mismatch: %+v", report)
+	}
+	// Files which aren't blockchain tests are reported as failures
+	evm = runEvm(t, "blocktest", filepath.Join("testdata", "1", "txs.json"))
+	evm.ExpectRegexp(`"name": "txs.json",\s*"pass": false[\s\S]*`)
+	evm.ExpectExit()
+}
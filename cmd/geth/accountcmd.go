@@ -185,6 +185,50 @@ Note:
 As you can directly copy your encrypted accounts to another ethereum instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:   "newhd",
+				Usage:  "Create a new hierarchical deterministic wallet",
+				Action: utils.MigrateFlags(accountCreateHD),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				Description: `
+    geth account newhd
+
+Creates a new BIP-39 mnemonic backed wallet, and prints the mnemonic along with
+the address of its first account (m/44'/60'/0'/0/0).
+
+The wallet seed is saved in encrypted format in the keystore, you are prompted
+for a password. Further accounts can be derived with personal_deriveAccount.
+
+Write down the mnemonic, it is the only way to restore the wallet if the wallet
+file or the password is lost.
+`,
+			},
+			{
+				Name:   "importhd",
+				Usage:  "Import a BIP-39 mnemonic into a new hierarchical deterministic wallet",
+				Action: utils.MigrateFlags(accountImportHD),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				ArgsUsage: "<mnemonicFile>",
+				Description: `
+    geth account importhd <mnemonicfile>
+
+Imports the BIP-39 mnemonic from <mnemonicfile> into a new wallet and prints the
+address of its first account (m/44'/60'/0'/0/0).
+
+The wallet seed is saved in encrypted format in the keystore, you are prompted
+for a password.
 `,
 			},
 		},
@@ -352,3 +396,46 @@ func accountImport(ctx *cli.Context) error {
 	fmt.Printf("Address: {%x}\n", acct.Address)
 	return nil
 }
+
+// accountCreateHD creates a new HD wallet into the keystore defined by the CLI
+// flags.
+func accountCreateHD(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	passphrase := utils.GetPassPhraseWithList("Your new wallet is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	wallet, mnemonic, err := ks.NewHDWallet(passphrase)
+	if err != nil {
+		utils.Fatalf("Failed to create wallet: %v", err)
+	}
+	fmt.Printf("\nYour new wallet was generated\n\n")
+	fmt.Printf("Mnemonic of the wallet:        %s\n", mnemonic)
+	fmt.Printf("Public address of the account: %s\n", wallet.Accounts()[0].Address.Hex())
+	fmt.Printf("Path of the wallet file:       %s\n\n", wallet.URL().Path)
+	fmt.Printf("- You must NEVER share the mnemonic with anyone! It controls access to all accounts of the wallet!\n")
+	fmt.Printf("- You must BACKUP your mnemonic! Without it, it's impossible to restore the wallet!\n")
+	fmt.Printf("- You must REMEMBER your password! Without the password, it's impossible to decrypt the wallet file!\n\n")
+	return nil
+}
+
+// accountImportHD imports a BIP-39 mnemonic into a new HD wallet.
+func accountImportHD(ctx *cli.Context) error {
+	file := ctx.Args().First()
+	if len(file) == 0 {
+		utils.Fatalf("mnemonic file must be given as argument")
+	}
+	mnemonic, err := ioutil.ReadFile(file)
+	if err != nil {
+		utils.Fatalf("Failed to read the mnemonic: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	passphrase := utils.GetPassPhraseWithList("Your new wallet is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	wallet, err := ks.ImportMnemonic(string(mnemonic), passphrase)
+	if err != nil {
+		utils.Fatalf("Could not create the wallet: %v", err)
+	}
+	fmt.Printf("Address: {%x}\n", wallet.Accounts()[0].Address)
+	return nil
+}
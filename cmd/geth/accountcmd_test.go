@@ -125,6 +125,21 @@ func importAccountWithExpect(t *testing.T, key string, expected string) {
 	geth.Expect(expected)
 }
 
+func TestAccountImportHD(t *testing.T) {
+	dir := tmpdir(t)
+	mnemonicFile := filepath.Join(dir, "mnemonic.txt")
+	if err := ioutil.WriteFile(mnemonicFile, []byte("test test test test test test test test test test test junk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	passwordFile := filepath.Join(dir, "password.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("foobar"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	geth := runGeth(t, "account", "importhd", mnemonicFile, "--lightkdf", "--password", passwordFile)
+	defer geth.ExpectExit()
+	geth.Expect("Address: {f39fd6e51aad88f6f4ce6ab8827279cfffb92266}\n")
+}
+
 func TestAccountNewBadRepeat(t *testing.T) {
 	geth := runGeth(t, "account", "new", "--lightkdf")
 	defer geth.ExpectExit()
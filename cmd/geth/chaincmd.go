@@ -27,6 +27,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -93,6 +94,7 @@ The dumpgenesis command dumps the genesis block configuration in JSON format to
 			utils.MetricsInfluxDBPasswordFlag,
 			utils.MetricsInfluxDBTagsFlag,
 			utils.TxLookupLimitFlag,
+			utils.BulkImportFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -100,7 +102,11 @@ The import command imports blocks from an RLP-encoded form. The form can be one
 with several RLP-encoded blocks, or several files can be used.
 
 If only one file is used, import error will result in failure. If several files are used,
-processing will proceed even if an individual RLP-file import failure occurs.`,
+processing will proceed even if an individual RLP-file import failure occurs.
+
+With --bulk, the ethash seals of each batch are verified in parallel on all cores
+before insertion, while the verification caches of the upcoming epochs are generated
+in the background.`,
 	}
 	exportCommand = cli.Command{
 		Action:    utils.MigrateFlags(exportChain),
@@ -248,19 +254,35 @@ func importChain(ctx *cli.Context) error {
 			time.Sleep(5 * time.Second)
 		}
 	}()
+	// Set up the bulk seal verifier if requested
+	var verifier *ethash.BulkVerifier
+	if ctx.GlobalBool(utils.BulkImportFlag.Name) {
+		engine, ok := chain.Engine().(*ethash.Ethash)
+		if !ok {
+			utils.Fatalf("Bulk import requires the ethash consensus engine")
+		}
+		verifier = engine.NewBulkVerifier(0)
+		defer verifier.Close()
+	}
+	importFile := func(fn string) error {
+		if verifier != nil {
+			return utils.ImportChainBulk(chain, fn, verifier)
+		}
+		return utils.ImportChain(chain, fn)
+	}
 	// Import the chain
 	start := time.Now()
 
 	var importErr error
 
 	if len(ctx.Args()) == 1 {
-		if err := utils.ImportChain(chain, ctx.Args().First()); err != nil {
+		if err := importFile(ctx.Args().First()); err != nil {
 			importErr = err
 			log.Error("Import error", "err", err)
 		}
 	} else {
 		for _, arg := range ctx.Args() {
-			if err := utils.ImportChain(chain, arg); err != nil {
+			if err := importFile(arg); err != nil {
 				importErr = err
 				log.Error("Import error", "file", arg, "err", err)
 			}
@@ -269,6 +291,12 @@ func importChain(ctx *cli.Context) error {
 	chain.Stop()
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
+	if verifier != nil {
+		if verified, elapsed := verifier.Stats(); verified > 0 {
+			fmt.Printf("Seals verified: %d in %v (%.2f/s)\n\n", verified, elapsed, float64(verified)/elapsed.Seconds())
+		}
+	}
+
 	// Output pre-compaction stats mostly to see the import trashing
 	showLeveldbStats(db)
 
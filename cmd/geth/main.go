@@ -87,6 +87,8 @@ var (
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
 		utils.SyncModeFlag,
+		utils.SyncAnchorFlag,
+		utils.CliqueConfirmationsFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
@@ -94,6 +96,11 @@ var (
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
+		utils.SnapServeWindowFlag,
+		utils.SnapServePeerBytesFlag,
+		utils.SnapServePeerReadsFlag,
+		utils.SnapServeTotalBytesFlag,
+		utils.SnapServeTotalReadsFlag,
 		utils.LightMaxPeersFlag,
 		utils.LightNoPruneFlag,
 		utils.LightKDFFlag,
@@ -182,6 +189,8 @@ var (
 		utils.InsecureUnlockAllowedFlag,
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCLogBlockRangeFlag,
+		utils.RPCLogResultCapFlag,
 		utils.AllowUnprotectedTxs,
 	}
 
@@ -47,6 +47,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.YoloV3Flag,
 			utils.RopstenFlag,
 			utils.SyncModeFlag,
+			utils.SyncAnchorFlag,
+			utils.CliqueConfirmationsFlag,
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
 			utils.TxLookupLimitFlag,
@@ -70,6 +72,16 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.LightNoSyncServeFlag,
 		},
 	},
+	{
+		Name: "SNAP SERVER",
+		Flags: []cli.Flag{
+			utils.SnapServeWindowFlag,
+			utils.SnapServePeerBytesFlag,
+			utils.SnapServePeerReadsFlag,
+			utils.SnapServeTotalBytesFlag,
+			utils.SnapServeTotalReadsFlag,
+		},
+	},
 	{
 		Name: "DEVELOPER CHAIN",
 		Flags: []cli.Flag{
@@ -152,6 +164,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.GraphQLVirtualHostsFlag,
 			utils.RPCGlobalGasCapFlag,
 			utils.RPCGlobalTxFeeCapFlag,
+			utils.RPCLogBlockRangeFlag,
+			utils.RPCLogResultCapFlag,
 			utils.AllowUnprotectedTxs,
 			utils.JSpathFlag,
 			utils.ExecFlag,
@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -118,6 +119,17 @@ func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritica
 }
 
 func ImportChain(chain *core.BlockChain, fn string) error {
+	return importChain(chain, fn, nil)
+}
+
+// ImportChainBulk imports a chain file like ImportChain, but verifies the block
+// seals of each batch in parallel with the given verifier before inserting it,
+// so the chain insertion itself doesn't need to.
+func ImportChainBulk(chain *core.BlockChain, fn string, verifier *ethash.BulkVerifier) error {
+	return importChain(chain, fn, verifier)
+}
+
+func importChain(chain *core.BlockChain, fn string, verifier *ethash.BulkVerifier) error {
 	// Watch for Ctrl-C while the import is running.
 	// If a signal is received, the import will stop at the next batch.
 	interrupt := make(chan os.Signal, 1)
@@ -193,6 +205,19 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 			log.Info("Skipping batch as all blocks present", "batch", batch, "first", blocks[0].Hash(), "last", blocks[i-1].Hash())
 			continue
 		}
+		if verifier != nil {
+			headers := make([]*types.Header, len(missing))
+			for j, block := range missing {
+				headers[j] = block.Header()
+			}
+			start := time.Now()
+			if err := verifier.Verify(headers, stop); err != nil {
+				return fmt.Errorf("invalid seal in batch %d: %v", batch, err)
+			}
+			verified, elapsed := verifier.Stats()
+			log.Info("Verified block seals", "batch", batch, "count", len(headers), "elapsed", common.PrettyDuration(time.Since(start)),
+				"total", verified, "rate", fmt.Sprintf("%.2f/s", float64(verified)/elapsed.Seconds()))
+		}
 		if _, err := chain.InsertChain(missing); err != nil {
 			return fmt.Errorf("invalid block %d: %v", n, err)
 		}
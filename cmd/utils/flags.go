@@ -38,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/bft"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
@@ -206,6 +207,14 @@ var (
 		Usage: `Blockchain sync mode ("fast", "full", "snap" or "light")`,
 		Value: &defaultSyncMode,
 	}
+	SyncAnchorFlag = cli.StringFlag{
+		Name:  "syncanchor",
+		Usage: "Trusted block hash to anchor fast/snap sync on, skipping header verification below it",
+	}
+	CliqueConfirmationsFlag = cli.Uint64Flag{
+		Name:  "clique.confirmations",
+		Usage: "Number of confirmations after which clique blocks are reported as finalized, half as many for safe (0 = disabled)",
+	}
 	GCModeFlag = cli.StringFlag{
 		Name:  "gcmode",
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
@@ -215,6 +224,10 @@ var (
 		Name:  "snapshot",
 		Usage: `Enables snapshot-database mode (default = enable)`,
 	}
+	BulkImportFlag = cli.BoolFlag{
+		Name:  "bulk",
+		Usage: "Verify the block seals of imported chain files in parallel ahead of insertion (ethash only)",
+	}
 	TxLookupLimitFlag = cli.Uint64Flag{
 		Name:  "txlookuplimit",
 		Usage: "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
@@ -280,6 +293,32 @@ var (
 		Name:  "light.nosyncserve",
 		Usage: "Enables serving light clients before syncing",
 	}
+	// Snap server settings
+	SnapServeWindowFlag = cli.DurationFlag{
+		Name:  "snap.serve.window",
+		Usage: "Time window over which the snap serving budgets are recharged",
+		Value: ethconfig.Defaults.SnapServe.Window,
+	}
+	SnapServePeerBytesFlag = cli.Uint64Flag{
+		Name:  "snap.serve.peerbytes",
+		Usage: "Megabytes of snap data served to a single peer per window (0 = unlimited)",
+		Value: ethconfig.Defaults.SnapServe.PeerBytes / 1024 / 1024,
+	}
+	SnapServePeerReadsFlag = cli.Uint64Flag{
+		Name:  "snap.serve.peerreads",
+		Usage: "Database reads for serving snap data to a single peer per window (0 = unlimited)",
+		Value: ethconfig.Defaults.SnapServe.PeerReads,
+	}
+	SnapServeTotalBytesFlag = cli.Uint64Flag{
+		Name:  "snap.serve.totalbytes",
+		Usage: "Megabytes of snap data served to all peers per window (0 = unlimited)",
+		Value: ethconfig.Defaults.SnapServe.TotalBytes / 1024 / 1024,
+	}
+	SnapServeTotalReadsFlag = cli.Uint64Flag{
+		Name:  "snap.serve.totalreads",
+		Usage: "Database reads for serving snap data to all peers per window (0 = unlimited)",
+		Value: ethconfig.Defaults.SnapServe.TotalReads,
+	}
 	// Ethash settings
 	EthashCacheDirFlag = DirectoryFlag{
 		Name:  "ethash.cachedir",
@@ -501,6 +540,16 @@ var (
 		Usage: "Sets a cap on transaction fee (in ether) that can be sent via the RPC APIs (0 = no cap)",
 		Value: ethconfig.Defaults.RPCTxFeeCap,
 	}
+	RPCLogBlockRangeFlag = cli.Uint64Flag{
+		Name:  "rpc.logblockrange",
+		Usage: "Sets a cap on the number of blocks a single log query may span (0 = no cap)",
+		Value: ethconfig.Defaults.RPCLogBlockRange,
+	}
+	RPCLogResultCapFlag = cli.Uint64Flag{
+		Name:  "rpc.logresultcap",
+		Usage: "Sets a cap on the number of logs a single log query may return (0 = no cap)",
+		Value: ethconfig.Defaults.RPCLogResultCap,
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -1049,6 +1098,28 @@ func setLes(ctx *cli.Context, cfg *ethconfig.Config) {
 	}
 }
 
+// setSnapServe configures the snap serving limits from the command line flags.
+func setSnapServe(ctx *cli.Context, cfg *ethconfig.Config) {
+	if ctx.GlobalIsSet(SnapServeWindowFlag.Name) {
+		if window := ctx.GlobalDuration(SnapServeWindowFlag.Name); window <= 0 {
+			Fatalf("Option %q: window must be positive, got %v", SnapServeWindowFlag.Name, window)
+		}
+		cfg.SnapServe.Window = ctx.GlobalDuration(SnapServeWindowFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapServePeerBytesFlag.Name) {
+		cfg.SnapServe.PeerBytes = ctx.GlobalUint64(SnapServePeerBytesFlag.Name) * 1024 * 1024
+	}
+	if ctx.GlobalIsSet(SnapServePeerReadsFlag.Name) {
+		cfg.SnapServe.PeerReads = ctx.GlobalUint64(SnapServePeerReadsFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapServeTotalBytesFlag.Name) {
+		cfg.SnapServe.TotalBytes = ctx.GlobalUint64(SnapServeTotalBytesFlag.Name) * 1024 * 1024
+	}
+	if ctx.GlobalIsSet(SnapServeTotalReadsFlag.Name) {
+		cfg.SnapServe.TotalReads = ctx.GlobalUint64(SnapServeTotalReadsFlag.Name)
+	}
+}
+
 // MakeDatabaseHandles raises out the number of allowed file handles per process
 // for Geth and returns half of the allowance to assign to the database.
 func MakeDatabaseHandles() int {
@@ -1481,6 +1552,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setMiner(ctx, &cfg.Miner)
 	setWhitelist(ctx, cfg)
 	setLes(ctx, cfg)
+	setSnapServe(ctx, cfg)
 
 	// Cap the cache allowance and tune the garbage collector
 	mem, err := gopsutil.VirtualMemory()
@@ -1505,6 +1577,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.GlobalIsSet(SyncAnchorFlag.Name) {
+		anchor := ctx.GlobalString(SyncAnchorFlag.Name)
+		if err := cfg.SyncAnchor.UnmarshalText([]byte(anchor)); err != nil {
+			Fatalf("Invalid sync anchor hash %s: %v", anchor, err)
+		}
+	}
+	if ctx.GlobalIsSet(CliqueConfirmationsFlag.Name) {
+		cfg.CliqueConfirmations = ctx.GlobalUint64(CliqueConfirmationsFlag.Name)
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -1584,6 +1665,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.GlobalFloat64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.GlobalIsSet(RPCLogBlockRangeFlag.Name) {
+		cfg.RPCLogBlockRange = ctx.GlobalUint64(RPCLogBlockRangeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogResultCapFlag.Name) {
+		cfg.RPCLogResultCap = ctx.GlobalUint64(RPCLogResultCapFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
@@ -1836,6 +1923,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	var engine consensus.Engine
 	if config.Clique != nil {
 		engine = clique.New(config.Clique, chainDb)
+	} else if config.BFT != nil {
+		engine = bft.New(config.BFT, chainDb)
 	} else {
 		engine = ethash.NewFaker()
 		if !ctx.GlobalBool(FakePoWFlag.Name) {
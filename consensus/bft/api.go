@@ -0,0 +1,142 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Status is the state of the consensus on the height being decided.
+type Status struct {
+	Height      uint64 `json:"height"`
+	Round       uint64 `json:"round"`
+	Step        string `json:"step"`
+	LockedRound int64  `json:"lockedRound"`
+	Peers       int    `json:"peers"`
+}
+
+// Finality is the proof of a block being final: the precommit signatures of a
+// quorum of the validators.
+type Finality struct {
+	Number  hexutil.Uint64   `json:"number"`
+	Hash    common.Hash      `json:"hash"`
+	Round   hexutil.Uint64   `json:"round"`
+	Signers []common.Address `json:"signers"`
+}
+
+// Finality retrieves the proof of a block being final, either from the commits
+// sealed into its child or from the ones collected locally. It returns nil if
+// the block is not known to be final.
+func (b *BFT) Finality(chain consensus.ChainHeaderReader, header *types.Header) (*Finality, error) {
+	validators, err := b.setup(chain)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		number = header.Number.Uint64()
+		hash   = header.Hash()
+	)
+	// The genesis block is final by definition
+	if number == 0 {
+		return &Finality{Hash: hash}, nil
+	}
+	var commit *commitRecord
+	if child := chain.GetHeaderByNumber(number + 1); child != nil && child.ParentHash == hash {
+		ext, err := decodeExtra(child)
+		if err != nil {
+			return nil, err
+		}
+		commit = &commitRecord{Round: ext.CommitRound, Signatures: ext.Commits}
+	} else if commit = b.commit(hash); commit == nil {
+		return nil, nil
+	}
+	signers, err := verifyCommits(validators, number, commit.Round, hash, commit.Signatures)
+	if err != nil {
+		return nil, err
+	}
+	return &Finality{
+		Number:  hexutil.Uint64(number),
+		Hash:    hash,
+		Round:   hexutil.Uint64(commit.Round),
+		Signers: signers,
+	}, nil
+}
+
+// FinalizedHeader retrieves the most recent block of the local chain known to be
+// final. That's the head if its commit was collected locally, or its parent,
+// whose commit is sealed into the head.
+func (b *BFT) FinalizedHeader(chain consensus.ChainHeaderReader) *types.Header {
+	head := chain.CurrentHeader()
+	if head.Number.Uint64() == 0 || b.commit(head.Hash()) != nil {
+		return head
+	}
+	return chain.GetHeader(head.ParentHash, head.Number.Uint64()-1)
+}
+
+// API is a user facing RPC API to query the validators, the state of the
+// consensus and the finality of blocks.
+type API struct {
+	chain consensus.ChainHeaderReader
+	bft   *BFT
+}
+
+// GetValidators retrieves the list of validators.
+func (api *API) GetValidators() ([]common.Address, error) {
+	return api.bft.setup(api.chain)
+}
+
+// GetStatus retrieves the state of the consensus on the height being decided,
+// or nil if the local node didn't take part in the consensus yet.
+func (api *API) GetStatus() *Status {
+	ch := make(chan *Status, 1)
+	select {
+	case api.bft.statusCh <- ch:
+		return <-ch
+	case <-api.bft.quit:
+		return nil
+	}
+}
+
+// GetFinalized retrieves the most recent block known to be final.
+//
+// The commits of a block are only carried by its child, so unless the local node
+// collected the commit of the head itself while taking part in the consensus,
+// the head is not known to be final and its parent is returned instead.
+func (api *API) GetFinalized() *types.Header {
+	return api.bft.FinalizedHeader(api.chain)
+}
+
+// GetFinality retrieves the proof of the specified block being final, or nil if
+// the block is not known to be final yet. As the commits of a block are only
+// carried by its child, that's usually the case for the head block.
+func (api *API) GetFinality(number *rpc.BlockNumber) (*Finality, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return api.bft.Finality(api.chain, header)
+}
@@ -0,0 +1,717 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bft implements a round based byzantine fault tolerant consensus engine
+// with instant finality.
+//
+// A fixed set of validators, listed in the genesis block, agrees on every block
+// in rounds of propose, prevote and precommit steps. A block is final as soon as
+// more than two thirds of the validators precommit it. The precommit signatures
+// of a block are sealed into the extra-data of its child, so the finality of any
+// block with a child can be verified from the chain alone.
+package bft
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	inmemoryCommits  = 128  // Number of recent block commits to keep in memory
+	inmemoryMessages = 4096 // Number of recent consensus messages to remember as known
+
+	defaultTimeout = 3000 // Default base timeout of a round step in milliseconds
+)
+
+// BFT protocol constants.
+var (
+	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for validator vanity
+
+	blockDifficulty = big.NewInt(1) // Block difficulty, each height has exactly one final block
+
+	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+
+	commitPrefix  = []byte("bft-commit-")     // Database prefix of the block commits
+	roundStateKey = []byte("bft-round-state") // Database key of the local node's state of the current height
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of validators is requested for a
+	// block that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errMissingVanity is returned if a block's extra-data section is shorter than
+	// 32 bytes, which is required to store the validator vanity.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// errInvalidExtra is returned if the consensus fields in a block's extra-data
+	// section can't be decoded.
+	errInvalidExtra = errors.New("invalid consensus extra-data")
+
+	// errMissingValidators is returned if the genesis block doesn't list any
+	// validators.
+	errMissingValidators = errors.New("genesis block without validators")
+
+	// errExtraValidators is returned if a non-genesis block lists validators.
+	errExtraValidators = errors.New("non-genesis block contains validator list")
+
+	// errInvalidMixDigest is returned if a block's mix digest is non-zero.
+	errInvalidMixDigest = errors.New("non-zero mix digest")
+
+	// errInvalidNonce is returned if a block's nonce is non-zero.
+	errInvalidNonce = errors.New("non-zero nonce")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errUnauthorizedValidator is returned if a header is sealed, or a consensus
+	// message is signed, by a non-validator.
+	errUnauthorizedValidator = errors.New("unauthorized validator")
+
+	// errInsufficientCommits is returned if a block doesn't carry precommits for
+	// its parent from a quorum of the validators.
+	errInsufficientCommits = errors.New("insufficient parent commits")
+
+	// errDuplicateCommit is returned if a block carries multiple precommits for
+	// its parent from the same validator.
+	errDuplicateCommit = errors.New("duplicate parent commit")
+
+	// errMissingParentCommits is returned when preparing a block whose parent's
+	// commit is not known locally.
+	errMissingParentCommits = errors.New("parent commits unknown")
+
+	// errUnexpectedCommits is returned if the first block after genesis carries
+	// parent commits.
+	errUnexpectedCommits = errors.New("commits for genesis block")
+)
+
+// SignerFn hashes and signs the data to be signed by a backing account.
+type SignerFn func(signer accounts.Account, mimeType string, message []byte) ([]byte, error)
+
+// extra is the consensus data stored in a header's extra-data after the vanity.
+type extra struct {
+	Validators  []common.Address // Validator set, only present in the genesis block
+	CommitRound uint64           // Consensus round in which the parent got committed
+	Commits     [][]byte         // Precommit signatures of the parent from a quorum of validators
+	Seal        []byte           // Signature of the block creator over the seal hash
+}
+
+// decodeExtra extracts the consensus data from a header's extra-data.
+func decodeExtra(header *types.Header) (*extra, error) {
+	if len(header.Extra) < extraVanity {
+		return nil, errMissingVanity
+	}
+	ext := new(extra)
+	if err := rlp.DecodeBytes(header.Extra[extraVanity:], ext); err != nil {
+		return nil, errInvalidExtra
+	}
+	return ext, nil
+}
+
+// encodeExtra assembles the extra-data of a header from the vanity and the
+// consensus data.
+func encodeExtra(vanity []byte, ext *extra) ([]byte, error) {
+	blob, err := rlp.EncodeToBytes(ext)
+	if err != nil {
+		return nil, err
+	}
+	extra := make([]byte, extraVanity, extraVanity+len(blob))
+	if len(vanity) > extraVanity {
+		vanity = vanity[:extraVanity]
+	}
+	copy(extra, vanity)
+	return append(extra, blob...), nil
+}
+
+// GenesisExtra assembles the extra-data of a genesis block for the given
+// validator set.
+func GenesisExtra(vanity []byte, validators []common.Address) ([]byte, error) {
+	return encodeExtra(vanity, &extra{Validators: validators})
+}
+
+// SealHash returns the hash of a block prior to it being sealed, which is the
+// hash of the header with the creator's seal removed from the extra-data.
+func SealHash(header *types.Header) common.Hash {
+	cpy := types.CopyHeader(header)
+	if ext, err := decodeExtra(header); err == nil {
+		ext.Seal = nil
+		if blob, err := encodeExtra(header.Extra, ext); err == nil {
+			cpy.Extra = blob
+		}
+	}
+	return rlpHash(cpy)
+}
+
+// ecrecover extracts the Ethereum account address from a signed payload.
+func ecrecover(payload []byte, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, errInvalidSignature
+	}
+	pubkey, err := crypto.SigToPub(crypto.Keccak256(payload), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// BFT is the round based byzantine fault tolerant consensus engine.
+type BFT struct {
+	config *params.BFTConfig // Consensus engine configuration parameters
+	db     ethdb.Database    // Database to store and retrieve block commits
+
+	validators []common.Address // Validator set loaded from the genesis block
+	chain      consensus.ChainHeaderReader
+	setupLock  sync.RWMutex // Protects the validator set and the chain
+
+	commits *lru.ARCCache // Commits of recent blocks to seal into their children
+
+	signer common.Address // Ethereum address of the signing key
+	signFn SignerFn       // Signer function to authorize messages with
+	lock   sync.RWMutex   // Protects the signer fields
+
+	peers *peerSet   // Peers running the consensus protocol
+	known *lru.Cache // Hashes of recently seen consensus messages
+
+	committed event.Feed // Blocks committed by the consensus but not sealed locally
+	scope     event.SubscriptionScope
+
+	submitCh  chan *sealTask
+	messageCh chan *message
+	timeoutCh chan timeout
+	peerCh    chan *peer
+	statusCh  chan chan *Status
+	quit      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// New creates a BFT consensus engine and starts its consensus loop. The validator
+// set is read from the genesis block upon first use.
+func New(config *params.BFTConfig, db ethdb.Database) *BFT {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Timeout == 0 {
+		conf.Timeout = defaultTimeout
+	}
+	commits, _ := lru.NewARC(inmemoryCommits)
+	known, _ := lru.New(inmemoryMessages)
+
+	b := &BFT{
+		config:    &conf,
+		db:        db,
+		commits:   commits,
+		peers:     newPeerSet(),
+		known:     known,
+		submitCh:  make(chan *sealTask),
+		messageCh: make(chan *message, 256),
+		timeoutCh: make(chan timeout),
+		peerCh:    make(chan *peer),
+		statusCh:  make(chan chan *Status),
+		quit:      make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+// Authorize injects a private key into the consensus engine to validate and
+// propose blocks with.
+func (b *BFT) Authorize(signer common.Address, signFn SignerFn) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.signer = signer
+	b.signFn = signFn
+}
+
+// SubscribeCommittedBlocks subscribes to the blocks committed by the consensus
+// which were proposed by other validators, so they can be imported without
+// waiting for their propagation.
+func (b *BFT) SubscribeCommittedBlocks(ch chan<- *types.Block) event.Subscription {
+	return b.scope.Track(b.committed.Subscribe(ch))
+}
+
+// setup retrieves the validator set from the genesis block of the chain, also
+// remembering the chain for validating consensus messages.
+func (b *BFT) setup(chain consensus.ChainHeaderReader) ([]common.Address, error) {
+	b.setupLock.RLock()
+	validators := b.validators
+	b.setupLock.RUnlock()
+
+	if validators != nil {
+		return validators, nil
+	}
+	genesis := chain.GetHeaderByNumber(0)
+	if genesis == nil {
+		return nil, errUnknownBlock
+	}
+	ext, err := decodeExtra(genesis)
+	if err != nil {
+		return nil, err
+	}
+	if len(ext.Validators) == 0 {
+		return nil, errMissingValidators
+	}
+	b.setupLock.Lock()
+	b.validators, b.chain = ext.Validators, chain
+	b.setupLock.Unlock()
+
+	return ext.Validators, nil
+}
+
+// validatorSet returns the validator set and the chain if already set up.
+func (b *BFT) validatorSet() ([]common.Address, consensus.ChainHeaderReader) {
+	b.setupLock.RLock()
+	defer b.setupLock.RUnlock()
+
+	return b.validators, b.chain
+}
+
+// Author implements consensus.Engine, returning the Ethereum address recovered
+// from the creator's seal in the header's extra-data section.
+func (b *BFT) Author(header *types.Header) (common.Address, error) {
+	ext, err := decodeExtra(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return ecrecover(SealHash(header).Bytes(), ext.Seal)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules.
+func (b *BFT) VerifyHeader(chain consensus.ChainHeaderReader, header *types.Header, seal bool) error {
+	return b.verifyHeader(chain, header, nil)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (b *BFT) VerifyHeaders(chain consensus.ChainHeaderReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := b.verifyHeader(chain, header, headers[:i])
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database.
+func (b *BFT) verifyHeader(chain consensus.ChainHeaderReader, header *types.Header, parents []*types.Header) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Don't waste time checking blocks from the future
+	if header.Time > uint64(time.Now().Unix()) {
+		return consensus.ErrFutureBlock
+	}
+	ext, err := decodeExtra(header)
+	if err != nil {
+		return err
+	}
+	// Only the genesis block may (and must) define the validator set
+	if number > 0 && len(ext.Validators) > 0 {
+		return errExtraValidators
+	}
+	// Ensure that the PoW and uncle related fields are all empty
+	if header.MixDigest != (common.Hash{}) {
+		return errInvalidMixDigest
+	}
+	if header.Nonce != (types.BlockNonce{}) {
+		return errInvalidNonce
+	}
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	if number > 0 && (header.Difficulty == nil || header.Difficulty.Cmp(blockDifficulty) != 0) {
+		return errInvalidDifficulty
+	}
+	// If all checks passed, validate any special fields for hard forks
+	if err := misc.VerifyForkHashes(chain.Config(), header, false); err != nil {
+		return err
+	}
+	// All basic checks passed, verify cascading fields
+	return b.verifyCascadingFields(chain, header, ext, parents)
+}
+
+// verifyCascadingFields verifies all the header fields that are not standalone,
+// rather depend on the parent and the validator set.
+func (b *BFT) verifyCascadingFields(chain consensus.ChainHeaderReader, header *types.Header, ext *extra, parents []*types.Header) error {
+	// The genesis block is the always valid dead-end
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil
+	}
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time+b.config.Period > header.Time {
+		return errInvalidTimestamp
+	}
+	validators, err := b.setup(chain)
+	if err != nil {
+		return err
+	}
+	// Ensure the parent was committed by a quorum of the validators
+	if number == 1 {
+		if len(ext.Commits) > 0 {
+			return errUnexpectedCommits
+		}
+	} else if _, err := verifyCommits(validators, number-1, ext.CommitRound, header.ParentHash, ext.Commits); err != nil {
+		return err
+	}
+	// Ensure the block was created by one of the validators
+	creator, err := ecrecover(SealHash(header).Bytes(), ext.Seal)
+	if err != nil {
+		return err
+	}
+	if !isValidator(validators, creator) {
+		return errUnauthorizedValidator
+	}
+	return nil
+}
+
+// verifyCommits checks that the precommit signatures of a block come from a
+// quorum of distinct validators, returning the signers.
+func verifyCommits(validators []common.Address, number uint64, round uint64, hash common.Hash, commits [][]byte) ([]common.Address, error) {
+	payload := voteData(PrecommitMsg, number, round, hash)
+
+	signers := make([]common.Address, 0, len(commits))
+	for _, sig := range commits {
+		signer, err := ecrecover(payload, sig)
+		if err != nil {
+			return nil, err
+		}
+		if !isValidator(validators, signer) {
+			return nil, errUnauthorizedValidator
+		}
+		for _, seen := range signers {
+			if seen == signer {
+				return nil, errDuplicateCommit
+			}
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) < quorum(len(validators)) {
+		return nil, errInsufficientCommits
+	}
+	return signers, nil
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (b *BFT) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (b *BFT) Prepare(chain consensus.ChainHeaderReader, header *types.Header) error {
+	number := header.Number.Uint64()
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Nonce = types.BlockNonce{}
+	header.MixDigest = common.Hash{}
+	header.Difficulty = new(big.Int).Set(blockDifficulty)
+
+	// Seal the commit of the parent into the header
+	ext := new(extra)
+	if number > 1 {
+		commit := b.commit(header.ParentHash)
+		if commit == nil {
+			return errMissingParentCommits
+		}
+		ext.CommitRound, ext.Commits = commit.Round, commit.Signatures
+	}
+	blob, err := encodeExtra(header.Extra, ext)
+	if err != nil {
+		return err
+	}
+	header.Extra = blob
+
+	// Ensure the timestamp has the correct delay
+	header.Time = parent.Time + b.config.Period
+	if header.Time < uint64(time.Now().Unix()) {
+		header.Time = uint64(time.Now().Unix())
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given.
+func (b *BFT) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header) {
+	// No block rewards in BFT, so the state remains as is and uncles are dropped
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+}
+
+// FinalizeAndAssemble implements consensus.Engine, ensuring no uncles are set,
+// nor block rewards given, and returns the final block.
+func (b *BFT) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	// Finalize block
+	b.Finalize(chain, header, state, txs, uncles)
+
+	// Assemble and return the final block for sealing
+	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil)), nil
+}
+
+// Seal implements consensus.Engine, signing the block with the local validator
+// key and offering it to the consensus as the local proposal for its height.
+// The block is delivered on the results channel once the validators commit it.
+func (b *BFT) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	validators, err := b.setup(chain)
+	if err != nil {
+		return err
+	}
+	// Don't hold the signer fields for the entire sealing procedure
+	b.lock.RLock()
+	signer, signFn := b.signer, b.signFn
+	b.lock.RUnlock()
+
+	if !isValidator(validators, signer) {
+		return errUnauthorizedValidator
+	}
+	// Sign the block and wait until its time is due before offering it
+	ext, err := decodeExtra(header)
+	if err != nil {
+		return err
+	}
+	sig, err := signFn(accounts.Account{Address: signer}, accounts.MimetypeBFT, SealHash(header).Bytes())
+	if err != nil {
+		return err
+	}
+	ext.Seal = sig
+	if header.Extra, err = encodeExtra(header.Extra, ext); err != nil {
+		return err
+	}
+	task := &sealTask{
+		block:   block.WithSeal(header),
+		results: results,
+		stop:    stop,
+	}
+	delay := time.Unix(int64(header.Time), 0).Sub(time.Now()) // nolint: gosimple
+	go func() {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		select {
+		case b.submitCh <- task:
+		case <-stop:
+		case <-b.quit:
+		}
+	}()
+	return nil
+}
+
+// SealHash returns the hash of a block prior to it being sealed.
+func (b *BFT) SealHash(header *types.Header) common.Hash {
+	return SealHash(header)
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
+// that a new block should have, which is constant as every block is final.
+func (b *BFT) CalcDifficulty(chain consensus.ChainHeaderReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(blockDifficulty)
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to query
+// the validators and the finality of blocks.
+func (b *BFT) APIs(chain consensus.ChainHeaderReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "bft",
+		Version:   "1.0",
+		Service:   &API{chain: chain, bft: b},
+		Public:    false,
+	}}
+}
+
+// Close implements consensus.Engine, terminating the consensus loop and
+// disconnecting the consensus peers.
+func (b *BFT) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.quit)
+		b.wg.Wait()
+		b.scope.Close()
+	})
+	return nil
+}
+
+// commitRecord is the precommit quorum a block got committed with.
+type commitRecord struct {
+	Round      uint64
+	Signatures [][]byte
+}
+
+// commit retrieves the commit of a block from memory or the database.
+func (b *BFT) commit(hash common.Hash) *commitRecord {
+	if commit, ok := b.commits.Get(hash); ok {
+		return commit.(*commitRecord)
+	}
+	blob, err := b.db.Get(append(commitPrefix, hash[:]...))
+	if err != nil {
+		return nil
+	}
+	commit := new(commitRecord)
+	if err := rlp.DecodeBytes(blob, commit); err != nil {
+		log.Error("Invalid block commit in database", "hash", hash, "err", err)
+		return nil
+	}
+	b.commits.Add(hash, commit)
+	return commit
+}
+
+// storeCommit persists the commit of a block, needed to seal it into the child.
+func (b *BFT) storeCommit(hash common.Hash, commit *commitRecord) {
+	b.commits.Add(hash, commit)
+
+	blob, err := rlp.EncodeToBytes(commit)
+	if err != nil {
+		log.Crit("Failed to encode block commit", "err", err)
+	}
+	if err := b.db.Put(append(commitPrefix, hash[:]...), blob); err != nil {
+		log.Crit("Failed to store block commit", "err", err)
+	}
+}
+
+// signedMessage is a consensus message signed by the local node, in wire form.
+type signedMessage struct {
+	Code    uint64
+	Payload []byte
+}
+
+// roundState is the part of the consensus state of a height which must survive
+// restarts, so that the local node never contradicts its earlier messages: the
+// block it is locked on and the messages it signed.
+type roundState struct {
+	Height      uint64
+	LockedRound uint64       // One plus the round the node locked in, zero if not locked
+	LockedBlock *types.Block `rlp:"nil"`
+	Messages    []signedMessage
+}
+
+// roundState retrieves the persisted consensus state of the given height, or nil
+// if none was stored for it.
+func (b *BFT) roundState(height uint64) *roundState {
+	blob, err := b.db.Get(roundStateKey)
+	if err != nil {
+		return nil
+	}
+	state := new(roundState)
+	if err := rlp.DecodeBytes(blob, state); err != nil {
+		log.Error("Invalid consensus state in database", "err", err)
+		return nil
+	}
+	if state.Height != height {
+		return nil
+	}
+	return state
+}
+
+// storeRoundState persists the consensus state of the current height. It must be
+// called before any message signed by the local node is sent out.
+func (b *BFT) storeRoundState(state *roundState) {
+	blob, err := rlp.EncodeToBytes(state)
+	if err != nil {
+		log.Crit("Failed to encode consensus state", "err", err)
+	}
+	if err := b.db.Put(roundStateKey, blob); err != nil {
+		log.Crit("Failed to store consensus state", "err", err)
+	}
+}
+
+// quorum returns the minimum number of validators that need to agree for a
+// decision, i.e. more than two thirds of them.
+func quorum(validators int) int {
+	return validators*2/3 + 1
+}
+
+// isValidator reports whether the address is part of the validator set.
+func isValidator(validators []common.Address, address common.Address) bool {
+	for _, validator := range validators {
+		if validator == address {
+			return true
+		}
+	}
+	return false
+}
+
+// rlpHash encodes x and hashes the encoded bytes.
+func rlpHash(x interface{}) (h common.Hash) {
+	hasher := crypto.NewKeccakState()
+	rlp.Encode(hasher, x)
+	hasher.Read(h[:])
+	return h
+}
@@ -0,0 +1,426 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestChain creates a blockchain whose genesis block lists the validators,
+// along with the consensus engine running it.
+func newTestChain(t *testing.T, validators []common.Address, config *params.BFTConfig) (*BFT, *core.BlockChain) {
+	t.Helper()
+
+	chainConfig := *params.TestChainConfig
+	chainConfig.Ethash, chainConfig.BFT = nil, config
+
+	extra, err := GenesisExtra(nil, validators)
+	if err != nil {
+		t.Fatalf("failed to create genesis extra-data: %v", err)
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis := &core.Genesis{Config: &chainConfig, ExtraData: extra, GasLimit: params.GenesisGasLimit}
+	genesis.MustCommit(db)
+
+	engine := New(config, db)
+	chain, err := core.NewBlockChain(db, nil, &chainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return engine, chain
+}
+
+// signerFn creates a signing function backed by a private key.
+func signerFn(key *ecdsa.PrivateKey) SignerFn {
+	return func(signer accounts.Account, mimeType string, message []byte) ([]byte, error) {
+		return crypto.Sign(crypto.Keccak256(message), key)
+	}
+}
+
+// buildBlock assembles an empty child of the chain head, sealed by the key.
+func buildBlock(t *testing.T, engine *BFT, chain *core.BlockChain, key *ecdsa.PrivateKey, tamper func(ext *extra)) *types.Block {
+	t.Helper()
+
+	parent := chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Coinbase:   crypto.PubkeyToAddress(key.PublicKey),
+	}
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to open parent state: %v", err)
+	}
+	block, err := engine.FinalizeAndAssemble(chain, header, statedb, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to assemble block: %v", err)
+	}
+	header = block.Header()
+
+	ext, err := decodeExtra(header)
+	if err != nil {
+		t.Fatalf("failed to decode extra-data: %v", err)
+	}
+	if tamper != nil {
+		tamper(ext)
+		header.Extra, _ = encodeExtra(header.Extra, ext)
+	}
+	ext.Seal, _ = signerFn(key)(accounts.Account{}, accounts.MimetypeBFT, SealHash(header).Bytes())
+	header.Extra, _ = encodeExtra(header.Extra, ext)
+
+	return block.WithSeal(header)
+}
+
+// commitSignatures creates the precommit signatures of the keys for a block.
+func commitSignatures(keys []*ecdsa.PrivateKey, number uint64, round uint64, hash common.Hash) [][]byte {
+	var sigs [][]byte
+	for _, key := range keys {
+		sig, _ := signerFn(key)(accounts.Account{}, accounts.MimetypeBFT, voteData(PrecommitMsg, number, round, hash))
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// Tests that headers are only accepted if sealed by a validator and if carrying
+// the precommits of a validator quorum for their parent.
+func TestVerifyHeader(t *testing.T) {
+	var (
+		keys       []*ecdsa.PrivateKey
+		validators []common.Address
+	)
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		keys, validators = append(keys, key), append(validators, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	outsider, _ := crypto.GenerateKey()
+
+	engine, chain := newTestChain(t, validators, &params.BFTConfig{})
+	defer engine.Close()
+	defer chain.Stop()
+
+	// The first block carries no commits but needs a validator seal
+	if err := engine.VerifyHeader(chain, buildBlock(t, engine, chain, outsider, nil).Header(), true); err != errUnauthorizedValidator {
+		t.Fatalf("outsider seal error mismatch: have %v, want %v", err, errUnauthorizedValidator)
+	}
+	first := buildBlock(t, engine, chain, keys[0], nil)
+	if _, err := chain.InsertChain(types.Blocks{first}); err != nil {
+		t.Fatalf("failed to insert first block: %v", err)
+	}
+	// Any further block needs the parent commits to be known locally
+	parent := chain.CurrentBlock()
+	if err := engine.Prepare(chain, &types.Header{ParentHash: parent.Hash(), Number: big.NewInt(2)}); err != errMissingParentCommits {
+		t.Fatalf("missing commit error mismatch: have %v, want %v", err, errMissingParentCommits)
+	}
+	engine.storeCommit(parent.Hash(), &commitRecord{Round: 1, Signatures: commitSignatures(keys[:3], 1, 1, parent.Hash())})
+
+	tests := []struct {
+		tamper func(ext *extra)
+		err    error
+	}{
+		// Commits of a quorum are accepted
+		{nil, nil},
+		// Commits below the quorum are rejected
+		{func(ext *extra) { ext.Commits = ext.Commits[:2] }, errInsufficientCommits},
+		// Duplicate commits of a validator are rejected
+		{func(ext *extra) { ext.Commits = append(ext.Commits[:2], ext.Commits[0]) }, errDuplicateCommit},
+		// Commits from outsiders are rejected
+		{func(ext *extra) {
+			ext.Commits = append(ext.Commits[:2], commitSignatures([]*ecdsa.PrivateKey{outsider}, 1, 1, parent.Hash())...)
+		}, errUnauthorizedValidator},
+		// Commits of another round are rejected
+		{func(ext *extra) { ext.CommitRound = 0 }, errUnauthorizedValidator},
+		// Validator sets outside of the genesis are rejected
+		{func(ext *extra) { ext.Validators = validators }, errExtraValidators},
+	}
+	for i, tt := range tests {
+		block := buildBlock(t, engine, chain, keys[1], tt.tamper)
+		if err := engine.VerifyHeader(chain, block.Header(), true); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Ensure the finality of the first block is provable from the commits
+	if _, err := chain.InsertChain(types.Blocks{buildBlock(t, engine, chain, keys[1], nil)}); err != nil {
+		t.Fatalf("failed to insert second block: %v", err)
+	}
+	finality, err := engine.Finality(chain, first.Header())
+	if err != nil {
+		t.Fatalf("failed to retrieve finality: %v", err)
+	}
+	if finality == nil || finality.Hash != first.Hash() || finality.Round != 1 || len(finality.Signers) != 3 {
+		t.Fatalf("finality mismatch: have %+v", finality)
+	}
+}
+
+// testValidator is a simulated node running the consensus with a minimal miner,
+// which keeps offering an empty block on top of its chain head.
+type testValidator struct {
+	engine *BFT
+	chain  *core.BlockChain
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func (v *testValidator) Start() error {
+	v.wg.Add(1)
+	go v.loop()
+	return nil
+}
+
+func (v *testValidator) Stop() error {
+	close(v.quit)
+	v.wg.Wait()
+
+	v.engine.Close()
+	v.chain.Stop()
+	return nil
+}
+
+func (v *testValidator) loop() {
+	defer v.wg.Done()
+
+	var (
+		heads     = make(chan core.ChainHeadEvent, 16)
+		committed = make(chan *types.Block, 16)
+		results   = make(chan *types.Block, 1)
+		stop      chan struct{}
+	)
+	headSub := v.chain.SubscribeChainHeadEvent(heads)
+	defer headSub.Unsubscribe()
+	committedSub := v.engine.SubscribeCommittedBlocks(committed)
+	defer committedSub.Unsubscribe()
+
+	seal := func() {
+		if stop != nil {
+			close(stop)
+		}
+		stop = make(chan struct{})
+
+		parent := v.chain.CurrentBlock()
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			GasLimit:   parent.GasLimit(),
+		}
+		if err := v.engine.Prepare(v.chain, header); err != nil {
+			return
+		}
+		statedb, err := v.chain.StateAt(parent.Root())
+		if err != nil {
+			return
+		}
+		block, err := v.engine.FinalizeAndAssemble(v.chain, header, statedb, nil, nil, nil)
+		if err != nil {
+			return
+		}
+		v.engine.Seal(v.chain, block, results, stop)
+	}
+	seal()
+	for {
+		select {
+		case block := <-results:
+			v.chain.InsertChain(types.Blocks{block})
+		case block := <-committed:
+			v.chain.InsertChain(types.Blocks{block})
+		case <-heads:
+			seal()
+		case <-v.quit:
+			if stop != nil {
+				close(stop)
+			}
+			return
+		}
+	}
+}
+
+// Tests that a simulated network of validators agrees on a single chain, and
+// keeps making progress with a validator offline.
+func TestSimulatedConsensus(t *testing.T) {
+	const validatorCount = 4
+
+	var (
+		configs    []*adapters.NodeConfig
+		validators []common.Address
+	)
+	for i := 0; i < validatorCount; i++ {
+		config := adapters.RandomNodeConfig()
+		config.Lifecycles = []string{"bft"}
+		configs, validators = append(configs, config), append(validators, crypto.PubkeyToAddress(config.PrivateKey.PublicKey))
+	}
+	var (
+		nodes = make(map[enode.ID]*testValidator)
+		lock  sync.Mutex
+	)
+	adapter := adapters.NewSimAdapter(adapters.LifecycleConstructors{
+		"bft": func(ctx *adapters.ServiceContext, stack *node.Node) (node.Lifecycle, error) {
+			engine, chain := newTestChain(t, validators, &params.BFTConfig{Timeout: 100})
+			engine.Authorize(crypto.PubkeyToAddress(ctx.Config.PrivateKey.PublicKey), signerFn(ctx.Config.PrivateKey))
+			v := &testValidator{engine: engine, chain: chain, quit: make(chan struct{})}
+			stack.RegisterProtocols(engine.Protocols())
+			stack.RegisterLifecycle(v)
+
+			lock.Lock()
+			nodes[ctx.Config.ID] = v
+			lock.Unlock()
+			return v, nil
+		},
+	})
+	network := simulations.NewNetwork(adapter, &simulations.NetworkConfig{DefaultService: "bft"})
+	defer network.Shutdown()
+
+	var ids []enode.ID
+	for _, config := range configs {
+		node, err := network.NewNodeWithConfig(config)
+		if err != nil {
+			t.Fatalf("failed to create node: %v", err)
+		}
+		if err := network.Start(node.ID()); err != nil {
+			t.Fatalf("failed to start node: %v", err)
+		}
+		ids = append(ids, node.ID())
+	}
+	if err := network.ConnectNodesFull(ids); err != nil {
+		t.Fatalf("failed to connect nodes: %v", err)
+	}
+	// Wait for all the validators to reach a height and check they agree
+	waitHeight := func(ids []enode.ID, height uint64) {
+		t.Helper()
+
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			reached := true
+			for _, id := range ids {
+				lock.Lock()
+				v := nodes[id]
+				lock.Unlock()
+
+				if v.chain.CurrentBlock().NumberU64() < height {
+					reached = false
+				}
+			}
+			if reached {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("validators failed to reach height %d", height)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		reference := nodes[ids[0]].chain
+		for _, id := range ids[1:] {
+			for number := uint64(1); number <= height; number++ {
+				if have, want := nodes[id].chain.GetHeaderByNumber(number).Hash(), reference.GetHeaderByNumber(number).Hash(); have != want {
+					t.Fatalf("validator %s block %d mismatch: have %x, want %x", id, number, have, want)
+				}
+			}
+		}
+	}
+	waitHeight(ids, 8)
+
+	// Take one validator offline and ensure the rest keeps finalizing blocks
+	if err := network.Stop(ids[0]); err != nil {
+		t.Fatalf("failed to stop validator: %v", err)
+	}
+	head := nodes[ids[1]].chain.CurrentBlock().NumberU64()
+	waitHeight(ids[1:], head+2*validatorCount)
+
+	finality, err := nodes[ids[1]].engine.Finality(nodes[ids[1]].chain, nodes[ids[1]].chain.GetHeaderByNumber(head))
+	if err != nil || finality == nil {
+		t.Fatalf("failed to prove finality of block %d: %v", head, err)
+	}
+	if len(finality.Signers) < quorum(validatorCount) {
+		t.Fatalf("finality signer count mismatch: have %d, want at least %d", len(finality.Signers), quorum(validatorCount))
+	}
+}
+
+// Tests that the lock and the signed messages of a validator survive a restart,
+// so that it can't vote for a different block after coming back.
+func TestRestoreRoundState(t *testing.T) {
+	var (
+		keys       []*ecdsa.PrivateKey
+		validators []common.Address
+	)
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		keys, validators = append(keys, key), append(validators, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	config := &params.BFTConfig{Timeout: 60000}
+	engine, chain := newTestChain(t, validators, config)
+	defer engine.Close()
+	defer chain.Stop()
+
+	newMachine := func(engine *BFT) *machine {
+		if _, err := engine.setup(chain); err != nil {
+			t.Fatalf("failed to set up engine: %v", err)
+		}
+		engine.Authorize(validators[0], signerFn(keys[0]))
+
+		c := &machine{b: engine}
+		c.enterHeight(chain.Genesis().Header())
+		return c
+	}
+	// Lock on a block and precommit it, as if it got a prevote quorum
+	block := buildBlock(t, engine, chain, keys[1], nil)
+
+	c := newMachine(engine)
+	c.vote(PrevoteMsg, block.Hash())
+	c.state.lockedRound, c.state.lockedBlock = 0, block
+	c.vote(PrecommitMsg, block.Hash())
+
+	// Restart the engine on the same database and check the state is restored
+	restarted := New(config, engine.db)
+	defer restarted.Close()
+
+	c = newMachine(restarted)
+	if s := c.state; s.lockedRound != 0 || s.lockedBlock == nil || s.lockedBlock.Hash() != block.Hash() {
+		t.Fatalf("lock not restored: round %d", s.lockedRound)
+	}
+	if len(c.state.signed) != 2 || c.state.prevotes[0].count(block.Hash()) != 1 || c.state.precommits[0].count(block.Hash()) != 1 {
+		t.Fatalf("signed votes not restored: %d", len(c.state.signed))
+	}
+	// Conflicting votes in the same round must not be cast
+	c.vote(PrecommitMsg, common.Hash{})
+	if c.state.precommits[0].total() != 1 || len(c.state.signed) != 2 {
+		t.Fatalf("conflicting precommit cast after restart")
+	}
+	// The persisted state must only apply to its own height
+	if saved := restarted.roundState(2); saved != nil {
+		t.Fatalf("consensus state restored for wrong height")
+	}
+}
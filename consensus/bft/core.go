@@ -0,0 +1,617 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// maxFutureMessages is the number of consensus messages for upcoming heights
+// buffered until the local node reaches them.
+const maxFutureMessages = 1024
+
+// step is a phase of a consensus round.
+type step uint8
+
+const (
+	stepPropose step = iota
+	stepPrevote
+	stepPrecommit
+)
+
+// String implements fmt.Stringer.
+func (s step) String() string {
+	switch s {
+	case stepPropose:
+		return "propose"
+	case stepPrevote:
+		return "prevote"
+	case stepPrecommit:
+		return "precommit"
+	default:
+		return "unknown"
+	}
+}
+
+// sealTask is a block offered by the local miner for its height.
+type sealTask struct {
+	block   *types.Block
+	results chan<- *types.Block
+	stop    <-chan struct{}
+}
+
+// stopped reports whether the miner abandoned the block.
+func (t *sealTask) stopped() bool {
+	select {
+	case <-t.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// timeout is a scheduled expiry of a round step.
+type timeout struct {
+	height uint64
+	round  uint64
+	step   step
+}
+
+// roundProposal is a received proposal along with the validity of its block.
+type roundProposal struct {
+	*proposal
+	valid bool
+}
+
+// heightState is the consensus state of the height currently being decided.
+type heightState struct {
+	height uint64        // Number of the block being decided
+	parent *types.Header // Committed block the height builds on
+	round  uint64        // Current consensus round
+	step   step          // Current step within the round
+
+	proposals  map[uint64]*roundProposal              // Proposals received in each round
+	prevotes   map[uint64]*voteSet                    // Prevotes received in each round
+	precommits map[uint64]*voteSet                    // Precommits received in each round
+	senders    map[uint64]map[common.Address]struct{} // Validators heard from in each round
+	fired      map[uint64]map[step]struct{}           // One-off rules already triggered in each round
+	messages   []*message                             // All messages of the height, to relay to new peers
+	signed     []*message                             // Messages signed by the local node, persisted across restarts
+
+	lockedRound int64        // Round the node locked on a block in, -1 if not locked
+	lockedBlock *types.Block // Block the node is locked on
+	validRound  int64        // Last round a block got a prevote quorum in, -1 if none
+	validBlock  *types.Block // Last block that got a prevote quorum
+
+	proposePending bool // Local node is the proposer but had no block to propose yet
+}
+
+func newHeightState(parent *types.Header) *heightState {
+	return &heightState{
+		height:      parent.Number.Uint64() + 1,
+		parent:      parent,
+		proposals:   make(map[uint64]*roundProposal),
+		prevotes:    make(map[uint64]*voteSet),
+		precommits:  make(map[uint64]*voteSet),
+		senders:     make(map[uint64]map[common.Address]struct{}),
+		fired:       make(map[uint64]map[step]struct{}),
+		lockedRound: -1,
+		validRound:  -1,
+	}
+}
+
+// fire marks a one-off rule of a round as triggered, returning false if it was
+// already triggered before.
+func (s *heightState) fire(round uint64, rule step) bool {
+	if s.fired[round] == nil {
+		s.fired[round] = make(map[step]struct{})
+	}
+	if _, ok := s.fired[round][rule]; ok {
+		return false
+	}
+	s.fired[round][rule] = struct{}{}
+	return true
+}
+
+// votes returns the vote set of a kind for a round, creating it if needed.
+func (s *heightState) votes(code uint64, round uint64) *voteSet {
+	sets := s.prevotes
+	if code == PrecommitMsg {
+		sets = s.precommits
+	}
+	if sets[round] == nil {
+		sets[round] = newVoteSet()
+	}
+	return sets[round]
+}
+
+// machine is the consensus state machine, only ever accessed from the consensus loop.
+type machine struct {
+	b      *BFT
+	state  *heightState // Height being decided, nil until the local chain is known
+	task   *sealTask    // Latest block offered by the local miner
+	future []*message   // Messages of heights not yet reached
+}
+
+// loop is the consensus event loop, driving the state machine with the blocks
+// offered by the miner, the messages of the other validators and the timeouts.
+func (b *BFT) loop() {
+	defer b.wg.Done()
+
+	c := &machine{b: b}
+	for {
+		select {
+		case task := <-b.submitCh:
+			c.submit(task)
+
+		case msg := <-b.messageCh:
+			c.handle(msg)
+
+		case t := <-b.timeoutCh:
+			c.timeout(t)
+
+		case p := <-b.peerCh:
+			if c.state != nil {
+				for _, msg := range c.state.messages {
+					p.send(msg)
+				}
+			}
+
+		case ch := <-b.statusCh:
+			ch <- c.status()
+
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// self returns the local validator address, if any.
+func (c *machine) self() (common.Address, SignerFn) {
+	c.b.lock.RLock()
+	defer c.b.lock.RUnlock()
+
+	return c.b.signer, c.b.signFn
+}
+
+// proposer returns the validator proposing in the given round of a height.
+func proposer(validators []common.Address, height uint64, round uint64) common.Address {
+	return validators[(height+round)%uint64(len(validators))]
+}
+
+// submit handles a block offered by the local miner, entering its height if the
+// consensus is behind the local chain.
+func (c *machine) submit(task *sealTask) {
+	_, chain := c.b.validatorSet()
+	if chain == nil {
+		return
+	}
+	c.task = task
+
+	number := task.block.NumberU64()
+	switch {
+	case c.state == nil || number > c.state.height:
+		parent := chain.GetHeader(task.block.ParentHash(), number-1)
+		if parent == nil {
+			return
+		}
+		c.enterHeight(parent)
+
+	case number < c.state.height || task.block.ParentHash() != c.state.parent.Hash():
+		log.Debug("Discarding stale consensus block", "number", number, "height", c.state.height)
+		return
+	}
+	if c.state.proposePending && c.state.step == stepPropose {
+		c.propose()
+	}
+	c.evaluate()
+}
+
+// handle processes a consensus message received from a peer or created locally.
+func (c *machine) handle(msg *message) {
+	validators, chain := c.b.validatorSet()
+	if validators == nil {
+		return
+	}
+	if !isValidator(validators, msg.sender) {
+		log.Debug("Discarding consensus message from non-validator", "sender", msg.sender, "peer", msg.origin)
+		return
+	}
+	if msg.proposal != nil && proposer(validators, msg.height(), msg.round()) != msg.sender {
+		log.Debug("Discarding proposal from non-proposer", "sender", msg.sender, "height", msg.height(), "round", msg.round())
+		return
+	}
+	// Join the consensus on the child of the local head if the state fell behind
+	// the chain, buffer messages of further heights, drop the ones of past heights
+	if c.state == nil || msg.height() > c.state.height {
+		head := chain.CurrentHeader()
+		if msg.height() != head.Number.Uint64()+1 || (c.state != nil && c.state.height > head.Number.Uint64()) {
+			if len(c.future) >= maxFutureMessages {
+				c.future = c.future[1:]
+			}
+			c.future = append(c.future, msg)
+			c.b.peers.broadcast(msg)
+			return
+		}
+		c.enterHeight(head)
+	}
+	if msg.height() < c.state.height {
+		return
+	}
+	if !c.add(msg) {
+		return
+	}
+	c.b.peers.broadcast(msg)
+	c.evaluate()
+}
+
+// add inserts a message of the current height into the state, returning false if
+// the validator already sent a message of the same kind in the round.
+func (c *machine) add(msg *message) bool {
+	s, round := c.state, msg.round()
+
+	if msg.proposal != nil {
+		if _, ok := s.proposals[round]; ok {
+			return false
+		}
+		s.proposals[round] = &roundProposal{
+			proposal: msg.proposal,
+			valid:    c.validate(msg.proposal.Block),
+		}
+	} else if !s.votes(msg.code, round).add(msg.sender, msg.vote) {
+		return false
+	}
+	if s.senders[round] == nil {
+		s.senders[round] = make(map[common.Address]struct{})
+	}
+	s.senders[round][msg.sender] = struct{}{}
+	s.messages = append(s.messages, msg)
+	return true
+}
+
+// validate checks whether a proposed block is a valid child of the parent.
+func (c *machine) validate(block *types.Block) bool {
+	s := c.state
+	if block.NumberU64() != s.height || block.ParentHash() != s.parent.Hash() {
+		return false
+	}
+	_, chain := c.b.validatorSet()
+	if err := c.b.verifyHeader(chain, block.Header(), []*types.Header{s.parent}); err != nil {
+		log.Debug("Invalid proposed block", "number", block.Number(), "hash", block.Hash(), "err", err)
+		return false
+	}
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+		log.Debug("Invalid proposed block transactions", "number", block.Number(), "hash", block.Hash())
+		return false
+	}
+	if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+		log.Debug("Invalid proposed block uncles", "number", block.Number(), "hash", block.Hash())
+		return false
+	}
+	return true
+}
+
+// enterHeight starts deciding the child of the given block. If the local node
+// already took part in deciding the height before a restart, its lock and the
+// messages it signed are restored, so it can't contradict them.
+func (c *machine) enterHeight(parent *types.Header) {
+	c.state = newHeightState(parent)
+	log.Debug("Entering consensus height", "height", c.state.height, "parent", parent.Hash())
+
+	if saved := c.b.roundState(c.state.height); saved != nil {
+		c.restore(saved)
+	}
+	c.startRound(0)
+
+	// Replay any buffered messages of the new height
+	var future []*message
+	for _, msg := range c.future {
+		switch {
+		case msg.height() == c.state.height:
+			c.add(msg)
+		case msg.height() > c.state.height:
+			future = append(future, msg)
+		}
+	}
+	c.future = future
+}
+
+// restore reloads the persisted lock and signed messages of the current height.
+func (c *machine) restore(saved *roundState) {
+	s := c.state
+	if saved.LockedRound > 0 && saved.LockedBlock != nil {
+		s.lockedRound, s.lockedBlock = int64(saved.LockedRound-1), saved.LockedBlock
+	}
+	for _, signed := range saved.Messages {
+		msg, err := decodeMessage(signed.Code, signed.Payload)
+		if err != nil || msg.height() != s.height {
+			log.Error("Invalid signed consensus message in database", "err", err)
+			continue
+		}
+		c.b.known.Add(msg.hash, struct{}{})
+		if c.add(msg) {
+			s.signed = append(s.signed, msg)
+		}
+	}
+	log.Info("Restored consensus state", "height", s.height, "locked", s.lockedRound, "messages", len(s.signed))
+}
+
+// persist stores the lock and the signed messages of the current height.
+func (c *machine) persist() {
+	s := c.state
+	saved := &roundState{Height: s.height}
+	if s.lockedRound >= 0 {
+		saved.LockedRound, saved.LockedBlock = uint64(s.lockedRound+1), s.lockedBlock
+	}
+	for _, msg := range s.signed {
+		saved.Messages = append(saved.Messages, signedMessage{Code: msg.code, Payload: msg.payload})
+	}
+	c.b.storeRoundState(saved)
+}
+
+// startRound moves the consensus to a new round, proposing a block if the local
+// node is the round's proposer.
+func (c *machine) startRound(round uint64) {
+	s := c.state
+	s.round, s.step, s.proposePending = round, stepPropose, false
+
+	validators, _ := c.b.validatorSet()
+	if self, _ := c.self(); proposer(validators, s.height, round) == self {
+		c.propose()
+	}
+	c.schedule(timeout{height: s.height, round: round, step: stepPropose})
+}
+
+// propose sends the proposal of the local node for the current round. Any block
+// which already got a prevote quorum is proposed again, otherwise the block
+// offered by the local miner.
+func (c *machine) propose() {
+	s := c.state
+
+	block, validRound := s.validBlock, uint64(s.validRound+1)
+	if block == nil {
+		if c.task == nil || c.task.stopped() || c.task.block.NumberU64() != s.height || c.task.block.ParentHash() != s.parent.Hash() {
+			s.proposePending = true
+			return
+		}
+		block = c.task.block
+	}
+	s.proposePending = false
+
+	self, signFn := c.self()
+	sig, err := signFn(accounts.Account{Address: self}, accounts.MimetypeBFT, proposalData(s.height, s.round, validRound, block.Hash()))
+	if err != nil {
+		log.Error("Failed to sign proposal", "err", err)
+		return
+	}
+	msg, err := encodeMessage(ProposalMsg, self, &proposal{
+		Height:     s.height,
+		Round:      s.round,
+		ValidRound: validRound,
+		Block:      block,
+		Signature:  sig,
+	})
+	if err != nil {
+		log.Error("Failed to encode proposal", "err", err)
+		return
+	}
+	log.Debug("Proposing block", "height", s.height, "round", s.round, "hash", block.Hash())
+	c.local(msg)
+}
+
+// vote sends the prevote or precommit of the local node for the current round.
+func (c *machine) vote(code uint64, hash common.Hash) {
+	s := c.state
+
+	validators, _ := c.b.validatorSet()
+	self, signFn := c.self()
+	if signFn == nil || !isValidator(validators, self) {
+		return
+	}
+	sig, err := signFn(accounts.Account{Address: self}, accounts.MimetypeBFT, voteData(code, s.height, s.round, hash))
+	if err != nil {
+		log.Error("Failed to sign vote", "err", err)
+		return
+	}
+	msg, err := encodeMessage(code, self, &vote{
+		Height:    s.height,
+		Round:     s.round,
+		Hash:      hash,
+		Signature: sig,
+	})
+	if err != nil {
+		log.Error("Failed to encode vote", "err", err)
+		return
+	}
+	c.local(msg)
+}
+
+// local inserts a message created by the local node into the state and sends it
+// to all the peers, after persisting it along with any lock.
+func (c *machine) local(msg *message) {
+	c.b.known.Add(msg.hash, struct{}{})
+	if c.add(msg) {
+		c.state.signed = append(c.state.signed, msg)
+		c.persist()
+		c.b.peers.broadcast(msg)
+	}
+}
+
+// evaluate applies the consensus rules until the state settles.
+func (c *machine) evaluate() {
+	for c.state != nil && c.apply() {
+	}
+}
+
+// apply executes the first consensus rule whose conditions are met, returning
+// whether the state changed.
+func (c *machine) apply() bool {
+	var (
+		s             = c.state
+		validators, _ = c.b.validatorSet()
+		needed        = quorum(len(validators))
+		faulty        = (len(validators) - 1) / 3
+	)
+	// Commit any block that got a precommit quorum in any round
+	for round, precommits := range s.precommits {
+		for _, p := range s.proposals {
+			if p.valid && precommits.count(p.Block.Hash()) >= needed {
+				c.decide(p.Block, round)
+				return true
+			}
+		}
+	}
+	// Catch up with the rest of the validators if enough of them are ahead
+	for round, senders := range s.senders {
+		if round > s.round && len(senders) > faulty {
+			c.startRound(round)
+			return true
+		}
+	}
+	p := s.proposals[s.round]
+
+	// Prevote on the proposal of the round, unless locked on another block
+	if s.step == stepPropose && p != nil {
+		hash := p.Block.Hash()
+		if p.ValidRound == 0 {
+			if p.valid && (s.lockedRound == -1 || s.lockedBlock.Hash() == hash) {
+				c.vote(PrevoteMsg, hash)
+			} else {
+				c.vote(PrevoteMsg, common.Hash{})
+			}
+			s.step = stepPrevote
+			return true
+		}
+		if validRound := p.ValidRound - 1; validRound < s.round && s.prevotes[validRound] != nil && s.prevotes[validRound].count(hash) >= needed {
+			if p.valid && (s.lockedRound <= int64(validRound) || s.lockedBlock.Hash() == hash) {
+				c.vote(PrevoteMsg, hash)
+			} else {
+				c.vote(PrevoteMsg, common.Hash{})
+			}
+			s.step = stepPrevote
+			return true
+		}
+	}
+	prevotes := s.votes(PrevoteMsg, s.round)
+
+	// Lock on and precommit the proposal once it got a prevote quorum
+	if s.step >= stepPrevote && p != nil && p.valid && prevotes.count(p.Block.Hash()) >= needed && s.fire(s.round, stepPropose) {
+		if s.step == stepPrevote {
+			s.lockedRound, s.lockedBlock = int64(s.round), p.Block
+			c.vote(PrecommitMsg, p.Block.Hash())
+			s.step = stepPrecommit
+		}
+		s.validRound, s.validBlock = int64(s.round), p.Block
+		return true
+	}
+	// Precommit nothing if a quorum prevoted nothing
+	if s.step == stepPrevote && prevotes.count(common.Hash{}) >= needed {
+		c.vote(PrecommitMsg, common.Hash{})
+		s.step = stepPrecommit
+		return true
+	}
+	// Start waiting for stragglers once a quorum voted on anything
+	if s.step == stepPrevote && prevotes.total() >= needed && s.fire(s.round, stepPrevote) {
+		c.schedule(timeout{height: s.height, round: s.round, step: stepPrevote})
+		return true
+	}
+	if s.votes(PrecommitMsg, s.round).total() >= needed && s.fire(s.round, stepPrecommit) {
+		c.schedule(timeout{height: s.height, round: s.round, step: stepPrecommit})
+		return true
+	}
+	return false
+}
+
+// decide finalizes a block of the current height, delivering it to the miner if
+// it's the local block, or to the committed block subscribers otherwise. The
+// consensus moves on to the next height right away.
+func (c *machine) decide(block *types.Block, round uint64) {
+	hash := block.Hash()
+	c.b.storeCommit(hash, &commitRecord{
+		Round:      round,
+		Signatures: c.state.precommits[round].signatures(hash),
+	})
+	log.Info("Committed new block", "number", block.Number(), "hash", hash, "round", round)
+
+	if task := c.task; task != nil && !task.stopped() && task.block.Hash() == hash {
+		select {
+		case task.results <- block:
+		default:
+			log.Warn("Sealing result is not read by miner", "sealhash", SealHash(block.Header()))
+		}
+	} else {
+		c.b.committed.Send(block)
+	}
+	c.task = nil
+	c.enterHeight(block.Header())
+}
+
+// timeout handles the expiry of a round step, moving on without waiting for the
+// missing messages.
+func (c *machine) timeout(t timeout) {
+	s := c.state
+	if s == nil || t.height != s.height || t.round != s.round {
+		return
+	}
+	switch t.step {
+	case stepPropose:
+		if s.step == stepPropose {
+			c.vote(PrevoteMsg, common.Hash{})
+			s.step = stepPrevote
+		}
+	case stepPrevote:
+		if s.step == stepPrevote {
+			c.vote(PrecommitMsg, common.Hash{})
+			s.step = stepPrecommit
+		}
+	case stepPrecommit:
+		c.startRound(s.round + 1)
+	}
+	c.evaluate()
+}
+
+// schedule arranges for a round step to expire. Timeouts grow linearly with the
+// rounds, so the validators eventually wait long enough to agree.
+func (c *machine) schedule(t timeout) {
+	delay := time.Duration(c.b.config.Timeout) * time.Millisecond * time.Duration(t.round+1)
+	time.AfterFunc(delay, func() {
+		select {
+		case c.b.timeoutCh <- t:
+		case <-c.b.quit:
+		}
+	})
+}
+
+// status returns the state of the consensus.
+func (c *machine) status() *Status {
+	if c.state == nil {
+		return nil
+	}
+	return &Status{
+		Height:      c.state.height,
+		Round:       c.state.round,
+		Step:        c.state.step.String(),
+		LockedRound: c.state.lockedRound,
+		Peers:       c.b.peers.len(),
+	}
+}
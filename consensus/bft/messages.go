@@ -0,0 +1,205 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Consensus protocol message codes.
+const (
+	ProposalMsg  = 0x00
+	PrevoteMsg   = 0x01
+	PrecommitMsg = 0x02
+)
+
+var (
+	// errInvalidSignature is returned if a signature has an invalid length.
+	errInvalidSignature = errors.New("invalid signature")
+
+	// errInvalidMsgCode is returned if a consensus message has an unknown code.
+	errInvalidMsgCode = errors.New("invalid message code")
+)
+
+// proposal is the message a round's proposer offers a block for the height with.
+type proposal struct {
+	Height     uint64
+	Round      uint64
+	ValidRound uint64 // One plus the round the block gathered a prevote quorum in, zero if none
+	Block      *types.Block
+	Signature  []byte
+}
+
+// proposalData returns the payload signed by the proposer of a round.
+func proposalData(height uint64, round uint64, validRound uint64, hash common.Hash) []byte {
+	blob, _ := rlp.EncodeToBytes([]interface{}{uint64(ProposalMsg), height, round, validRound, hash})
+	return blob
+}
+
+// vote is a prevote or precommit message of a validator for a block hash, or for
+// nothing if the hash is empty.
+type vote struct {
+	Height    uint64
+	Round     uint64
+	Hash      common.Hash
+	Signature []byte
+}
+
+// voteData returns the payload signed by validators voting in a round.
+func voteData(code uint64, height uint64, round uint64, hash common.Hash) []byte {
+	blob, _ := rlp.EncodeToBytes([]interface{}{code, height, round, hash})
+	return blob
+}
+
+// message is a decoded consensus message along with its wire form, which is
+// needed to relay it.
+type message struct {
+	code    uint64
+	payload []byte
+	hash    common.Hash // Hash of the wire form to detect duplicates
+	origin  string      // Peer the message was received from, empty if local
+
+	sender   common.Address // Validator who signed the message
+	proposal *proposal      // Decoded proposal, if the message is a proposal
+	vote     *vote          // Decoded vote, if the message is a vote
+}
+
+// decodeMessage parses a consensus message and recovers the validator who signed
+// it. The signer is not yet checked against the validator set.
+func decodeMessage(code uint64, payload []byte) (*message, error) {
+	msg := &message{
+		code:    code,
+		payload: payload,
+		hash:    crypto.Keccak256Hash([]byte{byte(code)}, payload),
+	}
+	var err error
+	switch code {
+	case ProposalMsg:
+		msg.proposal = new(proposal)
+		if err = rlp.DecodeBytes(payload, msg.proposal); err != nil {
+			return nil, err
+		}
+		if msg.proposal.Block == nil {
+			return nil, fmt.Errorf("proposal without block")
+		}
+		p := msg.proposal
+		msg.sender, err = ecrecover(proposalData(p.Height, p.Round, p.ValidRound, p.Block.Hash()), p.Signature)
+
+	case PrevoteMsg, PrecommitMsg:
+		msg.vote = new(vote)
+		if err = rlp.DecodeBytes(payload, msg.vote); err != nil {
+			return nil, err
+		}
+		v := msg.vote
+		msg.sender, err = ecrecover(voteData(code, v.Height, v.Round, v.Hash), v.Signature)
+
+	default:
+		return nil, errInvalidMsgCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// encodeMessage creates the wire form of a locally signed consensus message.
+func encodeMessage(code uint64, sender common.Address, data interface{}) (*message, error) {
+	payload, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	msg := &message{
+		code:    code,
+		payload: payload,
+		hash:    crypto.Keccak256Hash([]byte{byte(code)}, payload),
+		sender:  sender,
+	}
+	switch data := data.(type) {
+	case *proposal:
+		msg.proposal = data
+	case *vote:
+		msg.vote = data
+	}
+	return msg, nil
+}
+
+// height returns the consensus height the message belongs to.
+func (m *message) height() uint64 {
+	if m.proposal != nil {
+		return m.proposal.Height
+	}
+	return m.vote.Height
+}
+
+// round returns the consensus round the message belongs to.
+func (m *message) round() uint64 {
+	if m.proposal != nil {
+		return m.proposal.Round
+	}
+	return m.vote.Round
+}
+
+// voteSet collects the votes of a single kind cast in a round.
+type voteSet struct {
+	votes  map[common.Address]*vote
+	counts map[common.Hash]int
+}
+
+func newVoteSet() *voteSet {
+	return &voteSet{
+		votes:  make(map[common.Address]*vote),
+		counts: make(map[common.Hash]int),
+	}
+}
+
+// add inserts a vote into the set. Only the first vote of a validator counts, it
+// returns false for any further ones.
+func (s *voteSet) add(sender common.Address, v *vote) bool {
+	if _, ok := s.votes[sender]; ok {
+		return false
+	}
+	s.votes[sender] = v
+	s.counts[v.Hash]++
+	return true
+}
+
+// count returns the number of votes cast for the hash.
+func (s *voteSet) count(hash common.Hash) int {
+	return s.counts[hash]
+}
+
+// total returns the number of votes cast for anything.
+func (s *voteSet) total() int {
+	return len(s.votes)
+}
+
+// signatures returns the signatures of the votes cast for the hash.
+func (s *voteSet) signatures(hash common.Hash) [][]byte {
+	var sigs [][]byte
+	for _, v := range s.votes {
+		if v.Hash == hash {
+			sigs = append(sigs, v.Signature)
+		}
+	}
+	return sigs
+}
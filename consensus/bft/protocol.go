@@ -0,0 +1,236 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// ProtocolName is the official short name of the consensus protocol used
+	// during devp2p capability negotiation.
+	ProtocolName = "bft"
+
+	// ProtocolVersion is the version of the consensus protocol.
+	ProtocolVersion = 1
+
+	// protocolLength is the number of implemented message codes.
+	protocolLength = 3
+
+	// maxMessageSize is the maximum cap on the size of a consensus message.
+	maxMessageSize = 10 * 1024 * 1024
+
+	maxQueuedMessages = 256  // Maximum number of messages queued for sending to a peer
+	maxKnownMessages  = 1024 // Maximum number of message hashes to remember per peer
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errDecode         = errors.New("invalid message")
+	errPeerRegistered = errors.New("peer already registered")
+)
+
+// Protocols returns the p2p protocol the validators exchange consensus messages
+// over. It needs to be run by every node taking part in or following the consensus.
+func (b *BFT) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  protocolLength,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			return b.runPeer(newPeer(p, rw))
+		},
+	}}
+}
+
+// runPeer registers a consensus peer and handles its messages until the
+// connection is torn down.
+func (b *BFT) runPeer(p *peer) error {
+	if err := b.peers.register(p); err != nil {
+		return err
+	}
+	defer b.peers.unregister(p.id)
+
+	go p.writeLoop()
+	defer p.close()
+
+	// Catch the peer up with the messages of the current height
+	select {
+	case b.peerCh <- p:
+	case <-b.quit:
+		return p2p.DiscQuitting
+	}
+	for {
+		if err := b.handleMessage(p); err != nil {
+			p.Log().Debug("Consensus message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMessage reads and decodes the next message from the peer, forwarding it
+// to the consensus loop unless already seen.
+func (b *BFT) handleMessage(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	decoded, err := decodeMessage(msg.Code, payload)
+	if err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg.Code, err)
+	}
+	decoded.origin = p.id
+	p.known.Add(decoded.hash, struct{}{})
+
+	if seen, _ := b.known.ContainsOrAdd(decoded.hash, struct{}{}); seen {
+		return nil
+	}
+	select {
+	case b.messageCh <- decoded:
+		return nil
+	case <-b.quit:
+		return p2p.DiscQuitting
+	}
+}
+
+// peer is a remote node running the consensus protocol.
+type peer struct {
+	id string // Unique ID for the peer, cached
+
+	*p2p.Peer                   // The embedded P2P package peer
+	rw        p2p.MsgReadWriter // Input/output streams for the consensus protocol
+
+	known *lru.Cache    // Hashes of the messages known to the peer
+	queue chan *message // Messages waiting to be sent to the peer
+	term  chan struct{} // Termination channel to stop the writer
+}
+
+func newPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	known, _ := lru.New(maxKnownMessages)
+	return &peer{
+		id:    p.ID().String(),
+		Peer:  p,
+		rw:    rw,
+		known: known,
+		queue: make(chan *message, maxQueuedMessages),
+		term:  make(chan struct{}),
+	}
+}
+
+// send queues a message for sending to the peer, unless the peer already knows
+// about it. Messages are dropped if the peer can't keep up.
+func (p *peer) send(msg *message) {
+	if seen, _ := p.known.ContainsOrAdd(msg.hash, struct{}{}); seen {
+		return
+	}
+	select {
+	case p.queue <- msg:
+	default:
+		p.Log().Debug("Dropping consensus message", "code", msg.code, "hash", msg.hash)
+	}
+}
+
+// writeLoop sends the queued messages to the peer until terminated.
+func (p *peer) writeLoop() {
+	for {
+		select {
+		case msg := <-p.queue:
+			err := p.rw.WriteMsg(p2p.Msg{
+				Code:    msg.code,
+				Size:    uint32(len(msg.payload)),
+				Payload: bytes.NewReader(msg.payload),
+			})
+			if err != nil {
+				p.Log().Debug("Failed to send consensus message", "err", err)
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// close terminates the writer of the peer.
+func (p *peer) close() {
+	close(p.term)
+}
+
+// peerSet is the set of peers running the consensus protocol.
+type peerSet struct {
+	peers map[string]*peer
+	lock  sync.RWMutex
+}
+
+func newPeerSet() *peerSet {
+	return &peerSet{
+		peers: make(map[string]*peer),
+	}
+}
+
+// register adds a peer to the set.
+func (ps *peerSet) register(p *peer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.peers[p.id]; ok {
+		return errPeerRegistered
+	}
+	ps.peers[p.id] = p
+	return nil
+}
+
+// unregister removes a peer from the set.
+func (ps *peerSet) unregister(id string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	delete(ps.peers, id)
+}
+
+// broadcast relays a message to all peers, except the one it came from.
+func (ps *peerSet) broadcast(msg *message) {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	for id, p := range ps.peers {
+		if id != msg.origin {
+			p.send(msg)
+		}
+	}
+}
+
+// len returns the number of peers in the set.
+func (ps *peerSet) len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.peers)
+}
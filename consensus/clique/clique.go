@@ -181,6 +181,8 @@ type Clique struct {
 	signFn SignerFn       // Signer function to authorize hashes with
 	lock   sync.RWMutex   // Protects the signer fields
 
+	stateFn StateFn // State accessor to query the signer governance contract with
+
 	// The fields below are for testing only
 	fakeDiff bool // Skip difficulty verifications
 }
@@ -264,6 +266,10 @@ func (c *Clique) verifyHeader(chain consensus.ChainHeaderReader, header *types.H
 	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
 		return errInvalidCheckpointVote
 	}
+	// Votes are meaningless if the signers are managed by a governance contract
+	if c.governed() && (header.Coinbase != (common.Address{}) || !bytes.Equal(header.Nonce[:], nonceDropVote)) {
+		return errGovernedVote
+	}
 	// Check that the extra-data contains both the vanity and signature
 	if len(header.Extra) < extraVanity {
 		return errMissingVanity
@@ -279,6 +285,9 @@ func (c *Clique) verifyHeader(chain consensus.ChainHeaderReader, header *types.H
 	if checkpoint && signersBytes%common.AddressLength != 0 {
 		return errInvalidCheckpointSigners
 	}
+	if checkpoint && c.governed() && signersBytes == 0 {
+		return errInvalidCheckpointSigners
+	}
 	// Ensure that the mix digest is zero as we don't have fork protection currently
 	if header.MixDigest != (common.Hash{}) {
 		return errInvalidMixDigest
@@ -329,14 +338,11 @@ func (c *Clique) verifyCascadingFields(chain consensus.ChainHeaderReader, header
 	if err != nil {
 		return err
 	}
-	// If the block is a checkpoint block, verify the signer list
-	if number%c.config.Epoch == 0 {
-		signers := make([]byte, len(snap.Signers)*common.AddressLength)
-		for i, signer := range snap.signers() {
-			copy(signers[i*common.AddressLength:], signer[:])
-		}
+	// If the block is a checkpoint block, verify the signer list. Contract governed
+	// lists are checked against the parent state during block processing instead.
+	if number%c.config.Epoch == 0 && !c.governed() {
 		extraSuffix := len(header.Extra) - extraSeal
-		if !bytes.Equal(header.Extra[extraVanity:extraSuffix], signers) {
+		if !bytes.Equal(header.Extra[extraVanity:extraSuffix], packSigners(snap.signers())) {
 			return errMismatchingCheckpointSigners
 		}
 	}
@@ -492,7 +498,7 @@ func (c *Clique) Prepare(chain consensus.ChainHeaderReader, header *types.Header
 	if err != nil {
 		return err
 	}
-	if number%c.config.Epoch != 0 {
+	if number%c.config.Epoch != 0 && !c.governed() {
 		c.lock.RLock()
 
 		// Gather all the proposals that make sense voting on
@@ -523,9 +529,13 @@ func (c *Clique) Prepare(chain consensus.ChainHeaderReader, header *types.Header
 	header.Extra = header.Extra[:extraVanity]
 
 	if number%c.config.Epoch == 0 {
-		for _, signer := range snap.signers() {
-			header.Extra = append(header.Extra, signer[:]...)
+		signers := snap.signers()
+		if c.governed() {
+			if signers, err = c.checkpointSigners(chain, header); err != nil {
+				return err
+			}
 		}
+		header.Extra = append(header.Extra, packSigners(signers)...)
 	}
 	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
 
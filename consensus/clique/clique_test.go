@@ -17,7 +17,9 @@
 package clique
 
 import (
+	"bytes"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -111,3 +113,114 @@ func TestReimportMirroredState(t *testing.T) {
 		t.Fatalf("chain head mismatch: have %d, want %d", head, 3)
 	}
 }
+
+// Tests that on contract governed chains, checkpoint blocks must carry the signer
+// list returned by the governance contract in the parent state, which replaces
+// the authorized signers from there on.
+func TestGovernedSigners(t *testing.T) {
+	var (
+		key1, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _  = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1    = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2    = crypto.PubkeyToAddress(key2.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+	)
+	// Deploy a governance contract answering any call with both signers
+	output, err := signerContract.Methods["getSigners"].Outputs.Pack([]common.Address{addr2, addr1})
+	if err != nil {
+		t.Fatalf("failed to pack signer list: %v", err)
+	}
+	size := []byte{byte(len(output) >> 8), byte(len(output))}
+	code := append([]byte{
+		byte(vm.PUSH2), size[0], size[1], byte(vm.PUSH1), 0x0e, byte(vm.PUSH1), 0x00, byte(vm.CODECOPY),
+		byte(vm.PUSH2), size[0], size[1], byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+	}, output...)
+
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = &params.CliqueConfig{Epoch: 3, SignerContract: &contract}
+
+	genspec := &core.Genesis{
+		Config:    &config,
+		ExtraData: make([]byte, extraVanity+common.AddressLength+extraSeal),
+		Alloc: map[common.Address]core.GenesisAccount{
+			contract: {Balance: new(big.Int), Code: code},
+		},
+	}
+	copy(genspec.ExtraData[extraVanity:], addr1[:])
+
+	// Create a chain of blocks signed by the only genesis signer, with the given
+	// signer list on the checkpoint block
+	makeChain := func(checkpoint []common.Address) (*Clique, []*types.Block, *core.BlockChain) {
+		db := rawdb.NewMemoryDatabase()
+		genesis := genspec.MustCommit(db)
+		engine := New(config.Clique, db)
+
+		blocks, _ := core.GenerateChain(&config, genesis, engine, db, 3, func(i int, block *core.BlockGen) {
+			block.SetDifficulty(diffInTurn)
+		})
+		for i, block := range blocks {
+			header := block.Header()
+			if i > 0 {
+				header.ParentHash = blocks[i-1].Hash()
+			}
+			header.Extra = make([]byte, extraVanity+extraSeal)
+			if header.Number.Uint64() == 3 {
+				header.Extra = append(header.Extra[:extraVanity], append(packSigners(checkpoint), header.Extra[extraVanity:]...)...)
+			}
+			header.Difficulty = diffInTurn
+
+			sig, _ := crypto.Sign(SealHash(header).Bytes(), key1)
+			copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+			blocks[i] = block.WithSeal(header)
+		}
+		db = rawdb.NewMemoryDatabase()
+		genspec.MustCommit(db)
+		engine = New(config.Clique, db)
+
+		chain, _ := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil, nil)
+		return engine, blocks, chain
+	}
+	// A checkpoint with the local signer list instead of the contract's is rejected
+	_, blocks, chain := makeChain([]common.Address{addr1})
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != errMismatchingCheckpointSigners {
+		t.Fatalf("checkpoint error mismatch: have %v, want %v", err, errMismatchingCheckpointSigners)
+	}
+	// A checkpoint with the contract's signer list is accepted and applied
+	signers := []common.Address{addr1, addr2}
+	if bytes.Compare(addr2[:], addr1[:]) < 0 {
+		signers = []common.Address{addr2, addr1}
+	}
+	engine, blocks, chain := makeChain(signers)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert governed checkpoint: %v", err)
+	}
+	snap, err := engine.snapshot(chain, 3, blocks[2].Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve snapshot: %v", err)
+	}
+	if have := snap.signers(); !reflect.DeepEqual(have, signers) {
+		t.Fatalf("signer list mismatch: have %x, want %x", have, signers)
+	}
+	// Preparing a checkpoint block reads the signer list from the contract
+	header := &types.Header{ParentHash: blocks[1].Hash(), Number: big.NewInt(3)}
+	if err := engine.Prepare(chain, header); err != errNoStateAccess {
+		t.Fatalf("stateless prepare error mismatch: have %v, want %v", err, errNoStateAccess)
+	}
+	engine.SetStateFn(chain.StateAt)
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("failed to prepare checkpoint: %v", err)
+	}
+	if have := header.Extra[extraVanity : len(header.Extra)-extraSeal]; !bytes.Equal(have, packSigners(signers)) {
+		t.Fatalf("prepared signer list mismatch: have %x, want %x", have, packSigners(signers))
+	}
+	// Votes are rejected on governed chains
+	vote := types.CopyHeader(blocks[0].Header())
+	vote.Coinbase = addr2
+	if err := engine.verifyHeader(chain, vote, nil); err != errGovernedVote {
+		t.Fatalf("vote error mismatch: have %v, want %v", err, errGovernedVote)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// signerContractGas is the gas allowance for querying the governance contract.
+const signerContractGas = 50_000_000
+
+// signerContractABI is the interface the signer governance contract must
+// implement: a getter returning the current list of authorized signers.
+const signerContractABI = `[{"inputs":[],"name":"getSigners","outputs":[{"internalType":"address[]","name":"","type":"address[]"}],"stateMutability":"view","type":"function"}]`
+
+var signerContract abi.ABI
+
+func init() {
+	var err error
+	if signerContract, err = abi.JSON(strings.NewReader(signerContractABI)); err != nil {
+		panic(err)
+	}
+}
+
+var (
+	// errGovernedVote is returned if a block casts a signer vote while the signers
+	// are managed by a governance contract.
+	errGovernedVote = errors.New("signer vote on contract governed chain")
+
+	// errNoGovernedSigners is returned if the governance contract returns an empty
+	// signer list.
+	errNoGovernedSigners = errors.New("empty signer list from governance contract")
+
+	// errNoStateAccess is returned if the signer list of a checkpoint block needs
+	// to be read from the governance contract, but no state accessor was set.
+	errNoStateAccess = errors.New("no state access to governance contract")
+)
+
+// StateFn opens the state of a block for reading, given its state root.
+type StateFn func(root common.Hash) (*state.StateDB, error)
+
+// SetStateFn injects the state accessor needed to read the signer list from the
+// governance contract when preparing checkpoint blocks.
+func (c *Clique) SetStateFn(fn StateFn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stateFn = fn
+}
+
+// governed reports whether the signers are managed by a governance contract.
+func (c *Clique) governed() bool {
+	return c.config.SignerContract != nil
+}
+
+// VerifyPreState implements consensus.PreStateVerifier, checking that the signer
+// list of a checkpoint block matches the one held by the governance contract in
+// the parent state.
+func (c *Clique) VerifyPreState(chain consensus.ChainHeaderReader, header *types.Header, statedb *state.StateDB) error {
+	if !c.governed() || header.Number.Uint64()%c.config.Epoch != 0 {
+		return nil
+	}
+	signers, err := c.contractSigners(chain, header, statedb.Copy())
+	if err != nil {
+		return err
+	}
+	extraSuffix := len(header.Extra) - extraSeal
+	if !bytes.Equal(header.Extra[extraVanity:extraSuffix], packSigners(signers)) {
+		return errMismatchingCheckpointSigners
+	}
+	return nil
+}
+
+// checkpointSigners retrieves the signer list of the governance contract from
+// the state of the header's parent, to be embedded into a checkpoint block.
+func (c *Clique) checkpointSigners(chain consensus.ChainHeaderReader, header *types.Header) ([]common.Address, error) {
+	c.lock.RLock()
+	stateFn := c.stateFn
+	c.lock.RUnlock()
+
+	if stateFn == nil {
+		return nil, errNoStateAccess
+	}
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	statedb, err := stateFn(parent.Root)
+	if err != nil {
+		return nil, err
+	}
+	return c.contractSigners(chain, header, statedb)
+}
+
+// contractSigners calls the governance contract on top of the given state to
+// retrieve the signer list, returned sorted and deduplicated.
+func (c *Clique) contractSigners(chain consensus.ChainHeaderReader, header *types.Header, statedb *state.StateDB) ([]common.Address, error) {
+	input, err := signerContract.Pack("getSigners")
+	if err != nil {
+		return nil, err
+	}
+	var (
+		context = core.NewEVMBlockContext(header, chainContext{chain, c}, &header.Coinbase)
+		evm     = vm.NewEVM(context, vm.TxContext{}, statedb, chain.Config(), vm.Config{})
+	)
+	output, _, err := evm.StaticCall(vm.AccountRef(common.Address{}), *c.config.SignerContract, input, signerContractGas)
+	if err != nil {
+		return nil, fmt.Errorf("governance contract call failed: %w", err)
+	}
+	result, err := signerContract.Unpack("getSigners", output)
+	if err != nil {
+		return nil, fmt.Errorf("invalid governance contract output: %w", err)
+	}
+	listed := *abi.ConvertType(result[0], new([]common.Address)).(*[]common.Address)
+
+	sort.Sort(signersAscending(listed))
+	signers := make([]common.Address, 0, len(listed))
+	for i, signer := range listed {
+		if i == 0 || signer != listed[i-1] {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, errNoGovernedSigners
+	}
+	return signers, nil
+}
+
+// packSigners concatenates a list of signers into their extra-data form.
+func packSigners(signers []common.Address) []byte {
+	blob := make([]byte, len(signers)*common.AddressLength)
+	for i, signer := range signers {
+		copy(blob[i*common.AddressLength:], signer[:])
+	}
+	return blob
+}
+
+// chainContext adapts a header reader to the chain context needed to run the
+// EVM for governance contract calls.
+type chainContext struct {
+	consensus.ChainHeaderReader
+	engine consensus.Engine
+}
+
+// Engine retrieves the chain's consensus engine.
+func (c chainContext) Engine() consensus.Engine {
+	return c.engine
+}
@@ -204,6 +204,11 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 		logged = time.Now()
 	)
 	for i, header := range headers {
+		// If we're taking too much time (ecrecover), notify the user once a while
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Reconstructing voting history", "processed", i, "total", len(headers), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
 		// Remove any votes on checkpoint blocks
 		number := header.Number.Uint64()
 		if number%s.config.Epoch == 0 {
@@ -229,6 +234,26 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 		}
 		snap.Recents[number] = signer
 
+		// If the signers are managed by a governance contract, there are no votes to
+		// tally, but the signer list is replaced on checkpoint blocks. The list is
+		// only checked against the contract when the block is processed, so nodes
+		// not executing every block can't rely on it (full sync is enforced).
+		if s.config.SignerContract != nil {
+			if number%s.config.Epoch == 0 {
+				snap.Signers = make(map[common.Address]struct{})
+				for i := extraVanity; i < len(header.Extra)-extraSeal; i += common.AddressLength {
+					snap.Signers[common.BytesToAddress(header.Extra[i:i+common.AddressLength])] = struct{}{}
+				}
+				// Signer list changed, delete any recents outside the new window
+				limit := uint64(len(snap.Signers)/2 + 1)
+				for block := range snap.Recents {
+					if number >= limit && block <= number-limit {
+						delete(snap.Recents, block)
+					}
+				}
+			}
+			continue
+		}
 		// Header authorized, discard any previous votes from the signer
 		for i, vote := range snap.Votes {
 			if vote.Signer == signer && vote.Address == header.Coinbase {
@@ -291,11 +316,6 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 			}
 			delete(snap.Tally, header.Coinbase)
 		}
-		// If we're taking too much time (ecrecover), notify the user once a while
-		if time.Since(logged) > 8*time.Second {
-			log.Info("Reconstructing voting history", "processed", i, "total", len(headers), "elapsed", common.PrettyDuration(time.Since(start)))
-			logged = time.Now()
-		}
 	}
 	if time.Since(start) > 8*time.Second {
 		log.Info("Reconstructed voting history", "processed", len(headers), "elapsed", common.PrettyDuration(time.Since(start)))
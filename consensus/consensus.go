@@ -118,6 +118,14 @@ type Engine interface {
 	Close() error
 }
 
+// PreStateVerifier is an optional interface for consensus engines with header
+// fields that depend on the state the block is applied on top of.
+type PreStateVerifier interface {
+	// VerifyPreState checks the header against the state of its parent, before
+	// any of the block's transactions are applied.
+	VerifyPreState(chain ChainHeaderReader, header *types.Header, state *state.StateDB) error
+}
+
 // PoW is a consensus engine based on proof-of-work.
 type PoW interface {
 	Engine
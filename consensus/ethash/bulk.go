@@ -0,0 +1,266 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	// verifiedSealsLimit is the number of recently verified header hashes the
+	// engine remembers. It should cover a few import batches of a bulk verifier.
+	verifiedSealsLimit = 16384
+
+	// bulkCachesAhead is the number of upcoming epochs a bulk verifier generates
+	// the verification caches for in the background.
+	bulkCachesAhead = 2
+)
+
+// errBulkAborted is returned if a bulk seal verification is aborted.
+var errBulkAborted = errors.New("seal verification aborted")
+
+// newSealCache creates the cache of recently verified header hashes.
+func newSealCache() *simplelru.LRU {
+	cache, _ := simplelru.NewLRU(verifiedSealsLimit, nil)
+	return cache
+}
+
+// knownSeal reports whether the seal of the header was verified recently.
+func (ethash *Ethash) knownSeal(header *types.Header) bool {
+	if ethash.seals == nil {
+		return false
+	}
+	hash := header.Hash()
+
+	ethash.sealsLock.Lock()
+	defer ethash.sealsLock.Unlock()
+
+	return ethash.seals.Contains(hash)
+}
+
+// rememberSeal marks the seal of the header as verified.
+func (ethash *Ethash) rememberSeal(header *types.Header) {
+	if ethash.seals == nil {
+		return
+	}
+	hash := header.Hash()
+
+	ethash.sealsLock.Lock()
+	defer ethash.sealsLock.Unlock()
+
+	ethash.seals.Add(hash, struct{}{})
+}
+
+// BulkVerifier checks the seals of long runs of consecutive headers, such as the
+// ones in exported chain files, across all available cores. The verification
+// caches of the upcoming epochs are generated in the background, so the workers
+// don't stall on epoch transitions.
+//
+// Headers with valid seals are remembered by the engine, so the header
+// verification during the subsequent chain insertion skips the PoW check.
+type BulkVerifier struct {
+	ethash  *Ethash
+	workers int
+
+	caches map[uint64]*cache // Verification caches generated ahead, keyed by epoch
+	lock   sync.Mutex        // Protects the caches and the statistics
+
+	verified uint64        // Number of seals verified so far
+	elapsed  time.Duration // Time spent verifying seals so far
+}
+
+// NewBulkVerifier creates a seal verifier for bulk imports running the given
+// number of workers. If workers is not positive, all available cores are used.
+func (ethash *Ethash) NewBulkVerifier(workers int) *BulkVerifier {
+	if ethash.shared != nil {
+		ethash = ethash.shared
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &BulkVerifier{
+		ethash:  ethash,
+		workers: workers,
+		caches:  make(map[uint64]*cache),
+	}
+}
+
+// Verify checks the seals of a batch of headers in parallel, returning an error
+// for an invalid one if any.
+func (b *BulkVerifier) Verify(headers []*types.Header, abort <-chan struct{}) error {
+	if len(headers) == 0 {
+		return nil
+	}
+	if b.ethash.config.PowMode == ModeFake || b.ethash.config.PowMode == ModeFullFake {
+		return nil
+	}
+	start := time.Now()
+
+	// Make sure the caches of the batch and the few epochs after it are ready
+	// or being generated
+	first, last := headers[0].Number.Uint64(), headers[0].Number.Uint64()
+	for _, header := range headers[1:] {
+		if number := header.Number.Uint64(); number < first {
+			first = number
+		} else if number > last {
+			last = number
+		}
+	}
+	b.prepare(first/epochLength, last/epochLength+bulkCachesAhead)
+
+	// Spread the headers across the workers, stopping early on failure
+	var (
+		next   = int64(-1)
+		failed int32
+		errs   = make([]error, len(headers))
+		pend   sync.WaitGroup
+	)
+	workers := b.workers
+	if workers > len(headers) {
+		workers = len(headers)
+	}
+	pend.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer pend.Done()
+			for {
+				index := int(atomic.AddInt64(&next, 1))
+				if index >= len(headers) || atomic.LoadInt32(&failed) != 0 {
+					return
+				}
+				select {
+				case <-abort:
+					atomic.StoreInt32(&failed, 1)
+					return
+				default:
+				}
+				if errs[index] = b.verify(headers[index]); errs[index] != nil {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	pend.Wait()
+
+	b.lock.Lock()
+	b.verified += uint64(len(headers))
+	b.elapsed += time.Since(start)
+	b.lock.Unlock()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("header #%d [%x…]: %w", headers[i].Number, headers[i].Hash().Bytes()[:4], err)
+		}
+	}
+	select {
+	case <-abort:
+		return errBulkAborted
+	default:
+	}
+	return nil
+}
+
+// Stats returns the number of seals verified and the time spent on it so far.
+func (b *BulkVerifier) Stats() (uint64, time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.verified, b.elapsed
+}
+
+// Close releases the verification caches held by the verifier.
+func (b *BulkVerifier) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.caches = make(map[uint64]*cache)
+}
+
+// verify checks the seal of a single header against the cache of its epoch.
+func (b *BulkVerifier) verify(header *types.Header) error {
+	if header.Difficulty.Sign() <= 0 {
+		return errInvalidDifficulty
+	}
+	number := header.Number.Uint64()
+	cache := b.cache(number / epochLength)
+
+	size := datasetSize(number)
+	if b.ethash.config.PowMode == ModeTest {
+		size = 32 * 1024
+	}
+	digest, result := hashimotoLight(size, cache.cache, b.ethash.SealHash(header).Bytes(), header.Nonce.Uint64())
+
+	// Caches are unmapped in a finalizer. Ensure that the cache stays alive
+	// until after the call to hashimotoLight so it's not unmapped while being used.
+	runtime.KeepAlive(cache)
+
+	if err := checkSeal(header, digest, result); err != nil {
+		return err
+	}
+	b.ethash.rememberSeal(header)
+	return nil
+}
+
+// cache retrieves the verification cache of an epoch, waiting for it to be
+// generated if needed.
+func (b *BulkVerifier) cache(epoch uint64) *cache {
+	b.lock.Lock()
+	c := b.caches[epoch]
+	if c == nil {
+		c = newCache(epoch).(*cache)
+		b.caches[epoch] = c
+	}
+	b.lock.Unlock()
+
+	b.generate(c)
+	return c
+}
+
+// prepare drops the caches of the epochs before from and starts generating the
+// missing ones up to and including to in the background.
+func (b *BulkVerifier) prepare(from, to uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for epoch := range b.caches {
+		if epoch < from {
+			delete(b.caches, epoch)
+		}
+	}
+	for epoch := from; epoch <= to; epoch++ {
+		if _, ok := b.caches[epoch]; !ok {
+			c := newCache(epoch).(*cache)
+			b.caches[epoch] = c
+			go b.generate(c)
+		}
+	}
+}
+
+// generate creates the verification cache if it isn't done yet, loading it from
+// or storing it to disk if so configured.
+func (b *BulkVerifier) generate(c *cache) {
+	config := b.ethash.config
+	c.generate(config.CacheDir, config.CachesOnDisk, config.CachesLockMmap, config.PowMode == ModeTest)
+}
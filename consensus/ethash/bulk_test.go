@@ -0,0 +1,81 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that the bulk verifier accepts valid seals, remembering them for the
+// subsequent header verification, and rejects invalid ones.
+func TestBulkVerifier(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+
+	// Seal a batch of headers spanning an epoch transition
+	var headers []*types.Header
+	for _, number := range []uint64{1, 2, epochLength - 1, epochLength, epochLength + 1} {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: big.NewInt(100)}
+
+		results := make(chan *types.Block)
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+			t.Fatalf("failed to seal block %d: %v", number, err)
+		}
+		select {
+		case block := <-results:
+			headers = append(headers, block.Header())
+		case <-time.NewTimer(4 * time.Second).C:
+			t.Fatalf("sealing result timeout for block %d", number)
+		}
+	}
+	verifier := ethash.NewBulkVerifier(0)
+	defer verifier.Close()
+
+	if err := verifier.Verify(headers, nil); err != nil {
+		t.Fatalf("failed to verify valid seals: %v", err)
+	}
+	for _, header := range headers {
+		if !ethash.knownSeal(header) {
+			t.Errorf("seal of header %d not remembered", header.Number)
+		}
+	}
+	if verified, _ := verifier.Stats(); verified != uint64(len(headers)) {
+		t.Errorf("verified seal count mismatch: have %d, want %d", verified, len(headers))
+	}
+	// Tamper with one of the seals and ensure it's rejected
+	bad := types.CopyHeader(headers[3])
+	bad.Nonce = types.EncodeNonce(bad.Nonce.Uint64() + 1)
+
+	tampered := append(append([]*types.Header{}, headers[:3]...), bad)
+	if err := verifier.Verify(tampered, nil); !errors.Is(err, errInvalidMixDigest) {
+		t.Fatalf("invalid seal error mismatch: have %v, want %v", err, errInvalidMixDigest)
+	}
+	if ethash.knownSeal(bad) {
+		t.Errorf("invalid seal remembered")
+	}
+	// Ensure an aborted verification reports it
+	abort := make(chan struct{})
+	close(abort)
+	if err := verifier.Verify(headers, abort); err != errBulkAborted {
+		t.Fatalf("aborted verification error mismatch: have %v, want %v", err, errBulkAborted)
+	}
+}
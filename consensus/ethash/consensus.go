@@ -510,6 +510,10 @@ func (ethash *Ethash) verifySeal(chain consensus.ChainHeaderReader, header *type
 	if header.Difficulty.Sign() <= 0 {
 		return errInvalidDifficulty
 	}
+	// If the seal was checked recently (e.g. by a bulk verifier), accept it
+	if ethash.knownSeal(header) {
+		return nil
+	}
 	// Recompute the digest and PoW values
 	number := header.Number.Uint64()
 
@@ -545,7 +549,16 @@ func (ethash *Ethash) verifySeal(chain consensus.ChainHeaderReader, header *type
 		// until after the call to hashimotoLight so it's not unmapped while being used.
 		runtime.KeepAlive(cache)
 	}
-	// Verify the calculated values against the ones provided in the header
+	if err := checkSeal(header, digest, result); err != nil {
+		return err
+	}
+	ethash.rememberSeal(header)
+	return nil
+}
+
+// checkSeal verifies the calculated PoW values against the ones provided in the
+// header.
+func checkSeal(header *types.Header, digest []byte, result []byte) error {
 	if !bytes.Equal(header.MixDigest[:], digest) {
 		return errInvalidMixDigest
 	}
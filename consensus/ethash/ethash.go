@@ -435,6 +435,9 @@ type Ethash struct {
 	caches   *lru // In memory caches to avoid regenerating too often
 	datasets *lru // In memory datasets to avoid regenerating too often
 
+	seals     *simplelru.LRU // Hashes of recently verified headers to avoid rechecking their seals
+	sealsLock sync.Mutex     // Ensures thread safety for the verified seal cache
+
 	// Mining related fields
 	rand     *rand.Rand    // Properly seeded random source for nonces
 	threads  int           // Number of threads to mine on if mining
@@ -472,6 +475,7 @@ func New(config Config, notify []string, noverify bool) *Ethash {
 		config:   config,
 		caches:   newlru("cache", config.CachesInMem, newCache),
 		datasets: newlru("dataset", config.DatasetsInMem, newDataset),
+		seals:    newSealCache(),
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeterForced(),
 	}
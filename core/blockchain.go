@@ -52,6 +52,9 @@ var (
 	headHeaderGauge    = metrics.NewRegisteredGauge("chain/head/header", nil)
 	headFastBlockGauge = metrics.NewRegisteredGauge("chain/head/receipt", nil)
 
+	headFinalizedBlockGauge = metrics.NewRegisteredGauge("chain/head/finalized", nil)
+	headSafeBlockGauge      = metrics.NewRegisteredGauge("chain/head/safe", nil)
+
 	accountReadTimer   = metrics.NewRegisteredTimer("chain/account/reads", nil)
 	accountHashTimer   = metrics.NewRegisteredTimer("chain/account/hashes", nil)
 	accountUpdateTimer = metrics.NewRegisteredTimer("chain/account/updates", nil)
@@ -188,6 +191,9 @@ type BlockChain struct {
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
+	currentFinalizedBlock atomic.Value // Latest block considered final by the consensus (nil if none)
+	currentSafeBlock      atomic.Value // Latest block considered safe from reorgs (nil if none)
+
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache  *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
@@ -263,6 +269,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	var nilBlock *types.Block
 	bc.currentBlock.Store(nilBlock)
 	bc.currentFastBlock.Store(nilBlock)
+	bc.currentFinalizedBlock.Store(nilBlock)
+	bc.currentSafeBlock.Store(nilBlock)
 
 	// Initialize the chain with ancient data if it isn't empty.
 	var txIndexBlock uint64
@@ -456,6 +464,19 @@ func (bc *BlockChain) loadLastState() error {
 			headFastBlockGauge.Update(int64(block.NumberU64()))
 		}
 	}
+	// Restore the finality markers if still part of the canonical chain
+	var nilBlock *types.Block
+	bc.currentFinalizedBlock.Store(nilBlock)
+	bc.currentSafeBlock.Store(nilBlock)
+
+	if block := bc.canonicalBlock(rawdb.ReadFinalizedBlockHash(bc.db)); block != nil {
+		bc.currentFinalizedBlock.Store(block)
+		headFinalizedBlockGauge.Update(int64(block.NumberU64()))
+	}
+	if block := bc.canonicalBlock(rawdb.ReadSafeBlockHash(bc.db)); block != nil {
+		bc.currentSafeBlock.Store(block)
+		headSafeBlockGauge.Update(int64(block.NumberU64()))
+	}
 	// Issue a status log for the user
 	currentFastBlock := bc.CurrentFastBlock()
 
@@ -469,9 +490,25 @@ func (bc *BlockChain) loadLastState() error {
 	if pivot := rawdb.ReadLastPivotNumber(bc.db); pivot != nil {
 		log.Info("Loaded last fast-sync pivot marker", "number", *pivot)
 	}
+	if block := bc.CurrentFinalizedBlock(); block != nil {
+		log.Info("Loaded most recent finalized block", "number", block.Number(), "hash", block.Hash(), "age", common.PrettyAge(time.Unix(int64(block.Time()), 0)))
+	}
 	return nil
 }
 
+// canonicalBlock retrieves a block by hash if it's part of the canonical chain
+// up to the current head, or nil otherwise.
+func (bc *BlockChain) canonicalBlock(hash common.Hash) *types.Block {
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	block := bc.GetBlockByHash(hash)
+	if block == nil || block.NumberU64() > bc.CurrentBlock().NumberU64() || bc.GetCanonicalHash(block.NumberU64()) != hash {
+		return nil
+	}
+	return block
+}
+
 // SetHead rewinds the local chain to a new head. Depending on whether the node
 // was fast synced or full synced and in which state, the method will try to
 // delete minimal data from disk whilst retaining chain consistency.
@@ -620,6 +657,9 @@ func (bc *BlockChain) SetHeadBeyondRoot(head uint64, root common.Hash) (uint64,
 	bc.txLookupCache.Purge()
 	bc.futureBlocks.Purge()
 
+	// Drop any finality markers that are no longer part of the canonical chain
+	bc.dropFinality(bc.CurrentBlock().NumberU64())
+
 	return rootNumber, bc.loadLastState()
 }
 
@@ -648,6 +688,151 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	return nil
 }
 
+// InsertAnchorBlock inserts a trusted block, along with its receipts, whose
+// ancestors may not be known yet, making it the head header and head fast block.
+// The state of the block still needs to be synced and committed afterwards via
+// FastSyncCommitHead.
+//
+// If the parent of the block is unknown, its total difficulty can't be computed
+// yet, so a provisional one is used, counting every missing ancestor with the
+// minimum difficulty of one. It is corrected once BackfillHeaderChain links the
+// block up with the local chain.
+func (bc *BlockChain) InsertAnchorBlock(block *types.Block, receipts types.Receipts) error {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	var (
+		hash   = block.Hash()
+		number = block.NumberU64()
+	)
+	if number == 0 {
+		return errors.New("genesis can't be an anchor")
+	}
+	td := bc.GetTd(block.ParentHash(), number-1)
+	if td == nil {
+		td = new(big.Int).Add(bc.GetTd(bc.genesisBlock.Hash(), 0), new(big.Int).SetUint64(number-1))
+	}
+	td = new(big.Int).Add(td, block.Difficulty())
+
+	batch := bc.db.NewBatch()
+	rawdb.WriteTd(batch, hash, number, td)
+	rawdb.WriteBlock(batch, block)
+	rawdb.WriteReceipts(batch, hash, number, receipts)
+	rawdb.WriteTxLookupEntriesByBlock(batch, block)
+	rawdb.WriteCanonicalHash(batch, hash, number)
+	for i := number + 1; ; i++ {
+		if rawdb.ReadCanonicalHash(bc.db, i) == (common.Hash{}) {
+			break
+		}
+		rawdb.DeleteCanonicalHash(batch, i)
+	}
+	rawdb.WriteHeadHeaderHash(batch, hash)
+	rawdb.WriteHeadFastBlockHash(batch, hash)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	bc.hc.SetCurrentHeader(block.Header())
+	bc.currentFastBlock.Store(block)
+	headFastBlockGauge.Update(int64(number))
+
+	log.Info("Inserted anchor block", "number", number, "hash", hash, "td", td)
+	return nil
+}
+
+// BackfillHeaderChain inserts a batch of trusted headers below a block inserted
+// with InsertAnchorBlock, from the oldest one forward. The parent of the first
+// header must be known locally. The headers are made canonical without checking
+// their seals, as they are already linked to the trusted anchor by hash.
+//
+// Once the headers link up with the canonical header above them, the total
+// difficulties of that header and all its descendants are corrected.
+func (bc *BlockChain) BackfillHeaderChain(headers []*types.Header) (int, error) {
+	if len(headers) == 0 {
+		return 0, nil
+	}
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	first := headers[0]
+	td := bc.GetTd(first.ParentHash, first.Number.Uint64()-1)
+	if td == nil {
+		return 0, consensus.ErrUnknownAncestor
+	}
+	batch := bc.db.NewBatch()
+	for i, header := range headers {
+		if i > 0 && (header.Number.Uint64() != headers[i-1].Number.Uint64()+1 || header.ParentHash != headers[i-1].Hash()) {
+			return i, fmt.Errorf("non contiguous insert: item %d is #%d [%x..], item %d is #%d [%x..] (parent [%x..])", i-1, headers[i-1].Number,
+				headers[i-1].Hash().Bytes()[:4], i, header.Number, header.Hash().Bytes()[:4], header.ParentHash.Bytes()[:4])
+		}
+		var (
+			hash   = header.Hash()
+			number = header.Number.Uint64()
+		)
+		td = new(big.Int).Add(td, header.Difficulty)
+		rawdb.WriteHeader(batch, header)
+		rawdb.WriteTd(batch, hash, number, td)
+		rawdb.WriteCanonicalHash(batch, hash, number)
+	}
+	if err := batch.Write(); err != nil {
+		return 0, err
+	}
+	// If the headers linked up with the chain above, correct its difficulties
+	last := headers[len(headers)-1]
+	if next := bc.GetHeaderByNumber(last.Number.Uint64() + 1); next != nil && next.ParentHash == last.Hash() {
+		if have := bc.GetTd(next.Hash(), next.Number.Uint64()); have != nil {
+			want := new(big.Int).Add(td, next.Difficulty)
+			if delta := new(big.Int).Sub(want, have); delta.Sign() != 0 {
+				if err := bc.shiftTd(next, delta); err != nil {
+					return len(headers), err
+				}
+			}
+		}
+	}
+	return len(headers), nil
+}
+
+// shiftTd adjusts the total difficulty of the given header and all its known
+// descendants by delta.
+func (bc *BlockChain) shiftTd(origin *types.Header, delta *big.Int) error {
+	var (
+		batch   = bc.db.NewBatch()
+		parents = map[common.Hash]bool{origin.ParentHash: true}
+	)
+	for number := origin.Number.Uint64(); len(parents) > 0; number++ {
+		children := make(map[common.Hash]bool)
+		for _, hash := range rawdb.ReadAllHashes(bc.db, number) {
+			header := rawdb.ReadHeader(bc.db, hash, number)
+			if header == nil || !parents[header.ParentHash] {
+				continue
+			}
+			if td := rawdb.ReadTd(bc.db, hash, number); td != nil {
+				rawdb.WriteTd(batch, hash, number, td.Add(td, delta))
+			}
+			children[hash] = true
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		parents = children
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	bc.hc.tdCache.Purge()
+
+	log.Info("Corrected anchored total difficulties", "number", origin.Number, "hash", origin.Hash(), "delta", delta)
+	return nil
+}
+
 // GasLimit returns the gas limit of the current HEAD block.
 func (bc *BlockChain) GasLimit() uint64 {
 	return bc.CurrentBlock().GasLimit()
@@ -670,6 +855,66 @@ func (bc *BlockChain) CurrentFastBlock() *types.Block {
 	return bc.currentFastBlock.Load().(*types.Block)
 }
 
+// CurrentFinalizedBlock retrieves the latest block considered final by the
+// consensus, or nil if no block was finalized yet.
+func (bc *BlockChain) CurrentFinalizedBlock() *types.Block {
+	return bc.currentFinalizedBlock.Load().(*types.Block)
+}
+
+// CurrentSafeBlock retrieves the latest block considered safe from reorgs, or
+// nil if no block is considered safe yet.
+func (bc *BlockChain) CurrentSafeBlock() *types.Block {
+	return bc.currentSafeBlock.Load().(*types.Block)
+}
+
+// SetFinalized marks a canonical block as final, moving the safe block up to it
+// too if lagging behind.
+func (bc *BlockChain) SetFinalized(block *types.Block) error {
+	if bc.canonicalBlock(block.Hash()) == nil {
+		return fmt.Errorf("finalized block %d [%x..] not canonical", block.NumberU64(), block.Hash().Bytes()[:4])
+	}
+	bc.currentFinalizedBlock.Store(block)
+	rawdb.WriteFinalizedBlockHash(bc.db, block.Hash())
+	headFinalizedBlockGauge.Update(int64(block.NumberU64()))
+
+	if safe := bc.CurrentSafeBlock(); safe == nil || safe.NumberU64() < block.NumberU64() {
+		bc.currentSafeBlock.Store(block)
+		rawdb.WriteSafeBlockHash(bc.db, block.Hash())
+		headSafeBlockGauge.Update(int64(block.NumberU64()))
+	}
+	return nil
+}
+
+// SetSafe marks a canonical block as safe from reorgs.
+func (bc *BlockChain) SetSafe(block *types.Block) error {
+	if bc.canonicalBlock(block.Hash()) == nil {
+		return fmt.Errorf("safe block %d [%x..] not canonical", block.NumberU64(), block.Hash().Bytes()[:4])
+	}
+	if final := bc.CurrentFinalizedBlock(); final != nil && final.NumberU64() > block.NumberU64() {
+		return fmt.Errorf("safe block %d below finalized block %d", block.NumberU64(), final.NumberU64())
+	}
+	bc.currentSafeBlock.Store(block)
+	rawdb.WriteSafeBlockHash(bc.db, block.Hash())
+	headSafeBlockGauge.Update(int64(block.NumberU64()))
+	return nil
+}
+
+// dropFinality clears the finality markers pointing above the given number,
+// which got reorged out of the canonical chain.
+func (bc *BlockChain) dropFinality(number uint64) {
+	var nilBlock *types.Block
+	if block := bc.CurrentFinalizedBlock(); block != nil && block.NumberU64() > number {
+		log.Warn("Finalized block reorged out", "number", block.Number(), "hash", block.Hash())
+		bc.currentFinalizedBlock.Store(nilBlock)
+		rawdb.WriteFinalizedBlockHash(bc.db, common.Hash{})
+	}
+	if block := bc.CurrentSafeBlock(); block != nil && block.NumberU64() > number {
+		log.Warn("Safe block reorged out", "number", block.Number(), "hash", block.Hash())
+		bc.currentSafeBlock.Store(nilBlock)
+		rawdb.WriteSafeBlockHash(bc.db, common.Hash{})
+	}
+}
+
 // Validator returns the current validator.
 func (bc *BlockChain) Validator() Validator {
 	return bc.validator
@@ -1686,6 +1931,75 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 	return n, err
 }
 
+// SetCanonical makes the given block the head of the canonical chain, reorging
+// or rewinding the chain onto it regardless of the total difficulty. The block
+// must already be present. It's meant for external consensus drivers choosing
+// the head themselves.
+func (bc *BlockChain) SetCanonical(head *types.Block) error {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	if !bc.HasBlock(head.Hash(), head.NumberU64()) {
+		return fmt.Errorf("unknown block %d [%x..]", head.NumberU64(), head.Hash().Bytes()[:4])
+	}
+	// Side chain blocks are stored without state, process them (and any of their
+	// stateless ancestors) before switching over
+	if !bc.HasState(head.Root()) {
+		var blocks types.Blocks
+		for block := head; !bc.HasState(block.Root()); {
+			blocks = append(blocks, block)
+			if block = bc.GetBlock(block.ParentHash(), block.NumberU64()-1); block == nil {
+				return fmt.Errorf("missing ancestor of block %d [%x..]", head.NumberU64(), head.Hash().Bytes()[:4])
+			}
+		}
+		for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+			blocks[i], blocks[j] = blocks[j], blocks[i]
+		}
+		if _, err := bc.insertChain(blocks, false); err != nil {
+			return err
+		}
+	}
+	current := bc.CurrentBlock()
+	if current.Hash() == head.Hash() {
+		return nil
+	}
+	if head.ParentHash() != current.Hash() {
+		if err := bc.reorg(current, head); err != nil {
+			return err
+		}
+	}
+	bc.writeHeadBlock(head)
+
+	// Delete the canonical number assignments above the new head, left over if
+	// the chain was rewound
+	batch := bc.db.NewBatch()
+	for i := head.NumberU64() + 1; ; i++ {
+		hash := rawdb.ReadCanonicalHash(bc.db, i)
+		if hash == (common.Hash{}) {
+			break
+		}
+		rawdb.DeleteCanonicalHash(batch, i)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete stale canonical hashes", "err", err)
+	}
+	var logs []*types.Log
+	for _, receipt := range rawdb.ReadReceipts(bc.db, head.Hash(), head.NumberU64(), bc.chainConfig) {
+		logs = append(logs, receipt.Logs...)
+	}
+	bc.chainFeed.Send(ChainEvent{Block: head, Hash: head.Hash(), Logs: logs})
+	if len(logs) > 0 {
+		bc.logsFeed.Send(logs)
+	}
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: head})
+
+	log.Info("Chain head was updated", "number", head.Number(), "hash", head.Hash())
+	return nil
+}
+
 // InsertChainWithoutSealVerification works exactly the same
 // except for seal verification, seal verification is omitted
 func (bc *BlockChain) InsertChainWithoutSealVerification(block *types.Block) (int, error) {
@@ -2248,9 +2562,15 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		blockReorgAddMeter.Mark(int64(len(newChain)))
 		blockReorgDropMeter.Mark(int64(len(oldChain)))
 		blockReorgMeter.Mark(1)
+	} else if len(oldChain) > 0 || len(newChain) > 1 {
+		// Rewinding onto an ancestor or jumping ahead onto a descendant, only
+		// possible if the head is chosen externally
+		log.Info("Chain head moved along its branch", "number", commonBlock.Number(), "hash", commonBlock.Hash(), "drop", len(oldChain), "add", len(newChain))
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
+	bc.dropFinality(commonBlock.NumberU64())
+
 	// Insert the new chain(except the head block(reverse order)),
 	// taking care of the proper incremental order.
 	for i := len(newChain) - 1; i >= 1; i-- {
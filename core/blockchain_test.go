@@ -374,6 +374,186 @@ func testReorgShort(t *testing.T, full bool) {
 	testReorg(t, easy, diff, 12615120, full)
 }
 
+// Tests that the finalized and safe blocks are persisted across restarts and get
+// dropped if reorged out of the canonical chain. The two chains share their first
+// two blocks.
+func TestFinalityMarkers(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	easyBlocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 3, func(i int, b *BlockGen) {
+		b.OffsetTime([]int64{0, 0, -9}[i])
+	})
+	diffBlocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 4, func(i int, b *BlockGen) {
+		b.OffsetTime([]int64{0, 0, 0, -9}[i])
+	})
+	if _, err := blockchain.InsertChain(easyBlocks); err != nil {
+		t.Fatalf("failed to insert easy chain: %v", err)
+	}
+	// Non-canonical blocks and safe blocks below the finalized one are rejected
+	if err := blockchain.SetFinalized(diffBlocks[2]); err == nil {
+		t.Fatalf("non-canonical block finalized")
+	}
+	if err := blockchain.SetFinalized(easyBlocks[1]); err != nil {
+		t.Fatalf("failed to set finalized block: %v", err)
+	}
+	if err := blockchain.SetSafe(easyBlocks[0]); err == nil {
+		t.Fatalf("safe block below finalized accepted")
+	}
+	if err := blockchain.SetSafe(easyBlocks[2]); err != nil {
+		t.Fatalf("failed to set safe block: %v", err)
+	}
+	blockchain.Stop()
+
+	// Reopen the chain and check that the markers are restored
+	blockchain, _ = NewBlockChain(db, nil, params.AllEthashProtocolChanges, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+
+	if block := blockchain.CurrentFinalizedBlock(); block == nil || block.Hash() != easyBlocks[1].Hash() {
+		t.Fatalf("finalized block mismatch: have %v, want %x", block, easyBlocks[1].Hash())
+	}
+	if block := blockchain.CurrentSafeBlock(); block == nil || block.Hash() != easyBlocks[2].Hash() {
+		t.Fatalf("safe block mismatch: have %v, want %x", block, easyBlocks[2].Hash())
+	}
+	// Reorg the safe block out and check that only that one is dropped
+	if _, err := blockchain.InsertChain(diffBlocks); err != nil {
+		t.Fatalf("failed to insert difficult chain: %v", err)
+	}
+	if block := blockchain.CurrentFinalizedBlock(); block == nil || block.Hash() != easyBlocks[1].Hash() {
+		t.Fatalf("finalized block mismatch after reorg: have %v, want %x", block, easyBlocks[1].Hash())
+	}
+	if block := blockchain.CurrentSafeBlock(); block != nil {
+		t.Fatalf("safe block not dropped: %x", block.Hash())
+	}
+	if hash := rawdb.ReadSafeBlockHash(db); hash != (common.Hash{}) {
+		t.Fatalf("safe block hash not dropped: %x", hash)
+	}
+	// Rewind the head below the finalized block and check that it's dropped too
+	if err := blockchain.SetHead(1); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if block := blockchain.CurrentFinalizedBlock(); block != nil {
+		t.Fatalf("finalized block not dropped after rewind: %x", block.Hash())
+	}
+	if hash := rawdb.ReadFinalizedBlockHash(db); hash != (common.Hash{}) {
+		t.Fatalf("finalized block hash not dropped after rewind: %x", hash)
+	}
+}
+
+// Tests that the head can be moved onto any known block regardless of the total
+// difficulty, rewinding the chain or reorging onto a lighter side chain.
+// Tests that a block inserted as an anchor without its ancestors becomes the head
+// header, and that backfilling the headers below it links it up with the chain,
+// correcting the total difficulties of the anchor and its descendants.
+func TestAnchorBackfill(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	blocks, receipts := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 10, func(i int, b *BlockGen) {})
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	want := func(block *types.Block) *big.Int {
+		td := new(big.Int).Set(blockchain.GetTd(blockchain.genesisBlock.Hash(), 0))
+		for _, header := range headers[:block.NumberU64()] {
+			td.Add(td, header.Difficulty)
+		}
+		return td
+	}
+	anchor := blocks[6]
+	if err := blockchain.InsertAnchorBlock(anchor, receipts[6]); err != nil {
+		t.Fatalf("failed to insert anchor: %v", err)
+	}
+	if head := blockchain.CurrentHeader(); head.Hash() != anchor.Hash() {
+		t.Fatalf("head header mismatch: have %x, want %x", head.Hash(), anchor.Hash())
+	}
+	if head := blockchain.CurrentFastBlock(); head.Hash() != anchor.Hash() {
+		t.Fatalf("head fast block mismatch: have %x, want %x", head.Hash(), anchor.Hash())
+	}
+	if blockchain.GetHeaderByNumber(anchor.NumberU64()-1) != nil {
+		t.Fatalf("anchor parent known before backfill")
+	}
+	// Extend the chain above the anchor, on top of the provisional difficulty
+	if _, err := blockchain.InsertHeaderChain(headers[7:], 1); err != nil {
+		t.Fatalf("failed to insert headers above anchor: %v", err)
+	}
+	// Backfill the headers below the anchor in two batches
+	if _, err := blockchain.BackfillHeaderChain(headers[3:6]); err == nil {
+		t.Fatalf("backfill with unknown ancestors succeeded")
+	}
+	if _, err := blockchain.BackfillHeaderChain(headers[:3]); err != nil {
+		t.Fatalf("failed to backfill headers: %v", err)
+	}
+	if _, err := blockchain.BackfillHeaderChain(headers[3:6]); err != nil {
+		t.Fatalf("failed to backfill headers: %v", err)
+	}
+	for _, block := range blocks {
+		if hash := blockchain.GetCanonicalHash(block.NumberU64()); hash != block.Hash() {
+			t.Errorf("canonical hash #%d mismatch: have %x, want %x", block.NumberU64(), hash, block.Hash())
+		}
+		if td := blockchain.GetTd(block.Hash(), block.NumberU64()); td.Cmp(want(block)) != 0 {
+			t.Errorf("total difficulty #%d mismatch: have %v, want %v", block.NumberU64(), td, want(block))
+		}
+	}
+	if head := blockchain.CurrentHeader(); head.Hash() != blocks[9].Hash() {
+		t.Fatalf("head header mismatch after backfill: have %x, want %x", head.Hash(), blocks[9].Hash())
+	}
+}
+
+func TestSetCanonical(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	// Build a heavy canonical chain and a light side chain off its second block
+	canon, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 6, func(i int, b *BlockGen) {
+		b.OffsetTime(-9)
+	})
+	side, _ := GenerateChain(params.TestChainConfig, canon[1], ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	if _, err := blockchain.InsertChain(canon); err != nil {
+		t.Fatalf("failed to insert canonical chain: %v", err)
+	}
+	if _, err := blockchain.InsertChain(side); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != canon[5].Hash() {
+		t.Fatalf("side chain became canonical: %d [%x]", head.Number(), head.Hash())
+	}
+	// Rewind the chain onto an ancestor
+	if err := blockchain.SetCanonical(canon[3]); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != canon[3].Hash() {
+		t.Fatalf("head mismatch after rewind: have %x, want %x", head.Hash(), canon[3].Hash())
+	}
+	if block := blockchain.GetBlockByNumber(canon[4].NumberU64()); block != nil {
+		t.Fatalf("canonical block above head after rewind: %x", block.Hash())
+	}
+	// Reorg onto the side chain
+	if err := blockchain.SetCanonical(side[1]); err != nil {
+		t.Fatalf("failed to reorg chain: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != side[1].Hash() {
+		t.Fatalf("head mismatch after reorg: have %x, want %x", head.Hash(), side[1].Hash())
+	}
+	if hash := blockchain.GetCanonicalHash(side[0].NumberU64()); hash != side[0].Hash() {
+		t.Fatalf("canonical hash mismatch after reorg: have %x, want %x", hash, side[0].Hash())
+	}
+	// Unknown blocks are rejected
+	if err := blockchain.SetCanonical(makeBlockChain(side[1], 1, ethash.NewFaker(), db, forkSeed)[0]); err == nil {
+		t.Fatalf("unknown block set as head")
+	}
+}
+
 func testReorg(t *testing.T, first, second []int64, td int64, full bool) {
 	// Create a pristine chain and database
 	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, full)
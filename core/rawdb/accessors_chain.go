@@ -188,6 +188,38 @@ func WriteHeadFastBlockHash(db ethdb.KeyValueWriter, hash common.Hash) {
 	}
 }
 
+// ReadFinalizedBlockHash retrieves the hash of the latest finalized block.
+func ReadFinalizedBlockHash(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(headFinalizedBlockKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteFinalizedBlockHash stores the hash of the latest finalized block.
+func WriteFinalizedBlockHash(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(headFinalizedBlockKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store last finalized block's hash", "err", err)
+	}
+}
+
+// ReadSafeBlockHash retrieves the hash of the latest safe block.
+func ReadSafeBlockHash(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(headSafeBlockKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteSafeBlockHash stores the hash of the latest safe block.
+func WriteSafeBlockHash(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(headSafeBlockKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store last safe block's hash", "err", err)
+	}
+}
+
 // ReadLastPivotNumber retrieves the number of the last pivot block. If the node
 // full synced, the last pivot will always be nil.
 func ReadLastPivotNumber(db ethdb.KeyValueReader) *uint64 {
@@ -214,6 +246,47 @@ func WriteLastPivotNumber(db ethdb.KeyValueWriter, pivot uint64) {
 	}
 }
 
+// anchorBackfill is the progress of the header backfill below a sync anchor.
+type anchorBackfill struct {
+	Anchor common.Hash // Hash of the anchor block being backfilled
+	Tail   uint64      // Number of the lowest header downloaded so far
+}
+
+// ReadAnchorBackfill retrieves the hash of the sync anchor whose ancestors are
+// being backfilled and the number of the lowest header downloaded so far. The
+// hash is empty if no backfill is in progress.
+func ReadAnchorBackfill(db ethdb.KeyValueReader) (common.Hash, uint64) {
+	data, _ := db.Get(anchorBackfillKey)
+	if len(data) == 0 {
+		return common.Hash{}, 0
+	}
+	var progress anchorBackfill
+	if err := rlp.DecodeBytes(data, &progress); err != nil {
+		log.Error("Invalid anchor backfill progress in database", "err", err)
+		return common.Hash{}, 0
+	}
+	return progress.Anchor, progress.Tail
+}
+
+// WriteAnchorBackfill stores the progress of the header backfill below a sync
+// anchor.
+func WriteAnchorBackfill(db ethdb.KeyValueWriter, anchor common.Hash, tail uint64) {
+	enc, err := rlp.EncodeToBytes(&anchorBackfill{Anchor: anchor, Tail: tail})
+	if err != nil {
+		log.Crit("Failed to encode anchor backfill progress", "err", err)
+	}
+	if err := db.Put(anchorBackfillKey, enc); err != nil {
+		log.Crit("Failed to store anchor backfill progress", "err", err)
+	}
+}
+
+// DeleteAnchorBackfill removes the progress of a finished header backfill.
+func DeleteAnchorBackfill(db ethdb.KeyValueWriter) {
+	if err := db.Delete(anchorBackfillKey); err != nil {
+		log.Crit("Failed to delete anchor backfill progress", "err", err)
+	}
+}
+
 // ReadFastTrieProgress retrieves the number of tries nodes fast synced to allow
 // reporting correct numbers across restarts.
 func ReadFastTrieProgress(db ethdb.KeyValueReader) uint64 {
@@ -340,6 +413,39 @@ func WriteHeader(db ethdb.KeyValueWriter, header *types.Header) {
 	}
 }
 
+// ReadAnchorHeader retrieves a header downloaded during anchored sync, which is
+// not yet part of the local chain.
+func ReadAnchorHeader(db ethdb.KeyValueReader, number uint64) *types.Header {
+	data, _ := db.Get(anchorHeaderKey(number))
+	if len(data) == 0 {
+		return nil
+	}
+	header := new(types.Header)
+	if err := rlp.Decode(bytes.NewReader(data), header); err != nil {
+		log.Error("Invalid anchor header RLP", "number", number, "err", err)
+		return nil
+	}
+	return header
+}
+
+// WriteAnchorHeader stores a header downloaded during anchored sync.
+func WriteAnchorHeader(db ethdb.KeyValueWriter, header *types.Header) {
+	data, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		log.Crit("Failed to RLP encode anchor header", "err", err)
+	}
+	if err := db.Put(anchorHeaderKey(header.Number.Uint64()), data); err != nil {
+		log.Crit("Failed to store anchor header", "err", err)
+	}
+}
+
+// DeleteAnchorHeader removes a header downloaded during anchored sync.
+func DeleteAnchorHeader(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Delete(anchorHeaderKey(number)); err != nil {
+		log.Crit("Failed to delete anchor header", "err", err)
+	}
+}
+
 // DeleteHeader removes all block header data associated with a hash.
 func DeleteHeader(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 	deleteHeaderWithoutNumber(db, hash, number)
@@ -196,3 +196,43 @@ func DeleteSnapshotSyncStatus(db ethdb.KeyValueWriter) {
 		log.Crit("Failed to remove snapshot sync status", "err", err)
 	}
 }
+
+// ReadSnapshotHealNode retrieves a trie node or contract code checkpointed during
+// snap sync healing.
+func ReadSnapshotHealNode(db ethdb.KeyValueReader, hash common.Hash) []byte {
+	data, _ := db.Get(snapshotHealKey(hash))
+	return data
+}
+
+// WriteSnapshotHealNode checkpoints a trie node or contract code retrieved during
+// snap sync healing, allowing an interrupted sync to resume without retrieving
+// it again.
+func WriteSnapshotHealNode(db ethdb.KeyValueWriter, hash common.Hash, blob []byte) {
+	if err := db.Put(snapshotHealKey(hash), blob); err != nil {
+		log.Crit("Failed to store snapshot heal node", "err", err)
+	}
+}
+
+// DeleteSnapshotHealNodes deletes all the trie nodes and contract codes
+// checkpointed during snap sync healing.
+func DeleteSnapshotHealNodes(db ethdb.KeyValueStore) {
+	it := db.NewIterator(snapshotHealPrefix, nil)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		if len(it.Key()) != len(snapshotHealPrefix)+common.HashLength {
+			continue
+		}
+		batch.Delete(it.Key())
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete snapshot heal nodes", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete snapshot heal nodes", "err", err)
+	}
+}
@@ -373,7 +373,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, lastPivotKey,
 				fastTrieProgressKey, snapshotRootKey, snapshotJournalKey, snapshotGeneratorKey,
 				snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey, uncleanShutdownKey,
-				badBlockKey,
+				badBlockKey, headFinalizedBlockKey, headSafeBlockKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
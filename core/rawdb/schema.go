@@ -39,9 +39,18 @@ var (
 	// headFastBlockKey tracks the latest known incomplete block's hash during fast sync.
 	headFastBlockKey = []byte("LastFast")
 
+	// headFinalizedBlockKey tracks the latest block considered final by the consensus.
+	headFinalizedBlockKey = []byte("LastFinalized")
+
+	// headSafeBlockKey tracks the latest block considered safe from reorgs.
+	headSafeBlockKey = []byte("LastSafe")
+
 	// lastPivotKey tracks the last pivot block used by fast sync (to reenable on sethead).
 	lastPivotKey = []byte("LastPivot")
 
+	// anchorBackfillKey tracks the progress of the header backfill below a sync anchor.
+	anchorBackfillKey = []byte("AnchorBackfill")
+
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
@@ -78,6 +87,8 @@ var (
 	headerHashSuffix   = []byte("n") // headerPrefix + num (uint64 big endian) + headerHashSuffix -> hash
 	headerNumberPrefix = []byte("H") // headerNumberPrefix + hash -> num (uint64 big endian)
 
+	anchorHeaderPrefix = []byte("A") // anchorHeaderPrefix + num (uint64 big endian) -> header downloaded during anchored sync
+
 	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 
@@ -87,6 +98,8 @@ var (
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 
+	snapshotHealPrefix = []byte("SH") // snapshotHealPrefix + hash -> trie node or code retrieved during snap sync healing
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
@@ -149,6 +162,11 @@ func headerKey(number uint64, hash common.Hash) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// anchorHeaderKey = anchorHeaderPrefix + num (uint64 big endian)
+func anchorHeaderKey(number uint64) []byte {
+	return append(anchorHeaderPrefix, encodeBlockNumber(number)...)
+}
+
 // headerTDKey = headerPrefix + num (uint64 big endian) + hash + headerTDSuffix
 func headerTDKey(number uint64, hash common.Hash) []byte {
 	return append(headerKey(number, hash), headerTDSuffix...)
@@ -209,6 +227,11 @@ func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
 }
 
+// snapshotHealKey = snapshotHealPrefix + hash
+func snapshotHealKey(hash common.Hash) []byte {
+	return append(snapshotHealPrefix, hash.Bytes()...)
+}
+
 // codeKey = CodePrefix + hash
 func codeKey(hash common.Hash) []byte {
 	return append(CodePrefix, hash.Bytes()...)
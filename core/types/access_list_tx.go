@@ -42,6 +42,8 @@ func (al AccessList) StorageKeys() int {
 	return sum
 }
 
+//go:generate go run ../../rlp/rlpgen -type AccessListTx -out gen_access_list_tx_rlp.go
+
 // AccessListTx is the data of EIP-2930 access list transactions.
 type AccessListTx struct {
 	ChainID    *big.Int        // destination chain ID
@@ -0,0 +1,196 @@
+// Code generated by rlpgen. DO NOT EDIT.
+
+package types
+
+import (
+	"errors"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func (obj *Header) EncodeRLP(_w io.Writer) error {
+	w := rlp.NewEncoderBuffer(_w)
+	_tmp0 := w.List()
+	w.WriteBytes(obj.ParentHash[:])
+	w.WriteBytes(obj.UncleHash[:])
+	w.WriteBytes(obj.Coinbase[:])
+	w.WriteBytes(obj.Root[:])
+	w.WriteBytes(obj.TxHash[:])
+	w.WriteBytes(obj.ReceiptHash[:])
+	w.WriteBytes(obj.Bloom[:])
+	if obj.Difficulty == nil {
+		w.Write(rlp.EmptyString)
+	} else {
+		if obj.Difficulty.Sign() == -1 {
+			return rlp.ErrNegativeBigInt
+		}
+		w.WriteBigInt(obj.Difficulty)
+	}
+	if obj.Number == nil {
+		w.Write(rlp.EmptyString)
+	} else {
+		if obj.Number.Sign() == -1 {
+			return rlp.ErrNegativeBigInt
+		}
+		w.WriteBigInt(obj.Number)
+	}
+	w.WriteUint64(obj.GasLimit)
+	w.WriteUint64(obj.GasUsed)
+	w.WriteUint64(obj.Time)
+	w.WriteBytes(obj.Extra)
+	w.WriteBytes(obj.MixDigest[:])
+	w.WriteBytes(obj.Nonce[:])
+	w.ListEnd(_tmp0)
+	return w.Flush()
+}
+
+func (obj *Header) DecodeRLP(dec *rlp.Stream) error {
+	var _tmp0 Header
+	{
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		// ParentHash:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp1 common.Hash
+		if err := dec.ReadBytes(_tmp1[:]); err != nil {
+			return err
+		}
+		_tmp0.ParentHash = _tmp1
+		// UncleHash:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp2 common.Hash
+		if err := dec.ReadBytes(_tmp2[:]); err != nil {
+			return err
+		}
+		_tmp0.UncleHash = _tmp2
+		// Coinbase:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp3 common.Address
+		if err := dec.ReadBytes(_tmp3[:]); err != nil {
+			return err
+		}
+		_tmp0.Coinbase = _tmp3
+		// Root:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp4 common.Hash
+		if err := dec.ReadBytes(_tmp4[:]); err != nil {
+			return err
+		}
+		_tmp0.Root = _tmp4
+		// TxHash:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp5 common.Hash
+		if err := dec.ReadBytes(_tmp5[:]); err != nil {
+			return err
+		}
+		_tmp0.TxHash = _tmp5
+		// ReceiptHash:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp6 common.Hash
+		if err := dec.ReadBytes(_tmp6[:]); err != nil {
+			return err
+		}
+		_tmp0.ReceiptHash = _tmp6
+		// Bloom:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp7 Bloom
+		if err := dec.ReadBytes(_tmp7[:]); err != nil {
+			return err
+		}
+		_tmp0.Bloom = _tmp7
+		// Difficulty:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		_tmp8, err := dec.BigInt()
+		if err != nil {
+			return err
+		}
+		_tmp0.Difficulty = _tmp8
+		// Number:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		_tmp9, err := dec.BigInt()
+		if err != nil {
+			return err
+		}
+		_tmp0.Number = _tmp9
+		// GasLimit:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		_tmp10, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		_tmp0.GasLimit = _tmp10
+		// GasUsed:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		_tmp11, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		_tmp0.GasUsed = _tmp11
+		// Time:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		_tmp12, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		_tmp0.Time = _tmp12
+		// Extra:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		_tmp13, err := dec.Bytes()
+		if err != nil {
+			return err
+		}
+		_tmp0.Extra = _tmp13
+		// MixDigest:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp14 common.Hash
+		if err := dec.ReadBytes(_tmp14[:]); err != nil {
+			return err
+		}
+		_tmp0.MixDigest = _tmp14
+		// Nonce:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.Header")
+		}
+		var _tmp15 BlockNonce
+		if err := dec.ReadBytes(_tmp15[:]); err != nil {
+			return err
+		}
+		_tmp0.Nonce = _tmp15
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+	}
+	*obj = _tmp0
+	return nil
+}
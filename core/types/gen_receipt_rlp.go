@@ -0,0 +1,91 @@
+// Code generated by rlpgen. DO NOT EDIT.
+
+package types
+
+import (
+	"errors"
+	"io"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func (obj *receiptRLP) EncodeRLP(_w io.Writer) error {
+	w := rlp.NewEncoderBuffer(_w)
+	_tmp0 := w.List()
+	w.WriteBytes(obj.PostStateOrStatus)
+	w.WriteUint64(obj.CumulativeGasUsed)
+	w.WriteBytes(obj.Bloom[:])
+	_tmp1 := w.List()
+	for _, _tmp2 := range obj.Logs {
+		if _tmp2 == nil {
+			w.Write(rlp.EmptyList)
+		} else {
+			if err := (*_tmp2).EncodeRLP(w); err != nil {
+				return err
+			}
+		}
+	}
+	w.ListEnd(_tmp1)
+	w.ListEnd(_tmp0)
+	return w.Flush()
+}
+
+func (obj *receiptRLP) DecodeRLP(dec *rlp.Stream) error {
+	var _tmp0 receiptRLP
+	{
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		// PostStateOrStatus:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.receiptRLP")
+		}
+		_tmp1, err := dec.Bytes()
+		if err != nil {
+			return err
+		}
+		_tmp0.PostStateOrStatus = _tmp1
+		// CumulativeGasUsed:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.receiptRLP")
+		}
+		_tmp2, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		_tmp0.CumulativeGasUsed = _tmp2
+		// Bloom:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.receiptRLP")
+		}
+		var _tmp3 Bloom
+		if err := dec.ReadBytes(_tmp3[:]); err != nil {
+			return err
+		}
+		_tmp0.Bloom = _tmp3
+		// Logs:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.receiptRLP")
+		}
+		_tmp4 := []*Log{}
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		for dec.MoreDataInList() {
+			var _tmp5 Log
+			if err := _tmp5.DecodeRLP(dec); err != nil {
+				return err
+			}
+			_tmp4 = append(_tmp4, &_tmp5)
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		_tmp0.Logs = _tmp4
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+	}
+	*obj = _tmp0
+	return nil
+}
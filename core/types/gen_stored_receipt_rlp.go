@@ -0,0 +1,81 @@
+// Code generated by rlpgen. DO NOT EDIT.
+
+package types
+
+import (
+	"errors"
+	"io"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func (obj *storedReceiptRLP) EncodeRLP(_w io.Writer) error {
+	w := rlp.NewEncoderBuffer(_w)
+	_tmp0 := w.List()
+	w.WriteBytes(obj.PostStateOrStatus)
+	w.WriteUint64(obj.CumulativeGasUsed)
+	_tmp1 := w.List()
+	for _, _tmp2 := range obj.Logs {
+		if _tmp2 == nil {
+			w.Write(rlp.EmptyList)
+		} else {
+			if err := (*_tmp2).EncodeRLP(w); err != nil {
+				return err
+			}
+		}
+	}
+	w.ListEnd(_tmp1)
+	w.ListEnd(_tmp0)
+	return w.Flush()
+}
+
+func (obj *storedReceiptRLP) DecodeRLP(dec *rlp.Stream) error {
+	var _tmp0 storedReceiptRLP
+	{
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		// PostStateOrStatus:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.storedReceiptRLP")
+		}
+		_tmp1, err := dec.Bytes()
+		if err != nil {
+			return err
+		}
+		_tmp0.PostStateOrStatus = _tmp1
+		// CumulativeGasUsed:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.storedReceiptRLP")
+		}
+		_tmp2, err := dec.Uint64()
+		if err != nil {
+			return err
+		}
+		_tmp0.CumulativeGasUsed = _tmp2
+		// Logs:
+		if !dec.MoreDataInList() {
+			return errors.New("rlp: too few elements for types.storedReceiptRLP")
+		}
+		_tmp3 := []*LogForStorage{}
+		if _, err := dec.List(); err != nil {
+			return err
+		}
+		for dec.MoreDataInList() {
+			var _tmp4 LogForStorage
+			if err := _tmp4.DecodeRLP(dec); err != nil {
+				return err
+			}
+			_tmp3 = append(_tmp3, &_tmp4)
+		}
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+		_tmp0.Logs = _tmp3
+		if err := dec.ListEnd(); err != nil {
+			return err
+		}
+	}
+	*obj = _tmp0
+	return nil
+}
@@ -62,6 +62,8 @@ type logMarshaling struct {
 	Index       hexutil.Uint
 }
 
+//go:generate go run ../../rlp/rlpgen -type rlpLog -out gen_log_rlp.go
+
 type rlpLog struct {
 	Address common.Address
 	Topics  []common.Hash
@@ -85,7 +87,8 @@ type legacyRlpStorageLog struct {
 
 // EncodeRLP implements rlp.Encoder.
 func (l *Log) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, rlpLog{Address: l.Address, Topics: l.Topics, Data: l.Data})
+	rl := rlpLog{Address: l.Address, Topics: l.Topics, Data: l.Data}
+	return rlp.Encode(w, &rl)
 }
 
 // DecodeRLP implements rlp.Decoder.
@@ -82,6 +82,8 @@ type receiptMarshaling struct {
 	TransactionIndex  hexutil.Uint
 }
 
+//go:generate go run ../../rlp/rlpgen -type receiptRLP -out gen_receipt_rlp.go
+
 // receiptRLP is the consensus encoding of a receipt.
 type receiptRLP struct {
 	PostStateOrStatus []byte
@@ -90,6 +92,8 @@ type receiptRLP struct {
 	Logs              []*Log
 }
 
+//go:generate go run ../../rlp/rlpgen -type storedReceiptRLP -out gen_stored_receipt_rlp.go
+
 // storedReceiptRLP is the storage encoding of a receipt.
 type storedReceiptRLP struct {
 	PostStateOrStatus []byte
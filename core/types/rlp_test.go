@@ -0,0 +1,119 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	fuzz "github.com/google/gofuzz"
+)
+
+// The types below have the same fields and struct tags as the types with
+// generated RLP methods, but no methods. Encoding them goes through the
+// reflection-based code in package rlp.
+type (
+	reflectHeader           Header
+	reflectLegacyTx         LegacyTx
+	reflectAccessListTx     AccessListTx
+	reflectLog              rlpLog
+	reflectReceipt          receiptRLP
+	reflectStoredReceiptRLP storedReceiptRLP
+)
+
+// TestGeneratedRLP checks that the generated RLP methods produce the same
+// output as the reflection-based encoder and decoder.
+func TestGeneratedRLP(t *testing.T) {
+	tests := []struct {
+		name        string
+		gen, reflct func() interface{}
+	}{
+		{"Header", func() interface{} { return new(Header) }, func() interface{} { return new(reflectHeader) }},
+		{"LegacyTx", func() interface{} { return new(LegacyTx) }, func() interface{} { return new(reflectLegacyTx) }},
+		{"AccessListTx", func() interface{} { return new(AccessListTx) }, func() interface{} { return new(reflectAccessListTx) }},
+		{"rlpLog", func() interface{} { return new(rlpLog) }, func() interface{} { return new(reflectLog) }},
+		{"receiptRLP", func() interface{} { return new(receiptRLP) }, func() interface{} { return new(reflectReceipt) }},
+		{"storedReceiptRLP", func() interface{} { return new(storedReceiptRLP) }, func() interface{} { return new(reflectStoredReceiptRLP) }},
+	}
+	f := fuzz.NewWithSeed(1).NilChance(0.1).Funcs(func(i *big.Int, c fuzz.Continue) {
+		b := make([]byte, c.Intn(40))
+		c.Read(b)
+		i.SetBytes(b)
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i := 0; i < 500; i++ {
+				val := test.gen()
+				f.Fuzz(val)
+
+				// Encode using both paths. The reflect type is converted from
+				// the generated one, so both encode the same data.
+				genEnc, err := rlp.EncodeToBytes(val)
+				if err != nil {
+					t.Fatalf("generated encoder error: %v", err)
+				}
+				refVal := reflect.ValueOf(val).Convert(reflect.TypeOf(test.reflct())).Interface()
+				refEnc, err := rlp.EncodeToBytes(refVal)
+				if err != nil {
+					t.Fatalf("reflect encoder error: %v", err)
+				}
+				if !bytes.Equal(genEnc, refEnc) {
+					t.Fatalf("encoding mismatch for %+v\ngenerated: %x\nreflect:   %x", val, genEnc, refEnc)
+				}
+
+				// Decode the encoding using both paths.
+				genDec, refDec := test.gen(), test.reflct()
+				genErr := rlp.DecodeBytes(genEnc, genDec)
+				refErr := rlp.DecodeBytes(genEnc, refDec)
+				if (genErr == nil) != (refErr == nil) {
+					t.Fatalf("decoder error mismatch for %x\ngenerated: %v\nreflect:   %v", genEnc, genErr, refErr)
+				}
+				if genErr != nil {
+					continue
+				}
+				converted := reflect.ValueOf(refDec).Convert(reflect.TypeOf(genDec)).Interface()
+				if !reflect.DeepEqual(genDec, converted) {
+					t.Fatalf("decoding mismatch for %x\ngenerated: %+v\nreflect:   %+v", genEnc, genDec, converted)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratedRLPNegativeBigInt(t *testing.T) {
+	h := &Header{Difficulty: big.NewInt(-1), Number: big.NewInt(1)}
+	if _, err := rlp.EncodeToBytes(h); err != rlp.ErrNegativeBigInt {
+		t.Fatalf("wrong error for generated encoder: %v", err)
+	}
+	if _, err := rlp.EncodeToBytes((*reflectHeader)(h)); err != rlp.ErrNegativeBigInt {
+		t.Fatalf("wrong error for reflect encoder: %v", err)
+	}
+}
+
+// This test checks that a generated decoder running into the end of its list
+// returns an error instead of rlp.EOL. Reflection-based slice decoding would
+// otherwise treat the incomplete element as the end of the slice.
+func TestGeneratedRLPTooFewElements(t *testing.T) {
+	var headers []*Header
+	err := rlp.DecodeBytes([]byte{0xC1, 0xC0}, &headers)
+	if err == nil || err == rlp.EOL {
+		t.Fatalf("expected decoding error, got %v (decoded %d headers)", err, len(headers))
+	}
+}
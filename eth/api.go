@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -523,3 +524,9 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 	}
 	return dirty, nil
 }
+
+// SnapSyncProgress returns the detailed progress of the snap sync, including the
+// healing phase. It returns nil if no snap sync was ever started on this node.
+func (api *PrivateDebugAPI) SnapSyncProgress() *snap.Progress {
+	return api.eth.Downloader().SnapSyncer.Progress()
+}
@@ -71,6 +71,13 @@ func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock().Header(), nil
 	}
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		block, err := b.finalityBlock(number)
+		if err != nil {
+			return nil, err
+		}
+		return block.Header(), nil
+	}
 	return b.eth.blockchain.GetHeaderByNumber(uint64(number)), nil
 }
 
@@ -105,9 +112,27 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock(), nil
 	}
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		return b.finalityBlock(number)
+	}
 	return b.eth.blockchain.GetBlockByNumber(uint64(number)), nil
 }
 
+// finalityBlock resolves the finalized or safe block tag to the block tracked by
+// the chain, failing if the consensus didn't mark any block yet.
+func (b *EthAPIBackend) finalityBlock(number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.FinalizedBlockNumber {
+		if block := b.eth.blockchain.CurrentFinalizedBlock(); block != nil {
+			return block, nil
+		}
+		return nil, errors.New("finalized block not found")
+	}
+	if block := b.eth.blockchain.CurrentSafeBlock(); block != nil {
+		return block, nil
+	}
+	return nil, errors.New("safe block not found")
+}
+
 func (b *EthAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
 	return b.eth.blockchain.GetBlockByHash(hash), nil
 }
@@ -307,6 +332,14 @@ func (b *EthAPIBackend) RPCTxFeeCap() float64 {
 	return b.eth.config.RPCTxFeeCap
 }
 
+func (b *EthAPIBackend) RPCLogBlockRange() uint64 {
+	return b.eth.config.RPCLogBlockRange
+}
+
+func (b *EthAPIBackend) RPCLogResultCap() uint64 {
+	return b.eth.config.RPCLogResultCap
+}
+
 func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
 	sections, _, _ := b.eth.bloomIndexer.Sections()
 	return params.BloomBitsBlocks, sections
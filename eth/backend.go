@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/bft"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
@@ -70,6 +71,7 @@ type Ethereum struct {
 	handler            *handler
 	ethDialCandidates  enode.Iterator
 	snapDialCandidates enode.Iterator
+	snapLimiter        *snap.ServeLimiter
 
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
@@ -136,6 +138,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
+	// Contract governed clique checkpoints are only verified when executing blocks
+	if chainConfig.Clique != nil && chainConfig.Clique.SignerContract != nil && config.SyncMode != downloader.FullSync {
+		return nil, fmt.Errorf("clique signer contract requires full sync, not %v sync", config.SyncMode)
+	}
 	if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb, stack.ResolvePath(config.TrieCleanCacheJournal)); err != nil {
 		log.Error("Failed to recover state", "error", err)
 	}
@@ -199,6 +205,11 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	// Give clique access to the state for querying any signer governance contract
+	if engine, ok := eth.engine.(*clique.Clique); ok {
+		engine.SetStateFn(eth.blockchain.StateAt)
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
@@ -216,6 +227,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		TxPool:     eth.txPool,
 		Network:    config.NetworkId,
 		Sync:       config.SyncMode,
+		SyncAnchor: config.SyncAnchor,
 		BloomCache: uint64(cacheLimit),
 		EventMux:   eth.eventMux,
 		Checkpoint: checkpoint,
@@ -245,6 +257,8 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	eth.snapLimiter = snap.NewServeLimiter(eth.config.SnapServe)
+
 	// Start the RPC service
 	eth.netRPCService = ethapi.NewPublicNetAPI(eth.p2pServer, config.NetworkId)
 
@@ -469,6 +483,14 @@ func (s *Ethereum) StartMining(threads int) error {
 			}
 			clique.Authorize(eb, wallet.SignData)
 		}
+		if bft, ok := s.engine.(*bft.BFT); ok {
+			wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
+			if wallet == nil || err != nil {
+				log.Error("Etherbase account unavailable locally", "err", err)
+				return fmt.Errorf("validator missing: %v", err)
+			}
+			bft.Authorize(eb, wallet.SignData)
+		}
 		// If mining is started, we can disable the transaction rejection mechanism
 		// introduced to speed sync times.
 		atomic.StoreUint32(&s.handler.acceptTxs, 1)
@@ -507,12 +529,23 @@ func (s *Ethereum) Synced() bool                       { return atomic.LoadUint3
 func (s *Ethereum) ArchiveMode() bool                  { return s.config.NoPruning }
 func (s *Ethereum) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
 
+// SyncTo requests a sync cycle to backfill the chain from the network up to the
+// given block, e.g. when an external consensus driver is ahead of the node. The
+// block is synced regardless of its total difficulty, but the request is dropped
+// if the block could not be retrieved within a few minutes.
+func (s *Ethereum) SyncTo(target common.Hash) {
+	s.handler.chainSync.requestSync(target)
+}
+
 // Protocols returns all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
 	protos := eth.MakeProtocols((*ethHandler)(s.handler), s.networkID, s.ethDialCandidates)
 	if s.config.SnapshotCache > 0 {
-		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
+		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates, s.snapLimiter)...)
+	}
+	if engine, ok := s.engine.(*bft.BFT); ok {
+		protos = append(protos, engine.Protocols()...)
 	}
 	return protos
 }
@@ -535,9 +568,101 @@ func (s *Ethereum) Start() error {
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	// Import the blocks committed by the validators without waiting for them to
+	// propagate if the chain runs the BFT consensus
+	if engine, ok := s.engine.(*bft.BFT); ok {
+		go s.importCommittedBlocks(engine)
+	}
+	// Track the finalized and safe blocks if the consensus engine has a local
+	// finality rule
+	if rule := s.finalityRule(); rule != nil {
+		go s.trackFinality(rule)
+	}
 	return nil
 }
 
+// finalityRule returns the function deriving the finalized and safe blocks from
+// the chain head, or nil if the finality is not derivable locally.
+func (s *Ethereum) finalityRule() func(head *types.Block) (finalized *types.Block, safe *types.Block) {
+	switch engine := s.engine.(type) {
+	case *clique.Clique:
+		depth := s.config.CliqueConfirmations
+		if depth == 0 {
+			return nil
+		}
+		return func(head *types.Block) (*types.Block, *types.Block) {
+			var (
+				finalized, safe *types.Block
+				number          = head.NumberU64()
+			)
+			if number >= depth {
+				finalized = s.blockchain.GetBlockByNumber(number - depth)
+			}
+			if number >= (depth+1)/2 {
+				safe = s.blockchain.GetBlockByNumber(number - (depth+1)/2)
+			}
+			return finalized, safe
+		}
+
+	case *bft.BFT:
+		return func(head *types.Block) (*types.Block, *types.Block) {
+			header := engine.FinalizedHeader(s.blockchain)
+			if header == nil {
+				return nil, nil
+			}
+			return s.blockchain.GetBlock(header.Hash(), header.Number.Uint64()), nil
+		}
+	}
+	return nil
+}
+
+// trackFinality applies the finality rule to every new chain head, updating the
+// finalized and safe blocks of the chain until it's stopped.
+func (s *Ethereum) trackFinality(rule func(head *types.Block) (*types.Block, *types.Block)) {
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := s.blockchain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-heads:
+			finalized, safe := rule(ev.Block)
+			if finalized != nil {
+				if err := s.blockchain.SetFinalized(finalized); err != nil {
+					log.Debug("Failed to update finalized block", "err", err)
+				}
+			}
+			if safe != nil {
+				if err := s.blockchain.SetSafe(safe); err != nil {
+					log.Debug("Failed to update safe block", "err", err)
+				}
+			}
+		case <-sub.Err():
+			return
+		}
+	}
+}
+
+// importCommittedBlocks inserts the blocks committed by the BFT validators into
+// the local chain until the consensus engine is closed.
+func (s *Ethereum) importCommittedBlocks(engine *bft.BFT) {
+	blocks := make(chan *types.Block, 16)
+	sub := engine.SubscribeCommittedBlocks(blocks)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case block := <-blocks:
+			if _, err := s.blockchain.InsertChain(types.Blocks{block}); err != nil {
+				log.Warn("Failed to import committed block", "number", block.Number(), "hash", block.Hash(), "err", err)
+			}
+		case <-sub.Err():
+			return
+		}
+	}
+}
+
 // Stop implements node.Lifecycle, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
@@ -21,9 +21,11 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -45,23 +47,50 @@ func Register(stack *node.Node, backend *eth.Ethereum) error {
 	}
 
 	log.Warn("Catalyst mode enabled")
+	api := newConsensusAPI(backend)
 	stack.RegisterAPIs([]rpc.API{
 		{
 			Namespace: "consensus",
 			Version:   "1.0",
-			Service:   newConsensusAPI(backend),
+			Service:   api,
 			Public:    true,
 		},
 	})
+	// Registered after the eth service, so the payload builders are stopped
+	// before the chain they're building on
+	stack.RegisterLifecycle(api)
 	return nil
 }
 
 type consensusAPI struct {
-	eth *eth.Ethereum
+	eth      *eth.Ethereum
+	payloads payloadQueue  // Recently prepared payloads, built in the background
+	syncing  syncingBlocks // New blocks waiting for their ancestors to be synced
+
+	quit     chan struct{}  // Channel to stop the payload builders with
+	quitOnce sync.Once      // Ensures the builders are only stopped once
+	builders sync.WaitGroup // Payload builders running in the background
 }
 
 func newConsensusAPI(eth *eth.Ethereum) *consensusAPI {
-	return &consensusAPI{eth: eth}
+	return &consensusAPI{
+		eth:  eth,
+		quit: make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle, starting nothing as payloads are only built
+// on request.
+func (api *consensusAPI) Start() error {
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating all the payload builders and
+// waiting for them to exit.
+func (api *consensusAPI) Stop() error {
+	api.quitOnce.Do(func() { close(api.quit) })
+	api.builders.Wait()
+	return nil
 }
 
 // blockExecutionEnv gathers all the data required to execute
@@ -107,10 +136,10 @@ func (api *consensusAPI) makeEnv(parent *types.Block, header *types.Header) (*bl
 func (api *consensusAPI) AssembleBlock(params assembleBlockParams) (*executableData, error) {
 	log.Info("Producing block", "parentHash", params.ParentHash)
 
-	bc := api.eth.BlockChain()
-	parent := bc.GetBlockByHash(params.ParentHash)
-	pool := api.eth.TxPool()
-
+	parent := api.eth.BlockChain().GetBlockByHash(params.ParentHash)
+	if parent == nil {
+		return nil, fmt.Errorf("could not find parent %x", params.ParentHash)
+	}
 	if parent.Time() >= params.Timestamp {
 		return nil, fmt.Errorf("child timestamp lower than parent's: %d >= %d", parent.Time(), params.Timestamp)
 	}
@@ -120,7 +149,7 @@ func (api *consensusAPI) AssembleBlock(params assembleBlockParams) (*executableD
 		time.Sleep(wait)
 	}
 
-	pending, err := pool.Pending()
+	pending, err := api.eth.TxPool().Pending()
 	if err != nil {
 		return nil, err
 	}
@@ -129,23 +158,41 @@ func (api *consensusAPI) AssembleBlock(params assembleBlockParams) (*executableD
 	if err != nil {
 		return nil, err
 	}
+	attrs := &payloadAttributes{
+		ParentHash:   params.ParentHash,
+		Timestamp:    params.Timestamp,
+		FeeRecipient: coinbase,
+	}
+	block, _, err := api.assembleBlock(parent, attrs, pending)
+	if err != nil {
+		return nil, err
+	}
+	return blockToExecutableData(block), nil
+}
+
+// assembleBlock creates a new block on top of the given parent with the given
+// attributes, filled with the pending transactions. Besides the block, the fees
+// earned by the fee recipient are returned.
+func (api *consensusAPI) assembleBlock(parent *types.Block, attrs *payloadAttributes, pending map[common.Address]types.Transactions) (*types.Block, *big.Int, error) {
+	bc := api.eth.BlockChain()
+
 	num := parent.Number()
 	header := &types.Header{
 		ParentHash: parent.Hash(),
 		Number:     num.Add(num, common.Big1),
-		Coinbase:   coinbase,
+		Coinbase:   attrs.FeeRecipient,
 		GasLimit:   parent.GasLimit(), // Keep the gas limit constant in this prototype
 		Extra:      []byte{},
-		Time:       params.Timestamp,
+		Time:       attrs.Timestamp,
 	}
-	err = api.eth.Engine().Prepare(bc, header)
-	if err != nil {
-		return nil, err
+	if err := api.eth.Engine().Prepare(bc, header); err != nil {
+		return nil, nil, err
 	}
+	header.MixDigest = attrs.Random
 
 	env, err := api.makeEnv(parent, header)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var (
@@ -168,7 +215,7 @@ func (api *consensusAPI) AssembleBlock(params assembleBlockParams) (*executableD
 
 		// Execute the transaction
 		env.state.Prepare(tx.Hash(), common.Hash{}, env.tcount)
-		err = env.commitTransaction(tx, coinbase)
+		err = env.commitTransaction(tx, attrs.FeeRecipient)
 		switch err {
 		case core.ErrGasLimitReached:
 			// Pop the current out-of-gas transaction without shifting in the next from the account
@@ -198,12 +245,71 @@ func (api *consensusAPI) AssembleBlock(params assembleBlockParams) (*executableD
 			txHeap.Shift()
 		}
 	}
+	fees := new(big.Int)
+	for i, tx := range env.txs {
+		fees.Add(fees, new(big.Int).Mul(new(big.Int).SetUint64(env.receipts[i].GasUsed), tx.GasPrice()))
+	}
 
 	// Create the block.
 	block, err := api.eth.Engine().FinalizeAndAssemble(bc, header, env.state, transactions, nil /* uncles */, env.receipts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, fees, nil
+}
+
+// PreparePayload starts building a payload on top of the given parent with the
+// given attributes, and returns the identifier to retrieve it with. The payload
+// is improved in the background as new transactions arrive.
+func (api *consensusAPI) PreparePayload(attrs payloadAttributes) (*preparePayloadResponse, error) {
+	id := computePayloadID(&attrs)
+	if api.payloads.get(id) != nil {
+		return &preparePayloadResponse{hexutil.Uint64(id)}, nil
+	}
+	parent := api.eth.BlockChain().GetBlockByHash(attrs.ParentHash)
+	if parent == nil {
+		return nil, fmt.Errorf("could not find parent %x", attrs.ParentHash)
+	}
+	if parent.Time() >= attrs.Timestamp {
+		return nil, fmt.Errorf("child timestamp lower than parent's: %d >= %d", parent.Time(), attrs.Timestamp)
+	}
+	// Assemble an empty block right away, so there's always something to deliver
+	empty, _, err := api.assembleBlock(parent, &attrs, nil)
 	if err != nil {
 		return nil, err
 	}
+	payload := newPayload(id, attrs, empty)
+	if api.payloads.add(payload) {
+		log.Info("Preparing payload", "id", id, "parentHash", attrs.ParentHash, "number", empty.Number())
+
+		select {
+		case <-api.quit:
+			// Shutting down, deliver the empty payload without building on it
+			payload.close()
+			close(payload.done)
+		default:
+			api.builders.Add(1)
+			go func() {
+				defer api.builders.Done()
+				api.buildPayload(payload, parent)
+			}()
+		}
+	}
+	return &preparePayloadResponse{hexutil.Uint64(id)}, nil
+}
+
+// GetPayload retrieves the best version built so far of a previously prepared
+// payload. The payload is not improved any more once delivered.
+func (api *consensusAPI) GetPayload(id hexutil.Uint64) (*executableData, error) {
+	payload := api.payloads.get(uint64(id))
+	if payload == nil {
+		return nil, errUnknownPayload
+	}
+	payload.close()
+	return blockToExecutableData(payload.resolve()), nil
+}
+
+func blockToExecutableData(block *types.Block) *executableData {
 	return &executableData{
 		BlockHash:    block.Hash(),
 		ParentHash:   block.ParentHash(),
@@ -216,7 +322,8 @@ func (api *consensusAPI) AssembleBlock(params assembleBlockParams) (*executableD
 		ReceiptRoot:  block.ReceiptHash(),
 		LogsBloom:    block.Bloom().Bytes(),
 		Transactions: encodeTransactions(block.Transactions()),
-	}, nil
+		Random:       block.MixDigest(),
+	}
 }
 
 func encodeTransactions(txs []*types.Transaction) [][]byte {
@@ -260,6 +367,7 @@ func insertBlockParamsToBlock(params executableData) (*types.Block, error) {
 		GasLimit:    params.GasLimit,
 		GasUsed:     params.GasUsed,
 		Time:        params.Timestamp,
+		MixDigest:   params.Random,
 	}
 	block := types.NewBlockWithHeader(header).WithBody(txs, nil /* uncles */)
 	return block, nil
@@ -267,19 +375,52 @@ func insertBlockParamsToBlock(params executableData) (*types.Block, error) {
 
 // NewBlock creates an Eth1 block, inserts it in the chain, and either returns true,
 // or false + an error. This is a bit redundant for go, but simplifies things on the
-// eth2 side.
+// eth2 side. If the parent block or its state is not available yet, the block is
+// kept around, the chain is backfilled from the network and the syncing status
+// is returned.
 func (api *consensusAPI) NewBlock(params executableData) (*newBlockResponse, error) {
-	parent := api.eth.BlockChain().GetBlockByHash(params.ParentHash)
-	if parent == nil {
-		return &newBlockResponse{false}, fmt.Errorf("could not find parent %x", params.ParentHash)
-	}
 	block, err := insertBlockParamsToBlock(params)
 	if err != nil {
 		return nil, err
 	}
+	bc := api.eth.BlockChain()
+	if parent := bc.GetBlockByHash(params.ParentHash); parent == nil || !bc.HasState(parent.Root()) {
+		log.Info("Parent of new block unavailable, syncing", "number", block.Number(), "hash", block.Hash(), "parent", params.ParentHash)
+		api.syncing.add(block)
+		api.eth.SyncTo(params.ParentHash)
+		return &newBlockResponse{Valid: false, Status: statusSyncing}, nil
+	}
+	if _, err := bc.InsertChainWithoutSealVerification(block); err != nil {
+		return &newBlockResponse{Valid: false, Status: statusInvalid}, err
+	}
+	return &newBlockResponse{Valid: true, Status: statusValid}, nil
+}
+
+// importSyncingBlocks inserts the chain of blocks kept around while syncing up
+// to the given head, provided the backfill reached their ancestors. It returns
+// the head block, or nil if it's not available yet.
+func (api *consensusAPI) importSyncingBlocks(head common.Hash) *types.Block {
+	bc := api.eth.BlockChain()
 
-	_, err = api.eth.BlockChain().InsertChainWithoutSealVerification(block)
-	return &newBlockResponse{err == nil}, err
+	var chain []*types.Block
+	for hash := head; ; {
+		block := api.syncing.get(hash)
+		if block == nil {
+			return nil
+		}
+		chain = append(chain, block)
+		if hash = block.ParentHash(); bc.HasBlockAndState(hash, block.NumberU64()-1) {
+			break
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if _, err := bc.InsertChainWithoutSealVerification(chain[i]); err != nil {
+			log.Warn("Failed to import synced block", "number", chain[i].Number(), "hash", chain[i].Hash(), "err", err)
+			return nil
+		}
+		api.syncing.remove(chain[i].Hash())
+	}
+	return chain[0]
 }
 
 // Used in tests to add a the list of transactions from a block to the tx pool.
@@ -290,13 +431,64 @@ func (api *consensusAPI) addBlockTxs(block *types.Block) error {
 	return nil
 }
 
-// FinalizeBlock is called to mark a block as synchronized, so
+// FinalizeBlock is called to mark a block as finalized, so
 // that data that is no longer needed can be removed.
 func (api *consensusAPI) FinalizeBlock(blockHash common.Hash) (*genericResponse, error) {
+	block := api.eth.BlockChain().GetBlockByHash(blockHash)
+	if block == nil {
+		return &genericResponse{false}, fmt.Errorf("could not find block %x", blockHash)
+	}
+	if err := api.eth.BlockChain().SetFinalized(block); err != nil {
+		return &genericResponse{false}, err
+	}
 	return &genericResponse{true}, nil
 }
 
+// ForkchoiceUpdated makes the chosen head block the head of the canonical chain
+// and marks the chosen safe and finalized blocks. If the head block is not yet
+// available, the chain is backfilled from the network and the syncing status is
+// returned.
+func (api *consensusAPI) ForkchoiceUpdated(state forkchoiceState) (*forkchoiceResponse, error) {
+	bc := api.eth.BlockChain()
+
+	head := bc.GetBlockByHash(state.HeadBlockHash)
+	if head == nil {
+		if head = api.importSyncingBlocks(state.HeadBlockHash); head == nil {
+			log.Info("Fork choice head unavailable, syncing", "hash", state.HeadBlockHash)
+			api.eth.SyncTo(state.HeadBlockHash)
+			return &forkchoiceResponse{statusSyncing}, nil
+		}
+	}
+	if err := bc.SetCanonical(head); err != nil {
+		return &forkchoiceResponse{statusInvalid}, err
+	}
+	// Update the finalized block first, the safe block can't lag behind it
+	if state.FinalizedBlockHash != (common.Hash{}) {
+		block := bc.GetBlockByHash(state.FinalizedBlockHash)
+		if block == nil {
+			return &forkchoiceResponse{statusInvalid}, fmt.Errorf("could not find finalized block %x", state.FinalizedBlockHash)
+		}
+		if err := bc.SetFinalized(block); err != nil {
+			return &forkchoiceResponse{statusInvalid}, err
+		}
+	}
+	if state.SafeBlockHash != (common.Hash{}) {
+		block := bc.GetBlockByHash(state.SafeBlockHash)
+		if block == nil {
+			return &forkchoiceResponse{statusInvalid}, fmt.Errorf("could not find safe block %x", state.SafeBlockHash)
+		}
+		if err := bc.SetSafe(block); err != nil {
+			return &forkchoiceResponse{statusInvalid}, err
+		}
+	}
+	return &forkchoiceResponse{statusValid}, nil
+}
+
 // SetHead is called to perform a force choice.
 func (api *consensusAPI) SetHead(newHead common.Hash) (*genericResponse, error) {
-	return &genericResponse{true}, nil
+	resp, err := api.ForkchoiceUpdated(forkchoiceState{HeadBlockHash: newHead})
+	if err != nil {
+		return &genericResponse{false}, err
+	}
+	return &genericResponse{resp.Status == statusValid}, nil
 }
@@ -19,6 +19,7 @@ package catalyst
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
@@ -68,7 +69,7 @@ func generateTestChainWithFork(n int, fork int) (*core.Genesis, []*types.Block,
 	}
 	db := rawdb.NewMemoryDatabase()
 	//nolint:composites
-	config := &params.ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(0), new(params.EthashConfig), nil}
+	config := &params.ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(0), new(params.EthashConfig), nil, nil}
 	genesis := &core.Genesis{
 		Config:    config,
 		Alloc:     core.GenesisAlloc{testAddr: {Balance: testBalance}},
@@ -141,6 +142,120 @@ func TestEth2AssembleBlockWithAnotherBlocksTxs(t *testing.T) {
 	}
 }
 
+func TestEth2PreparePayload(t *testing.T) {
+	genesis, blocks, _ := generateTestChainWithFork(10, 4)
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+	attrs := payloadAttributes{
+		ParentHash:   blocks[8].Hash(),
+		Timestamp:    blocks[9].Time(),
+		FeeRecipient: common.Address{0x01},
+		Random:       common.Hash{0x02},
+	}
+	resp, err := api.PreparePayload(attrs)
+	if err != nil {
+		t.Fatalf("error preparing payload, err=%v", err)
+	}
+	// Preparing the same payload again should yield the same identifier
+	if again, err := api.PreparePayload(attrs); err != nil || again.PayloadID != resp.PayloadID {
+		t.Fatalf("payload identifier mismatch: have %v, want %v (err=%v)", again, resp.PayloadID, err)
+	}
+	// Add a transaction to the pool and wait for the payload to include it
+	signer := types.NewEIP155Signer(ethservice.BlockChain().Config().ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, blocks[8].Coinbase(), big.NewInt(1000), params.TxGas, nil, nil), signer, testKey)
+	if err != nil {
+		t.Fatalf("error signing transaction, err=%v", err)
+	}
+	ethservice.TxPool().AddLocal(tx)
+
+	payload := api.payloads.get(uint64(resp.PayloadID))
+	for start := time.Now(); len(payload.resolve().Transactions()) != 1; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("payload not improved with the new transaction")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	execData, err := api.GetPayload(resp.PayloadID)
+	if err != nil {
+		t.Fatalf("error getting payload, err=%v", err)
+	}
+	if execData.ParentHash != attrs.ParentHash || execData.Timestamp != attrs.Timestamp || execData.Miner != attrs.FeeRecipient || execData.Random != attrs.Random {
+		t.Fatalf("payload attributes mismatch: %+v", execData)
+	}
+	if len(execData.Transactions) != 1 {
+		t.Fatalf("payload transaction count mismatch: have %d, want 1", len(execData.Transactions))
+	}
+	// Delivering the payload should stop its builder
+	select {
+	case <-payload.done:
+	case <-time.After(time.Second):
+		t.Fatalf("payload builder still running after delivery")
+	}
+	// The built payload should be importable as is
+	success, err := api.NewBlock(*execData)
+	if err != nil || !success.Valid {
+		t.Fatalf("failed to insert payload: %v", err)
+	}
+	if head := ethservice.BlockChain().CurrentBlock().Hash(); head != execData.BlockHash {
+		t.Fatalf("head mismatch: have %x, want %x", head, execData.BlockHash)
+	}
+}
+
+func TestEth2PayloadBuilderStop(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+	resp, err := api.PreparePayload(payloadAttributes{ParentHash: blocks[8].Hash(), Timestamp: blocks[9].Time()})
+	if err != nil {
+		t.Fatalf("error preparing payload, err=%v", err)
+	}
+	payload := api.payloads.get(uint64(resp.PayloadID))
+
+	// Stopping the API should terminate the running builders
+	if err := api.Stop(); err != nil {
+		t.Fatalf("failed to stop API: %v", err)
+	}
+	select {
+	case <-payload.done:
+	default:
+		t.Fatalf("payload builder still running after stop")
+	}
+	// Payloads prepared afterwards should not be built on
+	resp, err = api.PreparePayload(payloadAttributes{ParentHash: blocks[8].Hash(), Timestamp: blocks[9].Time() + 1})
+	if err != nil {
+		t.Fatalf("error preparing payload, err=%v", err)
+	}
+	select {
+	case <-api.payloads.get(uint64(resp.PayloadID)).done:
+	default:
+		t.Fatalf("payload builder started after stop")
+	}
+	if _, err := api.GetPayload(resp.PayloadID); err != nil {
+		t.Fatalf("error getting payload, err=%v", err)
+	}
+}
+
+func TestEth2PreparePayloadErrors(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+	if _, err := api.PreparePayload(payloadAttributes{ParentHash: common.Hash{0x01}, Timestamp: blocks[9].Time()}); err == nil {
+		t.Fatalf("prepared payload on unknown parent")
+	}
+	if _, err := api.PreparePayload(payloadAttributes{ParentHash: blocks[8].Hash(), Timestamp: blocks[8].Time()}); err == nil {
+		t.Fatalf("prepared payload with stale timestamp")
+	}
+	if _, err := api.GetPayload(0x01); err != errUnknownPayload {
+		t.Fatalf("unknown payload error mismatch: have %v, want %v", err, errUnknownPayload)
+	}
+}
+
 func TestEth2NewBlock(t *testing.T) {
 	genesis, blocks, forkedBlocks := generateTestChainWithFork(10, 4)
 	n, ethservice := startEthService(t, genesis, blocks[1:5])
@@ -202,6 +317,111 @@ func TestEth2NewBlock(t *testing.T) {
 	}
 }
 
+func TestEth2FinalizeBlock(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+	if _, err := api.FinalizeBlock(common.Hash{0x01}); err == nil {
+		t.Fatalf("finalized unknown block")
+	}
+	if ethservice.BlockChain().CurrentFinalizedBlock() != nil {
+		t.Fatalf("finalized block set before finalization")
+	}
+	success, err := api.FinalizeBlock(blocks[5].Hash())
+	if err != nil || !success.Success {
+		t.Fatalf("failed to finalize block: %v", err)
+	}
+	if have, want := ethservice.BlockChain().CurrentFinalizedBlock().Hash(), blocks[5].Hash(); have != want {
+		t.Fatalf("finalized block mismatch: have %x, want %x", have, want)
+	}
+	if have, want := ethservice.BlockChain().CurrentSafeBlock().Hash(), blocks[5].Hash(); have != want {
+		t.Fatalf("safe block mismatch: have %x, want %x", have, want)
+	}
+}
+
+func TestEth2ForkchoiceUpdated(t *testing.T) {
+	genesis, blocks, forkedBlocks := generateTestChainWithFork(10, 4)
+	n, ethservice := startEthService(t, genesis, blocks[1:5])
+	defer n.Close()
+
+	var (
+		api = newConsensusAPI(ethservice)
+		bc  = ethservice.BlockChain()
+	)
+	// Feed a block whose parent is missing, it should be kept until synced
+	params := blockToExecutableData(blocks[6])
+	block, err := insertBlockParamsToBlock(*params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := api.NewBlock(*params)
+	if err != nil || resp.Valid || resp.Status != statusSyncing {
+		t.Fatalf("unexpected response to block with missing parent: %+v (err=%v)", resp, err)
+	}
+	state := forkchoiceState{
+		HeadBlockHash:      block.Hash(),
+		SafeBlockHash:      blocks[5].Hash(),
+		FinalizedBlockHash: blocks[4].Hash(),
+	}
+	if resp, err := api.ForkchoiceUpdated(state); err != nil || resp.Status != statusSyncing {
+		t.Fatalf("unexpected response to unavailable head: %+v (err=%v)", resp, err)
+	}
+	// Backfill the missing parent and choose the kept block as the head
+	if _, err := bc.InsertChain(blocks[5:6]); err != nil {
+		t.Fatalf("failed to backfill chain: %v", err)
+	}
+	if resp, err := api.ForkchoiceUpdated(state); err != nil || resp.Status != statusValid {
+		t.Fatalf("failed to update fork choice: %+v (err=%v)", resp, err)
+	}
+	if head := bc.CurrentBlock().Hash(); head != block.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, block.Hash())
+	}
+	if safe := bc.CurrentSafeBlock().Hash(); safe != blocks[5].Hash() {
+		t.Fatalf("safe block mismatch: have %x, want %x", safe, blocks[5].Hash())
+	}
+	if final := bc.CurrentFinalizedBlock().Hash(); final != blocks[4].Hash() {
+		t.Fatalf("finalized block mismatch: have %x, want %x", final, blocks[4].Hash())
+	}
+	// Insert a fork and switch over to it, regardless of the total difficulty
+	var fork *types.Block
+	for i := 0; i < 2; i++ {
+		params := blockToExecutableData(forkedBlocks[i])
+		if fork != nil {
+			params.ParentHash = fork.Hash()
+		}
+		if resp, err := api.NewBlock(*params); err != nil || !resp.Valid {
+			t.Fatalf("failed to insert forked block #%d: %v", i, err)
+		}
+		if fork, err = insertBlockParamsToBlock(*params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state = forkchoiceState{HeadBlockHash: fork.Hash(), FinalizedBlockHash: blocks[4].Hash()}
+	if resp, err := api.ForkchoiceUpdated(state); err != nil || resp.Status != statusValid {
+		t.Fatalf("failed to switch to fork: %+v (err=%v)", resp, err)
+	}
+	if head := bc.CurrentBlock().Hash(); head != fork.Hash() {
+		t.Fatalf("head mismatch after switch: have %x, want %x", head, fork.Hash())
+	}
+	if safe := bc.CurrentSafeBlock(); safe == nil || safe.Hash() != blocks[4].Hash() {
+		t.Fatalf("safe block not reset to finalized block: %v", safe)
+	}
+	// A finalized block off the canonical chain is rejected
+	state = forkchoiceState{HeadBlockHash: fork.Hash(), FinalizedBlockHash: blocks[5].Hash()}
+	if resp, err := api.ForkchoiceUpdated(state); err == nil || resp.Status != statusInvalid {
+		t.Fatalf("non-canonical finalized block accepted: %+v", resp)
+	}
+	// Rewind the chain through the legacy head setter
+	if resp, err := api.SetHead(blocks[4].Hash()); err != nil || !resp.Success {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if head := bc.CurrentBlock().Hash(); head != blocks[4].Hash() {
+		t.Fatalf("head mismatch after rewind: have %x, want %x", head, blocks[4].Hash())
+	}
+}
+
 // startEthService creates a full node instance for testing.
 func startEthService(t *testing.T, genesis *core.Genesis, blocks []*types.Block) (*node.Node, *eth.Ethereum) {
 	t.Helper()
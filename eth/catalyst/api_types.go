@@ -34,6 +34,21 @@ type assembleBlockParamsMarshaling struct {
 	Timestamp hexutil.Uint64
 }
 
+//go:generate go run github.com/fjl/gencodec -type payloadAttributes -field-override payloadAttributesMarshaling -out gen_payloadattributes.go
+
+// payloadAttributes are the parameters of a payload to build on top of a parent.
+type payloadAttributes struct {
+	ParentHash   common.Hash    `json:"parentHash"    gencodec:"required"`
+	Timestamp    uint64         `json:"timestamp"     gencodec:"required"`
+	FeeRecipient common.Address `json:"feeRecipient"  gencodec:"required"`
+	Random       common.Hash    `json:"random"        gencodec:"required"`
+}
+
+// JSON type overrides for payloadAttributes.
+type payloadAttributesMarshaling struct {
+	Timestamp hexutil.Uint64
+}
+
 //go:generate go run github.com/fjl/gencodec -type executableData -field-override executableDataMarshaling -out gen_ed.go
 
 // Structure described at https://notes.ethereum.org/@n0ble/rayonism-the-merge-spec#Parameters1
@@ -49,6 +64,7 @@ type executableData struct {
 	ReceiptRoot  common.Hash    `json:"receiptsRoot"  gencodec:"required"`
 	LogsBloom    []byte         `json:"logsBloom"     gencodec:"required"`
 	Transactions [][]byte       `json:"transactions"  gencodec:"required"`
+	Random       common.Hash    `json:"random"`
 }
 
 // JSON type overrides for executableData.
@@ -61,10 +77,34 @@ type executableDataMarshaling struct {
 	Transactions []hexutil.Bytes
 }
 
+// Statuses of the blocks and fork choices reported to the consensus driver.
+const (
+	statusValid   = "VALID"
+	statusInvalid = "INVALID"
+	statusSyncing = "SYNCING"
+)
+
 type newBlockResponse struct {
-	Valid bool `json:"valid"`
+	Valid  bool   `json:"valid"`
+	Status string `json:"status"`
+}
+
+// forkchoiceState is the head, safe and finalized blocks chosen by the consensus
+// driver. The safe and finalized hashes may be zero if not known yet.
+type forkchoiceState struct {
+	HeadBlockHash      common.Hash `json:"headBlockHash"`
+	SafeBlockHash      common.Hash `json:"safeBlockHash"`
+	FinalizedBlockHash common.Hash `json:"finalizedBlockHash"`
+}
+
+type forkchoiceResponse struct {
+	Status string `json:"status"`
 }
 
 type genericResponse struct {
 	Success bool `json:"success"`
 }
+
+type preparePayloadResponse struct {
+	PayloadID hexutil.Uint64 `json:"payloadId"`
+}
@@ -26,6 +26,7 @@ func (e executableData) MarshalJSON() ([]byte, error) {
 		ReceiptRoot  common.Hash     `json:"receiptsRoot"  gencodec:"required"`
 		LogsBloom    hexutil.Bytes   `json:"logsBloom"     gencodec:"required"`
 		Transactions []hexutil.Bytes `json:"transactions"  gencodec:"required"`
+		Random       common.Hash     `json:"random"`
 	}
 	var enc executableData
 	enc.BlockHash = e.BlockHash
@@ -44,6 +45,7 @@ func (e executableData) MarshalJSON() ([]byte, error) {
 			enc.Transactions[k] = v
 		}
 	}
+	enc.Random = e.Random
 	return json.Marshal(&enc)
 }
 
@@ -61,6 +63,7 @@ func (e *executableData) UnmarshalJSON(input []byte) error {
 		ReceiptRoot  *common.Hash    `json:"receiptsRoot"  gencodec:"required"`
 		LogsBloom    *hexutil.Bytes  `json:"logsBloom"     gencodec:"required"`
 		Transactions []hexutil.Bytes `json:"transactions"  gencodec:"required"`
+		Random       *common.Hash    `json:"random"`
 	}
 	var dec executableData
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -113,5 +116,8 @@ func (e *executableData) UnmarshalJSON(input []byte) error {
 	for k, v := range dec.Transactions {
 		e.Transactions[k] = v
 	}
+	if dec.Random != nil {
+		e.Random = *dec.Random
+	}
 	return nil
 }
@@ -0,0 +1,208 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// maxTrackedPayloads is the maximum number of prepared payloads kept around
+	// for retrieval, the oldest ones being dropped first.
+	maxTrackedPayloads = 10
+
+	// payloadBuildTimeout is the time after which a payload isn't improved any
+	// more, roughly the length of a beacon chain slot.
+	payloadBuildTimeout = 12 * time.Second
+
+	// txChanSize is the size of channel listening to NewTxsEvent.
+	txChanSize = 4096
+)
+
+var errUnknownPayload = errors.New("unknown payload")
+
+// computePayloadID derives the identifier of a payload from its attributes, so
+// that preparing the same payload twice yields the same one.
+func computePayloadID(attrs *payloadAttributes) uint64 {
+	var timestamp [8]byte
+	binary.BigEndian.PutUint64(timestamp[:], attrs.Timestamp)
+
+	hash := crypto.Keccak256(attrs.ParentHash[:], timestamp[:], attrs.FeeRecipient[:], attrs.Random[:])
+	return binary.BigEndian.Uint64(hash[:8])
+}
+
+// payload is a block being built in the background, replaced by better versions
+// of itself as new transactions arrive, until stopped.
+type payload struct {
+	id    uint64
+	attrs payloadAttributes
+
+	best *types.Block // Best version of the payload built so far
+	fees *big.Int     // Fees earned by the fee recipient with the best version
+	lock sync.Mutex
+
+	stop     chan struct{} // Channel to stop the builder with
+	stopOnce sync.Once
+	done     chan struct{} // Closed when the builder exits
+}
+
+func newPayload(id uint64, attrs payloadAttributes, empty *types.Block) *payload {
+	return &payload{
+		id:    id,
+		attrs: attrs,
+		best:  empty,
+		fees:  new(big.Int),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// update replaces the best version of the payload with the given block if it's
+// more profitable, or equally profitable but including more transactions.
+func (p *payload) update(block *types.Block, fees *big.Int) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	switch fees.Cmp(p.fees) {
+	case 1:
+	case 0:
+		if block.GasUsed() <= p.best.GasUsed() {
+			return false
+		}
+	default:
+		return false
+	}
+	p.best, p.fees = block, fees
+	return true
+}
+
+// resolve retrieves the best version of the payload built so far.
+func (p *payload) resolve() *types.Block {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.best
+}
+
+// close stops the background building of the payload.
+func (p *payload) close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// payloadQueue tracks the most recently prepared payloads.
+type payloadQueue struct {
+	payloads []*payload
+	lock     sync.Mutex
+}
+
+// add inserts a new payload into the queue, dropping the oldest one if full. It
+// returns false if a payload with the same identifier is already tracked.
+func (q *payloadQueue) add(p *payload) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, tracked := range q.payloads {
+		if tracked.id == p.id {
+			return false
+		}
+	}
+	if len(q.payloads) >= maxTrackedPayloads {
+		q.payloads[0].close()
+		q.payloads = q.payloads[1:]
+	}
+	q.payloads = append(q.payloads, p)
+	return true
+}
+
+// get retrieves a tracked payload by its identifier, or nil if unknown.
+func (q *payloadQueue) get(id uint64) *payload {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, p := range q.payloads {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// buildPayload keeps rebuilding the payload with the pending transactions each
+// time new ones arrive, until the payload is stopped or delivered, the API is
+// stopped or the build times out.
+func (api *consensusAPI) buildPayload(p *payload, parent *types.Block) {
+	defer close(p.done)
+
+	txs := make(chan core.NewTxsEvent, txChanSize)
+	sub := api.eth.TxPool().SubscribeNewTxsEvent(txs)
+	defer sub.Unsubscribe()
+
+	timeout := time.NewTimer(payloadBuildTimeout)
+	defer timeout.Stop()
+
+	for {
+		pending, err := api.eth.TxPool().Pending()
+		if err != nil {
+			log.Warn("Failed to retrieve pending transactions", "id", p.id, "err", err)
+			return
+		}
+		block, fees, err := api.assembleBlock(parent, &p.attrs, pending)
+		if err != nil {
+			log.Warn("Failed to build payload", "id", p.id, "err", err)
+			return
+		}
+		if p.update(block, fees) {
+			log.Debug("Improved payload", "id", p.id, "txs", len(block.Transactions()), "gas", block.GasUsed(), "fees", fees)
+		}
+		// Wait for new transactions, batching up the ones already arrived
+		select {
+		case <-txs:
+		case <-timeout.C:
+			return
+		case <-p.stop:
+			return
+		case <-api.quit:
+			return
+		case <-sub.Err():
+			return
+		}
+		for drained := false; !drained; {
+			select {
+			case <-txs:
+			default:
+				drained = true
+			}
+		}
+		// Don't start rebuilding if stopped in the meantime
+		select {
+		case <-p.stop:
+			return
+		case <-api.quit:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxSyncingBlocks is the maximum number of new blocks kept around while their
+// ancestors are being synced, the lowest ones being dropped first.
+const maxSyncingBlocks = 64
+
+// syncingBlocks tracks the new blocks received while the node is still syncing,
+// to be imported once the chain is backfilled up to them.
+type syncingBlocks struct {
+	blocks map[common.Hash]*types.Block
+	lock   sync.Mutex
+}
+
+// add stores a new block, dropping the lowest one tracked if full.
+func (s *syncingBlocks) add(block *types.Block) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.blocks == nil {
+		s.blocks = make(map[common.Hash]*types.Block)
+	}
+	if len(s.blocks) >= maxSyncingBlocks {
+		var lowest *types.Block
+		for _, tracked := range s.blocks {
+			if lowest == nil || tracked.NumberU64() < lowest.NumberU64() {
+				lowest = tracked
+			}
+		}
+		delete(s.blocks, lowest.Hash())
+	}
+	s.blocks[block.Hash()] = block
+}
+
+// get retrieves a tracked block by hash, or nil if unknown.
+func (s *syncingBlocks) get(hash common.Hash) *types.Block {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.blocks[hash]
+}
+
+// remove drops a tracked block, once imported.
+func (s *syncingBlocks) remove(hash common.Hash) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.blocks, hash)
+}
@@ -0,0 +1,584 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// maxBackfillBatches is the maximum number of header batches downloaded ahead
+	// of the verified tail of the anchor backfill, waiting to be linked up.
+	maxBackfillBatches = 64
+
+	// backfillPeerPenalty is the time a peer failing to serve a backfill request
+	// is not asked for headers again.
+	backfillPeerPenalty = time.Minute
+)
+
+// SetSyncAnchor configures a trusted block hash to anchor fast and snap sync on.
+//
+// If set, a node which doesn't have the anchor block yet retrieves the anchor
+// block by hash and inserts it as the head header right away, without its
+// ancestors. The state of the anchor is then synced, after which the anchor
+// becomes the head block and the chain is synced from there on as usual.
+// Meanwhile the header chain below the anchor is downloaded backward in the
+// background from all available peers, linking each header to its child by hash
+// instead of verifying the seals. Bodies and receipts of the blocks below the
+// anchor are not retrieved.
+//
+// The anchor must be set before the first sync cycle starts.
+func (d *Downloader) SetSyncAnchor(hash common.Hash) {
+	d.anchor = hash
+}
+
+// needsAnchorSync reports whether the current sync cycle should be anchored on
+// the configured trusted block.
+func (d *Downloader) needsAnchorSync() bool {
+	if d.anchor == (common.Hash{}) || d.blockchain == nil || d.getMode() != FastSync {
+		return false
+	}
+	header := d.lightchain.GetHeaderByHash(d.anchor)
+	if header == nil {
+		return true
+	}
+	return d.blockchain.CurrentBlock().NumberU64() < header.Number.Uint64()
+}
+
+// syncAnchored runs a sync cycle anchored on the trusted block: the anchor block
+// is inserted as the head header, the headers below it are backfilled in the
+// background and the anchor state is downloaded. The anchor block is committed
+// as the new head as soon as its state is complete, independent of the backfill.
+func (d *Downloader) syncAnchored(p *peerConnection) error {
+	anchor := d.lightchain.GetHeaderByHash(d.anchor)
+	if anchor == nil || !d.blockchain.HasFastBlock(d.anchor, anchor.Number.Uint64()) {
+		header, err := d.fetchAnchor(p)
+		if err != nil {
+			return err
+		}
+		if header.Number.Sign() == 0 {
+			return fmt.Errorf("%w: anchor has unknown genesis %x", errInvalidChain, d.anchor)
+		}
+		block, receipts, err := d.fetchAnchorBlock(p, header)
+		if err != nil {
+			return err
+		}
+		if err := d.blockchain.InsertAnchorBlock(block, receipts); err != nil {
+			return err
+		}
+		anchor = header
+
+		// Unless the anchor already links up with the local chain, backfill the
+		// headers below it
+		if !d.isLocalHeader(anchor.ParentHash, anchor.Number.Uint64()-1) {
+			rawdb.WriteAnchorBackfill(d.stateDB, d.anchor, anchor.Number.Uint64())
+		}
+	}
+	d.startBackfill()
+
+	number := anchor.Number.Uint64()
+	log.Info("Starting anchored sync", "number", number, "hash", d.anchor)
+
+	d.syncStatsLock.Lock()
+	d.syncStatsChainOrigin = 0
+	d.syncStatsChainHeight = number
+	d.syncStatsLock.Unlock()
+
+	// Write out the anchor as the pivot, so a rollback beyond it (or a restart
+	// before it's committed) reenables fast sync
+	rawdb.WriteLastPivotNumber(d.stateDB, number)
+
+	d.pivotLock.Lock()
+	d.pivotHeader = anchor
+	d.pivotLock.Unlock()
+
+	sync := d.syncState(anchor.Root)
+	defer sync.Cancel()
+
+	select {
+	case <-sync.done:
+		if sync.err != nil {
+			return sync.err
+		}
+	case <-d.cancelCh:
+		return errCanceled
+	}
+	if err := d.blockchain.FastSyncCommitHead(d.anchor); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&d.committed, 1)
+
+	if d.stateBloom != nil {
+		d.stateBloom.Close()
+	}
+	log.Info("Anchored sync completed", "number", number, "hash", d.anchor)
+	return nil
+}
+
+// fetchAnchor retrieves the trusted anchor header from a remote peer.
+func (d *Downloader) fetchAnchor(p *peerConnection) (*types.Header, error) {
+	p.log.Debug("Retrieving sync anchor", "hash", d.anchor)
+	go p.peer.RequestHeadersByHash(d.anchor, 1, 0, false)
+
+	headers, err := d.waitAnchorHeaders(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) != 1 {
+		return nil, fmt.Errorf("%w: returned headers %d != requested %d", errBadPeer, len(headers), 1)
+	}
+	if hash := headers[0].Hash(); hash != d.anchor {
+		return nil, fmt.Errorf("%w: anchor hash mismatch: have %x, want %x", errBadPeer, hash, d.anchor)
+	}
+	return headers[0], nil
+}
+
+// isLocalHeader reports whether the header is part of the local chain, with its
+// total difficulty known.
+func (d *Downloader) isLocalHeader(hash common.Hash, number uint64) bool {
+	return d.lightchain.HasHeader(hash, number) && d.lightchain.GetTd(hash, number) != nil
+}
+
+// backfillTask is a range of headers to download below the anchor, counting
+// downward from the origin.
+type backfillTask struct {
+	origin uint64
+	count  int
+}
+
+// backfillRequest is a header retrieval request of the backfill in flight.
+type backfillRequest struct {
+	backfillTask
+	peer *peerConnection
+	sent time.Time
+}
+
+// backfillResponse is a header batch delivered for a backfill request.
+type backfillResponse struct {
+	req     *backfillRequest
+	headers []*types.Header
+}
+
+// backfiller is the state of the header backfill below a sync anchor shared with
+// the packet delivery.
+type backfiller struct {
+	pending  map[string]*backfillRequest // Requests in flight, by peer id
+	response chan *backfillResponse      // Channel delivering the responses to the backfill loop
+	lock     sync.Mutex                  // Lock protecting the pending requests
+
+	done chan struct{} // Channel closed when the backfill terminates
+}
+
+// startBackfill launches the background header backfill below the sync anchor,
+// if one is in progress and not running yet.
+func (d *Downloader) startBackfill() {
+	if d.blockchain == nil {
+		return
+	}
+	hash, tail := rawdb.ReadAnchorBackfill(d.stateDB)
+	if hash == (common.Hash{}) {
+		return
+	}
+	d.backfillLock.Lock()
+	defer d.backfillLock.Unlock()
+
+	if d.backfill != nil {
+		return
+	}
+	anchor := d.lightchain.GetHeaderByHash(hash)
+	if anchor == nil {
+		log.Error("Backfilled sync anchor missing", "hash", hash)
+		return
+	}
+	b := &backfiller{
+		pending:  make(map[string]*backfillRequest),
+		response: make(chan *backfillResponse),
+		done:     make(chan struct{}),
+	}
+	d.backfill = b
+	go d.runBackfill(b, anchor, tail)
+}
+
+// deliverBackfill hands a header batch to the background backfill if it's the
+// response to one of its requests, reporting whether it was consumed.
+func (d *Downloader) deliverBackfill(id string, headers []*types.Header) bool {
+	d.backfillLock.Lock()
+	b := d.backfill
+	d.backfillLock.Unlock()
+
+	if b == nil {
+		return false
+	}
+	b.lock.Lock()
+	req := b.pending[id]
+	if req == nil {
+		b.lock.Unlock()
+		return false
+	}
+	// A mismatching response is for the master peer of the sync cycle if it was
+	// asked for headers meanwhile, otherwise it's a failed backfill request
+	if len(headers) > 0 && headers[0].Number.Uint64() != req.origin {
+		d.cancelLock.RLock()
+		master := d.cancelPeer
+		d.cancelLock.RUnlock()
+
+		if d.Synchronising() && id == master {
+			b.lock.Unlock()
+			return false
+		}
+		headers = nil
+	}
+	delete(b.pending, id)
+	b.lock.Unlock()
+
+	headerInMeter.Mark(int64(len(headers)))
+	select {
+	case b.response <- &backfillResponse{req: req, headers: headers}:
+	case <-b.done:
+	}
+	return true
+}
+
+// runBackfill downloads the headers below the anchor backward from the given
+// number, the lowest header already downloaded, until they link up with the
+// local chain. Batches are requested concurrently from all idle peers, except
+// the master peer of a running sync cycle, and linked up by hash in order.
+// Finally the headers are inserted into the local chain.
+func (d *Downloader) runBackfill(b *backfiller, anchor *types.Header, number uint64) {
+	defer func() {
+		b.lock.Lock()
+		for id, req := range b.pending {
+			atomic.StoreInt32(&req.peer.headerIdle, 0)
+			delete(b.pending, id)
+		}
+		b.lock.Unlock()
+
+		d.backfillLock.Lock()
+		d.backfill = nil
+		d.backfillLock.Unlock()
+
+		close(b.done)
+	}()
+	// Resume from the lowest header downloaded so far
+	tail := anchor
+	if number < anchor.Number.Uint64() {
+		if tail = rawdb.ReadAnchorHeader(d.stateDB, number); tail == nil {
+			log.Warn("Backfilled anchor header missing, restarting", "number", number)
+			tail = anchor
+		}
+	}
+	log.Info("Backfilling headers below sync anchor", "anchor", anchor.Number, "tail", tail.Number)
+
+	var (
+		results = make(map[uint64]*backfillResponse) // Downloaded batches by origin, waiting to be linked up
+		retries []backfillTask                       // Ranges to request again after failures
+		next    = tail.Number.Uint64() - 1           // Origin of the next range to request
+		stale   = make(map[string]time.Time)         // Peers which recently failed to serve the backfill
+		ticker  = time.NewTicker(time.Second)
+		logged  = time.Now()
+	)
+	defer ticker.Stop()
+
+	for {
+		// Link the downloaded batches up with the verified tail
+		for !d.isLocalHeader(tail.ParentHash, tail.Number.Uint64()-1) {
+			res := results[tail.Number.Uint64()-1]
+			if res == nil {
+				break
+			}
+			delete(results, res.req.origin)
+
+			linked, err := d.linkBackfill(anchor, tail, res.headers)
+			if linked > 0 {
+				tail = res.headers[linked-1]
+			}
+			if err != nil {
+				log.Debug("Invalid backfill headers", "peer", res.req.peer.id, "origin", res.req.origin, "err", err)
+				stale[res.req.peer.id] = time.Now()
+				if d.dropPeer == nil {
+					// The dropPeer method is nil when `--copydb` is used for a local copy.
+					log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", res.req.peer.id)
+				} else {
+					d.dropPeer(res.req.peer.id)
+				}
+			}
+			// Request the rest of partial batches again
+			if linked < res.req.count && !d.isLocalHeader(tail.ParentHash, tail.Number.Uint64()-1) {
+				retries = append(retries, backfillTask{origin: tail.Number.Uint64() - 1, count: res.req.count - linked})
+			}
+		}
+		if d.isLocalHeader(tail.ParentHash, tail.Number.Uint64()-1) {
+			if err := d.importBackfill(anchor, tail.Number.Uint64()); err != nil {
+				if err != errCanceled {
+					log.Error("Failed to import backfilled headers", "err", err)
+				}
+				return
+			}
+			log.Info("Backfilled headers below sync anchor", "anchor", anchor.Number, "hash", anchor.Hash())
+			return
+		}
+		if tail.Number.Uint64() == 1 {
+			log.Error("Sync anchor has unknown genesis", "anchor", anchor.Hash(), "genesis", tail.ParentHash)
+			return
+		}
+		// Assign the pending ranges to the idle peers
+		d.cancelLock.RLock()
+		master := d.cancelPeer
+		d.cancelLock.RUnlock()
+		syncing := d.Synchronising()
+
+		for _, p := range d.peers.AllPeers() {
+			if len(retries) == 0 && (next == 0 || tail.Number.Uint64()-1-next >= uint64(maxBackfillBatches*MaxHeaderFetch)) {
+				break
+			}
+			if (syncing && p.id == master) || time.Since(stale[p.id]) < backfillPeerPenalty {
+				continue
+			}
+			var task backfillTask
+			if len(retries) > 0 {
+				task, retries = retries[0], retries[1:]
+			} else {
+				task = backfillTask{origin: next, count: MaxHeaderFetch}
+				if next < uint64(task.count) {
+					task.count = int(next)
+				}
+				next -= uint64(task.count)
+			}
+			req := &backfillRequest{backfillTask: task, peer: p, sent: time.Now()}
+
+			b.lock.Lock()
+			_, busy := b.pending[p.id]
+			if !busy {
+				b.pending[p.id] = req
+			}
+			b.lock.Unlock()
+
+			if busy || p.FetchAnchorHeaders(task.origin, task.count) != nil {
+				if !busy {
+					b.lock.Lock()
+					delete(b.pending, p.id)
+					b.lock.Unlock()
+				}
+				retries = append(retries, task)
+			}
+		}
+		// Wait for responses and time out the stalling requests
+		select {
+		case res := <-b.response:
+			res.req.peer.SetHeadersIdle(len(res.headers), time.Now())
+			if len(res.headers) == 0 {
+				stale[res.req.peer.id] = time.Now()
+				retries = append(retries, res.req.backfillTask)
+				continue
+			}
+			results[res.req.origin] = res
+
+		case <-ticker.C:
+			b.lock.Lock()
+			for id, req := range b.pending {
+				if time.Since(req.sent) > d.requestTTL() {
+					delete(b.pending, id)
+					req.peer.SetHeadersIdle(0, time.Now())
+					stale[id] = time.Now()
+					retries = append(retries, req.backfillTask)
+				}
+			}
+			inflight := len(b.pending)
+			b.lock.Unlock()
+
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Backfilling headers below sync anchor", "anchor", anchor.Number, "tail", tail.Number, "inflight", inflight, "queued", len(results))
+				logged = time.Now()
+			}
+
+		case <-d.quitCh:
+			return
+		}
+	}
+}
+
+// linkBackfill verifies that the header batch links up with the tail of the
+// backfill by hash, storing the linked headers along with the new tail. It stops
+// at the first header whose parent is part of the local chain and returns the
+// number of headers linked.
+func (d *Downloader) linkBackfill(anchor, tail *types.Header, headers []*types.Header) (int, error) {
+	var (
+		batch  = d.stateDB.NewBatch()
+		linked int
+		err    error
+	)
+	for _, header := range headers {
+		if header.Hash() != tail.ParentHash || header.Number.Uint64()+1 != tail.Number.Uint64() {
+			err = fmt.Errorf("%w: header #%d [%x..] is not the parent of #%d", errInvalidChain, header.Number, header.Hash().Bytes()[:4], tail.Number)
+			break
+		}
+		rawdb.WriteAnchorHeader(batch, header)
+		tail, linked = header, linked+1
+
+		if d.isLocalHeader(header.ParentHash, header.Number.Uint64()-1) {
+			break
+		}
+	}
+	if linked > 0 {
+		rawdb.WriteAnchorBackfill(batch, anchor.Hash(), tail.Number.Uint64())
+		if err := batch.Write(); err != nil {
+			return 0, err
+		}
+	}
+	return linked, err
+}
+
+// importBackfill inserts the backfilled headers into the local chain from the
+// given number forward, linking the anchor up with the local chain. Seals are not
+// checked since the whole chain is already linked to the trusted anchor.
+func (d *Downloader) importBackfill(anchor *types.Header, from uint64) error {
+	chunk := make([]*types.Header, 0, maxHeadersProcess)
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if n, err := d.blockchain.BackfillHeaderChain(chunk); err != nil {
+			log.Warn("Invalid backfilled header encountered", "number", chunk[n].Number, "hash", chunk[n].Hash(), "parent", chunk[n].ParentHash, "err", err)
+			return fmt.Errorf("%w: %v", errInvalidChain, err)
+		}
+		batch := d.stateDB.NewBatch()
+		for _, header := range chunk {
+			rawdb.DeleteAnchorHeader(batch, header.Number.Uint64())
+		}
+		rawdb.WriteAnchorBackfill(batch, anchor.Hash(), chunk[len(chunk)-1].Number.Uint64()+1)
+		chunk = chunk[:0]
+		return batch.Write()
+	}
+	for number := from; number < anchor.Number.Uint64(); number++ {
+		select {
+		case <-d.quitCh:
+			return errCanceled
+		default:
+		}
+		header := rawdb.ReadAnchorHeader(d.stateDB, number)
+		if header == nil {
+			return fmt.Errorf("missing backfilled header #%d", number)
+		}
+		chunk = append(chunk, header)
+		if len(chunk) == cap(chunk) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	rawdb.DeleteAnchorBackfill(d.stateDB)
+	return nil
+}
+
+// fetchAnchorBlock retrieves the body and receipts of the anchor block, which
+// are needed to make it the head block.
+func (d *Downloader) fetchAnchorBlock(p *peerConnection, anchor *types.Header) (*types.Block, types.Receipts, error) {
+	go p.peer.RequestBodies([]common.Hash{d.anchor})
+
+	var block *types.Block
+	timeout := time.After(d.requestTTL())
+	for block == nil {
+		select {
+		case <-d.cancelCh:
+			return nil, nil, errCanceled
+
+		case packet := <-d.bodyCh:
+			if packet.PeerId() != p.id {
+				log.Debug("Received bodies from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			bodies := packet.(*bodyPack)
+			if bodies.Items() != 1 {
+				return nil, nil, fmt.Errorf("%w: returned bodies %d != requested %d", errBadPeer, bodies.Items(), 1)
+			}
+			txs, uncles := bodies.transactions[0], bodies.uncles[0]
+			if types.DeriveSha(types.Transactions(txs), trie.NewStackTrie(nil)) != anchor.TxHash || types.CalcUncleHash(uncles) != anchor.UncleHash {
+				return nil, nil, fmt.Errorf("%w: anchor body mismatch", errInvalidBody)
+			}
+			block = types.NewBlockWithHeader(anchor).WithBody(txs, uncles)
+
+		case <-timeout:
+			p.log.Debug("Waiting for anchor body timed out", "elapsed", d.requestTTL())
+			return nil, nil, errTimeout
+		}
+	}
+	go p.peer.RequestReceipts([]common.Hash{d.anchor})
+
+	timeout = time.After(d.requestTTL())
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, nil, errCanceled
+
+		case packet := <-d.receiptCh:
+			if packet.PeerId() != p.id {
+				log.Debug("Received receipts from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			receipts := packet.(*receiptPack).receipts
+			if len(receipts) != 1 {
+				return nil, nil, fmt.Errorf("%w: returned receipts %d != requested %d", errBadPeer, len(receipts), 1)
+			}
+			if types.DeriveSha(types.Receipts(receipts[0]), trie.NewStackTrie(nil)) != anchor.ReceiptHash {
+				return nil, nil, fmt.Errorf("%w: anchor receipts mismatch", errInvalidReceipt)
+			}
+			return block, receipts[0], nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for anchor receipts timed out", "elapsed", d.requestTTL())
+			return nil, nil, errTimeout
+		}
+	}
+}
+
+// waitAnchorHeaders waits for the response to a header request sent to the
+// given peer during anchored sync.
+func (d *Downloader) waitAnchorHeaders(p *peerConnection) ([]*types.Header, error) {
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCanceled
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			return packet.(*headerPack).headers, nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for anchor headers timed out", "elapsed", ttl)
+			return nil, errTimeout
+		}
+	}
+}
@@ -92,6 +92,7 @@ var (
 	errNoSyncActive            = errors.New("no sync active")
 	errTooOld                  = errors.New("peer's protocol version too old")
 	errNoAncestorFound         = errors.New("no common ancestor found")
+	errUnknownHead             = errors.New("requested head unknown to peer")
 )
 
 type Downloader struct {
@@ -105,10 +106,11 @@ type Downloader struct {
 	mode uint32         // Synchronisation mode defining the strategy used (per sync cycle), use d.getMode() to get the SyncMode
 	mux  *event.TypeMux // Event multiplexer to announce sync operation events
 
-	checkpoint uint64   // Checkpoint block number to enforce head against (e.g. fast sync)
-	genesis    uint64   // Genesis block number to limit sync to (e.g. light client CHT)
-	queue      *queue   // Scheduler for selecting the hashes to download
-	peers      *peerSet // Set of active peers from which download can proceed
+	checkpoint uint64      // Checkpoint block number to enforce head against (e.g. fast sync)
+	anchor     common.Hash // Trusted block hash to anchor fast sync on (skips header verification)
+	genesis    uint64      // Genesis block number to limit sync to (e.g. light client CHT)
+	queue      *queue      // Scheduler for selecting the hashes to download
+	peers      *peerSet    // Set of active peers from which download can proceed
 
 	stateDB    ethdb.Database  // Database to state sync into (and deduplicate via)
 	stateBloom *trie.SyncBloom // Bloom filter for fast trie node and contract code existence checks
@@ -140,6 +142,10 @@ type Downloader struct {
 	receiptWakeCh chan bool            // Channel to signal the receipt fetcher of new tasks
 	headerProcCh  chan []*types.Header // Channel to feed the header processor new tasks
 
+	// Anchored sync
+	backfill     *backfiller // Header backfill below the sync anchor running in the background (nil if idle)
+	backfillLock sync.Mutex  // Lock protecting the backfill field
+
 	// State sync
 	pivotHeader *types.Header // Pivot block header to dynamically push the syncing state root
 	pivotLock   sync.RWMutex  // Lock protecting pivot header reads from updates
@@ -214,6 +220,14 @@ type BlockChain interface {
 
 	// InsertReceiptChain inserts a batch of receipts into the local chain.
 	InsertReceiptChain(types.Blocks, []types.Receipts, uint64) (int, error)
+
+	// InsertAnchorBlock inserts a trusted block with unknown ancestors as the
+	// new head header and fast block.
+	InsertAnchorBlock(*types.Block, types.Receipts) error
+
+	// BackfillHeaderChain inserts a batch of trusted headers below an anchor
+	// block into the local chain.
+	BackfillHeaderChain([]*types.Header) (int, error)
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
@@ -250,6 +264,9 @@ func New(checkpoint uint64, stateDb ethdb.Database, stateBloom *trie.SyncBloom,
 	}
 	go dl.qosTuner()
 	go dl.stateFetcher()
+
+	// Resume any header backfill below a sync anchor interrupted by a restart
+	dl.startBackfill()
 	return dl
 }
 
@@ -277,13 +294,31 @@ func (d *Downloader) Progress() ethereum.SyncProgress {
 	default:
 		log.Error("Unknown downloader chain/mode combo", "light", d.lightchain != nil, "full", d.blockchain != nil, "mode", mode)
 	}
-	return ethereum.SyncProgress{
+	progress := ethereum.SyncProgress{
 		StartingBlock: d.syncStatsChainOrigin,
 		CurrentBlock:  current,
 		HighestBlock:  d.syncStatsChainHeight,
 		PulledStates:  d.syncStatsState.processed,
 		KnownStates:   d.syncStatsState.processed + d.syncStatsState.pending,
 	}
+	if d.SnapSyncer == nil {
+		return progress
+	}
+	if snap := d.SnapSyncer.Progress(); snap != nil {
+		progress.SyncedAccounts = snap.AccountSynced
+		progress.SyncedAccountBytes = uint64(snap.AccountBytes)
+		progress.SyncedBytecodes = snap.BytecodeSynced
+		progress.SyncedBytecodeBytes = uint64(snap.BytecodeBytes)
+		progress.SyncedStorage = snap.StorageSynced
+		progress.SyncedStorageBytes = uint64(snap.StorageBytes)
+		progress.HealedTrienodes = snap.TrienodeHealSynced
+		progress.HealedTrienodeBytes = uint64(snap.TrienodeHealBytes)
+		progress.HealedBytecodes = snap.BytecodeHealSynced
+		progress.HealedBytecodeBytes = uint64(snap.BytecodeHealBytes)
+		progress.HealingTrienodes = snap.HealingTrienodes
+		progress.HealingBytecode = snap.HealingBytecodes
+	}
+	return progress
 }
 
 // Synchronising returns whether the downloader is currently retrieving blocks.
@@ -340,6 +375,8 @@ func (d *Downloader) UnregisterPeer(id string) error {
 
 // Synchronise tries to sync up our local block chain with a remote peer, both
 // adding various sanity checks as well as wrapping it with various log entries.
+// The chain is synced up to the given head, which is usually the one advertised
+// by the peer, but may be any block of the peer's canonical chain.
 func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode SyncMode) error {
 	err := d.synchronise(id, head, td, mode)
 
@@ -470,8 +507,12 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		log.Debug("Synchronisation terminated", "elapsed", common.PrettyDuration(time.Since(start)))
 	}(time.Now())
 
+	// If a trusted anchor is configured and not yet reached, sync straight to it
+	if d.needsAnchorSync() {
+		return d.syncAnchored(p)
+	}
 	// Look up the sync boundaries: the common ancestor and the target block
-	latest, pivot, err := d.fetchHead(p)
+	latest, pivot, err := d.fetchHead(p, hash)
 	if err != nil {
 		return err
 	}
@@ -646,14 +687,13 @@ func (d *Downloader) Terminate() {
 	d.Cancel()
 }
 
-// fetchHead retrieves the head header and prior pivot block (if available) from
-// a remote peer.
-func (d *Downloader) fetchHead(p *peerConnection) (head *types.Header, pivot *types.Header, err error) {
-	p.log.Debug("Retrieving remote chain head")
+// fetchHead retrieves the requested head header and prior pivot block (if
+// available) from a remote peer.
+func (d *Downloader) fetchHead(p *peerConnection, latest common.Hash) (head *types.Header, pivot *types.Header, err error) {
+	p.log.Debug("Retrieving remote chain head", "hash", latest)
 	mode := d.getMode()
 
-	// Request the advertised remote head block and wait for the response
-	latest, _ := p.peer.Head()
+	// Request the remote head block and wait for the response
 	fetch := 1
 	if mode == FastSync {
 		fetch = 2 // head + pivot headers
@@ -675,6 +715,13 @@ func (d *Downloader) fetchHead(p *peerConnection) (head *types.Header, pivot *ty
 			}
 			// Make sure the peer gave us at least one and at most the requested headers
 			headers := packet.(*headerPack).headers
+			if len(headers) == 0 {
+				// A peer not knowing its own advertised head is misbehaving, but the
+				// head requested might also be a block the peer just doesn't have
+				if advertised, _ := p.peer.Head(); advertised != latest {
+					return nil, nil, errUnknownHead
+				}
+			}
 			if len(headers) == 0 || len(headers) > fetch {
 				return nil, nil, fmt.Errorf("%w: returned headers %d != requested %d", errBadPeer, len(headers), fetch)
 			}
@@ -1954,6 +2001,9 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 // DeliverHeaders injects a new batch of block headers received from a remote
 // node into the download schedule.
 func (d *Downloader) DeliverHeaders(id string, headers []*types.Header) error {
+	if d.deliverBackfill(id, headers) {
+		return nil
+	}
 	return d.deliver(d.headerCh, &headerPack{id, headers}, headerInMeter, headerDropMeter)
 }
 
@@ -342,6 +342,66 @@ func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []typ
 	return len(blocks), nil
 }
 
+// InsertAnchorBlock injects a trusted block with unknown ancestors into the
+// simulated chain, with a provisional total difficulty.
+func (dl *downloadTester) InsertAnchorBlock(block *types.Block, receipts types.Receipts) error {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	td := dl.getTd(block.ParentHash())
+	if td == nil {
+		td = new(big.Int).Add(dl.genesis.Difficulty(), new(big.Int).SetUint64(block.NumberU64()-1))
+	}
+	hash := block.Hash()
+	if dl.getHeaderByHash(hash) == nil {
+		dl.ownHashes = append(dl.ownHashes, hash)
+	}
+	dl.ownHeaders[hash] = block.Header()
+	dl.ownBlocks[hash] = block
+	dl.ownReceipts[hash] = receipts
+	dl.ownChainTd[hash] = new(big.Int).Add(td, block.Difficulty())
+	return nil
+}
+
+// BackfillHeaderChain injects a batch of trusted headers below an anchor block
+// into the simulated chain, recalculating the total difficulties above them.
+func (dl *downloadTester) BackfillHeaderChain(headers []*types.Header) (int, error) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	if dl.getTd(headers[0].ParentHash) == nil {
+		return 0, errors.New("BackfillHeaderChain: unknown parent")
+	}
+	// Insert the headers into the hash chain ahead of everything above them
+	pos := len(dl.ownHashes)
+	for i, hash := range dl.ownHashes {
+		if header := dl.getHeaderByHash(hash); header != nil && header.Number.Cmp(headers[0].Number) >= 0 {
+			pos = i
+			break
+		}
+	}
+	hashes := make([]common.Hash, 0, len(headers))
+	for i, header := range headers {
+		if i > 0 && header.ParentHash != headers[i-1].Hash() {
+			return i, fmt.Errorf("non-contiguous import at position %d", i)
+		}
+		hashes = append(hashes, header.Hash())
+	}
+	for _, header := range headers {
+		dl.ownHeaders[header.Hash()] = header
+	}
+	dl.ownHashes = append(dl.ownHashes[:pos], append(hashes, dl.ownHashes[pos:]...)...)
+
+	for _, hash := range dl.ownHashes[pos:] {
+		if header := dl.ownHeaders[hash]; header != nil {
+			if td := dl.getTd(header.ParentHash); td != nil {
+				dl.ownChainTd[hash] = new(big.Int).Add(td, header.Difficulty)
+			}
+		}
+	}
+	return len(headers), nil
+}
+
 // SetHead rewinds the local chain to a new head.
 func (dl *downloadTester) SetHead(head uint64) error {
 	dl.lock.Lock()
@@ -1705,3 +1765,146 @@ func testCheckpointEnforcement(t *testing.T, protocol uint, mode SyncMode) {
 		assertOwnChain(t, tester, chain.len())
 	}
 }
+
+// Tests that a fast sync anchored on a trusted block commits the anchor block and
+// state before the headers below it are backfilled, which happens in the
+// background from any peer, after which regular syncing continues from the
+// anchor.
+func TestAnchoredSync65(t *testing.T) { testAnchoredSync(t, eth.ETH65) }
+func TestAnchoredSync66(t *testing.T) { testAnchoredSync(t, eth.ETH66) }
+
+func testAnchoredSync(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Anchor the sync far enough below the head to need multiple header requests
+	chain := testChainBase.shorten(blockCacheMaxItems - 15)
+	anchor := chain.blockm[chain.chain[2*MaxHeaderFetch+10]]
+	tester.downloader.SetSyncAnchor(anchor.Hash())
+
+	// Sync with a peer withholding the headers below the anchor, which must not
+	// prevent the anchor from becoming the head block
+	withholding := chain.shorten(chain.len())
+	for i := 1; i < int(anchor.NumberU64()); i++ {
+		delete(withholding.headerm, withholding.chain[i])
+	}
+	tester.newPeer("withholding", protocol, withholding)
+
+	if err := tester.sync("withholding", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise to anchor: %v", err)
+	}
+	if head := tester.CurrentBlock(); head.Hash() != anchor.Hash() {
+		t.Fatalf("head block mismatch: have #%d [%x], want #%d [%x]", head.Number(), head.Hash(), anchor.Number(), anchor.Hash())
+	}
+	if tester.GetHeaderByHash(anchor.ParentHash()) != nil {
+		t.Fatalf("anchor parent imported without backfill")
+	}
+	// Backfill the headers from another peer
+	tester.newPeer("peer", protocol, chain)
+	waitBackfill(t, tester)
+
+	// All headers up to the anchor should be present, but only the genesis and
+	// the anchor blocks
+	if hs := len(tester.ownHeaders) + len(tester.ancientHeaders) - 1; hs != int(anchor.NumberU64())+1 {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, anchor.NumberU64()+1)
+	}
+	if bs := len(tester.ownBlocks) + len(tester.ancientBlocks) - 1; bs != 2 {
+		t.Fatalf("synchronised blocks mismatch: have %v, want %v", bs, 2)
+	}
+	if td, want := tester.GetTd(anchor.Hash(), anchor.NumberU64()), chain.td(anchor.Hash()); td.Cmp(want) != 0 {
+		t.Fatalf("anchor total difficulty mismatch: have %v, want %v", td, want)
+	}
+	for number := uint64(1); number < anchor.NumberU64(); number++ {
+		if rawdb.ReadAnchorHeader(tester.stateDb, number) != nil {
+			t.Fatalf("anchor header #%d not cleaned up", number)
+		}
+	}
+	// Once the anchor is reached, the rest of the chain is synced normally
+	if err := tester.sync("peer", nil, FullSync); err != nil {
+		t.Fatalf("failed to synchronise from anchor: %v", err)
+	}
+	if head := tester.CurrentBlock(); head.Hash() != chain.headBlock().Hash() {
+		t.Fatalf("head block mismatch: have #%d, want #%d", head.Number(), chain.headBlock().Number())
+	}
+}
+
+// Tests that an interrupted header backfill below a sync anchor resumes from the
+// lowest header already downloaded after a restart, instead of starting over.
+func TestAnchoredSyncBackfillResume(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(blockCacheMaxItems - 15)
+	anchor := chain.blockm[chain.chain[2*MaxHeaderFetch+10]]
+	tester.downloader.SetSyncAnchor(anchor.Hash())
+
+	// Sync with a peer only serving the upper part of the headers below the anchor
+	middle := MaxHeaderFetch + 5
+	upper := chain.shorten(chain.len())
+	for i := 1; i < middle; i++ {
+		delete(upper.headerm, upper.chain[i])
+	}
+	tester.newPeer("upper", eth.ETH66, upper)
+
+	if err := tester.sync("upper", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise to anchor: %v", err)
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, tail := rawdb.ReadAnchorBackfill(tester.stateDb); tail == uint64(middle) {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("partial backfill timed out")
+		}
+	}
+	// Restart the downloader with a peer only serving the headers still missing,
+	// which is enough to finish if the backfill resumes where it stopped
+	tester.downloader.Terminate()
+	tester.downloader = New(0, tester.stateDb, trie.NewSyncBloom(1, tester.stateDb), new(event.TypeMux), tester, nil, tester.dropPeer)
+
+	lower := chain.shorten(chain.len())
+	for i := middle; i < chain.len(); i++ {
+		delete(lower.headerm, lower.chain[i])
+	}
+	tester.newPeer("lower", eth.ETH66, lower)
+	waitBackfill(t, tester)
+
+	if td, want := tester.GetTd(anchor.Hash(), anchor.NumberU64()), chain.td(anchor.Hash()); td.Cmp(want) != 0 {
+		t.Fatalf("anchor total difficulty mismatch: have %v, want %v", td, want)
+	}
+	if hs := len(tester.ownHeaders) + len(tester.ancientHeaders) - 1; hs != int(anchor.NumberU64())+1 {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, anchor.NumberU64()+1)
+	}
+}
+
+// waitBackfill waits until the header backfill below the sync anchor completes.
+func waitBackfill(t *testing.T, tester *downloadTester) {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		if hash, _ := rawdb.ReadAnchorBackfill(tester.stateDb); hash == (common.Hash{}) {
+			return
+		}
+	}
+	t.Fatalf("anchor backfill timed out")
+}
+
+// Tests that anchored sync rejects peers which don't serve the anchor block.
+func TestAnchoredSyncUnknownAnchor(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	tester.downloader.SetSyncAnchor(common.HexToHash("0xdeadbeef"))
+	tester.newPeer("peer", eth.ETH66, testChainBase.shorten(MaxHeaderFetch))
+
+	if err := tester.sync("peer", nil, FastSync); !errors.Is(err, errBadPeer) {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, errBadPeer)
+	}
+	assertOwnChain(t, tester, 1)
+}
@@ -155,6 +155,21 @@ func (p *peerConnection) FetchHeaders(from uint64, count int) error {
 	return nil
 }
 
+// FetchAnchorHeaders sends a header retrieval request to the remote peer for the
+// backfill below a sync anchor (absolute downwards without gaps).
+func (p *peerConnection) FetchAnchorHeaders(from uint64, count int) error {
+	// Short circuit if the peer is already fetching
+	if !atomic.CompareAndSwapInt32(&p.headerIdle, 0, 1) {
+		return errAlreadyFetching
+	}
+	p.headerStarted = time.Now()
+
+	// Issue the header retrieval request (absolute downwards without gaps)
+	go p.peer.RequestHeadersByNumber(from, count, 0, true)
+
+	return nil
+}
+
 // FetchBodies sends a block body retrieval request to the remote peer.
 func (p *peerConnection) FetchBodies(request *fetchRequest) error {
 	// Short circuit if the peer is already fetching
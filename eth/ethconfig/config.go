@@ -27,11 +27,13 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/bft"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/miner"
@@ -83,6 +85,7 @@ var Defaults = Config{
 		Recommit: 3 * time.Second,
 	},
 	TxPool:      core.DefaultTxPoolConfig,
+	SnapServe:   snap.DefaultServeConfig,
 	RPCGasCap:   25000000,
 	GPO:         FullNodeGPO,
 	RPCTxFeeCap: 1, // 1 ether
@@ -121,6 +124,15 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 
+	// SyncAnchor is a trusted block hash to anchor fast and snap sync on. The
+	// headers below it are linked by hash instead of being verified.
+	SyncAnchor common.Hash `toml:",omitempty"`
+
+	// CliqueConfirmations is the number of blocks built on top of a clique block
+	// after which it's reported as finalized. Blocks are reported as safe after
+	// half as many confirmations. Zero disables the tracking.
+	CliqueConfirmations uint64 `toml:",omitempty"`
+
 	// This can be set to list of enrtree:// URLs which will be queried for
 	// for nodes to connect to.
 	EthDiscoveryURLs  []string
@@ -171,6 +183,9 @@ type Config struct {
 	// Transaction pool options
 	TxPool core.TxPoolConfig
 
+	// Snap serving limits
+	SnapServe snap.ServeConfig
+
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
@@ -193,6 +208,14 @@ type Config struct {
 	// send-transction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// RPCLogBlockRange is the maximum number of blocks a single log query may
+	// span (0 = no cap).
+	RPCLogBlockRange uint64
+
+	// RPCLogResultCap is the maximum number of logs a single log query may
+	// return (0 = no cap). Paginated queries are capped to this page size.
+	RPCLogResultCap uint64
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
@@ -209,6 +232,10 @@ func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, co
 	if chainConfig.Clique != nil {
 		return clique.New(chainConfig.Clique, db)
 	}
+	// If byzantine fault tolerant consensus is requested, set it up
+	if chainConfig.BFT != nil {
+		return bft.New(chainConfig.BFT, db)
+	}
 	// Otherwise assume proof-of-work
 	switch config.PowMode {
 	case ethash.ModeFake:
@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -278,6 +279,100 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	return rpcSub, nil
 }
 
+// ConfirmedLogs creates a subscription that fires for all new logs that match the
+// given filter criteria once their block has been buried under the requested
+// number of confirmations. If a reorg deeper than the confirmation depth drops
+// an already delivered block, its logs are delivered again with removed set.
+func (api *PublicFilterAPI) ConfirmedLogs(ctx context.Context, crit FilterCriteria, confirmations hexutil.Uint64) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if crit.BlockHash != nil {
+		return nil, errors.New("block hash filters are not supported by subscriptions")
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		headers := make(chan *types.Header)
+		headersSub := api.events.SubscribeNewHeads(headers)
+		tracker := newConfirmationTracker(api.backend, uint64(confirmations))
+
+		for {
+			select {
+			case h := <-headers:
+				err := tracker.update(context.Background(), h, func(header *types.Header, removed bool) {
+					logs := api.events.lightFilterLogs(header, crit.Addresses, crit.Topics, removed)
+					for _, log := range filterLogs(logs, crit.FromBlock, crit.ToBlock, nil, nil) {
+						notifier.Notify(rpcSub.ID, log)
+					}
+				})
+				if err != nil {
+					log.Warn("Failed to track confirmed logs", "err", err)
+				}
+			case <-rpcSub.Err(): // client send an unsubscribe request
+				headersSub.Unsubscribe()
+				return
+			case <-notifier.Closed(): // connection dropped
+				headersSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Receipts creates a subscription that fires for the receipts of all transactions
+// included in new canonical blocks, optionally only once their block has been
+// buried under the requested number of confirmations. If a reorg drops an already
+// delivered block, its receipts are delivered again with removed set. Receipts
+// are sent in their types.Receipt encoding, see receiptNotification.
+func (api *PublicFilterAPI) Receipts(ctx context.Context, confirmations *hexutil.Uint64) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	var depth uint64
+	if confirmations != nil {
+		depth = uint64(*confirmations)
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		headers := make(chan *types.Header)
+		headersSub := api.events.SubscribeNewHeads(headers)
+		tracker := newConfirmationTracker(api.backend, depth)
+
+		for {
+			select {
+			case h := <-headers:
+				err := tracker.update(context.Background(), h, func(header *types.Header, removed bool) {
+					receipts, err := api.backend.GetReceipts(context.Background(), header.Hash())
+					if err != nil {
+						log.Warn("Failed to retrieve receipts", "number", header.Number, "hash", header.Hash(), "err", err)
+						return
+					}
+					for _, receipt := range receipts {
+						notifier.Notify(rpcSub.ID, &receiptNotification{receipt: receipt, removed: removed})
+					}
+				})
+				if err != nil {
+					log.Warn("Failed to track confirmed receipts", "err", err)
+				}
+			case <-rpcSub.Err(): // client send an unsubscribe request
+				headersSub.Unsubscribe()
+				return
+			case <-notifier.Closed(): // connection dropped
+				headersSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 // Same as ethereum.FilterQuery but with UnmarshalJSON() method.
 type FilterCriteria ethereum.FilterQuery
@@ -331,31 +426,26 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 //
 // https://eth.wiki/json-rpc/API#eth_getlogs
 func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
-	var filter *Filter
-	if crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *crit.BlockHash, crit.Addresses, crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if crit.FromBlock != nil {
-			begin = crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if crit.ToBlock != nil {
-			end = crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
-	}
 	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	logs, err := criteriaFilter(api.backend, crit).Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return returnLogs(logs), err
 }
 
+// GetLogsPage returns a bounded page of logs matching the given argument that
+// are stored within the state, along with a cursor to request the next page
+// with. Passing a nil cursor starts the query from its beginning, a nil size
+// uses the node's result cap (or a default if uncapped).
+func (api *PublicFilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, cursor *LogCursor, size *hexutil.Uint64) (*LogPage, error) {
+	var limit uint64
+	if size != nil {
+		limit = uint64(*size)
+	}
+	return criteriaFilter(api.backend, crit).Page(ctx, cursor, limit)
+}
+
 // UninstallFilter removes the filter with the given filter id.
 //
 // https://eth.wiki/json-rpc/API#eth_uninstallfilter
@@ -386,25 +476,8 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		return nil, fmt.Errorf("filter not found")
 	}
 
-	var filter *Filter
-	if f.crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *f.crit.BlockHash, f.crit.Addresses, f.crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if f.crit.FromBlock != nil {
-			begin = f.crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if f.crit.ToBlock != nil {
-			end = f.crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
-	}
 	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	logs, err := criteriaFilter(api.backend, f.crit).Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -445,6 +518,26 @@ func (api *PublicFilterAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
 	return []interface{}{}, fmt.Errorf("filter not found")
 }
 
+// criteriaFilter converts the user supplied filter criteria into either a
+// single-shot block filter or a range filter.
+func criteriaFilter(backend Backend, crit FilterCriteria) *Filter {
+	if crit.BlockHash != nil {
+		// Block filter requested, construct a single-shot filter
+		return NewBlockFilter(backend, *crit.BlockHash, crit.Addresses, crit.Topics)
+	}
+	// Convert the RPC block numbers into internal representations
+	begin := rpc.LatestBlockNumber.Int64()
+	if crit.FromBlock != nil {
+		begin = crit.FromBlock.Int64()
+	}
+	end := rpc.LatestBlockNumber.Int64()
+	if crit.ToBlock != nil {
+		end = crit.ToBlock.Int64()
+	}
+	// Construct the range filter
+	return NewRangeFilter(backend, begin, end, crit.Addresses, crit.Topics)
+}
+
 // returnHashes is a helper that will return an empty hash array case the given hash array is nil,
 // otherwise the given hashes array is returned.
 func returnHashes(hashes []common.Hash) []common.Hash {
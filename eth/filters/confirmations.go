@@ -0,0 +1,120 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxConfirmationHistory is the number of confirmed blocks a confirmation
+// tracker remembers. Reorgs deeper than this below the confirmation depth can
+// not be detected and will not result in removal notifications.
+const maxConfirmationHistory = 1024
+
+// confirmationTracker follows the canonical chain at a fixed depth behind the
+// head, reporting blocks as they become confirmed and reporting them again as
+// removed if a reorg deeper than the confirmation depth drops them from the
+// canonical chain.
+type confirmationTracker struct {
+	backend   Backend
+	depth     uint64
+	delivered []*types.Header // Confirmed headers in ascending order
+}
+
+// newConfirmationTracker creates a tracker that considers a block confirmed
+// once depth further blocks have been built on top of it. A depth of zero
+// confirms blocks as soon as they become the chain head.
+func newConfirmationTracker(backend Backend, depth uint64) *confirmationTracker {
+	return &confirmationTracker{
+		backend: backend,
+		depth:   depth,
+	}
+}
+
+// update processes a new chain head, invoking the callback for every confirmed
+// block dropped from the canonical chain (in descending order) and afterwards
+// for every newly confirmed block (in ascending order).
+func (t *confirmationTracker) update(ctx context.Context, head *types.Header, callback func(header *types.Header, removed bool)) error {
+	if head.Number.Uint64() < t.depth {
+		return nil
+	}
+	target := head.Number.Uint64() - t.depth
+
+	// Roll back any previously confirmed blocks no longer canonical. Blocks above
+	// the target that are still canonical (e.g. the head is reported while a reorg
+	// is being imported) stay confirmed, they were buried deep enough before.
+	for len(t.delivered) > 0 {
+		last := t.delivered[len(t.delivered)-1]
+		canon, err := t.backend.HeaderByNumber(ctx, rpc.BlockNumber(last.Number.Uint64()))
+		if err != nil {
+			return err
+		}
+		if canon != nil && canon.Hash() == last.Hash() {
+			break
+		}
+		t.delivered = t.delivered[:len(t.delivered)-1]
+		callback(last, true)
+	}
+	// Deliver all blocks confirmed since the last update. If nothing has been
+	// delivered yet, start with the current target instead of backfilling.
+	next := target
+	if len(t.delivered) > 0 {
+		next = t.delivered[len(t.delivered)-1].Number.Uint64() + 1
+	}
+	for ; next <= target; next++ {
+		header, err := t.backend.HeaderByNumber(ctx, rpc.BlockNumber(next))
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			break
+		}
+		t.delivered = append(t.delivered, header)
+		callback(header, false)
+	}
+	if overflow := len(t.delivered) - maxConfirmationHistory; overflow > 0 {
+		t.delivered = append(t.delivered[:0], t.delivered[overflow:]...)
+	}
+	return nil
+}
+
+// receiptNotification is the payload delivered to receipt subscribers, which
+// is the JSON encoding of types.Receipt with an additional flag signalling
+// whether the receipt was removed by a chain reorg. Unlike the result of
+// eth_getTransactionReceipt, it carries no sender, recipient or gas price.
+type receiptNotification struct {
+	receipt *types.Receipt
+	removed bool
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n *receiptNotification) MarshalJSON() ([]byte, error) {
+	blob, err := json.Marshal(n.receipt)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(blob, &fields); err != nil {
+		return nil, err
+	}
+	fields["removed"], _ = json.Marshal(n.removed)
+	return json.Marshal(fields)
+}
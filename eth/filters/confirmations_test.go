@@ -0,0 +1,240 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Tests that the confirmation tracker delivers blocks once they are deep enough
+// and redelivers them as removed on reorgs deeper than the confirmation depth.
+func TestConfirmationTracker(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		genesis = core.GenesisBlockForTesting(db, common.Address{}, common.Big1)
+	)
+	// Create a canonical chain and a fork branching off at block 5
+	chain, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
+	fork, _ := core.GenerateChain(params.TestChainConfig, chain[4], ethash.NewFaker(), db, 7, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+	})
+	setCanon := func(blocks []*types.Block) {
+		for _, block := range blocks {
+			rawdb.WriteBlock(db, block)
+			rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+			rawdb.WriteHeadBlockHash(db, block.Hash())
+		}
+	}
+	var events []string
+	collect := func(header *types.Header, removed bool) {
+		events = append(events, fmt.Sprintf("%d:%x:%v", header.Number, header.Hash().Bytes()[:4], removed))
+	}
+	event := func(block *types.Block, removed bool) string {
+		return fmt.Sprintf("%d:%x:%v", block.Number(), block.Hash().Bytes()[:4], removed)
+	}
+	tracker := newConfirmationTracker(backend, 2)
+
+	// Import the canonical chain one by one, the first delivery (genesis) should
+	// happen once the head has enough confirmations on top of it.
+	setCanon(chain[:1])
+	if err := tracker.update(context.Background(), chain[0].Header(), collect); err != nil {
+		t.Fatalf("failed to update tracker: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("unconfirmed block delivered: %v", events)
+	}
+	for i := 1; i < len(chain); i++ {
+		setCanon(chain[i : i+1])
+		if err := tracker.update(context.Background(), chain[i].Header(), collect); err != nil {
+			t.Fatalf("failed to update tracker: %v", err)
+		}
+	}
+	want := []string{event(genesis, false)}
+	for i := 0; i < 8; i++ {
+		want = append(want, event(chain[i], false))
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("confirmed blocks mismatch:\nhave %v\nwant %v", events, want)
+	}
+	// Reorg to the fork, dropping 3 confirmed blocks and confirming 5 new ones
+	events, want = nil, nil
+	setCanon(fork)
+	if err := tracker.update(context.Background(), fork[len(fork)-1].Header(), collect); err != nil {
+		t.Fatalf("failed to update tracker: %v", err)
+	}
+	for i := 7; i >= 5; i-- {
+		want = append(want, event(chain[i], true))
+	}
+	for i := 0; i < 5; i++ {
+		want = append(want, event(fork[i], false))
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("reorged blocks mismatch:\nhave %v\nwant %v", events, want)
+	}
+}
+
+// Tests that receipt notifications carry the removed flag next to the usual
+// receipt fields.
+func TestReceiptNotificationJSON(t *testing.T) {
+	receipt := types.NewReceipt(nil, false, 21000)
+	receipt.TxHash = common.Hash{0x01}
+
+	blob, err := json.Marshal(&receiptNotification{receipt: receipt, removed: true})
+	if err != nil {
+		t.Fatalf("failed to marshal notification: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(blob, &fields); err != nil {
+		t.Fatalf("failed to unmarshal notification: %v", err)
+	}
+	if fields["removed"] != true {
+		t.Errorf("removed flag mismatch: have %v, want true", fields["removed"])
+	}
+	if fields["transactionHash"] != receipt.TxHash.Hex() {
+		t.Errorf("transaction hash mismatch: have %v, want %v", fields["transactionHash"], receipt.TxHash.Hex())
+	}
+}
+
+// Tests the confirmed log and receipt subscriptions end to end over RPC, checking
+// that a reorg deeper than the confirmation depth delivers the dropped logs and
+// receipts as removed before the ones of the new chain.
+func TestConfirmedSubscriptions(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		addr    = common.Address{0xaa}
+		genesis = core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	)
+	// Create a canonical chain and a fork branching off at block 3, each block
+	// holding a single transaction emitting a log
+	generate := func(parent *types.Block, n int, fork byte) ([]*types.Block, []types.Receipts) {
+		return core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+			gen.SetCoinbase(common.Address{fork})
+
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{{fork, byte(i)}}}}
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.Address{fork}, big.NewInt(int64(i)), 1, big.NewInt(1), nil))
+		})
+	}
+	chain, chainReceipts := generate(genesis, 6, 0x00)
+	fork, forkReceipts := generate(chain[2], 5, 0x01)
+
+	import_ := func(blocks []*types.Block, receipts []types.Receipts) {
+		for i, block := range blocks {
+			rawdb.WriteBlock(db, block)
+			rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+			rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+			rawdb.WriteHeadBlockHash(db, block.Hash())
+			backend.chainFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash()})
+		}
+	}
+	// Subscribe over RPC, waiting for 2 confirmations
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatalf("failed to register filter API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	logCh := make(chan types.Log)
+	logSub, err := client.EthSubscribe(context.Background(), logCh, "confirmedLogs", map[string]interface{}{"address": addr}, hexutil.Uint64(2))
+	if err != nil {
+		t.Fatalf("failed to subscribe to confirmed logs: %v", err)
+	}
+	defer logSub.Unsubscribe()
+
+	type receiptResult struct {
+		TxHash  common.Hash `json:"transactionHash"`
+		Removed bool        `json:"removed"`
+	}
+	receiptCh := make(chan receiptResult)
+	receiptSub, err := client.EthSubscribe(context.Background(), receiptCh, "receipts", hexutil.Uint64(2))
+	if err != nil {
+		t.Fatalf("failed to subscribe to receipts: %v", err)
+	}
+	defer receiptSub.Unsubscribe()
+
+	time.Sleep(1 * time.Second) // Ensure the subscriptions follow the chain head
+
+	var (
+		wantLogs     []string
+		wantReceipts []string
+	)
+	expect := func(block *types.Block, removed bool) {
+		wantLogs = append(wantLogs, fmt.Sprintf("%d:%x:%v", block.Number(), block.Hash().Bytes()[:4], removed))
+		wantReceipts = append(wantReceipts, fmt.Sprintf("%x:%v", block.Transactions()[0].Hash().Bytes()[:4], removed))
+	}
+	collect := func() {
+		var haveLogs, haveReceipts []string
+
+		timeout := time.After(5 * time.Second)
+		for len(haveLogs) < len(wantLogs) || len(haveReceipts) < len(wantReceipts) {
+			select {
+			case log := <-logCh:
+				haveLogs = append(haveLogs, fmt.Sprintf("%d:%x:%v", log.BlockNumber, log.BlockHash.Bytes()[:4], log.Removed))
+			case receipt := <-receiptCh:
+				haveReceipts = append(haveReceipts, fmt.Sprintf("%x:%v", receipt.TxHash.Bytes()[:4], receipt.Removed))
+			case err := <-logSub.Err():
+				t.Fatalf("log subscription failed: %v", err)
+			case err := <-receiptSub.Err():
+				t.Fatalf("receipt subscription failed: %v", err)
+			case <-timeout:
+				t.Fatalf("notification timeout: have %v logs and %v receipts, want %v and %v", haveLogs, haveReceipts, wantLogs, wantReceipts)
+			}
+		}
+		if !reflect.DeepEqual(haveLogs, wantLogs) {
+			t.Errorf("log notifications mismatch:\nhave %v\nwant %v", haveLogs, wantLogs)
+		}
+		if !reflect.DeepEqual(haveReceipts, wantReceipts) {
+			t.Errorf("receipt notifications mismatch:\nhave %v\nwant %v", haveReceipts, wantReceipts)
+		}
+		wantLogs, wantReceipts = nil, nil
+	}
+	// Import the canonical chain, the last two blocks are not yet confirmed
+	import_(chain, chainReceipts)
+	for i := 0; i < 4; i++ {
+		expect(chain[i], false)
+	}
+	collect()
+
+	// Reorg to the fork, dropping a confirmed block and confirming 3 new ones
+	import_(fork, forkReceipts)
+	expect(chain[3], true)
+	for i := 0; i < 3; i++ {
+		expect(fork[i], false)
+	}
+	collect()
+}
@@ -18,10 +18,13 @@ package filters
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -45,6 +48,55 @@ type Backend interface {
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+
+	RPCLogBlockRange() uint64 // maximum number of blocks a log query may span: DoS protection
+	RPCLogResultCap() uint64  // maximum number of logs a log query may return: DoS protection
+}
+
+// defaultLogPageSize is the number of logs returned by a paginated query if
+// neither the caller nor the node operator requested a specific page size.
+const defaultLogPageSize = 1000
+
+// LogCursor is a continuation token pointing at the first log of the next
+// page of a paginated log query.
+type LogCursor struct {
+	Block uint64 // Number of the block containing the next log
+	Index uint   // Index of the next log within its block
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c LogCursor) MarshalText() ([]byte, error) {
+	var blob [16]byte
+	binary.BigEndian.PutUint64(blob[:8], c.Block)
+	binary.BigEndian.PutUint64(blob[8:], uint64(c.Index))
+	return hexutil.Bytes(blob[:]).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *LogCursor) UnmarshalText(input []byte) error {
+	var blob hexutil.Bytes
+	if err := blob.UnmarshalText(input); err != nil {
+		return err
+	}
+	if len(blob) != 16 {
+		return fmt.Errorf("invalid log cursor length %d, want 16", len(blob))
+	}
+	c.Block = binary.BigEndian.Uint64(blob[:8])
+	c.Index = uint(binary.BigEndian.Uint64(blob[8:]))
+	return nil
+}
+
+// String implements fmt.Stringer, returning the textual form of the cursor.
+func (c LogCursor) String() string {
+	text, _ := c.MarshalText()
+	return string(text)
+}
+
+// LogPage is a bounded chunk of the results of a log query, along with the
+// cursor to resume the query from. Next is nil if the query is exhausted.
+type LogPage struct {
+	Logs []*types.Log `json:"logs"`
+	Next *LogCursor   `json:"next"`
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -57,6 +109,10 @@ type Filter struct {
 
 	block      common.Hash // Block hash if filtering a single block
 	begin, end int64       // Range interval if filtering multiple blocks
+	after      *LogCursor  // Logs preceding this position are skipped
+
+	maxRange   uint64 // Maximum number of blocks a range query may span (0 = unlimited)
+	maxResults uint64 // Maximum number of logs a query may return (0 = unlimited)
 
 	matcher *bloombits.Matcher
 }
@@ -107,41 +163,187 @@ func NewBlockFilter(backend Backend, block common.Hash, addresses []common.Addre
 // or based on range queries. The search criteria needs to be explicitly set.
 func newFilter(backend Backend, addresses []common.Address, topics [][]common.Hash) *Filter {
 	return &Filter{
-		backend:   backend,
-		addresses: addresses,
-		topics:    topics,
-		db:        backend.ChainDb(),
+		backend:    backend,
+		addresses:  addresses,
+		topics:     topics,
+		db:         backend.ChainDb(),
+		maxRange:   backend.RPCLogBlockRange(),
+		maxResults: backend.RPCLogResultCap(),
 	}
 }
 
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
+//
+// If the filter exceeds the block range or result count caps configured by the
+// backend, an error is returned instead of a partial result.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	// If we're doing singleton block filtering, execute and return
 	if f.block != (common.Hash{}) {
-		header, err := f.backend.HeaderByHash(ctx, f.block)
+		header, err := f.blockHeader(ctx)
 		if err != nil {
 			return nil, err
 		}
-		if header == nil {
-			return nil, errors.New("unknown block")
+		logs, err := f.blockLogs(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		if f.maxResults > 0 && uint64(len(logs)) > f.maxResults {
+			return nil, fmt.Errorf("query returned more than %d results", f.maxResults)
+		}
+		return logs, nil
+	}
+	// Figure out the limits of the filter range
+	end, ok, err := f.resolveRange(ctx)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if f.maxRange > 0 && int64(end) >= f.begin && end-uint64(f.begin)+1 > f.maxRange {
+		return nil, fmt.Errorf("block range %d exceeds limit of %d", end-uint64(f.begin)+1, f.maxRange)
+	}
+	var limit uint64
+	if f.maxResults > 0 {
+		limit = f.maxResults + 1
+	}
+	logs, err := f.rangeLogs(ctx, end, limit)
+	if err != nil {
+		return logs, err
+	}
+	if f.maxResults > 0 && uint64(len(logs)) > f.maxResults {
+		return nil, fmt.Errorf("query returned more than %d results", f.maxResults)
+	}
+	return logs, nil
+}
+
+// Page searches the blockchain for matching log entries similarly to Logs, but
+// instead of failing on large result sets, it returns at most size logs along
+// with a cursor to continue the query from. A nil cursor starts the query from
+// the beginning, a zero size uses the backend's result cap.
+//
+// For range filters, a single call never scans more blocks than the backend's
+// block range cap permits; if the cap is hit before the page is full, the page
+// is returned early with a cursor pointing at the first unscanned block.
+func (f *Filter) Page(ctx context.Context, cursor *LogCursor, size uint64) (*LogPage, error) {
+	if size == 0 || (f.maxResults > 0 && size > f.maxResults) {
+		size = f.maxResults
+	}
+	if size == 0 {
+		size = defaultLogPageSize
+	}
+	f.after = cursor
+
+	// If we're doing singleton block filtering, execute and paginate
+	if f.block != (common.Hash{}) {
+		header, err := f.blockHeader(ctx)
+		if err != nil {
+			return nil, err
 		}
-		return f.blockLogs(ctx, header)
+		if cursor != nil && cursor.Block != header.Number.Uint64() {
+			return nil, errors.New("log cursor outside of queried block")
+		}
+		logs, err := f.blockLogs(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		return paginate(logs, size, nil), nil
 	}
 	// Figure out the limits of the filter range
+	end, ok, err := f.resolveRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &LogPage{Logs: []*types.Log{}}, nil
+	}
+	if cursor != nil {
+		if cursor.Block < uint64(f.begin) || cursor.Block > end {
+			return nil, errors.New("log cursor outside of queried range")
+		}
+		f.begin = int64(cursor.Block)
+	}
+	// Limit the scanned window to the configured block range cap
+	var next *LogCursor
+	if f.maxRange > 0 && int64(end) >= f.begin && end-uint64(f.begin)+1 > f.maxRange {
+		end = uint64(f.begin) + f.maxRange - 1
+		next = &LogCursor{Block: end + 1}
+	}
+	logs, err := f.rangeLogs(ctx, end, size+1)
+	if err != nil {
+		return nil, err
+	}
+	return paginate(logs, size, next), nil
+}
+
+// paginate truncates the logs to at most size entries, returning a cursor to
+// the first dropped log, or the fallback cursor if nothing was dropped.
+func paginate(logs []*types.Log, size uint64, fallback *LogCursor) *LogPage {
+	if uint64(len(logs)) > size {
+		next := &LogCursor{Block: logs[size].BlockNumber, Index: logs[size].Index}
+		return &LogPage{Logs: logs[:size], Next: next}
+	}
+	if logs == nil {
+		logs = []*types.Log{}
+	}
+	return &LogPage{Logs: logs, Next: fallback}
+}
+
+// blockHeader retrieves the header of the block a singleton filter targets.
+func (f *Filter) blockHeader(ctx context.Context) (*types.Header, error) {
+	header, err := f.backend.HeaderByHash(ctx, f.block)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errors.New("unknown block")
+	}
+	return header, nil
+}
+
+// resolveRange converts the symbolic limits of a range filter ("latest",
+// "finalized" and "safe") into concrete block numbers and returns the concrete
+// end of the range. False is returned if the chain head is not available.
+func (f *Filter) resolveRange(ctx context.Context) (uint64, bool, error) {
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	if header == nil {
-		return nil, nil
+		return 0, false, nil
 	}
 	head := header.Number.Uint64()
 
-	if f.begin == -1 {
-		f.begin = int64(head)
+	begin, err := f.resolveNumber(ctx, f.begin, head)
+	if err != nil {
+		return 0, false, err
 	}
-	end := uint64(f.end)
-	if f.end == -1 {
-		end = head
+	end, err := f.resolveNumber(ctx, f.end, head)
+	if err != nil {
+		return 0, false, err
 	}
+	f.begin = begin
+	return uint64(end), true, nil
+}
+
+// resolveNumber converts a symbolic block number into a concrete one, looking up
+// the finalized and safe blocks through the backend.
+func (f *Filter) resolveNumber(ctx context.Context, number int64, head uint64) (int64, error) {
+	switch rpc.BlockNumber(number) {
+	case rpc.LatestBlockNumber:
+		return int64(head), nil
+	case rpc.FinalizedBlockNumber, rpc.SafeBlockNumber:
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return 0, err
+		}
+		if header == nil {
+			return 0, errors.New("unknown block")
+		}
+		return header.Number.Int64(), nil
+	}
+	return number, nil
+}
+
+// rangeLogs gathers the logs of a range filter up to the given end block. If
+// limit is non-zero, the search stops as soon as at least limit logs have been
+// gathered, always finishing the block it is currently processing.
+func (f *Filter) rangeLogs(ctx context.Context, end uint64, limit uint64) ([]*types.Log, error) {
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs []*types.Log
@@ -150,22 +352,34 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			logs, err = f.indexedLogs(ctx, end, limit)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			logs, err = f.indexedLogs(ctx, indexed-1, limit)
 		}
 		if err != nil {
 			return logs, err
 		}
+		if limitReached(logs, limit) {
+			return logs, nil
+		}
 	}
-	rest, err := f.unindexedLogs(ctx, end)
+	if limit > 0 {
+		limit -= uint64(len(logs))
+	}
+	rest, err := f.unindexedLogs(ctx, end, limit)
 	logs = append(logs, rest...)
 	return logs, err
 }
 
+// limitReached reports whether at least limit logs have been gathered, zero
+// meaning unlimited.
+func limitReached(logs []*types.Log, limit uint64) bool {
+	return limit > 0 && uint64(len(logs)) >= limit
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
-func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+func (f *Filter) indexedLogs(ctx context.Context, end uint64, limit uint64) ([]*types.Log, error) {
 	// Create a matcher session and request servicing from the backend
 	matches := make(chan uint64, 64)
 
@@ -203,6 +417,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 				return logs, err
 			}
 			logs = append(logs, found...)
+			if limitReached(logs, limit) {
+				return logs, nil
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -212,7 +429,7 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 
 // unindexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, limit uint64) ([]*types.Log, error) {
 	var logs []*types.Log
 
 	for ; f.begin <= int64(end); f.begin++ {
@@ -225,6 +442,10 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 			return logs, err
 		}
 		logs = append(logs, found...)
+		if limitReached(logs, limit) {
+			f.begin++
+			return logs, nil
+		}
 	}
 	return logs, nil
 }
@@ -267,11 +488,24 @@ func (f *Filter) checkMatches(ctx context.Context, header *types.Header) (logs [
 			}
 			logs = filterLogs(unfiltered, nil, nil, f.addresses, f.topics)
 		}
-		return logs, nil
+		return f.skipPreceding(logs), nil
 	}
 	return nil, nil
 }
 
+// skipPreceding drops the logs positioned before the filter's cursor, if any.
+func (f *Filter) skipPreceding(logs []*types.Log) []*types.Log {
+	if f.after == nil {
+		return logs
+	}
+	for i, log := range logs {
+		if log.BlockNumber > f.after.Block || (log.BlockNumber == f.after.Block && log.Index >= f.after.Index) {
+			return logs[i:]
+		}
+	}
+	return nil
+}
+
 func includes(addresses []common.Address, a common.Address) bool {
 	for _, addr := range addresses {
 		if addr == a {
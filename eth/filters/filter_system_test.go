@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -52,6 +53,8 @@ type testBackend struct {
 	rmLogsFeed      event.Feed
 	pendingLogsFeed event.Feed
 	chainFeed       event.Feed
+	logBlockRange   uint64
+	logResultCap    uint64
 }
 
 func (b *testBackend) ChainDb() ethdb.Database {
@@ -63,14 +66,23 @@ func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 		hash common.Hash
 		num  uint64
 	)
-	if blockNr == rpc.LatestBlockNumber {
+	switch blockNr {
+	case rpc.LatestBlockNumber, rpc.FinalizedBlockNumber, rpc.SafeBlockNumber:
 		hash = rawdb.ReadHeadBlockHash(b.db)
+		if blockNr == rpc.FinalizedBlockNumber {
+			hash = rawdb.ReadFinalizedBlockHash(b.db)
+		} else if blockNr == rpc.SafeBlockNumber {
+			hash = rawdb.ReadSafeBlockHash(b.db)
+		}
 		number := rawdb.ReadHeaderNumber(b.db, hash)
 		if number == nil {
+			if blockNr != rpc.LatestBlockNumber {
+				return nil, errors.New("block not found")
+			}
 			return nil, nil
 		}
 		num = *number
-	} else {
+	default:
 		num = uint64(blockNr)
 		hash = rawdb.ReadCanonicalHash(b.db, num)
 	}
@@ -126,6 +138,14 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) RPCLogBlockRange() uint64 {
+	return b.logBlockRange
+}
+
+func (b *testBackend) RPCLogResultCap() uint64 {
+	return b.logResultCap
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
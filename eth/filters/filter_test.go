@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 func makeReceipt(addr common.Address) *types.Receipt {
@@ -222,4 +223,118 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// Symbolic finality limits must be resolved through the backend
+	filter = NewRangeFilter(backend, 0, rpc.FinalizedBlockNumber.Int64(), []common.Address{addr}, [][]common.Hash{{hash3, hash4}})
+	if _, err := filter.Logs(context.Background()); err == nil {
+		t.Error("expected error for unknown finalized block")
+	}
+	rawdb.WriteFinalizedBlockHash(db, chain[998].Hash())
+	rawdb.WriteSafeBlockHash(db, chain[999].Hash())
+
+	filter = NewRangeFilter(backend, 0, rpc.FinalizedBlockNumber.Int64(), []common.Address{addr}, [][]common.Hash{{hash3, hash4}})
+
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 1 || logs[0].Topics[0] != hash3 {
+		t.Error("expected 1 log up to the finalized block, got", len(logs))
+	}
+	filter = NewRangeFilter(backend, rpc.FinalizedBlockNumber.Int64(), rpc.SafeBlockNumber.Int64(), []common.Address{addr}, nil)
+
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 {
+		t.Error("expected 2 log between the finalized and safe blocks, got", len(logs))
+	}
+}
+
+func TestFilterLimitsAndPages(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+	)
+	// Create a chain with three logs in every 10th block
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 100, func(i int, gen *core.BlockGen) {
+		if i%10 == 0 {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr}, {Address: addr}, {Address: addr}}
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Without limits, everything is returned in one go
+	logs, err := NewRangeFilter(backend, 0, -1, []common.Address{addr}, nil).Logs(context.Background())
+	if err != nil {
+		t.Fatalf("unlimited query failed: %v", err)
+	}
+	if len(logs) != 30 {
+		t.Fatalf("unlimited query log count mismatch: have %d, want %d", len(logs), 30)
+	}
+	// Exceeding any of the caps should be rejected
+	backend.logBlockRange, backend.logResultCap = 50, 0
+	if _, err := NewRangeFilter(backend, 0, -1, []common.Address{addr}, nil).Logs(context.Background()); err == nil {
+		t.Fatalf("block range cap not enforced")
+	}
+	if _, err := NewRangeFilter(backend, 0, 49, []common.Address{addr}, nil).Logs(context.Background()); err != nil {
+		t.Fatalf("query within block range cap failed: %v", err)
+	}
+	backend.logBlockRange, backend.logResultCap = 0, 20
+	if _, err := NewRangeFilter(backend, 0, -1, []common.Address{addr}, nil).Logs(context.Background()); err == nil {
+		t.Fatalf("result cap not enforced")
+	}
+	if logs, err := NewRangeFilter(backend, 0, 60, []common.Address{addr}, nil).Logs(context.Background()); err != nil || len(logs) != 18 {
+		t.Fatalf("query within result cap mismatch: have %d logs, err %v", len(logs), err)
+	}
+	// Paginate through all the logs with both caps in place, checking that all
+	// logs are returned exactly once and in order
+	backend.logBlockRange, backend.logResultCap = 25, 4
+
+	var (
+		cursor *LogCursor
+		found  []*types.Log
+		pages  int
+	)
+	for {
+		page, err := NewRangeFilter(backend, 0, -1, []common.Address{addr}, nil).Page(context.Background(), cursor, 0)
+		if err != nil {
+			t.Fatalf("page %d: query failed: %v", pages, err)
+		}
+		if len(page.Logs) > 4 {
+			t.Fatalf("page %d: page size exceeded: have %d, want <= %d", pages, len(page.Logs), 4)
+		}
+		found = append(found, page.Logs...)
+		pages++
+
+		if page.Next == nil {
+			break
+		}
+		// Round trip the cursor through its textual form
+		text, _ := page.Next.MarshalText()
+		cursor = new(LogCursor)
+		if err := cursor.UnmarshalText(text); err != nil {
+			t.Fatalf("page %d: failed to decode cursor %s: %v", pages, text, err)
+		}
+		if *cursor != *page.Next {
+			t.Fatalf("page %d: cursor mismatch after round trip: have %v, want %v", pages, cursor, page.Next)
+		}
+	}
+	if len(found) != 30 {
+		t.Fatalf("paginated log count mismatch: have %d, want %d", len(found), 30)
+	}
+	for i, log := range found {
+		if want := uint64(1 + 10*(i/3)); log.BlockNumber != want || log.Index != uint(i%3) {
+			t.Errorf("log %d: position mismatch: have (%d, %d), want (%d, %d)", i, log.BlockNumber, log.Index, want, i%3)
+		}
+	}
+	// Cursors outside of the queried range should be rejected
+	if _, err := NewRangeFilter(backend, 0, 10, []common.Address{addr}, nil).Page(context.Background(), &LogCursor{Block: 11}, 0); err == nil {
+		t.Fatalf("out of range cursor accepted")
+	}
 }
@@ -81,6 +81,7 @@ type handlerConfig struct {
 	TxPool     txPool                    // Transaction pool to propagate from
 	Network    uint64                    // Network identifier to adfvertise
 	Sync       downloader.SyncMode       // Whether to fast or full sync
+	SyncAnchor common.Hash               // Trusted block hash to anchor fast sync on
 	BloomCache uint64                    // Megabytes to alloc for fast sync bloom
 	EventMux   *event.TypeMux            // Legacy event mux, deprecate for `feed`
 	Checkpoint *params.TrustedCheckpoint // Hard coded checkpoint for sync challenges
@@ -184,7 +185,10 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	if atomic.LoadUint32(&h.fastSync) == 1 && atomic.LoadUint32(&h.snapSync) == 0 {
 		h.stateBloom = trie.NewSyncBloom(config.BloomCache, config.Database)
 	}
-	h.downloader = downloader.New(h.checkpointNumber, config.Database, h.stateBloom, h.eventMux, h.chain, nil, h.removePeer)
+	h.downloader = downloader.New(h.checkpointNumber, config.Database, h.stateBloom, h.eventMux, h.chain, nil, h.dropPeer)
+	if config.SyncAnchor != (common.Hash{}) {
+		h.downloader.SetSyncAnchor(config.SyncAnchor)
+	}
 
 	// Construct the fetcher (short sync)
 	validator := func(header *types.Header) error {
@@ -219,7 +223,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return n, err
 	}
-	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer)
+	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.dropPeer)
 
 	fetchTx := func(peer string, hashes []common.Hash) error {
 		p := h.peers.peer(peer)
@@ -388,6 +392,15 @@ func (h *handler) removePeer(id string) {
 	peer.Peer.Disconnect(p2p.DiscUselessPeer)
 }
 
+// dropPeer is invoked by the downloader and fetchers for misbehaving peers. It
+// records the violation in the reputation of the peer before removing it.
+func (h *handler) dropPeer(id string) {
+	if peer := h.peers.peer(id); peer != nil {
+		peer.Report(p2p.ScoreProtocolViolation)
+	}
+	h.removePeer(id)
+}
+
 func (h *handler) Start(maxPeers int) {
 	h.maxPeers = maxPeers
 
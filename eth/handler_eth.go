@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/trie"
 )
@@ -72,15 +73,19 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 		return h.handleBodies(peer, txset, uncleset)
 
 	case *eth.NodeDataPacket:
-		if err := h.downloader.DeliverNodeData(peer.ID(), *packet); err != nil {
+		err := h.downloader.DeliverNodeData(peer.ID(), *packet)
+		if err != nil {
 			log.Debug("Failed to deliver node state data", "err", err)
 		}
+		reportDelivery(peer, err)
 		return nil
 
 	case *eth.ReceiptsPacket:
-		if err := h.downloader.DeliverReceipts(peer.ID(), *packet); err != nil {
+		err := h.downloader.DeliverReceipts(peer.ID(), *packet)
+		if err != nil {
 			log.Debug("Failed to deliver receipts", "err", err)
 		}
+		reportDelivery(peer, err)
 		return nil
 
 	case *eth.NewBlockHashesPacket:
@@ -156,6 +161,7 @@ func (h *ethHandler) handleHeaders(peer *eth.Peer, headers []*types.Header) erro
 		if err != nil {
 			log.Debug("Failed to deliver headers", "err", err)
 		}
+		reportDelivery(peer, err)
 	}
 	return nil
 }
@@ -173,10 +179,21 @@ func (h *ethHandler) handleBodies(peer *eth.Peer, txs [][]*types.Transaction, un
 		if err != nil {
 			log.Debug("Failed to deliver bodies", "err", err)
 		}
+		reportDelivery(peer, err)
 	}
 	return nil
 }
 
+// reportDelivery adjusts the reputation of a peer depending on whether the data
+// it delivered was accepted by the downloader.
+func reportDelivery(peer *eth.Peer, err error) {
+	if err != nil {
+		peer.Report(p2p.ScoreUselessDelivery)
+	} else {
+		peer.Report(p2p.ScoreUsefulDelivery)
+	}
+}
+
 // handleBlockAnnounces is invoked from a peer's message handler when it transmits a
 // batch of block announcements for the local node to process.
 func (h *ethHandler) handleBlockAnnounces(peer *eth.Peer, hashes []common.Hash, numbers []uint64) error {
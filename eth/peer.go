@@ -57,7 +57,8 @@ func (p *ethPeer) info() *ethPeerInfo {
 // snapPeerInfo represents a short summary of the `snap` sub-protocol metadata known
 // about a connected peer.
 type snapPeerInfo struct {
-	Version uint `json:"version"` // Snapshot protocol version negotiated
+	Version uint            `json:"version"` // Snapshot protocol version negotiated
+	Served  snap.ServeStats `json:"served"`  // Serving resources consumed by the peer
 }
 
 // snapPeer is a wrapper around snap.Peer to maintain a few extra metadata.
@@ -69,5 +70,6 @@ type snapPeer struct {
 func (p *snapPeer) info() *snapPeerInfo {
 	return &snapPeerInfo{
 		Version: p.Version(),
+		Served:  p.ServeStats(),
 	}
 }
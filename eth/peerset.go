@@ -247,6 +247,20 @@ func (ps *peerSet) peerWithHighestTD() *eth.Peer {
 	return bestPeer
 }
 
+// peerWithHead retrieves a known `eth` peer which announced the given block as
+// its head, or nil if there's none.
+func (ps *peerSet) peerWithHead(hash common.Hash) *eth.Peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	for _, p := range ps.peers {
+		if head, _ := p.Head(); head == hash {
+			return p.Peer
+		}
+	}
+	return nil
+}
+
 // close disconnects all peers.
 func (ps *peerSet) close() {
 	ps.lock.Lock()
@@ -17,6 +17,7 @@
 package eth
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
@@ -160,6 +161,9 @@ func Handle(backend Backend, peer *Peer) error {
 	for {
 		if err := handleMessage(backend, peer); err != nil {
 			peer.Log().Debug("Message handling failed in `eth`", "err", err)
+			if errors.Is(err, errMsgTooLarge) || errors.Is(err, errDecode) || errors.Is(err, errInvalidMsgCode) {
+				peer.Report(p2p.ScoreProtocolViolation)
+			}
 			return err
 		}
 	}
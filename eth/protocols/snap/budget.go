@@ -0,0 +1,338 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// maxServeQueueTime is the maximum time a request waits for serving budget
+	// before it's answered with an empty response. It is well below the request
+	// timeout of the remote syncer so it can re-request the data elsewhere.
+	maxServeQueueTime = 3 * time.Second
+
+	// minServeBytes is the budget a request waits for before being served, even
+	// if it asks for less data, to avoid serving lots of tiny responses.
+	minServeBytes = 64 * 1024
+
+	// maxServeQueue is the maximum number of requests of a peer waiting to be
+	// served, beyond which they are answered with empty responses straight away.
+	maxServeQueue = 16
+)
+
+var (
+	serveBytesMeter    = metrics.NewRegisteredMeter("snap/serve/bytes", nil)
+	serveReadsMeter    = metrics.NewRegisteredMeter("snap/serve/reads", nil)
+	serveDroppedMeter  = metrics.NewRegisteredMeter("snap/serve/dropped", nil)
+	serveThrottleTimer = metrics.NewRegisteredTimer("snap/serve/throttle", nil)
+)
+
+// ServeConfig limits the resources spent on serving snap requests. Zero limits
+// are disabled.
+type ServeConfig struct {
+	Window     time.Duration // Time window over which the budgets are recharged
+	PeerBytes  uint64        // Bytes served to a single peer per window
+	PeerReads  uint64        // Database reads caused by a single peer per window
+	TotalBytes uint64        // Bytes served to all peers per window
+	TotalReads uint64        // Database reads caused by all peers per window
+}
+
+// DefaultServeConfig contains the default serving limits.
+var DefaultServeConfig = ServeConfig{
+	Window:     10 * time.Second,
+	PeerBytes:  64 * 1024 * 1024,
+	PeerReads:  64 * 1024,
+	TotalBytes: 256 * 1024 * 1024,
+	TotalReads: 256 * 1024,
+}
+
+// bucket is a token bucket holding the budget of a single resource. The budget
+// recharges linearly up to the limit over a window. Spending may overdraw the
+// bucket, which delays further requests until it is recharged.
+type bucket struct {
+	limit   float64 // Capacity of the bucket, zero if unlimited
+	rate    float64 // Tokens recharged per nanosecond
+	tokens  float64
+	updated mclock.AbsTime
+}
+
+func newBucket(limit uint64, window time.Duration, now mclock.AbsTime) bucket {
+	return bucket{
+		limit:   float64(limit),
+		rate:    float64(limit) / float64(window),
+		tokens:  float64(limit),
+		updated: now,
+	}
+}
+
+// available returns the budget left at the given time.
+func (b *bucket) available(now mclock.AbsTime) float64 {
+	if b.limit == 0 {
+		return math.Inf(1)
+	}
+	if now > b.updated {
+		b.tokens = math.Min(b.limit, b.tokens+float64(now-b.updated)*b.rate)
+		b.updated = now
+	}
+	return b.tokens
+}
+
+// waitTime returns the time until the given budget is available. Requests for
+// more than the capacity wait for a full bucket.
+func (b *bucket) waitTime(want float64, now mclock.AbsTime) time.Duration {
+	have := b.available(now)
+	if b.limit != 0 && want > b.limit {
+		want = b.limit
+	}
+	if have >= want {
+		return 0
+	}
+	return time.Duration(math.Ceil((want - have) / b.rate))
+}
+
+// spend takes the cost of a served request from the bucket.
+func (b *bucket) spend(amount float64) {
+	if b.limit != 0 {
+		b.tokens -= amount
+	}
+}
+
+// ServeLimiter enforces the serving limits shared by all peers.
+type ServeLimiter struct {
+	config ServeConfig
+	clock  mclock.Clock
+
+	lock  sync.Mutex
+	bytes bucket // Byte budget of all peers
+	reads bucket // Database read budget of all peers
+}
+
+// NewServeLimiter creates the limiter for the given serving limits.
+func NewServeLimiter(config ServeConfig) *ServeLimiter {
+	return newServeLimiter(config, mclock.System{})
+}
+
+func newServeLimiter(config ServeConfig, clock mclock.Clock) *ServeLimiter {
+	if config.Window <= 0 {
+		if config.Window < 0 {
+			log.Warn("Invalid snap serving window, using default", "provided", config.Window, "updated", DefaultServeConfig.Window)
+		}
+		config.Window = DefaultServeConfig.Window
+	}
+	now := clock.Now()
+	return &ServeLimiter{
+		config: config,
+		clock:  clock,
+		bytes:  newBucket(config.TotalBytes, config.Window, now),
+		reads:  newBucket(config.TotalReads, config.Window, now),
+	}
+}
+
+// newPeerBudget creates the serving budget of a newly connected peer.
+func (l *ServeLimiter) newPeerBudget() *peerBudget {
+	now := l.clock.Now()
+	return &peerBudget{
+		limiter: l,
+		bytes:   newBucket(l.config.PeerBytes, l.config.Window, now),
+		reads:   newBucket(l.config.PeerReads, l.config.Window, now),
+		queue:   make(chan *serveTask, maxServeQueue),
+	}
+}
+
+// ServeStats are the serving resources consumed by a peer.
+type ServeStats struct {
+	Bytes     uint64        `json:"bytes"`     // Bytes served to the peer
+	Reads     uint64        `json:"reads"`     // Database reads caused by the peer
+	Throttled time.Duration `json:"throttled"` // Time requests of the peer were queued
+	Dropped   uint64        `json:"dropped"`   // Requests answered empty for lack of budget
+}
+
+// serveTask is a request of a peer waiting for serving budget.
+type serveTask struct {
+	bytes  uint64                          // Bytes requested to be served
+	reads  uint64                          // Database reads needed for serving
+	queued mclock.AbsTime                  // Time the request was queued at
+	serve  func(bytes, reads uint64) error // Serves the request within the allowances
+	reject func() error                    // Answers the request with an empty response
+}
+
+// peerBudget tracks the serving budget of a single peer. The buckets are guarded
+// by the lock of the limiter, the statistics by the peer's own lock as they are
+// read by other goroutines.
+type peerBudget struct {
+	limiter *ServeLimiter
+	bytes   bucket
+	reads   bucket
+	queue   chan *serveTask // Requests waiting to be served in the background
+
+	lock  sync.Mutex
+	stats ServeStats
+}
+
+// enqueue schedules a request to be served in the background once the budget
+// allows, or rejects it straight away if too many requests are waiting already.
+func (b *peerBudget) enqueue(task *serveTask) error {
+	task.queued = b.limiter.clock.Now()
+	select {
+	case b.queue <- task:
+		return nil
+	default:
+		b.drop()
+		return task.reject()
+	}
+}
+
+// loop serves the queued requests of the peer one after the other, waiting for
+// the budget of each. It runs in its own goroutine, so the peer's message loop
+// keeps processing other messages (e.g. responses to our own requests) while
+// requests are throttled.
+func (b *peerBudget) loop(logger log.Logger, quit chan struct{}) {
+	for {
+		select {
+		case task := <-b.queue:
+			var err error
+			if bytes, reads, ok := b.reserve(task.bytes, task.reads, task.queued, quit); ok {
+				err = task.serve(bytes, reads)
+			} else {
+				select {
+				case <-quit:
+					return
+				default:
+				}
+				err = task.reject()
+			}
+			if err != nil {
+				logger.Debug("Failed to serve snap request", "err", err)
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+// reserve waits until both the peer's and the shared budgets allow serving a
+// request of the given size, and returns the allowances the request has to be
+// served within. If the budget doesn't become available in time counted from
+// the request's arrival, false is returned and the request shouldn't be served.
+// Waiting is also aborted if the quit channel is closed.
+func (b *peerBudget) reserve(bytes, reads uint64, start mclock.AbsTime, quit chan struct{}) (uint64, uint64, bool) {
+	var (
+		l         = b.limiter
+		wantBytes = math.Min(float64(bytes), minServeBytes)
+		wantReads = math.Min(float64(reads), 1)
+	)
+	for {
+		now := l.clock.Now()
+		l.lock.Lock()
+		wait := b.bytes.waitTime(wantBytes, now)
+		if d := b.reads.waitTime(wantReads, now); d > wait {
+			wait = d
+		}
+		if d := l.bytes.waitTime(wantBytes, now); d > wait {
+			wait = d
+		}
+		if d := l.reads.waitTime(wantReads, now); d > wait {
+			wait = d
+		}
+		if wait == 0 {
+			availBytes := math.Min(b.bytes.available(now), l.bytes.available(now))
+			availReads := math.Min(b.reads.available(now), l.reads.available(now))
+			l.lock.Unlock()
+
+			if throttled := now.Sub(start); throttled > 0 {
+				serveThrottleTimer.Update(throttled)
+				b.lock.Lock()
+				b.stats.Throttled += throttled
+				b.lock.Unlock()
+			}
+			return uint64(math.Min(float64(bytes), availBytes)), uint64(math.Min(float64(reads), availReads)), true
+		}
+		l.lock.Unlock()
+
+		if now.Add(wait).Sub(start) > maxServeQueueTime {
+			b.drop()
+			return 0, 0, false
+		}
+		select {
+		case <-l.clock.After(wait):
+		case <-quit:
+			return 0, 0, false
+		}
+	}
+}
+
+// drop accounts a request answered empty for lack of budget.
+func (b *peerBudget) drop() {
+	serveDroppedMeter.Mark(1)
+
+	b.lock.Lock()
+	b.stats.Dropped++
+	b.lock.Unlock()
+}
+
+// spend takes the cost of a served request from the peer's and the shared
+// budgets.
+func (b *peerBudget) spend(bytes, reads uint64) {
+	l := b.limiter
+	l.lock.Lock()
+	b.bytes.spend(float64(bytes))
+	b.reads.spend(float64(reads))
+	l.bytes.spend(float64(bytes))
+	l.reads.spend(float64(reads))
+	l.lock.Unlock()
+
+	serveBytesMeter.Mark(int64(bytes))
+	serveReadsMeter.Mark(int64(reads))
+
+	b.lock.Lock()
+	b.stats.Bytes += bytes
+	b.stats.Reads += reads
+	b.lock.Unlock()
+}
+
+// statistics returns the serving resources consumed by the peer.
+func (b *peerBudget) statistics() ServeStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.stats
+}
+
+// serve answers a request of the peer asking for the given bytes and database
+// reads. Without a serving budget, the request is served straight away within
+// the asked allowances. Otherwise it's queued up to be served in the background
+// once the budget allows, within the allowances the budget grants; or rejected
+// if the budget doesn't allow serving it in time.
+func (p *Peer) serve(bytes, reads uint64, serve func(bytes, reads uint64) error, reject func() error) error {
+	if p.budget == nil {
+		return serve(bytes, reads)
+	}
+	return p.budget.enqueue(&serveTask{bytes: bytes, reads: reads, serve: serve, reject: reject})
+}
+
+// spendServe accounts the resources spent on serving a request from the peer.
+func (p *Peer) spendServe(bytes, reads uint64) {
+	if p.budget != nil {
+		p.budget.spend(bytes, reads)
+	}
+}
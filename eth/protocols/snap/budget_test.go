@@ -0,0 +1,98 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+type reserveResult struct {
+	bytes, reads uint64
+	ok           bool
+}
+
+// reserveAsync runs a reservation in the background, as it might block on the
+// simulated clock.
+func reserveAsync(b *peerBudget, bytes, reads uint64) chan reserveResult {
+	res := make(chan reserveResult, 1)
+	go func() {
+		bytes, reads, ok := b.reserve(bytes, reads, b.limiter.clock.Now(), nil)
+		res <- reserveResult{bytes, reads, ok}
+	}()
+	return res
+}
+
+func TestServeBudget(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		limiter = newServeLimiter(ServeConfig{
+			Window:     10 * time.Second,
+			PeerBytes:  1024 * 1024,
+			PeerReads:  1000,
+			TotalBytes: 1536 * 1024,
+		}, clock)
+		first  = limiter.newPeerBudget()
+		second = limiter.newPeerBudget()
+	)
+	// Requests are served immediately within the budget and capped to it
+	if bytes, reads, ok := first.reserve(2*1024*1024, 2000, clock.Now(), nil); !ok || bytes != 1024*1024 || reads != 1000 {
+		t.Fatalf("wrong allowance: bytes %d, reads %d, ok %v", bytes, reads, ok)
+	}
+	first.spend(1024*1024, 500)
+
+	// Overdrawn peers are queued until their budget recharges
+	res := reserveAsync(first, 512*1024, 100)
+	clock.WaitForTimers(1)
+	clock.Run(time.Second)
+	if r := <-res; !r.ok || r.bytes != 104857 || r.reads != 100 {
+		t.Fatalf("wrong allowance after recharge: %+v", r)
+	}
+	if stats := first.statistics(); stats.Throttled != time.Second || stats.Bytes != 1024*1024 || stats.Reads != 500 {
+		t.Fatalf("wrong peer statistics: %+v", stats)
+	}
+	// The shared budget limits other peers
+	if bytes, _, ok := second.reserve(1024*1024, 10, clock.Now(), nil); !ok || bytes != 681574 {
+		t.Fatalf("wrong allowance from shared budget: %d", bytes)
+	}
+	second.spend(600*1024, 10)
+
+	// Requests are dropped if the budget doesn't recharge in time
+	first.spend(2*1024*1024, 0)
+	if _, _, ok := first.reserve(1024, 1, clock.Now(), nil); ok {
+		t.Fatal("request served with exhausted budget")
+	}
+	if stats := first.statistics(); stats.Dropped != 1 {
+		t.Fatalf("wrong dropped count: %d", stats.Dropped)
+	}
+}
+
+func TestServeBudgetUnlimited(t *testing.T) {
+	var (
+		limiter = newServeLimiter(ServeConfig{}, new(mclock.Simulated))
+		budget  = limiter.newPeerBudget()
+	)
+	for i := 0; i < 10; i++ {
+		bytes, reads, ok := budget.reserve(softResponseLimit, maxTrieNodeLookups, budget.limiter.clock.Now(), nil)
+		if !ok || bytes != softResponseLimit || reads != maxTrieNodeLookups {
+			t.Fatalf("wrong allowance: bytes %d, reads %d, ok %v", bytes, reads, ok)
+		}
+		budget.spend(softResponseLimit, maxTrieNodeLookups)
+	}
+}
@@ -18,6 +18,7 @@ package snap
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"time"
 
@@ -82,8 +83,10 @@ type Backend interface {
 	Handle(peer *Peer, packet Packet) error
 }
 
-// MakeProtocols constructs the P2P protocol definitions for `snap`.
-func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
+// MakeProtocols constructs the P2P protocol definitions for `snap`. Requests of
+// remote peers are served within the limits of the given serving limiter, which
+// may be nil to serve without limits.
+func MakeProtocols(backend Backend, dnsdisc enode.Iterator, limiter *ServeLimiter) []p2p.Protocol {
 	protocols := make([]p2p.Protocol, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		version := version // Closure
@@ -93,7 +96,11 @@ func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
 			Version: version,
 			Length:  protocolLengths[version],
 			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
-				return backend.RunPeer(newPeer(version, p, rw), func(peer *Peer) error {
+				peer := newPeer(version, p, rw)
+				if limiter != nil {
+					peer.budget = limiter.newPeerBudget()
+				}
+				return backend.RunPeer(peer, func(peer *Peer) error {
 					return handle(backend, peer)
 				})
 			},
@@ -113,9 +120,27 @@ func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
 // handle is the callback invoked to manage the life cycle of a `snap` peer.
 // When this function terminates, the peer is disconnected.
 func handle(backend Backend, peer *Peer) error {
+	// Serve the requests throttled by the peer's budget in the background
+	if peer.budget != nil {
+		var (
+			quit = make(chan struct{})
+			done = make(chan struct{})
+		)
+		go func() {
+			peer.budget.loop(peer.Log(), quit)
+			close(done)
+		}()
+		defer func() {
+			close(quit)
+			<-done
+		}()
+	}
 	for {
 		if err := handleMessage(backend, peer); err != nil {
 			peer.Log().Debug("Message handling failed in `snap`", "err", err)
+			if errors.Is(err, errMsgTooLarge) || errors.Is(err, errDecode) || errors.Is(err, errInvalidMsgCode) || errors.Is(err, errBadRequest) {
+				peer.Report(p2p.ScoreProtocolViolation)
+			}
 			return err
 		}
 	}
@@ -158,61 +183,12 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
-		// Retrieve the requested state and bail out if non existent
-		tr, err := trie.New(req.Root, backend.Chain().StateCache().TrieDB())
-		if err != nil {
+		// Serve the request within the peer's serving budget
+		return peer.serve(req.Bytes, req.Bytes/common.HashLength+1, func(bytes, reads uint64) error {
+			req.Bytes = bytes
+			return serveAccountRange(backend, peer, &req, reads)
+		}, func() error {
 			return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{ID: req.ID})
-		}
-		it, err := backend.Chain().Snapshots().AccountIterator(req.Root, req.Origin)
-		if err != nil {
-			return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{ID: req.ID})
-		}
-		// Iterate over the requested range and pile accounts up
-		var (
-			accounts []*AccountData
-			size     uint64
-			last     common.Hash
-		)
-		for it.Next() && size < req.Bytes {
-			hash, account := it.Hash(), common.CopyBytes(it.Account())
-
-			// Track the returned interval for the Merkle proofs
-			last = hash
-
-			// Assemble the reply item
-			size += uint64(common.HashLength + len(account))
-			accounts = append(accounts, &AccountData{
-				Hash: hash,
-				Body: account,
-			})
-			// If we've exceeded the request threshold, abort
-			if bytes.Compare(hash[:], req.Limit[:]) >= 0 {
-				break
-			}
-		}
-		it.Release()
-
-		// Generate the Merkle proofs for the first and last account
-		proof := light.NewNodeSet()
-		if err := tr.Prove(req.Origin[:], 0, proof); err != nil {
-			log.Warn("Failed to prove account range", "origin", req.Origin, "err", err)
-			return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{ID: req.ID})
-		}
-		if last != (common.Hash{}) {
-			if err := tr.Prove(last[:], 0, proof); err != nil {
-				log.Warn("Failed to prove account range", "last", last, "err", err)
-				return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{ID: req.ID})
-			}
-		}
-		var proofs [][]byte
-		for _, blob := range proof.NodeList() {
-			proofs = append(proofs, blob)
-		}
-		// Send back anything accumulated
-		return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{
-			ID:       req.ID,
-			Accounts: accounts,
-			Proof:    proofs,
 		})
 
 	case msg.Code == AccountRangeMsg:
@@ -242,108 +218,12 @@ func handleMessage(backend Backend, peer *Peer) error {
 		// TODO(karalabe):   - Logging locally is not ideal as remote faulst annoy the local user
 		// TODO(karalabe):   - Dropping the remote peer is less flexible wrt client bugs (slow is better than non-functional)
 
-		// Calculate the hard limit at which to abort, even if mid storage trie
-		hardLimit := uint64(float64(req.Bytes) * (1 + stateLookupSlack))
-
-		// Retrieve storage ranges until the packet limit is reached
-		var (
-			slots  [][]*StorageData
-			proofs [][]byte
-			size   uint64
-		)
-		for _, account := range req.Accounts {
-			// If we've exceeded the requested data limit, abort without opening
-			// a new storage range (that we'd need to prove due to exceeded size)
-			if size >= req.Bytes {
-				break
-			}
-			// The first account might start from a different origin and end sooner
-			var origin common.Hash
-			if len(req.Origin) > 0 {
-				origin, req.Origin = common.BytesToHash(req.Origin), nil
-			}
-			var limit = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
-			if len(req.Limit) > 0 {
-				limit, req.Limit = common.BytesToHash(req.Limit), nil
-			}
-			// Retrieve the requested state and bail out if non existent
-			it, err := backend.Chain().Snapshots().StorageIterator(req.Root, account, origin)
-			if err != nil {
-				return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
-			}
-			// Iterate over the requested range and pile slots up
-			var (
-				storage []*StorageData
-				last    common.Hash
-				abort   bool
-			)
-			for it.Next() {
-				if size >= hardLimit {
-					abort = true
-					break
-				}
-				hash, slot := it.Hash(), common.CopyBytes(it.Slot())
-
-				// Track the returned interval for the Merkle proofs
-				last = hash
-
-				// Assemble the reply item
-				size += uint64(common.HashLength + len(slot))
-				storage = append(storage, &StorageData{
-					Hash: hash,
-					Body: slot,
-				})
-				// If we've exceeded the request threshold, abort
-				if bytes.Compare(hash[:], limit[:]) >= 0 {
-					break
-				}
-			}
-			slots = append(slots, storage)
-			it.Release()
-
-			// Generate the Merkle proofs for the first and last storage slot, but
-			// only if the response was capped. If the entire storage trie included
-			// in the response, no need for any proofs.
-			if origin != (common.Hash{}) || abort {
-				// Request started at a non-zero hash or was capped prematurely, add
-				// the endpoint Merkle proofs
-				accTrie, err := trie.New(req.Root, backend.Chain().StateCache().TrieDB())
-				if err != nil {
-					return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
-				}
-				var acc state.Account
-				if err := rlp.DecodeBytes(accTrie.Get(account[:]), &acc); err != nil {
-					return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
-				}
-				stTrie, err := trie.New(acc.Root, backend.Chain().StateCache().TrieDB())
-				if err != nil {
-					return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
-				}
-				proof := light.NewNodeSet()
-				if err := stTrie.Prove(origin[:], 0, proof); err != nil {
-					log.Warn("Failed to prove storage range", "origin", req.Origin, "err", err)
-					return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
-				}
-				if last != (common.Hash{}) {
-					if err := stTrie.Prove(last[:], 0, proof); err != nil {
-						log.Warn("Failed to prove storage range", "last", last, "err", err)
-						return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
-					}
-				}
-				for _, blob := range proof.NodeList() {
-					proofs = append(proofs, blob)
-				}
-				// Proof terminates the reply as proofs are only added if a node
-				// refuses to serve more data (exception when a contract fetch is
-				// finishing, but that's that).
-				break
-			}
-		}
-		// Send back anything accumulated
-		return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{
-			ID:    req.ID,
-			Slots: slots,
-			Proof: proofs,
+		// Serve the request within the peer's serving budget
+		return peer.serve(req.Bytes, req.Bytes/common.HashLength+1, func(bytes, reads uint64) error {
+			req.Bytes = bytes
+			return serveStorageRanges(backend, peer, &req, reads)
+		}, func() error {
+			return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
 		})
 
 	case msg.Code == StorageRangesMsg:
@@ -374,28 +254,12 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if len(req.Hashes) > maxCodeLookups {
 			req.Hashes = req.Hashes[:maxCodeLookups]
 		}
-		// Retrieve bytecodes until the packet size limit is reached
-		var (
-			codes [][]byte
-			bytes uint64
-		)
-		for _, hash := range req.Hashes {
-			if hash == emptyCode {
-				// Peers should not request the empty code, but if they do, at
-				// least sent them back a correct response without db lookups
-				codes = append(codes, []byte{})
-			} else if blob, err := backend.Chain().ContractCode(hash); err == nil {
-				codes = append(codes, blob)
-				bytes += uint64(len(blob))
-			}
-			if bytes > req.Bytes {
-				break
-			}
-		}
-		// Send back anything accumulated
-		return p2p.Send(peer.rw, ByteCodesMsg, &ByteCodesPacket{
-			ID:    req.ID,
-			Codes: codes,
+		// Serve the request within the peer's serving budget
+		return peer.serve(req.Bytes, uint64(len(req.Hashes)), func(bytes, reads uint64) error {
+			req.Bytes = bytes
+			return serveByteCodes(backend, peer, &req, reads)
+		}, func() error {
+			return p2p.Send(peer.rw, ByteCodesMsg, &ByteCodesPacket{ID: req.ID})
 		})
 
 	case msg.Code == ByteCodesMsg:
@@ -415,37 +279,310 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
-		// Make sure we have the state associated with the request
-		triedb := backend.Chain().StateCache().TrieDB()
-
-		accTrie, err := trie.NewSecure(req.Root, triedb)
-		if err != nil {
-			// We don't have the requested state available, bail out
-			return p2p.Send(peer.rw, TrieNodesMsg, &TrieNodesPacket{ID: req.ID})
+		// Ensure we penalize invalid requests before queueing them up
+		for _, pathset := range req.Paths {
+			if len(pathset) == 0 {
+				return fmt.Errorf("%w: zero-item pathset requested", errBadRequest)
+			}
 		}
-		snap := backend.Chain().Snapshots().Snapshot(req.Root)
-		if snap == nil {
-			// We don't have the requested state snapshotted yet, bail out.
-			// In reality we could still serve using the account and storage
-			// tries only, but let's protect the node a bit while it's doing
-			// snapshot generation.
+		// Serve the request within the peer's serving budget
+		return peer.serve(req.Bytes, maxTrieNodeLookups, func(bytes, loads uint64) error {
+			req.Bytes = bytes
+			return serveTrieNodes(backend, peer, &req, loads)
+		}, func() error {
 			return p2p.Send(peer.rw, TrieNodesMsg, &TrieNodesPacket{ID: req.ID})
+		})
+
+	case msg.Code == TrieNodesMsg:
+		// A batch of trie nodes arrived to one of our previous requests
+		res := new(TrieNodesPacket)
+		if err := msg.Decode(res); err != nil {
+			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		}
+		return backend.Handle(peer, res)
+
+	default:
+		return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
+	}
+}
+
+// serveAccountRange answers an account range request, reading at most the given
+// number of accounts from the database.
+func serveAccountRange(backend Backend, peer *Peer, req *GetAccountRangePacket, maxReads uint64) error {
+	var size, reads uint64
+	defer func() { peer.spendServe(size, reads) }()
+
+	// Retrieve the requested state and bail out if non existent
+	tr, err := trie.New(req.Root, backend.Chain().StateCache().TrieDB())
+	if err != nil {
+		return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{ID: req.ID})
+	}
+	it, err := backend.Chain().Snapshots().AccountIterator(req.Root, req.Origin)
+	if err != nil {
+		return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{ID: req.ID})
+	}
+	// Iterate over the requested range and pile accounts up
+	var (
+		accounts []*AccountData
+		last     common.Hash
+	)
+	for size < req.Bytes && reads < maxReads && it.Next() {
+		hash, account := it.Hash(), common.CopyBytes(it.Account())
+		reads++
+
+		// Track the returned interval for the Merkle proofs
+		last = hash
+
+		// Assemble the reply item
+		size += uint64(common.HashLength + len(account))
+		accounts = append(accounts, &AccountData{
+			Hash: hash,
+			Body: account,
+		})
+		// If we've exceeded the request threshold, abort
+		if bytes.Compare(hash[:], req.Limit[:]) >= 0 {
+			break
+		}
+	}
+	it.Release()
+
+	// Generate the Merkle proofs for the first and last account
+	proof := light.NewNodeSet()
+	if err := tr.Prove(req.Origin[:], 0, proof); err != nil {
+		log.Warn("Failed to prove account range", "origin", req.Origin, "err", err)
+		return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{ID: req.ID})
+	}
+	if last != (common.Hash{}) {
+		if err := tr.Prove(last[:], 0, proof); err != nil {
+			log.Warn("Failed to prove account range", "last", last, "err", err)
+			return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{ID: req.ID})
+		}
+	}
+	size += uint64(proof.DataSize())
+	reads += uint64(proof.KeyCount())
+
+	var proofs [][]byte
+	for _, blob := range proof.NodeList() {
+		proofs = append(proofs, blob)
+	}
+	// Send back anything accumulated
+	return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{
+		ID:       req.ID,
+		Accounts: accounts,
+		Proof:    proofs,
+	})
+}
+
+// serveStorageRanges answers a storage range request, reading at most the given
+// number of slots from the database.
+func serveStorageRanges(backend Backend, peer *Peer, req *GetStorageRangesPacket, maxReads uint64) error {
+	var size, reads uint64
+	defer func() { peer.spendServe(size, reads) }()
+
+	// Calculate the hard limit at which to abort, even if mid storage trie
+	hardLimit := uint64(float64(req.Bytes) * (1 + stateLookupSlack))
+
+	// Retrieve storage ranges until the packet limit is reached
+	var (
+		slots  [][]*StorageData
+		proofs [][]byte
+	)
+	for _, account := range req.Accounts {
+		// If we've exceeded the requested data limit, abort without opening
+		// a new storage range (that we'd need to prove due to exceeded size)
+		if size >= req.Bytes || reads >= maxReads {
+			break
 		}
-		// Retrieve trie nodes until the packet size limit is reached
+		// The first account might start from a different origin and end sooner
+		var origin common.Hash
+		if len(req.Origin) > 0 {
+			origin, req.Origin = common.BytesToHash(req.Origin), nil
+		}
+		var limit = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		if len(req.Limit) > 0 {
+			limit, req.Limit = common.BytesToHash(req.Limit), nil
+		}
+		// Retrieve the requested state and bail out if non existent
+		it, err := backend.Chain().Snapshots().StorageIterator(req.Root, account, origin)
+		if err != nil {
+			return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
+		}
+		// Iterate over the requested range and pile slots up
 		var (
-			nodes [][]byte
-			bytes uint64
-			loads int // Trie hash expansions to cound database reads
+			storage []*StorageData
+			last    common.Hash
+			abort   bool
 		)
-		for _, pathset := range req.Paths {
-			switch len(pathset) {
-			case 0:
-				// Ensure we penalize invalid requests
-				return fmt.Errorf("%w: zero-item pathset requested", errBadRequest)
+		for it.Next() {
+			if size >= hardLimit || reads >= maxReads {
+				abort = true
+				break
+			}
+			hash, slot := it.Hash(), common.CopyBytes(it.Slot())
+			reads++
+
+			// Track the returned interval for the Merkle proofs
+			last = hash
 
-			case 1:
-				// If we're only retrieving an account trie node, fetch it directly
-				blob, resolved, err := accTrie.TryGetNode(pathset[0])
+			// Assemble the reply item
+			size += uint64(common.HashLength + len(slot))
+			storage = append(storage, &StorageData{
+				Hash: hash,
+				Body: slot,
+			})
+			// If we've exceeded the request threshold, abort
+			if bytes.Compare(hash[:], limit[:]) >= 0 {
+				break
+			}
+		}
+		slots = append(slots, storage)
+		it.Release()
+
+		// Generate the Merkle proofs for the first and last storage slot, but
+		// only if the response was capped. If the entire storage trie included
+		// in the response, no need for any proofs.
+		if origin != (common.Hash{}) || abort {
+			// Request started at a non-zero hash or was capped prematurely, add
+			// the endpoint Merkle proofs
+			accTrie, err := trie.New(req.Root, backend.Chain().StateCache().TrieDB())
+			if err != nil {
+				return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
+			}
+			var acc state.Account
+			if err := rlp.DecodeBytes(accTrie.Get(account[:]), &acc); err != nil {
+				return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
+			}
+			stTrie, err := trie.New(acc.Root, backend.Chain().StateCache().TrieDB())
+			if err != nil {
+				return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
+			}
+			proof := light.NewNodeSet()
+			if err := stTrie.Prove(origin[:], 0, proof); err != nil {
+				log.Warn("Failed to prove storage range", "origin", req.Origin, "err", err)
+				return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
+			}
+			if last != (common.Hash{}) {
+				if err := stTrie.Prove(last[:], 0, proof); err != nil {
+					log.Warn("Failed to prove storage range", "last", last, "err", err)
+					return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{ID: req.ID})
+				}
+			}
+			size += uint64(proof.DataSize())
+			reads += uint64(proof.KeyCount())
+
+			for _, blob := range proof.NodeList() {
+				proofs = append(proofs, blob)
+			}
+			// Proof terminates the reply as proofs are only added if a node
+			// refuses to serve more data (exception when a contract fetch is
+			// finishing, but that's that).
+			break
+		}
+	}
+	// Send back anything accumulated
+	return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{
+		ID:    req.ID,
+		Slots: slots,
+		Proof: proofs,
+	})
+}
+
+// serveByteCodes answers a bytecode request, reading at most the given number of
+// contract codes from the database.
+func serveByteCodes(backend Backend, peer *Peer, req *GetByteCodesPacket, maxReads uint64) error {
+	if uint64(len(req.Hashes)) > maxReads {
+		req.Hashes = req.Hashes[:maxReads]
+	}
+	// Retrieve bytecodes until the packet size limit is reached
+	var (
+		codes [][]byte
+		bytes uint64
+		reads uint64
+	)
+	for _, hash := range req.Hashes {
+		if hash == emptyCode {
+			// Peers should not request the empty code, but if they do, at
+			// least sent them back a correct response without db lookups
+			codes = append(codes, []byte{})
+		} else {
+			reads++ // always a database read, even for failures
+			if blob, err := backend.Chain().ContractCode(hash); err == nil {
+				codes = append(codes, blob)
+				bytes += uint64(len(blob))
+			}
+		}
+		if bytes > req.Bytes {
+			break
+		}
+	}
+	peer.spendServe(bytes, reads)
+
+	// Send back anything accumulated
+	return p2p.Send(peer.rw, ByteCodesMsg, &ByteCodesPacket{
+		ID:    req.ID,
+		Codes: codes,
+	})
+}
+
+// serveTrieNodes answers a trie node request, expanding at most the given number
+// of trie nodes from the database.
+func serveTrieNodes(backend Backend, peer *Peer, req *GetTrieNodesPacket, maxLoads uint64) error {
+	start := time.Now()
+
+	// Make sure we have the state associated with the request
+	triedb := backend.Chain().StateCache().TrieDB()
+
+	accTrie, err := trie.NewSecure(req.Root, triedb)
+	if err != nil {
+		// We don't have the requested state available, bail out
+		return p2p.Send(peer.rw, TrieNodesMsg, &TrieNodesPacket{ID: req.ID})
+	}
+	snap := backend.Chain().Snapshots().Snapshot(req.Root)
+	if snap == nil {
+		// We don't have the requested state snapshotted yet, bail out.
+		// In reality we could still serve using the account and storage
+		// tries only, but let's protect the node a bit while it's doing
+		// snapshot generation.
+		return p2p.Send(peer.rw, TrieNodesMsg, &TrieNodesPacket{ID: req.ID})
+	}
+	// Retrieve trie nodes until the packet size limit is reached
+	var (
+		nodes [][]byte
+		bytes uint64
+		loads int // Trie hash expansions to cound database reads
+	)
+	defer func() { peer.spendServe(bytes, uint64(loads)) }()
+
+	for _, pathset := range req.Paths {
+		switch len(pathset) {
+		case 0:
+			// Ensure we penalize invalid requests
+			return fmt.Errorf("%w: zero-item pathset requested", errBadRequest)
+
+		case 1:
+			// If we're only retrieving an account trie node, fetch it directly
+			blob, resolved, err := accTrie.TryGetNode(pathset[0])
+			loads += resolved // always account database reads, even for failures
+			if err != nil {
+				break
+			}
+			nodes = append(nodes, blob)
+			bytes += uint64(len(blob))
+
+		default:
+			// Storage slots requested, open the storage trie and retrieve from there
+			account, err := snap.Account(common.BytesToHash(pathset[0]))
+			loads++ // always account database reads, even for failures
+			if err != nil {
+				break
+			}
+			stTrie, err := trie.NewSecure(common.BytesToHash(account.Root), triedb)
+			loads++ // always account database reads, even for failures
+			if err != nil {
+				break
+			}
+			for _, path := range pathset[1:] {
+				blob, resolved, err := stTrie.TryGetNode(path)
 				loads += resolved // always account database reads, even for failures
 				if err != nil {
 					break
@@ -453,55 +590,22 @@ func handleMessage(backend Backend, peer *Peer) error {
 				nodes = append(nodes, blob)
 				bytes += uint64(len(blob))
 
-			default:
-				// Storage slots requested, open the storage trie and retrieve from there
-				account, err := snap.Account(common.BytesToHash(pathset[0]))
-				loads++ // always account database reads, even for failures
-				if err != nil {
-					break
-				}
-				stTrie, err := trie.NewSecure(common.BytesToHash(account.Root), triedb)
-				loads++ // always account database reads, even for failures
-				if err != nil {
+				// Sanity check limits to avoid DoS on the store trie loads
+				if bytes > req.Bytes || uint64(loads) > maxLoads || time.Since(start) > maxTrieNodeTimeSpent {
 					break
 				}
-				for _, path := range pathset[1:] {
-					blob, resolved, err := stTrie.TryGetNode(path)
-					loads += resolved // always account database reads, even for failures
-					if err != nil {
-						break
-					}
-					nodes = append(nodes, blob)
-					bytes += uint64(len(blob))
-
-					// Sanity check limits to avoid DoS on the store trie loads
-					if bytes > req.Bytes || loads > maxTrieNodeLookups || time.Since(start) > maxTrieNodeTimeSpent {
-						break
-					}
-				}
-			}
-			// Abort request processing if we've exceeded our limits
-			if bytes > req.Bytes || loads > maxTrieNodeLookups || time.Since(start) > maxTrieNodeTimeSpent {
-				break
 			}
 		}
-		// Send back anything accumulated
-		return p2p.Send(peer.rw, TrieNodesMsg, &TrieNodesPacket{
-			ID:    req.ID,
-			Nodes: nodes,
-		})
-
-	case msg.Code == TrieNodesMsg:
-		// A batch of trie nodes arrived to one of our previous requests
-		res := new(TrieNodesPacket)
-		if err := msg.Decode(res); err != nil {
-			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		// Abort request processing if we've exceeded our limits
+		if bytes > req.Bytes || uint64(loads) > maxLoads || time.Since(start) > maxTrieNodeTimeSpent {
+			break
 		}
-		return backend.Handle(peer, res)
-
-	default:
-		return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
 	}
+	// Send back anything accumulated
+	return p2p.Send(peer.rw, TrieNodesMsg, &TrieNodesPacket{
+		ID:    req.ID,
+		Nodes: nodes,
+	})
 }
 
 // NodeInfo represents a short summary of the `snap` sub-protocol metadata
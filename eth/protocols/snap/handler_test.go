@@ -0,0 +1,136 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// testBackend is a snap backend forwarding all delivered packets to a channel.
+type testBackend struct {
+	packets chan Packet
+}
+
+func (b *testBackend) Chain() *core.BlockChain                   { return nil }
+func (b *testBackend) RunPeer(peer *Peer, handler Handler) error { return handler(peer) }
+func (b *testBackend) PeerInfo(id enode.ID) interface{}          { return nil }
+
+func (b *testBackend) Handle(peer *Peer, packet Packet) error {
+	b.packets <- packet
+	return nil
+}
+
+// Tests that requests waiting for serving budget don't block the processing of
+// other messages of the peer, and that they are answered once the budget allows.
+func TestServeThrottledRequests(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		limiter = newServeLimiter(ServeConfig{Window: 10 * time.Second, PeerReads: 10}, clock)
+		backend = &testBackend{packets: make(chan Packet, 1)}
+
+		local, remote = p2p.MsgPipe()
+	)
+	defer local.Close()
+	defer remote.Close()
+
+	peer := newPeer(snap1, p2p.NewPeer(enode.ID{1}, "", nil), local)
+	peer.budget = limiter.newPeerBudget()
+	peer.budget.spend(0, 10)
+
+	errc := make(chan error, 1)
+	go func() { errc <- handle(backend, peer) }()
+
+	// Collect the responses to our requests in the background
+	responses := make(chan *ByteCodesPacket, 2)
+	go func() {
+		for {
+			msg, err := remote.ReadMsg()
+			if err != nil {
+				return
+			}
+			res := new(ByteCodesPacket)
+			if err := msg.Decode(res); err != nil {
+				t.Errorf("failed to decode response: %v", err)
+			}
+			responses <- res
+		}
+	}()
+	// Request some data while the budget is exhausted, it should get queued
+	if err := p2p.Send(remote, GetByteCodesMsg, &GetByteCodesPacket{ID: 1, Hashes: []common.Hash{emptyCode}, Bytes: 1024}); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	clock.WaitForTimers(1)
+
+	// Deliver a response meanwhile, which must not wait for the request
+	if err := p2p.Send(remote, AccountRangeMsg, &AccountRangePacket{ID: 2}); err != nil {
+		t.Fatalf("failed to send response: %v", err)
+	}
+	select {
+	case packet := <-backend.packets:
+		if res, ok := packet.(*AccountRangePacket); !ok || res.ID != 2 {
+			t.Fatalf("wrong packet delivered: %v", packet)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("response stalled by throttled request")
+	}
+	select {
+	case res := <-responses:
+		t.Fatalf("request served without budget: %v", res)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Recharge the budget and ensure the queued request is served
+	clock.Run(time.Second)
+	select {
+	case res := <-responses:
+		if res.ID != 1 || len(res.Codes) != 1 {
+			t.Fatalf("wrong response: id %d, codes %d", res.ID, len(res.Codes))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("throttled request not served")
+	}
+	// Exhaust the budget for longer than requests may wait, they should get
+	// rejected straight away
+	peer.budget.spend(0, 100)
+	if err := p2p.Send(remote, GetByteCodesMsg, &GetByteCodesPacket{ID: 3, Hashes: []common.Hash{emptyCode}, Bytes: 1024}); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	select {
+	case res := <-responses:
+		if res.ID != 3 || len(res.Codes) != 0 {
+			t.Fatalf("wrong response: id %d, codes %d", res.ID, len(res.Codes))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request not rejected")
+	}
+	if stats := peer.ServeStats(); stats.Dropped != 1 || stats.Throttled != time.Second {
+		t.Fatalf("wrong peer statistics: %+v", stats)
+	}
+	// Disconnecting must not hang on the background serving
+	remote.Close()
+	select {
+	case <-errc:
+	case <-time.After(time.Second):
+		t.Fatalf("handler not terminated")
+	}
+}
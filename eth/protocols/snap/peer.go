@@ -29,6 +29,7 @@ type Peer struct {
 	*p2p.Peer                   // The embedded P2P package peer
 	rw        p2p.MsgReadWriter // Input/output streams for snap
 	version   uint              // Protocol version negotiated
+	budget    *peerBudget       // Serving budget of the peer, nil if unlimited
 
 	logger log.Logger // Contextual logger with the peer id injected
 }
@@ -56,6 +57,14 @@ func (p *Peer) Version() uint {
 	return p.version
 }
 
+// ServeStats retrieves the serving resources consumed by the peer.
+func (p *Peer) ServeStats() ServeStats {
+	if p.budget == nil {
+		return ServeStats{}
+	}
+	return p.budget.statistics()
+}
+
 // Log overrides the P2P logget with the higher level one containing only the id.
 func (p *Peer) Log() log.Logger {
 	return p.logger
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 	"golang.org/x/crypto/sha3"
@@ -87,6 +88,10 @@ var (
 	// requestTimeout is the maximum time a peer is allowed to spend on serving
 	// a single network request.
 	requestTimeout = 15 * time.Second // TODO(karalabe): Make it dynamic ala fast-sync?
+
+	// healCheckpointInterval is the time between two checkpoints of the healing
+	// progress, allowing an interrupted sync to resume healing where it left off.
+	healCheckpointInterval = 30 * time.Second
 )
 
 // ErrCancelled is returned from snap syncing if the operation was prematurely
@@ -346,6 +351,41 @@ type syncProgress struct {
 	BytecodeHealBytes  common.StorageSize // Number of bytecodes persisted to disk
 	BytecodeHealDups   uint64             // Number of bytecodes already processed
 	BytecodeHealNops   uint64             // Number of bytecodes not requested
+	AccountHealed      uint64             // Number of accounts downloaded during the healing stage
+	AccountHealedBytes common.StorageSize // Number of raw account bytes persisted to disk during the healing stage
+	StorageHealed      uint64             // Number of storage slots downloaded during the healing stage
+	StorageHealedBytes common.StorageSize // Number of raw storage bytes persisted to disk during the healing stage
+
+	// Number of trie nodes and bytecodes checkpointed into the heal journal
+	HealJournaled uint64
+}
+
+// Progress is a status report of the snap sync, covering both the snapshot
+// download and the healing phases. Throughput related fields are estimates.
+type Progress struct {
+	Root    common.Hash `json:"root"`    // State root being synced
+	Healing bool        `json:"healing"` // Whether the sync is in the healing phase
+
+	// Status report during syncing phase
+	AccountSynced  uint64             `json:"accountSynced"`  // Number of accounts downloaded
+	AccountBytes   common.StorageSize `json:"accountBytes"`   // Number of account trie bytes persisted to disk
+	BytecodeSynced uint64             `json:"bytecodeSynced"` // Number of bytecodes downloaded
+	BytecodeBytes  common.StorageSize `json:"bytecodeBytes"`  // Number of bytecode bytes downloaded
+	StorageSynced  uint64             `json:"storageSynced"`  // Number of storage slots downloaded
+	StorageBytes   common.StorageSize `json:"storageBytes"`   // Number of storage trie bytes persisted to disk
+	Synced         float64            `json:"synced"`         // Estimated percentage of the syncing phase done
+	ETA            time.Duration      `json:"eta"`            // Estimated time left of the syncing phase
+
+	// Status report during healing phase
+	TrienodeHealSynced uint64             `json:"trienodeHealSynced"` // Number of state trie nodes downloaded
+	TrienodeHealBytes  common.StorageSize `json:"trienodeHealBytes"`  // Number of state trie bytes persisted to disk
+	BytecodeHealSynced uint64             `json:"bytecodeHealSynced"` // Number of bytecodes downloaded
+	BytecodeHealBytes  common.StorageSize `json:"bytecodeHealBytes"`  // Number of bytecodes persisted to disk
+	AccountHealed      uint64             `json:"accountHealed"`      // Number of accounts downloaded during the healing stage
+	StorageHealed      uint64             `json:"storageHealed"`      // Number of storage slots downloaded during the healing stage
+	HealingTrienodes   uint64             `json:"healingTrienodes"`   // Number of state trie nodes pending for retrieval
+	HealingBytecodes   uint64             `json:"healingBytecodes"`   // Number of bytecodes pending for retrieval
+	HealJournaled      uint64             `json:"healJournaled"`      // Number of healed items checkpointed for resumption
 }
 
 // SyncPeer abstracts out the methods required for a peer to be synced against
@@ -373,6 +413,9 @@ type SyncPeer interface {
 
 	// Log retrieves the peer's own contextual logger.
 	Log() log.Logger
+
+	// Report adjusts the peer's reputation based on the outcome of a request.
+	Report(event p2p.ScoreEvent)
 }
 
 // Syncer is an Ethereum account and storage trie syncer based on snapshots and
@@ -438,6 +481,12 @@ type Syncer struct {
 	storageHealed      uint64             // Number of storage slots downloaded during the healing stage
 	storageHealedBytes common.StorageSize // Number of raw storage bytes persisted to disk during the healing stage
 
+	healJournal    ethdb.Batch // Batch writer checkpointing the healed trie nodes and bytecodes
+	healJournaled  uint64      // Number of healed items checkpointed, if non-zero they are replayed
+	healCheckpoint time.Time   // Time instance when the healing progress was last checkpointed
+
+	progress *Progress // Latest progress report, protected by lock
+
 	startTime time.Time // Time instance when snapshot sync started
 	logTime   time.Time // Time instance when status was last reported
 
@@ -470,6 +519,7 @@ func NewSyncer(db ethdb.KeyValueStore) *Syncer {
 		trienodeHealReqs: make(map[uint64]*trienodeHealRequest),
 		bytecodeHealReqs: make(map[uint64]*bytecodeHealRequest),
 		stateWriter:      db.NewBatch(),
+		healJournal:      db.NewBatch(),
 	}
 }
 
@@ -551,8 +601,13 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 	s.loadSyncStatus()
 	if len(s.tasks) == 0 && s.healer.scheduler.Pending() == 0 {
 		log.Debug("Snapshot sync already completed")
+		s.dropHealJournal()
 		return nil
 	}
+	if s.healJournaled > 0 {
+		log.Info("Resuming state heal from checkpoint", "items", s.healJournaled)
+	}
+	s.updateProgress()
 	// If sync is still not finished, we need to ensure that any marker is wiped.
 	// Otherwise, it may happen that requests for e.g. genesis-data is delivered
 	// from the snapshot data, instead of from the trie
@@ -568,12 +623,16 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 
 	log.Debug("Starting snapshot sync cycle", "root", root)
 
-	// Flush out the last committed raw states
+	// Flush out the last committed raw states and healing checkpoints
 	defer func() {
 		if s.stateWriter.ValueSize() > 0 {
 			s.stateWriter.Write()
 			s.stateWriter.Reset()
 		}
+		if s.healJournal.ValueSize() > 0 {
+			s.healJournal.Write()
+			s.healJournal.Reset()
+		}
 	}()
 	defer s.report(true)
 
@@ -617,6 +676,7 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 		s.cleanStorageTasks()
 		s.cleanAccountTasks()
 		if len(s.tasks) == 0 && s.healer.scheduler.Pending() == 0 {
+			s.dropHealJournal()
 			return nil
 		}
 		// Assign all the data retrieval tasks to any free peers
@@ -693,6 +753,12 @@ func (s *Syncer) loadSyncStatus() {
 			s.trienodeHealBytes = progress.TrienodeHealBytes
 			s.bytecodeHealSynced = progress.BytecodeHealSynced
 			s.bytecodeHealBytes = progress.BytecodeHealBytes
+			s.accountHealed = progress.AccountHealed
+			s.accountHealedBytes = progress.AccountHealedBytes
+			s.storageHealed = progress.StorageHealed
+			s.storageHealedBytes = progress.StorageHealedBytes
+
+			s.healJournaled = progress.HealJournaled
 			return
 		}
 	}
@@ -705,6 +771,11 @@ func (s *Syncer) loadSyncStatus() {
 	s.storageSynced, s.storageBytes = 0, 0
 	s.trienodeHealSynced, s.trienodeHealBytes = 0, 0
 	s.bytecodeHealSynced, s.bytecodeHealBytes = 0, 0
+	s.accountHealed, s.accountHealedBytes = 0, 0
+	s.storageHealed, s.storageHealedBytes = 0, 0
+
+	// Any heal journal belongs to a sync which can't be resumed, drop it
+	s.dropHealJournal()
 
 	var next common.Hash
 	step := new(big.Int).Sub(
@@ -743,6 +814,11 @@ func (s *Syncer) saveSyncStatus() {
 		TrienodeHealBytes:  s.trienodeHealBytes,
 		BytecodeHealSynced: s.bytecodeHealSynced,
 		BytecodeHealBytes:  s.bytecodeHealBytes,
+		AccountHealed:      s.accountHealed,
+		AccountHealedBytes: s.accountHealedBytes,
+		StorageHealed:      s.storageHealed,
+		StorageHealedBytes: s.storageHealedBytes,
+		HealJournaled:      s.healJournaled,
 	}
 	status, err := json.Marshal(progress)
 	if err != nil {
@@ -751,6 +827,101 @@ func (s *Syncer) saveSyncStatus() {
 	rawdb.WriteSnapshotSyncStatus(s.db, status)
 }
 
+// journalHealNode queues a healed trie node or bytecode for the next healing
+// checkpoint. The scheduler only commits nodes once their whole subtrie is done,
+// so without the journal anything below an incomplete subtrie would need to be
+// retrieved again after a restart.
+func (s *Syncer) journalHealNode(hash common.Hash, blob []byte) {
+	rawdb.WriteSnapshotHealNode(s.healJournal, hash, blob)
+	s.healJournaled++
+}
+
+// checkpointHeal persists the heal journal along with the sync status. Unless
+// forced, it only does so every healCheckpointInterval or if the journal grew
+// too large to keep in memory.
+func (s *Syncer) checkpointHeal(force bool) {
+	if !force && time.Since(s.healCheckpoint) < healCheckpointInterval && s.healJournal.ValueSize() < ethdb.IdealBatchSize {
+		return
+	}
+	if err := s.healJournal.Write(); err != nil {
+		log.Crit("Failed to persist healing checkpoint", "err", err)
+	}
+	s.healJournal.Reset()
+	s.saveSyncStatus()
+
+	s.healCheckpoint = time.Now()
+	log.Debug("Checkpointed state heal progress", "items", s.healJournaled)
+}
+
+// dropHealJournal deletes any healing checkpoints from the database.
+func (s *Syncer) dropHealJournal() {
+	s.healJournal.Reset()
+	if s.healJournaled > 0 {
+		rawdb.DeleteSnapshotHealNodes(s.db)
+		s.healJournaled = 0
+	}
+}
+
+// fillHealTasks queues up trie node and bytecode tasks from the state sync
+// scheduler until the given number of tasks are waiting. Items found in the heal
+// journal of an interrupted sync are fed to the scheduler straight away instead
+// of being retrieved from the network.
+func (s *Syncer) fillHealTasks(want int) {
+	for {
+		have := len(s.healer.trieTasks) + len(s.healer.codeTasks)
+		if have >= want {
+			return
+		}
+		nodes, paths, codes := s.healer.scheduler.Missing(want - have)
+		if len(nodes) == 0 && len(codes) == 0 {
+			return
+		}
+		var replayed int
+		for i, hash := range nodes {
+			if s.replayHealNode(hash) {
+				replayed++
+				continue
+			}
+			s.healer.trieTasks[hash] = paths[i]
+		}
+		for _, hash := range codes {
+			if s.replayHealNode(hash) {
+				replayed++
+				continue
+			}
+			s.healer.codeTasks[hash] = struct{}{}
+		}
+		if replayed == 0 {
+			return
+		}
+		batch := s.db.NewBatch()
+		if err := s.healer.scheduler.Commit(batch); err != nil {
+			log.Error("Failed to commit healing data", "err", err)
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to persist healing data", "err", err)
+		}
+		log.Debug("Replayed checkpointed healing data", "items", replayed, "bytes", common.StorageSize(batch.ValueSize()))
+	}
+}
+
+// replayHealNode feeds a trie node or bytecode from the heal journal into the
+// scheduler, reporting whether it was found.
+func (s *Syncer) replayHealNode(hash common.Hash) bool {
+	if s.healJournaled == 0 {
+		return false
+	}
+	blob := rawdb.ReadSnapshotHealNode(s.db, hash)
+	if blob == nil {
+		return false
+	}
+	if err := s.healer.scheduler.Process(trie.SyncResult{Hash: hash, Data: blob}); err != nil {
+		log.Debug("Failed to replay checkpointed healing data", "hash", hash, "err", err)
+		return false
+	}
+	return true
+}
+
 // cleanAccountTasks removes account range retrieval tasks that have already been
 // completed.
 func (s *Syncer) cleanAccountTasks() {
@@ -860,6 +1031,7 @@ func (s *Syncer) assignAccountTasks(success chan *accountResponse, fail chan *ac
 		}
 		req.timeout = time.AfterFunc(requestTimeout, func() {
 			peer.Log().Debug("Account range request timed out", "reqid", reqid)
+			peer.Report(p2p.ScoreTimeout)
 			s.scheduleRevertAccountRequest(req)
 		})
 		s.accountReqs[reqid] = req
@@ -951,6 +1123,7 @@ func (s *Syncer) assignBytecodeTasks(success chan *bytecodeResponse, fail chan *
 		}
 		req.timeout = time.AfterFunc(requestTimeout, func() {
 			peer.Log().Debug("Bytecode request timed out", "reqid", reqid)
+			peer.Report(p2p.ScoreTimeout)
 			s.scheduleRevertBytecodeRequest(req)
 		})
 		s.bytecodeReqs[reqid] = req
@@ -1079,6 +1252,7 @@ func (s *Syncer) assignStorageTasks(success chan *storageResponse, fail chan *st
 		}
 		req.timeout = time.AfterFunc(requestTimeout, func() {
 			peer.Log().Debug("Storage request timed out", "reqid", reqid)
+			peer.Report(p2p.ScoreTimeout)
 			s.scheduleRevertStorageRequest(req)
 		})
 		s.storageReqs[reqid] = req
@@ -1121,19 +1295,8 @@ func (s *Syncer) assignTrienodeHealTasks(success chan *trienodeHealResponse, fai
 		// If there are not enough trie tasks queued to fully assign, fill the
 		// queue from the state sync scheduler. The trie synced schedules these
 		// together with bytecodes, so we need to queue them combined.
-		var (
-			have = len(s.healer.trieTasks) + len(s.healer.codeTasks)
-			want = maxTrieRequestCount + maxCodeRequestCount
-		)
-		if have < want {
-			nodes, paths, codes := s.healer.scheduler.Missing(want - have)
-			for i, hash := range nodes {
-				s.healer.trieTasks[hash] = paths[i]
-			}
-			for _, hash := range codes {
-				s.healer.codeTasks[hash] = struct{}{}
-			}
-		}
+		s.fillHealTasks(maxTrieRequestCount + maxCodeRequestCount)
+
 		// If all the heal tasks are bytecodes or already downloading, bail
 		if len(s.healer.trieTasks) == 0 {
 			return
@@ -1198,6 +1361,7 @@ func (s *Syncer) assignTrienodeHealTasks(success chan *trienodeHealResponse, fai
 		}
 		req.timeout = time.AfterFunc(requestTimeout, func() {
 			peer.Log().Debug("Trienode heal request timed out", "reqid", reqid)
+			peer.Report(p2p.ScoreTimeout)
 			s.scheduleRevertTrienodeHealRequest(req)
 		})
 		s.trienodeHealReqs[reqid] = req
@@ -1231,19 +1395,8 @@ func (s *Syncer) assignBytecodeHealTasks(success chan *bytecodeHealResponse, fai
 		// If there are not enough trie tasks queued to fully assign, fill the
 		// queue from the state sync scheduler. The trie synced schedules these
 		// together with trie nodes, so we need to queue them combined.
-		var (
-			have = len(s.healer.trieTasks) + len(s.healer.codeTasks)
-			want = maxTrieRequestCount + maxCodeRequestCount
-		)
-		if have < want {
-			nodes, paths, codes := s.healer.scheduler.Missing(want - have)
-			for i, hash := range nodes {
-				s.healer.trieTasks[hash] = paths[i]
-			}
-			for _, hash := range codes {
-				s.healer.codeTasks[hash] = struct{}{}
-			}
-		}
+		s.fillHealTasks(maxTrieRequestCount + maxCodeRequestCount)
+
 		// If all the heal tasks are trienodes or already downloading, bail
 		if len(s.healer.codeTasks) == 0 {
 			return
@@ -1300,6 +1453,7 @@ func (s *Syncer) assignBytecodeHealTasks(success chan *bytecodeHealResponse, fai
 		}
 		req.timeout = time.AfterFunc(requestTimeout, func() {
 			peer.Log().Debug("Bytecode heal request timed out", "reqid", reqid)
+			peer.Report(p2p.ScoreTimeout)
 			s.scheduleRevertBytecodeHealRequest(req)
 		})
 		s.bytecodeHealReqs[reqid] = req
@@ -1902,6 +2056,7 @@ func (s *Syncer) processTrienodeHealResponse(res *trienodeHealResponse) {
 		err := s.healer.scheduler.Process(trie.SyncResult{Hash: hash, Data: node})
 		switch err {
 		case nil:
+			s.journalHealNode(hash, node)
 		case trie.ErrAlreadyProcessed:
 			s.trienodeHealDups++
 		case trie.ErrNotRequested:
@@ -1918,6 +2073,7 @@ func (s *Syncer) processTrienodeHealResponse(res *trienodeHealResponse) {
 		log.Crit("Failed to persist healing data", "err", err)
 	}
 	log.Debug("Persisted set of healing data", "type", "trienodes", "bytes", common.StorageSize(batch.ValueSize()))
+	s.checkpointHeal(false)
 }
 
 // processBytecodeHealResponse integrates an already validated bytecode response
@@ -1938,6 +2094,7 @@ func (s *Syncer) processBytecodeHealResponse(res *bytecodeHealResponse) {
 		err := s.healer.scheduler.Process(trie.SyncResult{Hash: hash, Data: node})
 		switch err {
 		case nil:
+			s.journalHealNode(hash, node)
 		case trie.ErrAlreadyProcessed:
 			s.bytecodeHealDups++
 		case trie.ErrNotRequested:
@@ -1954,6 +2111,7 @@ func (s *Syncer) processBytecodeHealResponse(res *bytecodeHealResponse) {
 		log.Crit("Failed to persist healing data", "err", err)
 	}
 	log.Debug("Persisted set of healing data", "type", "bytecode", "bytes", common.StorageSize(batch.ValueSize()))
+	s.checkpointHeal(false)
 }
 
 // forwardAccountTask takes a filled account task and persists anything available
@@ -2080,6 +2238,7 @@ func (s *Syncer) OnAccounts(peer SyncPeer, id uint64, hashes []common.Hash, acco
 		// Request stale, perhaps the peer timed out but came through in the end
 		logger.Warn("Unexpected account range packet")
 		s.lock.Unlock()
+		peer.Report(p2p.ScoreUselessDelivery)
 		return nil
 	}
 	delete(s.accountReqs, id)
@@ -2162,6 +2321,7 @@ func (s *Syncer) OnAccounts(peer SyncPeer, id uint64, hashes []common.Hash, acco
 	case <-req.cancel:
 	case <-req.stale:
 	}
+	peer.Report(p2p.ScoreUsefulDelivery)
 	return nil
 }
 
@@ -2205,6 +2365,7 @@ func (s *Syncer) onByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) error
 		// Request stale, perhaps the peer timed out but came through in the end
 		logger.Warn("Unexpected bytecode packet")
 		s.lock.Unlock()
+		peer.Report(p2p.ScoreUselessDelivery)
 		return nil
 	}
 	delete(s.bytecodeReqs, id)
@@ -2268,6 +2429,7 @@ func (s *Syncer) onByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) error
 	case <-req.cancel:
 	case <-req.stale:
 	}
+	peer.Report(p2p.ScoreUsefulDelivery)
 	return nil
 }
 
@@ -2313,6 +2475,7 @@ func (s *Syncer) OnStorage(peer SyncPeer, id uint64, hashes [][]common.Hash, slo
 		// Request stale, perhaps the peer timed out but came through in the end
 		logger.Warn("Unexpected storage ranges packet")
 		s.lock.Unlock()
+		peer.Report(p2p.ScoreUselessDelivery)
 		return nil
 	}
 	delete(s.storageReqs, id)
@@ -2426,6 +2589,7 @@ func (s *Syncer) OnStorage(peer SyncPeer, id uint64, hashes [][]common.Hash, slo
 	case <-req.cancel:
 	case <-req.stale:
 	}
+	peer.Report(p2p.ScoreUsefulDelivery)
 	return nil
 }
 
@@ -2456,6 +2620,7 @@ func (s *Syncer) OnTrieNodes(peer SyncPeer, id uint64, trienodes [][]byte) error
 		// Request stale, perhaps the peer timed out but came through in the end
 		logger.Warn("Unexpected trienode heal packet")
 		s.lock.Unlock()
+		peer.Report(p2p.ScoreUselessDelivery)
 		return nil
 	}
 	delete(s.trienodeHealReqs, id)
@@ -2520,6 +2685,7 @@ func (s *Syncer) OnTrieNodes(peer SyncPeer, id uint64, trienodes [][]byte) error
 	case <-req.cancel:
 	case <-req.stale:
 	}
+	peer.Report(p2p.ScoreUsefulDelivery)
 	return nil
 }
 
@@ -2550,6 +2716,7 @@ func (s *Syncer) onHealByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) e
 		// Request stale, perhaps the peer timed out but came through in the end
 		logger.Warn("Unexpected bytecode heal packet")
 		s.lock.Unlock()
+		peer.Report(p2p.ScoreUselessDelivery)
 		return nil
 	}
 	delete(s.bytecodeHealReqs, id)
@@ -2613,6 +2780,7 @@ func (s *Syncer) onHealByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) e
 	case <-req.cancel:
 	case <-req.stale:
 	}
+	peer.Report(p2p.ScoreUsefulDelivery)
 	return nil
 }
 
@@ -2646,25 +2814,58 @@ func (s *Syncer) onHealState(paths [][]byte, value []byte) error {
 // hashSpace is the total size of the 256 bit hash space for accounts.
 var hashSpace = new(big.Int).Exp(common.Big2, common.Big256, nil)
 
-// report calculates various status reports and provides it to the user.
-func (s *Syncer) report(force bool) {
-	if len(s.tasks) > 0 {
-		s.reportSyncProgress(force)
-		return
+// Progress returns the latest status report of the snap sync, or nil if no sync
+// was started yet.
+func (s *Syncer) Progress() *Progress {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.progress == nil {
+		return nil
 	}
-	s.reportHealProgress(force)
+	progress := *s.progress
+	return &progress
 }
 
-// reportSyncProgress calculates various status reports and provides it to the user.
-func (s *Syncer) reportSyncProgress(force bool) {
-	// Don't report all the events, just occasionally
-	if !force && time.Since(s.logTime) < 3*time.Second {
-		return
+// updateProgress refreshes the status report returned by Progress.
+func (s *Syncer) updateProgress() *Progress {
+	progress := &Progress{
+		Root:               s.root,
+		Healing:            len(s.tasks) == 0,
+		AccountSynced:      s.accountSynced,
+		AccountBytes:       s.accountBytes,
+		BytecodeSynced:     s.bytecodeSynced,
+		BytecodeBytes:      s.bytecodeBytes,
+		StorageSynced:      s.storageSynced,
+		StorageBytes:       s.storageBytes,
+		TrienodeHealSynced: s.trienodeHealSynced,
+		TrienodeHealBytes:  s.trienodeHealBytes,
+		BytecodeHealSynced: s.bytecodeHealSynced,
+		BytecodeHealBytes:  s.bytecodeHealBytes,
+		AccountHealed:      s.accountHealed,
+		StorageHealed:      s.storageHealed,
+		HealJournaled:      s.healJournaled,
+	}
+	if progress.Healing {
+		progress.Synced = 100
+		progress.HealingTrienodes = uint64(s.healer.scheduler.PendingNodes())
+		progress.HealingBytecodes = uint64(s.healer.scheduler.PendingCodes())
+	} else {
+		progress.Synced, progress.ETA = s.estimateSyncProgress()
 	}
-	// Don't report anything until we have a meaningful progress
+	s.lock.Lock()
+	s.progress = progress
+	s.lock.Unlock()
+
+	return progress
+}
+
+// estimateSyncProgress estimates the percentage of the syncing phase done and
+// the time left based on how much of the account hash space is already filled.
+func (s *Syncer) estimateSyncProgress() (float64, time.Duration) {
 	synced := s.accountBytes + s.bytecodeBytes + s.storageBytes
 	if synced == 0 {
-		return
+		return 0, 0
 	}
 	accountGaps := new(big.Int)
 	for _, task := range s.tasks {
@@ -2672,9 +2873,8 @@ func (s *Syncer) reportSyncProgress(force bool) {
 	}
 	accountFills := new(big.Int).Sub(hashSpace, accountGaps)
 	if accountFills.BitLen() == 0 {
-		return
+		return 0, 0
 	}
-	s.logTime = time.Now()
 	estBytes := float64(new(big.Int).Div(
 		new(big.Int).Mul(new(big.Int).SetUint64(uint64(synced)), hashSpace),
 		accountFills,
@@ -2683,15 +2883,41 @@ func (s *Syncer) reportSyncProgress(force bool) {
 	elapsed := time.Since(s.startTime)
 	estTime := elapsed / time.Duration(synced) * time.Duration(estBytes)
 
+	return float64(synced) * 100 / estBytes, estTime - elapsed
+}
+
+// report calculates various status reports and provides it to the user.
+func (s *Syncer) report(force bool) {
+	if len(s.tasks) > 0 {
+		s.reportSyncProgress(force)
+		return
+	}
+	s.reportHealProgress(force)
+}
+
+// reportSyncProgress calculates various status reports and provides it to the user.
+func (s *Syncer) reportSyncProgress(force bool) {
+	// Don't report all the events, just occasionally
+	if !force && time.Since(s.logTime) < 3*time.Second {
+		return
+	}
+	// Don't report anything until we have a meaningful progress
+	status := s.updateProgress()
+	if status.Synced == 0 {
+		return
+	}
+	s.logTime = time.Now()
+
 	// Create a mega progress report
 	var (
-		progress = fmt.Sprintf("%.2f%%", float64(synced)*100/estBytes)
+		synced   = s.accountBytes + s.bytecodeBytes + s.storageBytes
+		progress = fmt.Sprintf("%.2f%%", status.Synced)
 		accounts = fmt.Sprintf("%v@%v", log.FormatLogfmtUint64(s.accountSynced), s.accountBytes.TerminalString())
 		storage  = fmt.Sprintf("%v@%v", log.FormatLogfmtUint64(s.storageSynced), s.storageBytes.TerminalString())
 		bytecode = fmt.Sprintf("%v@%v", log.FormatLogfmtUint64(s.bytecodeSynced), s.bytecodeBytes.TerminalString())
 	)
 	log.Info("State sync in progress", "synced", progress, "state", synced,
-		"accounts", accounts, "slots", storage, "codes", bytecode, "eta", common.PrettyDuration(estTime-elapsed))
+		"accounts", accounts, "slots", storage, "codes", bytecode, "eta", common.PrettyDuration(status.ETA))
 }
 
 // reportHealProgress calculates various status reports and provides it to the user.
@@ -2701,6 +2927,7 @@ func (s *Syncer) reportHealProgress(force bool) {
 		return
 	}
 	s.logTime = time.Now()
+	s.updateProgress()
 
 	// Create a mega progress report
 	var (
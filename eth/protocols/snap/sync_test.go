@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 	"golang.org/x/crypto/sha3"
@@ -156,6 +157,8 @@ func newTestPeer(id string, t *testing.T, term func()) *testPeer {
 func (t *testPeer) ID() string      { return t.id }
 func (t *testPeer) Log() log.Logger { return t.logger }
 
+func (t *testPeer) Report(event p2p.ScoreEvent) {}
+
 func (t *testPeer) RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error {
 	t.logger.Trace("Fetching range of accounts", "reqid", id, "root", root, "origin", origin, "limit", limit, "bytes", common.StorageSize(bytes))
 	go t.accountRequestHandler(t, id, root, origin, limit, bytes)
@@ -728,6 +731,80 @@ func TestSyncWithStorage(t *testing.T) {
 	verifyTrie(syncer.db, sourceAccountTrie.Hash(), t)
 }
 
+// TestSyncResumeHealing tests that healing progress checkpointed by an
+// interrupted sync is reused by the next sync instead of being retrieved again.
+func TestSyncResumeHealing(t *testing.T) {
+	t.Parallel()
+
+	var (
+		once   sync.Once
+		cancel = make(chan struct{})
+		term   = func() {
+			once.Do(func() {
+				close(cancel)
+			})
+		}
+	)
+	sourceAccountTrie, elems := makeAccountTrieNoStorage(100)
+	root := sourceAccountTrie.Hash()
+
+	// Serve the first trie node request (the root), then abort the sync
+	var served int
+	source := newTestPeer("source", t, term)
+	source.accountTrie = sourceAccountTrie
+	source.accountValues = elems
+	source.trieRequestHandler = func(t *testPeer, requestId uint64, root common.Hash, paths []TrieNodePathSet, cap uint64) error {
+		if served++; served > 1 {
+			term()
+			return nil
+		}
+		return defaultTrieRequestHandler(t, requestId, root, paths, cap)
+	}
+	syncer := setupSyncer(source)
+	if err := syncer.Sync(root, cancel); err != ErrCancelled {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, ErrCancelled)
+	}
+	if rawdb.ReadSnapshotHealNode(syncer.db, root) == nil {
+		t.Fatalf("healed root not checkpointed")
+	}
+	// Restart the sync on the same database, the root must not be requested again
+	var rootServed bool
+	resumed := newTestPeer("resumed", t, func() {})
+	resumed.accountTrie = sourceAccountTrie
+	resumed.accountValues = elems
+	resumed.trieRequestHandler = func(t *testPeer, requestId uint64, root common.Hash, paths []TrieNodePathSet, cap uint64) error {
+		for _, pathset := range paths {
+			if blob, _, _ := t.accountTrie.TryGetNode(pathset[0]); crypto.Keccak256Hash(blob) == root {
+				rootServed = true
+			}
+		}
+		return defaultTrieRequestHandler(t, requestId, root, paths, cap)
+	}
+	restarted := NewSyncer(syncer.db)
+	restarted.Register(resumed)
+	resumed.remote = restarted
+
+	if err := restarted.Sync(root, make(chan struct{})); err != nil {
+		t.Fatalf("resumed sync failed: %v", err)
+	}
+	if rootServed {
+		t.Fatalf("checkpointed root retrieved again")
+	}
+	verifyTrie(restarted.db, root, t)
+
+	// Check the progress report and that the checkpoints were cleaned up
+	progress := restarted.Progress()
+	if progress == nil || !progress.Healing || progress.Root != root {
+		t.Fatalf("invalid progress report: %+v", progress)
+	}
+	if progress.AccountSynced == 0 || progress.TrienodeHealSynced == 0 {
+		t.Fatalf("progress report missing sync stats: %+v", progress)
+	}
+	if rawdb.ReadSnapshotHealNode(restarted.db, root) != nil {
+		t.Fatalf("healing checkpoints not cleaned up")
+	}
+}
+
 // TestMultiSyncManyUseless contains one good peer, and many which doesn't return anything valuable at all
 func TestMultiSyncManyUseless(t *testing.T) {
 	t.Parallel()
@@ -34,6 +34,7 @@ import (
 const (
 	forceSyncCycle      = 10 * time.Second // Time interval to force syncs, even if few peers are available
 	defaultMinSyncPeers = 5                // Amount of peers desired to start syncing
+	syncTargetTimeout   = 5 * time.Minute  // Time after which an unreached requested sync target is dropped
 
 	// This is the target size for the packs of transactions sent by txsyncLoop64.
 	// A pack can get larger than this if a single transactions exceeds this size.
@@ -161,6 +162,11 @@ type chainSyncer struct {
 	forced      bool // true when force timer fired
 	peerEventCh chan struct{}
 	doneCh      chan error // non-nil when sync is running
+
+	target       common.Hash      // Block requested to be synced, regardless of peer count and difficulty
+	targetExpiry time.Time        // Time after which the requested block is not synced any more
+	targetFailed bool             // true when a sync cycle missed the target, until the force timer fires
+	targetCh     chan common.Hash // Channel to request syncing a block with
 }
 
 // chainSyncOp is a scheduled sync operation.
@@ -176,6 +182,16 @@ func newChainSyncer(handler *handler) *chainSyncer {
 	return &chainSyncer{
 		handler:     handler,
 		peerEventCh: make(chan struct{}),
+		targetCh:    make(chan common.Hash),
+	}
+}
+
+// requestSync asks for a sync cycle to retrieve the given block and its ancestors,
+// started as soon as there's a single peer to sync with.
+func (cs *chainSyncer) requestSync(target common.Hash) {
+	select {
+	case cs.targetCh <- target:
+	case <-cs.handler.quitSync:
 	}
 }
 
@@ -191,6 +207,12 @@ func (cs *chainSyncer) handlePeerEvent(peer *eth.Peer) bool {
 	}
 }
 
+// hasBlock reports whether the given block is available locally. Its state might
+// be missing if it's on a side chain, but that's not something to sync for.
+func (cs *chainSyncer) hasBlock(hash common.Hash) bool {
+	return cs.handler.chain.GetBlockByHash(hash) != nil
+}
+
 // loop runs in its own goroutine and launches the sync when necessary.
 func (cs *chainSyncer) loop() {
 	defer cs.handler.wg.Done()
@@ -213,12 +235,27 @@ func (cs *chainSyncer) loop() {
 		select {
 		case <-cs.peerEventCh:
 			// Peer information changed, recheck.
+		case target := <-cs.targetCh:
+			if !cs.hasBlock(target) {
+				cs.target, cs.targetExpiry, cs.targetFailed = target, time.Now().Add(syncTargetTimeout), false
+			}
 		case <-cs.doneCh:
 			cs.doneCh = nil
 			cs.force.Reset(forceSyncCycle)
 			cs.forced = false
+
+			// Drop the requested target once reached, otherwise retry it only after
+			// the force timer fires to avoid hammering peers not knowing about it
+			if cs.target != (common.Hash{}) {
+				if cs.hasBlock(cs.target) {
+					cs.target = common.Hash{}
+				} else {
+					cs.targetFailed = true
+				}
+			}
 		case <-cs.force.C:
 			cs.forced = true
+			cs.targetFailed = false
 
 		case <-cs.handler.quitSync:
 			// Disable all insertion on the blockchain. This needs to happen before
@@ -239,7 +276,13 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 	if cs.doneCh != nil {
 		return nil // Sync already running.
 	}
-
+	if cs.target != (common.Hash{}) && time.Now().After(cs.targetExpiry) {
+		log.Warn("Requested sync target not reached, dropping", "hash", cs.target)
+		cs.target = common.Hash{}
+	}
+	if cs.target != (common.Hash{}) && !cs.targetFailed {
+		return cs.targetSyncOp()
+	}
 	// Ensure we're at minimum peer count.
 	minPeers := defaultMinSyncPeers
 	if cs.forced {
@@ -267,6 +310,26 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 	return op
 }
 
+// targetSyncOp creates a sync operation towards the explicitly requested target
+// block, regardless of its total difficulty. The block is retrieved from a peer
+// having it as its head if possible, or otherwise from the best peer, hoping it
+// is part of its canonical chain.
+func (cs *chainSyncer) targetSyncOp() *chainSyncOp {
+	peer := cs.handler.peers.peerWithHead(cs.target)
+	if peer == nil {
+		if peer = cs.handler.peers.peerWithHighestTD(); peer == nil {
+			return nil
+		}
+	}
+	mode, ourTD := cs.modeAndLocalHead()
+	if mode == downloader.FastSync && atomic.LoadUint32(&cs.handler.snapSync) == 1 {
+		mode = downloader.SnapSync
+	}
+	// The target might be on a lighter branch than our head, use our own total
+	// difficulty so the peer isn't deemed stalling for not delivering more
+	return &chainSyncOp{mode: mode, peer: peer, td: ourTD, head: cs.target}
+}
+
 func peerToSyncOp(mode downloader.SyncMode, p *eth.Peer) *chainSyncOp {
 	peerHead, peerTD := p.Head()
 	return &chainSyncOp{mode: mode, peer: p, td: peerTD, head: peerHead}
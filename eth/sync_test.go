@@ -21,10 +21,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // Tests that fast sync is disabled after a successful sync cycle.
@@ -78,3 +82,64 @@ func testFastSyncDisabling(t *testing.T, protocol uint) {
 		t.Fatalf("fast sync not disabled after successful synchronisation")
 	}
 }
+
+// Tests that an explicitly requested sync target is retrieved through the
+// downloader, even if it's on a branch lighter than the local chain.
+func TestSyncToTarget65(t *testing.T) { testSyncToTarget(t, eth.ETH65) }
+func TestSyncToTarget66(t *testing.T) { testSyncToTarget(t, eth.ETH66) }
+
+func testSyncToTarget(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	// Create a local handler with a heavier chain than the remote one
+	local := newTestHandlerWithBlocks(12)
+	defer local.close()
+
+	remote := newTestHandler()
+	defer remote.close()
+
+	blocks, _ := core.GenerateChain(params.TestChainConfig, remote.chain.Genesis(), ethash.NewFaker(), remote.db, 10, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	if _, err := remote.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert remote chain: %v", err)
+	}
+	target := blocks[len(blocks)-1]
+	if local.chain.GetBlockByHash(target.Hash()) != nil {
+		t.Fatalf("target block known before sync")
+	}
+	// Connect the two handlers and request the lighter remote head
+	localPipe, remotePipe := p2p.MsgPipe()
+	defer localPipe.Close()
+	defer remotePipe.Close()
+
+	localPeer := eth.NewPeer(protocol, p2p.NewPeer(enode.ID{1}, "", nil), localPipe, local.txpool)
+	remotePeer := eth.NewPeer(protocol, p2p.NewPeer(enode.ID{2}, "", nil), remotePipe, remote.txpool)
+	defer localPeer.Close()
+	defer remotePeer.Close()
+
+	go local.handler.runEthPeer(localPeer, func(peer *eth.Peer) error {
+		return eth.Handle((*ethHandler)(local.handler), peer)
+	})
+	go remote.handler.runEthPeer(remotePeer, func(peer *eth.Peer) error {
+		return eth.Handle((*ethHandler)(remote.handler), peer)
+	})
+	head := local.chain.CurrentBlock()
+	local.handler.chainSync.requestSync(target.Hash())
+
+	// Wait for the target and all its ancestors to be synced
+	for start := time.Now(); local.chain.GetBlockByHash(target.Hash()) == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("sync target not retrieved")
+		}
+	}
+	for _, block := range blocks {
+		if local.chain.GetBlockByHash(block.Hash()) == nil {
+			t.Errorf("block #%d missing after sync", block.NumberU64())
+		}
+	}
+	// The lighter branch must not have replaced the local head
+	if current := local.chain.CurrentBlock(); current.Hash() != head.Hash() {
+		t.Errorf("head changed: have #%d [%x], want #%d [%x]", current.NumberU64(), current.Hash(), head.NumberU64(), head.Hash())
+	}
+}
@@ -79,7 +79,8 @@ func (ec *Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Blo
 }
 
 // BlockByNumber returns a block from the current canonical chain. If number is nil, the
-// latest known block is returned.
+// latest known block is returned. Passing rpc.FinalizedBlockNumber or rpc.SafeBlockNumber
+// returns the latest finalized or safe block.
 //
 // Note that loading full blocks requires two requests. Use HeaderByNumber
 // if you don't need all transactions or uncle headers.
@@ -176,7 +177,8 @@ func (ec *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.He
 }
 
 // HeaderByNumber returns a block header from the current canonical chain. If number is
-// nil, the latest known header is returned.
+// nil, the latest known header is returned. Passing rpc.FinalizedBlockNumber or
+// rpc.SafeBlockNumber returns the latest finalized or safe header.
 func (ec *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
 	var head *types.Header
 	err := ec.c.CallContext(ctx, &head, "eth_getBlockByNumber", toBlockNumArg(number), false)
@@ -292,6 +294,14 @@ func toBlockNumArg(number *big.Int) string {
 	if number.Cmp(pending) == 0 {
 		return "pending"
 	}
+	if number.IsInt64() {
+		switch rpc.BlockNumber(number.Int64()) {
+		case rpc.FinalizedBlockNumber:
+			return "finalized"
+		case rpc.SafeBlockNumber:
+			return "safe"
+		}
+	}
 	return hexutil.EncodeBig(number)
 }
 
@@ -301,6 +311,19 @@ type rpcProgress struct {
 	HighestBlock  hexutil.Uint64
 	PulledStates  hexutil.Uint64
 	KnownStates   hexutil.Uint64
+
+	SyncedAccounts      hexutil.Uint64
+	SyncedAccountBytes  hexutil.Uint64
+	SyncedBytecodes     hexutil.Uint64
+	SyncedBytecodeBytes hexutil.Uint64
+	SyncedStorage       hexutil.Uint64
+	SyncedStorageBytes  hexutil.Uint64
+	HealedTrienodes     hexutil.Uint64
+	HealedTrienodeBytes hexutil.Uint64
+	HealedBytecodes     hexutil.Uint64
+	HealedBytecodeBytes hexutil.Uint64
+	HealingTrienodes    hexutil.Uint64
+	HealingBytecode     hexutil.Uint64
 }
 
 // SyncProgress retrieves the current progress of the sync algorithm. If there's
@@ -325,6 +348,19 @@ func (ec *Client) SyncProgress(ctx context.Context) (*ethereum.SyncProgress, err
 		HighestBlock:  uint64(progress.HighestBlock),
 		PulledStates:  uint64(progress.PulledStates),
 		KnownStates:   uint64(progress.KnownStates),
+
+		SyncedAccounts:      uint64(progress.SyncedAccounts),
+		SyncedAccountBytes:  uint64(progress.SyncedAccountBytes),
+		SyncedBytecodes:     uint64(progress.SyncedBytecodes),
+		SyncedBytecodeBytes: uint64(progress.SyncedBytecodeBytes),
+		SyncedStorage:       uint64(progress.SyncedStorage),
+		SyncedStorageBytes:  uint64(progress.SyncedStorageBytes),
+		HealedTrienodes:     uint64(progress.HealedTrienodes),
+		HealedTrienodeBytes: uint64(progress.HealedTrienodeBytes),
+		HealedBytecodes:     uint64(progress.HealedBytecodes),
+		HealedBytecodeBytes: uint64(progress.HealedBytecodeBytes),
+		HealingTrienodes:    uint64(progress.HealingTrienodes),
+		HealingBytecode:     uint64(progress.HealingBytecode),
 	}, nil
 }
 
@@ -209,6 +209,9 @@ func newTestBackend(t *testing.T) (*node.Node, []*types.Block) {
 	if _, err := ethservice.BlockChain().InsertChain(blocks[1:]); err != nil {
 		t.Fatalf("can't import test blocks: %v", err)
 	}
+	if err := ethservice.BlockChain().SetFinalized(blocks[1]); err != nil {
+		t.Fatalf("can't finalize test block: %v", err)
+	}
 	return n, blocks
 }
 
@@ -292,6 +295,14 @@ func testHeader(t *testing.T, chain []*types.Block, client *rpc.Client) {
 			want:    nil,
 			wantErr: ethereum.NotFound,
 		},
+		"finalized_block": {
+			block: big.NewInt(int64(rpc.FinalizedBlockNumber)),
+			want:  chain[1].Header(),
+		},
+		"safe_block": {
+			block: big.NewInt(int64(rpc.SafeBlockNumber)),
+			want:  chain[1].Header(),
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -972,6 +972,7 @@ type Resolver struct {
 func (r *Resolver) Block(ctx context.Context, args struct {
 	Number *Long
 	Hash   *common.Hash
+	Tag    *string
 }) (*Block, error) {
 	var block *Block
 	if args.Number != nil {
@@ -990,6 +991,25 @@ func (r *Resolver) Block(ctx context.Context, args struct {
 			backend:      r.backend,
 			numberOrHash: &numberOrHash,
 		}
+	} else if args.Tag != nil && *args.Tag != "LATEST" {
+		// Pin the tagged block by hash, so it doesn't move while resolving
+		number := rpc.FinalizedBlockNumber
+		if *args.Tag == "SAFE" {
+			number = rpc.SafeBlockNumber
+		}
+		header, err := r.backend.HeaderByNumber(ctx, number)
+		if err != nil {
+			return nil, err
+		} else if header == nil {
+			return nil, nil
+		}
+		numberOrHash := rpc.BlockNumberOrHashWithHash(header.Hash(), false)
+		block = &Block{
+			backend:      r.backend,
+			numberOrHash: &numberOrHash,
+			hash:         header.Hash(),
+			header:       header,
+		}
 	} else {
 		numberOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
 		block = &Block{
@@ -1083,27 +1103,89 @@ type FilterCriteria struct {
 	Topics *[][]common.Hash
 }
 
-func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+// rangeFilter converts the root `logs` criteria into a range filter.
+func (r *Resolver) rangeFilter(crit FilterCriteria) *filters.Filter {
 	// Convert the RPC block numbers into internal representations
 	begin := rpc.LatestBlockNumber.Int64()
-	if args.Filter.FromBlock != nil {
-		begin = int64(*args.Filter.FromBlock)
+	if crit.FromBlock != nil {
+		begin = int64(*crit.FromBlock)
 	}
 	end := rpc.LatestBlockNumber.Int64()
-	if args.Filter.ToBlock != nil {
-		end = int64(*args.Filter.ToBlock)
+	if crit.ToBlock != nil {
+		end = int64(*crit.ToBlock)
 	}
 	var addresses []common.Address
-	if args.Filter.Addresses != nil {
-		addresses = *args.Filter.Addresses
+	if crit.Addresses != nil {
+		addresses = *crit.Addresses
 	}
 	var topics [][]common.Hash
-	if args.Filter.Topics != nil {
-		topics = *args.Filter.Topics
+	if crit.Topics != nil {
+		topics = *crit.Topics
 	}
 	// Construct the range filter
-	filter := filters.NewRangeFilter(filters.Backend(r.backend), begin, end, addresses, topics)
-	return runFilter(ctx, r.backend, filter)
+	return filters.NewRangeFilter(filters.Backend(r.backend), begin, end, addresses, topics)
+}
+
+func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+	return runFilter(ctx, r.backend, r.rangeFilter(args.Filter))
+}
+
+// LogPage represents a bounded chunk of log query results returned from the
+// `logsPage` accessor.
+type LogPage struct {
+	logs []*Log
+	next *filters.LogCursor
+}
+
+func (p *LogPage) Logs() []*Log {
+	return p.logs
+}
+
+func (p *LogPage) Next() (*hexutil.Bytes, error) {
+	if p.next == nil {
+		return nil, nil
+	}
+	text, err := p.next.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	var blob hexutil.Bytes
+	if err := blob.UnmarshalText(text); err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+func (r *Resolver) LogsPage(ctx context.Context, args struct {
+	Filter FilterCriteria
+	Cursor *hexutil.Bytes
+	Limit  *hexutil.Uint64
+}) (*LogPage, error) {
+	var cursor *filters.LogCursor
+	if args.Cursor != nil {
+		text, _ := args.Cursor.MarshalText()
+		cursor = new(filters.LogCursor)
+		if err := cursor.UnmarshalText(text); err != nil {
+			return nil, err
+		}
+	}
+	var limit uint64
+	if args.Limit != nil {
+		limit = uint64(*args.Limit)
+	}
+	page, err := r.rangeFilter(args.Filter).Page(ctx, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]*Log, 0, len(page.Logs))
+	for _, log := range page.Logs {
+		logs = append(logs, &Log{
+			backend:     r.backend,
+			transaction: &Transaction{backend: r.backend, hash: log.TxHash},
+			log:         log,
+		})
+	}
+	return &LogPage{logs: logs, next: page.Next}, nil
 }
 
 func (r *Resolver) GasPrice(ctx context.Context) (hexutil.Big, error) {
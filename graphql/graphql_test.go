@@ -126,6 +126,11 @@ func TestGraphQLBlockSerialization(t *testing.T) {
 			want: `{"errors":[{"message":"strconv.ParseInt: parsing \"a\": invalid syntax"}],"data":{}}`,
 			code: 400,
 		},
+		{ // Should return the tagged blocks
+			body: `{"query": "{latest: block(tag:LATEST){number} finalized: block(tag:FINALIZED){number} safe: block(tag:SAFE){number}}","variables": null}`,
+			want: `{"data":{"latest":{"number":10},"finalized":{"number":5},"safe":{"number":5}}}`,
+			code: 200,
+		},
 		{
 			body: `{"query": "{bleh{number}}","variables": null}"`,
 			want: `{"errors":[{"message":"Cannot query field \"bleh\" on type \"Query\".","locations":[{"line":1,"column":2}]}]}`,
@@ -143,6 +148,12 @@ func TestGraphQLBlockSerialization(t *testing.T) {
 			want: `{"data":{"block":{"number":10,"call":{"data":"0x","status":1}}}}`,
 			code: 200,
 		},
+		// should return an exhausted page if there are no logs
+		{
+			body: `{"query": "{logsPage(filter:{fromBlock:0}, limit:5){logs{index} next}}"}`,
+			want: `{"data":{"logsPage":{"logs":[],"next":null}}}`,
+			code: 200,
+		},
 	} {
 		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
 		if err != nil {
@@ -264,6 +275,9 @@ func createGQLService(t *testing.T, stack *node.Node) {
 	if err != nil {
 		t.Fatalf("could not create import blocks: %v", err)
 	}
+	if err := ethBackend.BlockChain().SetFinalized(chain[4]); err != nil {
+		t.Fatalf("could not finalize block: %v", err)
+	}
 	// create gql service
 	err = New(stack, ethBackend.APIBackend, []string{}, []string{})
 	if err != nil {
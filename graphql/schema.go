@@ -272,6 +272,15 @@ const schema string = `
         topics: [[Bytes32!]!]
     }
 
+    # LogPage is a bounded chunk of the results of a log query.
+    type LogPage {
+        # Logs is the list of log entries in this page.
+        logs: [Log!]!
+        # Next is the cursor to pass to retrieve the following page, or null
+        # if the query is exhausted.
+        next: Bytes
+    }
+
     # SyncState contains the current synchronisation state of the client.
     type SyncState{
         # StartingBlock is the block number at which synchronisation started.
@@ -303,10 +312,20 @@ const schema string = `
       estimateGas(data: CallData!): Long!
     }
 
+    # BlockTag selects a block by its position relative to the chain head.
+    enum BlockTag {
+      # Latest is the most recent known block.
+      LATEST
+      # Finalized is the most recent block considered final by the consensus.
+      FINALIZED
+      # Safe is the most recent block considered safe from reorgs.
+      SAFE
+    }
+
     type Query {
-        # Block fetches an Ethereum block by number or by hash. If neither is
-        # supplied, the most recent known block is returned.
-        block(number: Long, hash: Bytes32): Block
+        # Block fetches an Ethereum block by number, by hash or by tag. If none
+        # is supplied, the most recent known block is returned.
+        block(number: Long, hash: Bytes32, tag: BlockTag): Block
         # Blocks returns all the blocks between two numbers, inclusive. If
         # to is not supplied, it defaults to the most recent known block.
         blocks(from: Long, to: Long): [Block!]!
@@ -316,6 +335,10 @@ const schema string = `
         transaction(hash: Bytes32!): Transaction
         # Logs returns log entries matching the provided filter.
         logs(filter: FilterCriteria!): [Log!]!
+        # LogsPage returns a bounded page of log entries matching the provided
+        # filter, resuming from the given cursor if supplied. If limit is not
+        # supplied, the node's result cap is used as the page size.
+        logsPage(filter: FilterCriteria!, cursor: Bytes, limit: Long): LogPage!
         # GasPrice returns the node's estimate of a gas price sufficient to
         # ensure a transaction is mined in a timely fashion.
         gasPrice: BigInt!
@@ -47,6 +47,8 @@ type Backend interface {
 	ExtRPCEnabled() bool
 	RPCGasCap() uint64        // global gas cap for eth_call over rpc: DoS protection
 	RPCTxFeeCap() float64     // global tx fee cap for all transaction related APIs
+	RPCLogBlockRange() uint64 // global block range cap for log queries: DoS protection
+	RPCLogResultCap() uint64  // global result count cap for log queries: DoS protection
 	UnprotectedAllowed() bool // allows only for EIP155 transactions.
 
 	// Blockchain API
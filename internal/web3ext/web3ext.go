@@ -20,6 +20,7 @@ package web3ext
 var Modules = map[string]string{
 	"accounting": AccountingJs,
 	"admin":      AdminJs,
+	"bft":        BFTJs,
 	"chequebook": ChequebookJs,
 	"clique":     CliqueJs,
 	"ethash":     EthashJs,
@@ -118,6 +119,34 @@ web3._extend({
 });
 `
 
+const BFTJs = `
+web3._extend({
+	property: 'bft',
+	methods: [
+		new web3._extend.Method({
+			name: 'getFinality',
+			call: 'bft_getFinality',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'validators',
+			getter: 'bft_getValidators'
+		}),
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'bft_getStatus'
+		}),
+		new web3._extend.Property({
+			name: 'finalized',
+			getter: 'bft_getFinalized'
+		}),
+	]
+});
+`
+
 const EthashJs = `
 web3._extend({
 	property: 'ethash',
@@ -170,6 +199,28 @@ web3._extend({
 			call: 'admin_removeTrustedPeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unbanPeer',
+			call: 'admin_unbanPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'banNetwork',
+			call: 'admin_banNetwork',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unbanNetwork',
+			call: 'admin_unbanNetwork',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -232,6 +283,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'bans',
+			getter: 'admin_bans'
+		}),
 	]
 });
 `
@@ -482,6 +537,10 @@ web3._extend({
 			call: 'debug_freezeClient',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'snapSyncProgress',
+			call: 'debug_snapSyncProgress',
+		}),
 	],
 	properties: []
 });
@@ -18,6 +18,7 @@
 package les
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -94,6 +95,11 @@ func New(stack *node.Node, config *ethconfig.Config) (*LightEthereum, error) {
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
+	// Contract governed clique checkpoints are only verified when executing blocks
+	if chainConfig.Clique != nil && chainConfig.Clique.SignerContract != nil {
+		return nil, errors.New("clique signer contract requires full sync, not light sync")
+	}
+
 	peers := newServerPeerSet()
 	leth := &LightEthereum{
 		lesCommons: lesCommons{
@@ -19,7 +19,9 @@ package node
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -125,6 +127,144 @@ func (api *privateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// BanPeer prevents a remote node, given as enode URL or node ID, from connecting
+// and disconnects it. The ban is permanent unless a duration (e.g. "24h") is
+// given.
+func (api *privateAdminAPI) BanPeer(id string, duration *string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	nodeID, err := parseNodeID(id)
+	if err != nil {
+		return false, err
+	}
+	until, err := banExpiry(duration)
+	if err != nil {
+		return false, err
+	}
+	if err := server.BanNode(nodeID, until); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanPeer lifts the ban of a remote node, given as enode URL or node ID.
+func (api *privateAdminAPI) UnbanPeer(id string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	nodeID, err := parseNodeID(id)
+	if err != nil {
+		return false, err
+	}
+	if err := server.UnbanNode(nodeID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BanNetwork prevents all nodes within a network, given in CIDR notation or as
+// a single IP address, from connecting and disconnects them. The ban is
+// permanent unless a duration (e.g. "24h") is given.
+func (api *privateAdminAPI) BanNetwork(cidr string, duration *string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	network, err := parseNetwork(cidr)
+	if err != nil {
+		return false, err
+	}
+	until, err := banExpiry(duration)
+	if err != nil {
+		return false, err
+	}
+	if err := server.BanNetwork(network, until); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanNetwork lifts the ban of a network, given in CIDR notation or as a single
+// IP address. Bans of other networks overlapping with it are left in place.
+func (api *privateAdminAPI) UnbanNetwork(cidr string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	network, err := parseNetwork(cidr)
+	if err != nil {
+		return false, err
+	}
+	if err := server.UnbanNetwork(network); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Bans retrieves all nodes and networks which are currently banned, including
+// nodes banned for misbehaving.
+func (api *privateAdminAPI) Bans() ([]p2p.BanInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.Bans()
+}
+
+// parseNodeID parses a node ID given either as enode URL or in hex.
+func parseNodeID(id string) (enode.ID, error) {
+	if node, err := enode.Parse(enode.ValidSchemes, id); err == nil {
+		return node.ID(), nil
+	}
+	nodeID, err := enode.ParseID(id)
+	if err != nil {
+		return enode.ID{}, fmt.Errorf("invalid node: %v", err)
+	}
+	return nodeID, nil
+}
+
+// parseNetwork parses a network given in CIDR notation or as single IP address.
+func parseNetwork(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", cidr)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network: %v", err)
+	}
+	return network, nil
+}
+
+// banExpiry returns the expiry time of a ban with an optional duration. The
+// zero time is returned for permanent bans.
+func banExpiry(duration *string) (time.Time, error) {
+	if duration == nil || *duration == "" {
+		return time.Time{}, nil
+	}
+	d, err := time.ParseDuration(*duration)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid ban duration: %v", err)
+	}
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("invalid ban duration: %v", d)
+	}
+	return time.Now().Add(d), nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *privateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -24,7 +24,11 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/stretchr/testify/assert"
 )
@@ -296,6 +300,167 @@ func TestStartRPC(t *testing.T) {
 	}
 }
 
+// This test uses the admin ban APIs, checking that bans of nodes and networks
+// are applied and lifted on the p2p server.
+func TestAdminBans(t *testing.T) {
+	stack, err := New(&Config{P2P: p2p.Config{NoDiscovery: true, NoDial: true}})
+	if err != nil {
+		t.Fatal("can't create node:", err)
+	}
+	defer stack.Close()
+
+	api := &privateAdminAPI{stack}
+	if _, err := api.BanPeer(enode.ID{1}.String(), nil); err == nil {
+		t.Fatal("ban accepted by stopped node")
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatal("can't start node:", err)
+	}
+	// Invalid arguments are rejected
+	if _, err := api.BanPeer("0x1234", nil); err == nil {
+		t.Error("invalid node ID accepted")
+	}
+	if _, err := api.BanNetwork("10.1.0.0/33", nil); err == nil {
+		t.Error("invalid network accepted")
+	}
+	if _, err := api.BanNetwork("10.1.0.0/16", sp("-1h")); err == nil {
+		t.Error("negative ban duration accepted")
+	}
+	if _, err := api.BanPeer(enode.ID{1}.String(), sp("forever")); err == nil {
+		t.Error("invalid ban duration accepted")
+	}
+	// Ban nodes and networks in all supported notations
+	key, _ := crypto.GenerateKey()
+	url := enode.NewV4(&key.PublicKey, net.ParseIP("1.2.3.4"), 30303, 30303).URLv4()
+
+	_, err = api.BanPeer(url, nil)
+	assert.NoError(t, err)
+	_, err = api.BanPeer(enode.ID{1}.String(), sp("1h"))
+	assert.NoError(t, err)
+	_, err = api.BanNetwork("10.1.0.0/16", nil)
+	assert.NoError(t, err)
+	_, err = api.BanNetwork("10.2.0.1", sp("1h"))
+	assert.NoError(t, err)
+
+	bans, err := api.Bans()
+	assert.NoError(t, err)
+	if len(bans) != 4 {
+		t.Fatalf("wrong number of bans: %+v", bans)
+	}
+	nodes := map[enode.ID]bool{}
+	for _, ban := range bans[:2] {
+		if ban.ID == nil {
+			t.Fatalf("wrong node ban: %+v", ban)
+		}
+		nodes[*ban.ID] = ban.Until != nil
+	}
+	if expiring, ok := nodes[enode.PubkeyToIDV4(&key.PublicKey)]; !ok || expiring {
+		t.Errorf("missing permanent node ban: %+v", bans)
+	}
+	if expiring, ok := nodes[enode.ID{1}]; !ok || !expiring {
+		t.Errorf("missing expiring node ban: %+v", bans)
+	}
+	if bans[2].Network != "10.1.0.0/16" || bans[2].Until != nil {
+		t.Errorf("wrong network ban: %+v", bans[2])
+	}
+	if bans[3].Network != "10.2.0.1/32" || bans[3].Until == nil {
+		t.Errorf("wrong single address ban: %+v", bans[3])
+	}
+	// Lift the bans again
+	_, err = api.UnbanPeer(url)
+	assert.NoError(t, err)
+	_, err = api.UnbanNetwork("10.2.0.1")
+	assert.NoError(t, err)
+
+	bans, err = api.Bans()
+	assert.NoError(t, err)
+	if len(bans) != 2 || bans[0].ID == nil || *bans[0].ID != (enode.ID{1}) || bans[1].Network != "10.1.0.0/16" {
+		t.Fatalf("wrong bans after unbanning: %+v", bans)
+	}
+}
+
+// This test checks that a node unbanned using the admin API gets a clean
+// reputation, instead of being banned again by its next negative report.
+func TestAdminUnbanResetsScore(t *testing.T) {
+	stack, err := New(&Config{P2P: p2p.Config{ListenAddr: "127.0.0.1:0", NoDiscovery: true, NoDial: true, MaxPeers: 10}})
+	if err != nil {
+		t.Fatal("can't create node:", err)
+	}
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatal("can't start node:", err)
+	}
+	api := &privateAdminAPI{stack}
+	server := stack.Server()
+
+	events := make(chan *p2p.PeerEvent, 10)
+	sub := server.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	waitEvent := func(typ p2p.PeerEventType, id enode.ID, timeout time.Duration) bool {
+		deadline := time.After(timeout)
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == typ && ev.Peer == id {
+					return true
+				}
+			case <-deadline:
+				return false
+			}
+		}
+	}
+	// connect starts a remote server with the given key, dialing the node
+	key, _ := crypto.GenerateKey()
+	id := enode.PubkeyToIDV4(&key.PublicKey)
+	connect := func() (*p2p.Server, *p2p.Peer) {
+		remote := &p2p.Server{Config: p2p.Config{PrivateKey: key, MaxPeers: 1, NoDiscovery: true}}
+		if err := remote.Start(); err != nil {
+			t.Fatal("can't start remote server:", err)
+		}
+		remote.AddPeer(server.Self())
+		if !waitEvent(p2p.PeerEventTypeAdd, id, 5*time.Second) {
+			remote.Stop()
+			t.Fatal("remote peer not connected")
+		}
+		for _, p := range server.Peers() {
+			if p.ID() == id {
+				return remote, p
+			}
+		}
+		remote.Stop()
+		t.Fatal("remote peer not found")
+		return nil, nil
+	}
+	// Get the remote peer banned for misbehaving, then unban it
+	remote, peer := connect()
+	for i := 0; i < 3; i++ {
+		peer.Report(p2p.ScoreProtocolViolation)
+	}
+	if !waitEvent(p2p.PeerEventTypeDrop, id, 5*time.Second) {
+		t.Fatal("misbehaving peer not dropped")
+	}
+	remote.Stop()
+
+	_, err = api.UnbanPeer(id.String())
+	assert.NoError(t, err)
+
+	// A reconnected peer should survive a minor offence
+	remote, peer = connect()
+	defer remote.Stop()
+
+	if score := peer.Score(); score != 0 {
+		t.Errorf("score not reset on unban: have %v, want 0", score)
+	}
+	peer.Report(p2p.ScoreUselessDelivery)
+	if waitEvent(p2p.PeerEventTypeDrop, id, 500*time.Millisecond) {
+		t.Fatal("unbanned peer dropped after useless delivery")
+	}
+	bans, err := api.Bans()
+	assert.NoError(t, err)
+	assert.Empty(t, bans)
+}
+
 // checkReachable checks if the TCP endpoint in rawurl is open.
 func checkReachable(rawurl string) bool {
 	u, err := url.Parse(rawurl)
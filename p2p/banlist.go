@@ -0,0 +1,191 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// permanentBan is the expiry time stored for bans without an expiry.
+var permanentBan = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// BanInfo describes a node or network banned from connecting.
+type BanInfo struct {
+	ID      *enode.ID  `json:"id,omitempty"`      // Banned node, nil for network bans
+	Network string     `json:"network,omitempty"` // Banned network in CIDR notation
+	Until   *time.Time `json:"until,omitempty"`   // Expiry of the ban, nil if permanent
+}
+
+func newBanInfo(until time.Time) BanInfo {
+	if until.Equal(permanentBan) {
+		return BanInfo{}
+	}
+	until = until.UTC()
+	return BanInfo{Until: &until}
+}
+
+// netBan is a banned network.
+type netBan struct {
+	network *net.IPNet
+	until   time.Time
+}
+
+// banList manages the nodes and networks banned at runtime. Node bans are shared
+// with the reputation system and live in the node database only. Network bans
+// are persisted in the database as well, but also cached in memory as they are
+// checked for every connection attempt and discovered node.
+type banList struct {
+	db  *enode.DB
+	now func() time.Time
+	log log.Logger
+
+	lock sync.RWMutex
+	nets map[string]*netBan
+}
+
+func newBanList(db *enode.DB, log log.Logger) *banList {
+	bl := &banList{
+		db:   db,
+		now:  time.Now,
+		log:  log,
+		nets: make(map[string]*netBan),
+	}
+	for cidr, until := range db.NetBans() {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Warn("Dropping invalid network ban", "network", cidr, "err", err)
+			db.UpdateNetBan(cidr, time.Time{})
+			continue
+		}
+		bl.nets[cidr] = &netBan{network: network, until: until}
+	}
+	return bl
+}
+
+// banNode bans a node until the given time, or permanently for the zero time.
+// Existing bans are replaced.
+func (bl *banList) banNode(id enode.ID, until time.Time) error {
+	if until.IsZero() {
+		until = permanentBan
+	}
+	return bl.db.UpdateBannedUntil(id, until)
+}
+
+// unbanNode lifts the ban of a node.
+func (bl *banList) unbanNode(id enode.ID) error {
+	return bl.db.UpdateBannedUntil(id, time.Time{})
+}
+
+// banNetwork bans a network until the given time, or permanently for the zero
+// time. Existing bans of the network are replaced.
+func (bl *banList) banNetwork(network *net.IPNet, until time.Time) error {
+	if until.IsZero() {
+		until = permanentBan
+	}
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+
+	cidr := network.String()
+	if err := bl.db.UpdateNetBan(cidr, until); err != nil {
+		return err
+	}
+	bl.nets[cidr] = &netBan{network: network, until: until}
+	return nil
+}
+
+// unbanNetwork lifts the ban of a network. Bans of networks overlapping with it
+// are left in place.
+func (bl *banList) unbanNetwork(network *net.IPNet) error {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+
+	cidr := network.String()
+	if err := bl.db.UpdateNetBan(cidr, time.Time{}); err != nil {
+		return err
+	}
+	delete(bl.nets, cidr)
+	return nil
+}
+
+// bannedIP returns whether the IP is contained in a banned network.
+func (bl *banList) bannedIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	bl.lock.RLock()
+	defer bl.lock.RUnlock()
+
+	now := bl.now()
+	for _, ban := range bl.nets {
+		if ban.until.After(now) && ban.network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// bannedNode returns whether the node itself is banned, regardless of its network.
+func (bl *banList) bannedNode(id enode.ID) bool {
+	return bl.db.BannedUntil(id).After(bl.now())
+}
+
+// banned returns whether the node or its network is banned.
+func (bl *banList) banned(n *enode.Node) bool {
+	return bl.bannedNode(n.ID()) || bl.bannedIP(n.IP())
+}
+
+// list returns all active bans.
+func (bl *banList) list() []BanInfo {
+	var (
+		bans []BanInfo
+		now  = bl.now()
+	)
+	for id, until := range bl.db.Bans() {
+		if until.After(now) {
+			id := id
+			ban := newBanInfo(until)
+			ban.ID = &id
+			bans = append(bans, ban)
+		}
+	}
+	bl.lock.RLock()
+	for cidr, ban := range bl.nets {
+		if ban.until.After(now) {
+			info := newBanInfo(ban.until)
+			info.Network = cidr
+			bans = append(bans, info)
+		}
+	}
+	bl.lock.RUnlock()
+
+	sort.Slice(bans, func(i, j int) bool {
+		if (bans[i].ID == nil) != (bans[j].ID == nil) {
+			return bans[i].ID != nil
+		}
+		if bans[i].ID != nil {
+			return bans[i].ID.String() < bans[j].ID.String()
+		}
+		return bans[i].Network < bans[j].Network
+	})
+	return bans
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestServerBans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-bans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		key       = newkey()
+		remote    = newkey()
+		trustedID = randomID()
+	)
+	startServer := func() *Server {
+		srv := &Server{
+			Config: Config{
+				PrivateKey:   key,
+				MaxPeers:     10,
+				NoDial:       true,
+				NoDiscovery:  true,
+				NodeDatabase: filepath.Join(dir, "nodes"),
+				TrustedNodes: []*enode.Node{enode.SignNull(new(enr.Record), trustedID)},
+				Logger:       testlog.Logger(t, log.LvlTrace),
+			},
+		}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start: %v", err)
+		}
+		return srv
+	}
+	newconn := func(id enode.ID, ip string) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		var r enr.Record
+		r.Set(enr.IP(net.ParseIP(ip)))
+		node := enode.SignNull(&r, id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	var (
+		bannedID   = randomID()
+		expiringID = randomID()
+		network    = mustParseCIDR(t, "10.1.0.0/16")
+	)
+	srv := startServer()
+	if err := srv.BanNode(bannedID, time.Time{}); err != nil {
+		t.Fatalf("could not ban node: %v", err)
+	}
+	if err := srv.BanNode(trustedID, time.Time{}); err != nil {
+		t.Fatalf("could not ban node: %v", err)
+	}
+	if err := srv.BanNode(expiringID, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("could not ban node: %v", err)
+	}
+	if err := srv.BanNetwork(network, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("could not ban network: %v", err)
+	}
+	srv.Stop()
+
+	// Bans are persisted across restarts and enforced for inbound connections
+	srv = startServer()
+	defer srv.Stop()
+
+	bans, err := srv.Bans()
+	if err != nil {
+		t.Fatalf("could not list bans: %v", err)
+	}
+	if len(bans) != 3 || bans[0].ID == nil || bans[1].ID == nil || bans[2].Network != "10.1.0.0/16" || bans[2].Until == nil {
+		t.Fatalf("wrong bans: %+v", bans)
+	}
+	for _, ban := range bans[:2] {
+		if (*ban.ID != bannedID && *ban.ID != trustedID) || ban.Until != nil {
+			t.Fatalf("wrong node ban: %+v", ban)
+		}
+	}
+	if err := srv.checkpoint(newconn(bannedID, "1.2.3.4"), srv.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Fatalf("wrong error for banned node: %v", err)
+	}
+	if err := srv.checkpoint(newconn(randomID(), "10.1.2.3"), srv.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Fatalf("wrong error for banned network: %v", err)
+	}
+	if err := srv.checkInboundConn(net.ParseIP("10.1.2.3")); err == nil {
+		t.Fatalf("connection from banned network accepted")
+	}
+	if err := srv.checkpoint(newconn(expiringID, "1.2.3.4"), srv.checkpointPostHandshake); err != nil {
+		t.Fatalf("node with expired ban rejected: %v", err)
+	}
+	// Trusted nodes are exempt from node bans, but not from network bans
+	if err := srv.checkpoint(newconn(trustedID, "1.2.3.4"), srv.checkpointPostHandshake); err != nil {
+		t.Fatalf("banned trusted node rejected: %v", err)
+	}
+	if err := srv.checkpoint(newconn(trustedID, "10.1.2.3"), srv.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Fatalf("wrong error for trusted node in banned network: %v", err)
+	}
+	// Dials follow the same rules as inbound connections
+	dialNode := func(id enode.ID, ip string) *enode.Node {
+		var r enr.Record
+		r.Set(enr.IP(net.ParseIP(ip)))
+		return enode.SignNull(&r, id)
+	}
+	if !srv.dialBanned(dialNode(bannedID, "1.2.3.4")) {
+		t.Fatalf("banned node can be dialed")
+	}
+	if !srv.dialBanned(dialNode(randomID(), "10.1.2.3")) {
+		t.Fatalf("node in banned network can be dialed")
+	}
+	if srv.dialBanned(dialNode(trustedID, "1.2.3.4")) {
+		t.Fatalf("banned trusted node can't be dialed")
+	}
+	if !srv.dialBanned(dialNode(trustedID, "10.1.2.3")) {
+		t.Fatalf("trusted node in banned network can be dialed")
+	}
+	// Bans can be lifted
+	if err := srv.UnbanNode(bannedID); err != nil {
+		t.Fatalf("could not unban node: %v", err)
+	}
+	if err := srv.UnbanNetwork(network); err != nil {
+		t.Fatalf("could not unban network: %v", err)
+	}
+	if err := srv.checkpoint(newconn(bannedID, "10.1.2.3"), srv.checkpointPostHandshake); err != nil {
+		t.Fatalf("unbanned node rejected: %v", err)
+	}
+}
+
+func TestServerBanDisconnects(t *testing.T) {
+	remote := newkey()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    10,
+			NoDial:      true,
+			NoDiscovery: true,
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	events := make(chan *PeerEvent, 10)
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	newconn := func(ip string) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		var r enr.Record
+		r.Set(enr.IP(net.ParseIP(ip)))
+		node := enode.SignNull(&r, randomID())
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	first, second := newconn("1.2.3.4"), newconn("5.6.7.8")
+	for _, c := range []*conn{first, second} {
+		if err := srv.checkpoint(c, srv.checkpointAddPeer); err != nil {
+			t.Fatalf("could not add conn: %v", err)
+		}
+	}
+	network := mustParseCIDR(t, "5.6.0.0/16")
+	if err := srv.BanNode(first.node.ID(), time.Time{}); err != nil {
+		t.Fatalf("could not ban node: %v", err)
+	}
+	if err := srv.BanNetwork(network, time.Time{}); err != nil {
+		t.Fatalf("could not ban network: %v", err)
+	}
+	dropped := make(map[enode.ID]bool)
+	timeout := time.After(5 * time.Second)
+	for len(dropped) < 2 {
+		select {
+		case ev := <-events:
+			if ev.Type == PeerEventTypeDrop {
+				dropped[ev.Peer] = true
+			}
+		case <-timeout:
+			t.Fatalf("banned peers not dropped: %v", dropped)
+		}
+	}
+	if !dropped[first.node.ID()] || !dropped[second.node.ID()] {
+		t.Fatalf("wrong peers dropped: %v", dropped)
+	}
+}
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return network
+}
@@ -77,6 +77,8 @@ var (
 	errAlreadyDialing   = errors.New("already dialing")
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
+	errBanned           = errors.New("banned")
+	errGroupFull        = errors.New("peer group is full")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
 	errNoPort           = errors.New("node does not provide TCP port")
 )
@@ -106,9 +108,10 @@ type dialScheduler struct {
 
 	// Everything below here belongs to loop and
 	// should only be accessed by code on the loop goroutine.
-	dialing   map[enode.ID]*dialTask // active tasks
-	peers     map[enode.ID]connFlag  // all connected peers
-	dialPeers int                    // current number of dialed peers
+	dialing    map[enode.ID]*dialTask // active tasks
+	peers      map[enode.ID]connFlag  // all connected peers
+	dialPeers  int                    // current number of dialed peers
+	groupPeers groupSlots             // connected members of each peer group
 
 	// The static map tracks all static dial tasks. The subset of usable static dial tasks
 	// (i.e. those passing checkDial) is kept in staticPool. The scheduler prefers
@@ -130,10 +133,12 @@ type dialScheduler struct {
 type dialSetupFunc func(net.Conn, connFlag, *enode.Node) error
 
 type dialConfig struct {
-	self           enode.ID         // our own ID
-	maxDialPeers   int              // maximum number of dialed peers
-	maxActiveDials int              // maximum number of active dials
-	netRestrict    *netutil.Netlist // IP whitelist, disabled if nil
+	self           enode.ID               // our own ID
+	maxDialPeers   int                    // maximum number of dialed peers
+	maxActiveDials int                    // maximum number of active dials
+	netRestrict    *netutil.Netlist       // IP whitelist, disabled if nil
+	banned         func(*enode.Node) bool // Reports banned nodes, disabled if nil
+	groups         *peerGroups            // Peer group quotas, disabled if nil
 	resolver       nodeResolver
 	dialer         NodeDialer
 	log            log.Logger
@@ -167,6 +172,7 @@ func newDialScheduler(config dialConfig, it enode.Iterator, setupFunc dialSetupF
 		dialing:     make(map[enode.ID]*dialTask),
 		static:      make(map[enode.ID]*dialTask),
 		peers:       make(map[enode.ID]connFlag),
+		groupPeers:  make(groupSlots),
 		doneCh:      make(chan *dialTask),
 		nodesIn:     make(chan *enode.Node),
 		addStaticCh: make(chan *enode.Node),
@@ -232,6 +238,7 @@ loop:
 		// Launch new dials if slots are available.
 		slots := d.freeDialSlots()
 		slots -= d.startStaticDials(slots)
+		slots -= d.startGroupDials(slots)
 		if slots > 0 {
 			nodesCh = d.nodesIn
 		} else {
@@ -260,6 +267,9 @@ loop:
 			}
 			id := c.node.ID()
 			d.peers[id] = c.flags
+			if g := d.groups.of(id); g != nil {
+				d.groupPeers[g]++
+			}
 			// Remove from static pool because the node is now connected.
 			task := d.static[id]
 			if task != nil && task.staticPoolIndex >= 0 {
@@ -273,6 +283,13 @@ loop:
 			}
 			delete(d.peers, c.node.ID())
 			d.updateStaticPool(c.node.ID())
+			if g := d.groups.of(c.node.ID()); g != nil {
+				// Static members may have been held back by the group quota
+				d.groupPeers[g]--
+				for _, n := range g.Nodes {
+					d.updateStaticPool(n.ID())
+				}
+			}
 
 		case node := <-d.addStaticCh:
 			id := node.ID()
@@ -407,6 +424,12 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	if d.history.contains(string(n.ID().Bytes())) {
 		return errRecentlyDialed
 	}
+	if d.banned != nil && d.banned(n) {
+		return errBanned
+	}
+	if g := d.groups.of(n.ID()); g != nil && g.MaxSlots > 0 && d.groupPeers[g]+d.groupDialing(g) >= g.MaxSlots {
+		return errGroupFull
+	}
 	return nil
 }
 
@@ -421,6 +444,35 @@ func (d *dialScheduler) startStaticDials(n int) (started int) {
 	return started
 }
 
+// startGroupDials launches dials to members of peer groups which don't fill
+// their reserved slots yet. It returns the number of dials launched.
+func (d *dialScheduler) startGroupDials(n int) (started int) {
+	for _, g := range d.groups.groups() {
+		need := g.MinSlots - d.groupPeers[g] - d.groupDialing(g)
+		for _, i := range d.rand.Perm(len(g.Nodes)) {
+			if started >= n || need <= 0 {
+				break
+			}
+			if node := g.Nodes[i]; d.checkDial(node) == nil {
+				d.startDial(newDialTask(node, staticDialedConn))
+				started++
+				need--
+			}
+		}
+	}
+	return started
+}
+
+// groupDialing returns the number of active dials to members of the group.
+func (d *dialScheduler) groupDialing(g *PeerGroup) (n int) {
+	for id := range d.dialing {
+		if d.groups.of(id) == g {
+			n++
+		}
+	}
+	return n
+}
+
 // updateStaticPool attempts to move the given static dial back into staticPool.
 func (d *dialScheduler) updateStaticPool(id enode.ID) {
 	task, ok := d.static[id]
@@ -311,6 +311,68 @@ func TestDialSchedManyStaticNodes(t *testing.T) {
 	})
 }
 
+// This test checks that peer group quotas are respected by the dialer.
+func TestDialSchedPeerGroups(t *testing.T) {
+	t.Parallel()
+
+	groups, err := newPeerGroups([]PeerGroup{
+		{
+			Name:     "partners",
+			Nodes:    []*enode.Node{newNode(uintID(0x01), "127.0.0.1:30303"), newNode(uintID(0x02), "127.0.0.1:30303"), newNode(uintID(0x03), "127.0.0.1:30303")},
+			MaxSlots: 2,
+		},
+		{
+			Name:     "sentries",
+			Nodes:    []*enode.Node{newNode(uintID(0x05), "127.0.0.1:30303"), newNode(uintID(0x06), "127.0.0.1:30303")},
+			MinSlots: 2,
+		},
+	}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := dialConfig{
+		maxActiveDials: 5,
+		maxDialPeers:   10,
+		groups:         groups,
+	}
+	runDialTest(t, config, []dialTestRound{
+		// Members of the reserving group are dialed, members of the full group
+		// are not.
+		{
+			peersAdded: []*conn{
+				{flags: dynDialedConn, node: newNode(uintID(0x01), "")},
+				{flags: dynDialedConn, node: newNode(uintID(0x02), "")},
+			},
+			discovered: []*enode.Node{
+				newNode(uintID(0x03), "127.0.0.1:30303"), // not dialed because group is full
+				newNode(uintID(0x04), "127.0.0.1:30303"),
+			},
+			wantNewDials: []*enode.Node{
+				newNode(uintID(0x04), "127.0.0.1:30303"),
+				newNode(uintID(0x05), "127.0.0.1:30303"),
+				newNode(uintID(0x06), "127.0.0.1:30303"),
+			},
+		},
+		// A member of the full group drops, making room for another one.
+		{
+			succeeded: []enode.ID{
+				uintID(0x04),
+				uintID(0x05),
+				uintID(0x06),
+			},
+			peersRemoved: []enode.ID{
+				uintID(0x01),
+			},
+			discovered: []*enode.Node{
+				newNode(uintID(0x03), "127.0.0.1:30303"),
+			},
+			wantNewDials: []*enode.Node{
+				newNode(uintID(0x03), "127.0.0.1:30303"),
+			},
+		},
+	})
+}
+
 // This test checks that past dials are not retried for some time.
 func TestDialSchedHistory(t *testing.T) {
 	t.Parallel()
@@ -41,11 +41,12 @@ type Config struct {
 	PrivateKey *ecdsa.PrivateKey
 
 	// These settings are optional:
-	NetRestrict  *netutil.Netlist   // network whitelist
-	Bootnodes    []*enode.Node      // list of bootstrap nodes
-	Unhandled    chan<- ReadPacket  // unhandled packets are sent on this channel
-	Log          log.Logger         // if set, log messages go here
-	ValidSchemes enr.IdentityScheme // allowed identity schemes
+	NetRestrict  *netutil.Netlist       // network whitelist
+	Banned       func(*enode.Node) bool // reports nodes excluded from the table and lookups
+	Bootnodes    []*enode.Node          // list of bootstrap nodes
+	Unhandled    chan<- ReadPacket      // unhandled packets are sent on this channel
+	Log          log.Logger             // if set, log messages go here
+	ValidSchemes enr.IdentityScheme     // allowed identity schemes
 	Clock        mclock.Clock
 }
 
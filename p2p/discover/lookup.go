@@ -71,7 +71,7 @@ func (it *lookup) advance() bool {
 		case nodes := <-it.replyCh:
 			it.replyBuffer = it.replyBuffer[:0]
 			for _, n := range nodes {
-				if n != nil && !it.seen[n.ID()] {
+				if n != nil && !it.seen[n.ID()] && !it.tab.isBanned(n) {
 					it.seen[n.ID()] = true
 					it.result.push(n, bucketSize)
 					it.replyBuffer = append(it.replyBuffer, n)
@@ -79,7 +79,8 @@ type Table struct {
 	closeReq   chan struct{}
 	closed     chan struct{}
 
-	nodeAddedHook func(*node) // for testing
+	banned        func(*enode.Node) bool // reports nodes to keep out of the table and lookups
+	nodeAddedHook func(*node)            // for testing
 }
 
 // transport is implemented by the UDP transports.
@@ -455,13 +456,19 @@ func (tab *Table) bucketAtDistance(d int) *bucket {
 	return tab.buckets[d-bucketMinDistance-1]
 }
 
+// isBanned returns whether the node must not be added to the table or returned
+// by lookups.
+func (tab *Table) isBanned(n *node) bool {
+	return tab.banned != nil && tab.banned(unwrapNode(n))
+}
+
 // addSeenNode adds a node which may or may not be live to the end of a bucket. If the
 // bucket has space available, adding the node succeeds immediately. Otherwise, the node is
 // added to the replacements list.
 //
 // The caller must not hold tab.mutex.
 func (tab *Table) addSeenNode(n *node) {
-	if n.ID() == tab.self().ID() {
+	if n.ID() == tab.self().ID() || tab.isBanned(n) {
 		return
 	}
 
@@ -503,7 +510,7 @@ func (tab *Table) addVerifiedNode(n *node) {
 	if !tab.isInitDone() {
 		return
 	}
-	if n.ID() == tab.self().ID() {
+	if n.ID() == tab.self().ID() || tab.isBanned(n) {
 		return
 	}
 
@@ -305,6 +305,24 @@ func (*closeTest) Generate(rand *rand.Rand, size int) reflect.Value {
 	return reflect.ValueOf(t)
 }
 
+func TestTable_banned(t *testing.T) {
+	tab, db := newTestTable(newPingRecorder())
+	<-tab.initDone
+	defer db.Close()
+	defer tab.close()
+
+	banned := nodeAtDistance(tab.self().ID(), 256, net.IP{88, 77, 66, 1})
+	other := nodeAtDistance(tab.self().ID(), 256, net.IP{88, 77, 66, 2})
+	tab.banned = func(n *enode.Node) bool { return n.ID() == banned.ID() }
+
+	tab.addSeenNode(banned)
+	tab.addVerifiedNode(banned)
+	tab.addSeenNode(other)
+	if entries := tab.bucket(banned.ID()).entries; !reflect.DeepEqual(entries, []*node{other}) {
+		t.Fatalf("wrong bucket content: %v", entries)
+	}
+}
+
 func TestTable_addVerifiedNode(t *testing.T) {
 	tab, db := newTestTable(newPingRecorder())
 	<-tab.initDone
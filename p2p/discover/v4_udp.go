@@ -147,6 +147,7 @@ func ListenV4(c UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv4, error) {
 	if err != nil {
 		return nil, err
 	}
+	tab.banned = cfg.Banned
 	t.tab = tab
 	go tab.loop()
 
@@ -0,0 +1,521 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	topicQueueLimit     = 50               // max ads per topic at a registrar
+	topicTableLimit     = 5000             // max ads across all topics at a registrar
+	topicAdLifetime     = 15 * time.Minute // time until an ad expires
+	topicRegWindow      = 10 * time.Second // time a ticket can be used after its wait time
+	topicRegistrarCount = 8                // number of nodes an ad is placed at
+	topicRenewInterval  = topicAdLifetime / 2
+	topicRetryInterval  = 30 * time.Second // delay before retrying failed registrations
+	topicSearchInterval = time.Minute      // delay between topic search rounds
+
+	// topicQueryResultLimit is the number of nodes returned for a TOPICQUERY,
+	// which is the most the querier accepts.
+	topicQueryResultLimit = totalNodesResponseLimit * nodesResponseItemLimit
+)
+
+var (
+	errInvalidTicket = errors.New("invalid ticket")
+	errTicketTiming  = errors.New("ticket used outside of its registration window")
+)
+
+// Topic is the identifier of a service advertised in the DHT, e.g. a network
+// ID. Ads for a topic are placed at the nodes closest to the topic in the node
+// ID space.
+type Topic [32]byte
+
+// NewTopic creates the topic of a service name.
+func NewTopic(name string) Topic {
+	return Topic(crypto.Keccak256Hash([]byte(name)))
+}
+
+// topicAd is a node advertising a topic.
+type topicAd struct {
+	node    *enode.Node
+	expires mclock.AbsTime
+}
+
+// topicTable stores the topic ads placed at the local node. Ads of a topic are
+// kept in order of registration, so the first ad of a queue expires first.
+type topicTable struct {
+	mu         sync.Mutex
+	queues     map[Topic][]topicAd
+	total      int
+	queueLimit int
+	tableLimit int
+}
+
+func newTopicTable() *topicTable {
+	return &topicTable{
+		queues:     make(map[Topic][]topicAd),
+		queueLimit: topicQueueLimit,
+		tableLimit: topicTableLimit,
+	}
+}
+
+// expire drops all ads which have expired. The caller must hold tt.mu.
+func (tt *topicTable) expire(now mclock.AbsTime) {
+	for topic, queue := range tt.queues {
+		i := 0
+		for i < len(queue) && queue[i].expires <= now {
+			i++
+		}
+		tt.total -= i
+		if i == len(queue) {
+			delete(tt.queues, topic)
+		} else {
+			tt.queues[topic] = queue[i:]
+		}
+	}
+}
+
+// index returns the position of a node's ad in a topic queue, or -1. The caller
+// must hold tt.mu.
+func (tt *topicTable) index(topic Topic, id enode.ID) int {
+	for i, ad := range tt.queues[topic] {
+		if ad.node.ID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// waitTime returns the time a node has to wait until it can place an ad for the
+// topic, which is the time until an ad expires if the queue or table is full.
+func (tt *topicTable) waitTime(topic Topic, id enode.ID, now mclock.AbsTime) time.Duration {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	tt.expire(now)
+	if tt.index(topic, id) >= 0 {
+		return 0 // renewals don't take up more space
+	}
+	var wait time.Duration
+	if queue := tt.queues[topic]; len(queue) >= tt.queueLimit {
+		wait = queue[0].expires.Sub(now)
+	}
+	if tt.total >= tt.tableLimit {
+		oldest := mclock.AbsTime(0)
+		for _, queue := range tt.queues {
+			if oldest == 0 || queue[0].expires < oldest {
+				oldest = queue[0].expires
+			}
+		}
+		if d := oldest.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// add places an ad for the topic, or renews the existing ad of the node. It
+// returns false if there is no space for the ad.
+func (tt *topicTable) add(topic Topic, n *enode.Node, now mclock.AbsTime) bool {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	tt.expire(now)
+	queue := tt.queues[topic]
+	if i := tt.index(topic, n.ID()); i >= 0 {
+		queue = append(queue[:i], queue[i+1:]...)
+		tt.total--
+	} else if len(queue) >= tt.queueLimit || tt.total >= tt.tableLimit {
+		return false
+	}
+	tt.queues[topic] = append(queue, topicAd{node: n, expires: now.Add(topicAdLifetime)})
+	tt.total++
+	return true
+}
+
+// nodes returns the most recently registered nodes advertising the topic.
+func (tt *topicTable) nodes(topic Topic, now mclock.AbsTime, limit int) []*enode.Node {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	tt.expire(now)
+	queue := tt.queues[topic]
+	nodes := make([]*enode.Node, 0, min(limit, len(queue)))
+	for i := len(queue) - 1; i >= 0 && len(nodes) < limit; i-- {
+		nodes = append(nodes, queue[i].node)
+	}
+	return nodes
+}
+
+// ticket is issued by registrars to nodes wishing to place an ad. It is
+// authenticated by the registrar, which doesn't need to keep any state about
+// the tickets it issued.
+type ticket struct {
+	Topic    Topic
+	ID       enode.ID
+	IP       net.IP
+	Issued   uint64 // mclock.AbsTime
+	WaitTime uint64 // time.Duration
+}
+
+// ticketKey authenticates tickets issued by the local node.
+type ticketKey [32]byte
+
+func newTicketKey() (key ticketKey) {
+	crand.Read(key[:])
+	return key
+}
+
+// encode serializes and authenticates a ticket.
+func (key *ticketKey) encode(t *ticket) []byte {
+	enc, _ := rlp.EncodeToBytes(t)
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(enc)
+	return mac.Sum(enc)
+}
+
+// decode checks the authenticity of a ticket and deserializes it.
+func (key *ticketKey) decode(enc []byte) (*ticket, error) {
+	if len(enc) < sha256.Size {
+		return nil, errInvalidTicket
+	}
+	data, sum := enc[:len(enc)-sha256.Size], enc[len(enc)-sha256.Size:]
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(data)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, errInvalidTicket
+	}
+	t := new(ticket)
+	if err := rlp.DecodeBytes(data, t); err != nil {
+		return nil, errInvalidTicket
+	}
+	return t, nil
+}
+
+// RegisterTopic starts advertising the local node under the given topic. The
+// ad is kept alive until StopRegisterTopic is called or the transport is closed.
+func (t *UDPv5) RegisterTopic(topic Topic) {
+	t.topicRegLock.Lock()
+	defer t.topicRegLock.Unlock()
+
+	if _, ok := t.topicRegs[topic]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	t.topicRegs[topic] = cancel
+	go t.topicRegisterLoop(ctx, topic)
+}
+
+// StopRegisterTopic stops advertising the local node under the given topic.
+// Existing ads expire on their own.
+func (t *UDPv5) StopRegisterTopic(topic Topic) {
+	t.topicRegLock.Lock()
+	defer t.topicRegLock.Unlock()
+
+	if cancel, ok := t.topicRegs[topic]; ok {
+		cancel()
+		delete(t.topicRegs, topic)
+	}
+}
+
+// TopicNodes returns an iterator of nodes advertising the given topic. The
+// iterator keeps searching for new ads until it is closed.
+func (t *UDPv5) TopicNodes(topic Topic) enode.Iterator {
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	it := &topicIterator{ctx: ctx, cancel: cancel, ch: make(chan *enode.Node)}
+	go t.topicSearchLoop(ctx, topic, it.ch)
+	return it
+}
+
+// topicRegisterLoop places ads for the topic at the nodes closest to it,
+// renewing them before they expire.
+func (t *UDPv5) topicRegisterLoop(ctx context.Context, topic Topic) {
+	for {
+		var (
+			wg         sync.WaitGroup
+			registered int32
+			registrars = t.newLookup(ctx, enode.ID(topic)).run()
+		)
+		if len(registrars) > topicRegistrarCount {
+			registrars = registrars[:topicRegistrarCount]
+		}
+		for _, n := range registrars {
+			wg.Add(1)
+			go func(n *enode.Node) {
+				defer wg.Done()
+				if t.registerTopicAt(ctx, n, topic) {
+					atomic.AddInt32(&registered, 1)
+				}
+			}(n)
+		}
+		wg.Wait()
+
+		delay := topicRenewInterval
+		if registered == 0 {
+			delay = topicRetryInterval
+		}
+		t.log.Trace("Registered topic", "topic", enode.ID(topic), "registrars", registered)
+		if !t.sleepCtx(ctx, delay) {
+			return
+		}
+	}
+}
+
+// registerTopicAt obtains a ticket from a registrar and uses it to place an ad
+// once the wait time is over.
+func (t *UDPv5) registerTopicAt(ctx context.Context, n *enode.Node, topic Topic) bool {
+	ticket, wait, err := t.requestTicket(n, topic)
+	if err != nil {
+		t.log.Debug("Failed to request topic ticket", "id", n.ID(), "err", err)
+		return false
+	}
+	if wait > topicRenewInterval {
+		t.log.Trace("Topic registrar too busy", "id", n.ID(), "wait", wait)
+		return false
+	}
+	if !t.sleepCtx(ctx, wait) {
+		return false
+	}
+	ok, err := t.regtopic(n, ticket)
+	if err != nil {
+		t.log.Debug("Failed to register topic", "id", n.ID(), "err", err)
+	}
+	return ok
+}
+
+// topicSearchLoop queries the nodes closest to the topic for ads and delivers
+// the advertising nodes to ch, each at most once.
+func (t *UDPv5) topicSearchLoop(ctx context.Context, topic Topic, ch chan<- *enode.Node) {
+	seen := map[enode.ID]bool{t.Self().ID(): true}
+	deliver := func(nodes []*enode.Node) bool {
+		for _, n := range nodes {
+			if seen[n.ID()] || t.tab.isBanned(wrapNode(n)) {
+				continue
+			}
+			seen[n.ID()] = true
+			select {
+			case ch <- n:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+	for {
+		if !deliver(t.topics.nodes(topic, t.clock.Now(), topicQueryResultLimit)) {
+			return
+		}
+		var (
+			registrars = t.newLookup(ctx, enode.ID(topic)).run()
+			results    = make(chan []*enode.Node, len(registrars))
+		)
+		for _, n := range registrars {
+			go func(n *enode.Node) {
+				nodes, err := t.topicQuery(n, topic)
+				if err != nil {
+					t.log.Debug("Topic query failed", "id", n.ID(), "err", err)
+				}
+				results <- nodes
+			}(n)
+		}
+		for range registrars {
+			if !deliver(<-results) {
+				return
+			}
+		}
+		if !t.sleepCtx(ctx, topicSearchInterval) {
+			return
+		}
+	}
+}
+
+// sleepCtx waits for the given duration. It returns false if the context was
+// canceled before.
+func (t *UDPv5) sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := t.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// requestTicket calls REQUESTTICKET on a node and waits for a TICKET response.
+func (t *UDPv5) requestTicket(n *enode.Node, topic Topic) ([]byte, time.Duration, error) {
+	resp := t.call(n, v5wire.TicketMsg, &v5wire.RequestTicket{Topic: topic[:]})
+	defer t.callDone(resp)
+
+	select {
+	case respMsg := <-resp.ch:
+		tk := respMsg.(*v5wire.Ticket)
+		return tk.Ticket, time.Duration(tk.WaitTime) * time.Second, nil
+	case err := <-resp.err:
+		return nil, 0, err
+	}
+}
+
+// regtopic calls REGTOPIC on a node and waits for a REGCONFIRMATION response.
+func (t *UDPv5) regtopic(n *enode.Node, ticket []byte) (bool, error) {
+	req := &v5wire.Regtopic{Ticket: ticket, ENR: t.localNode.Node().Record()}
+	resp := t.call(n, v5wire.RegconfirmationMsg, req)
+	defer t.callDone(resp)
+
+	select {
+	case respMsg := <-resp.ch:
+		return respMsg.(*v5wire.Regconfirmation).Registered, nil
+	case err := <-resp.err:
+		return false, err
+	}
+}
+
+// topicQuery calls TOPICQUERY on a node and waits for NODES responses.
+func (t *UDPv5) topicQuery(n *enode.Node, topic Topic) ([]*enode.Node, error) {
+	resp := t.call(n, v5wire.NodesMsg, &v5wire.TopicQuery{Topic: topic[:]})
+	return t.waitForNodes(resp, nil)
+}
+
+// handleRequestTicket issues a ticket for placing an ad.
+func (t *UDPv5) handleRequestTicket(p *v5wire.RequestTicket, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if len(p.Topic) != len(Topic{}) {
+		t.log.Debug("Invalid topic in "+p.Name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	var (
+		topic Topic
+		now   = t.clock.Now()
+	)
+	copy(topic[:], p.Topic)
+
+	// Wait times are sent in seconds, round up to not accept tickets early
+	wait := t.topics.waitTime(topic, fromID, now)
+	seconds := (wait + time.Second - 1) / time.Second
+	tk := &ticket{
+		Topic:    topic,
+		ID:       fromID,
+		IP:       fromAddr.IP,
+		Issued:   uint64(now),
+		WaitTime: uint64(seconds * time.Second),
+	}
+	t.sendResponse(fromID, fromAddr, &v5wire.Ticket{
+		ReqID:    p.ReqID,
+		Ticket:   t.ticketKey.encode(tk),
+		WaitTime: uint(seconds),
+	})
+}
+
+// handleRegtopic places an ad if the ticket is valid.
+func (t *UDPv5) handleRegtopic(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) {
+	registered, err := t.registerAd(p, fromID, fromAddr)
+	if err != nil {
+		t.log.Debug("Rejected "+p.Name(), "id", fromID, "addr", fromAddr, "err", err)
+	}
+	t.sendResponse(fromID, fromAddr, &v5wire.Regconfirmation{ReqID: p.ReqID, Registered: registered})
+}
+
+func (t *UDPv5) registerAd(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) (bool, error) {
+	tk, err := t.ticketKey.decode(p.Ticket)
+	if err != nil {
+		return false, err
+	}
+	if tk.ID != fromID || !tk.IP.Equal(fromAddr.IP) {
+		return false, errInvalidTicket
+	}
+	now := t.clock.Now()
+	start := mclock.AbsTime(tk.Issued).Add(time.Duration(tk.WaitTime))
+	if now < start || now > start.Add(topicRegWindow) {
+		return false, errTicketTiming
+	}
+	if p.ENR == nil {
+		return false, errors.New("missing record")
+	}
+	n, err := enode.New(t.validSchemes, p.ENR)
+	if err != nil {
+		return false, err
+	}
+	if n.ID() != fromID {
+		return false, errors.New("record of other node")
+	}
+	if t.tab.isBanned(wrapNode(n)) {
+		return false, nil
+	}
+	return t.topics.add(tk.Topic, n, now), nil
+}
+
+// handleTopicQuery returns the nodes advertising a topic.
+func (t *UDPv5) handleTopicQuery(p *v5wire.TopicQuery, fromID enode.ID, fromAddr *net.UDPAddr) {
+	var topic Topic
+	if len(p.Topic) != len(topic) {
+		t.log.Debug("Invalid topic in "+p.Name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	copy(topic[:], p.Topic)
+
+	nodes := t.topics.nodes(topic, t.clock.Now(), topicQueryResultLimit)
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
+
+// topicIterator is the iterator returned by TopicNodes.
+type topicIterator struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	ch     chan *enode.Node
+	cur    *enode.Node
+}
+
+// Next moves to the next node advertising the topic.
+func (it *topicIterator) Next() bool {
+	select {
+	case n := <-it.ch:
+		it.cur = n
+		return true
+	case <-it.ctx.Done():
+		it.cur = nil
+		return false
+	}
+}
+
+// Node returns the current node.
+func (it *topicIterator) Node() *enode.Node {
+	return it.cur
+}
+
+// Close ends the iterator.
+func (it *topicIterator) Close() {
+	it.cancel()
+}
@@ -0,0 +1,206 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestTopicTable(t *testing.T) {
+	var (
+		tt    = newTopicTable()
+		topic = NewTopic("test")
+		other = NewTopic("other")
+		nodes = nodesAtDistance(enode.ID{}, 256, 4)
+		now   = mclock.AbsTime(0)
+	)
+	tt.queueLimit, tt.tableLimit = 2, 3
+
+	// Ads are accepted until the queue is full
+	for i, n := range nodes[:2] {
+		if wait := tt.waitTime(topic, n.ID(), now); wait != 0 {
+			t.Fatalf("ad %d: wait time %v for non-full queue", i, wait)
+		}
+		if !tt.add(topic, n, now) {
+			t.Fatalf("ad %d rejected", i)
+		}
+		now = now.Add(time.Minute)
+	}
+	if wait := tt.waitTime(topic, nodes[2].ID(), now); wait != topicAdLifetime-2*time.Minute {
+		t.Fatalf("wrong wait time for full queue: %v", wait)
+	}
+	if tt.add(topic, nodes[2], now) {
+		t.Fatalf("ad accepted into full queue")
+	}
+	// Renewals are accepted and move the ad to the end of the queue
+	if wait := tt.waitTime(topic, nodes[0].ID(), now); wait != 0 {
+		t.Fatalf("wait time %v for renewal", wait)
+	}
+	if !tt.add(topic, nodes[0], now) {
+		t.Fatalf("renewal rejected")
+	}
+	if wait := tt.waitTime(topic, nodes[2].ID(), now); wait != topicAdLifetime-time.Minute {
+		t.Fatalf("wrong wait time after renewal: %v", wait)
+	}
+	if res := tt.nodes(topic, now, 10); len(res) != 2 {
+		t.Fatalf("wrong number of nodes: %d", len(res))
+	} else if err := checkNodesEqual(res, []*enode.Node{nodes[0], nodes[1]}); err != nil {
+		t.Fatal(err)
+	}
+	// The table limit applies across topics
+	if !tt.add(other, nodes[2], now) {
+		t.Fatalf("ad for other topic rejected")
+	}
+	if tt.add(other, nodes[3], now) {
+		t.Fatalf("ad accepted into full table")
+	}
+	// Ads expire
+	now = now.Add(topicAdLifetime)
+	if res := tt.nodes(topic, now, 10); len(res) != 0 {
+		t.Fatalf("ads not expired: %v", res)
+	}
+	if !tt.add(other, nodes[3], now) {
+		t.Fatalf("ad rejected after expiry")
+	}
+}
+
+func TestUDPv5_topicHandling(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	var (
+		topic  = NewTopic("test")
+		remote = test.getNode(test.remotekey, test.remoteaddr).Node()
+		ticket []byte
+	)
+	requestTicket := func(reqID []byte) {
+		test.packetIn(&v5wire.RequestTicket{ReqID: reqID, Topic: topic[:]})
+		test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {
+			if !bytes.Equal(p.ReqID, reqID) {
+				t.Errorf("wrong request ID in response: %x", p.ReqID)
+			}
+			if p.WaitTime != 0 {
+				t.Errorf("wrong wait time: %d", p.WaitTime)
+			}
+			ticket = p.Ticket
+		})
+	}
+	expectConfirmation := func(reqID []byte, want bool) {
+		test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+			if !bytes.Equal(p.ReqID, reqID) {
+				t.Errorf("wrong request ID in response: %x", p.ReqID)
+			}
+			if p.Registered != want {
+				t.Errorf("wrong registration result: have %v, want %v", p.Registered, want)
+			}
+		})
+	}
+	// Tickets are required to register
+	requestTicket([]byte{1})
+	forged := append([]byte{}, ticket...)
+	forged[0]++
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{2}, Ticket: forged, ENR: remote.Record()})
+	expectConfirmation([]byte{2}, false)
+
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{3}, Ticket: ticket, ENR: remote.Record()})
+	expectConfirmation([]byte{3}, true)
+
+	// Tickets are bound to the requester
+	otherKey, otherAddr := newkey(), &net.UDPAddr{IP: net.IP{10, 0, 1, 100}, Port: 30303}
+	other := test.getNode(otherKey, otherAddr).Node()
+	test.packetInFrom(otherKey, otherAddr, &v5wire.Regtopic{ReqID: []byte{4}, Ticket: ticket, ENR: other.Record()})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.Registered {
+			t.Errorf("registered with ticket of other node")
+		}
+	})
+	// Registered nodes are returned for topic queries
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte{5}, Topic: topic[:]})
+	test.expectNodes([]byte{5}, 1, []*enode.Node{remote})
+
+	other2 := NewTopic("other")
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte{6}, Topic: other2[:]})
+	test.expectNodes([]byte{6}, 1, nil)
+
+	// Requesters have to wait when the queue is full
+	test.udp.topics.mu.Lock()
+	test.udp.topics.queueLimit = 1
+	test.udp.topics.mu.Unlock()
+	test.packetInFrom(otherKey, otherAddr, &v5wire.RequestTicket{ReqID: []byte{7}, Topic: topic[:]})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.WaitTime == 0 || time.Duration(p.WaitTime)*time.Second > topicAdLifetime+time.Second {
+			t.Errorf("wrong wait time for full queue: %d", p.WaitTime)
+		}
+		ticket = p.Ticket
+	})
+	test.packetInFrom(otherKey, otherAddr, &v5wire.Regtopic{ReqID: []byte{8}, Ticket: ticket, ENR: other.Record()})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.Registered {
+			t.Errorf("registered before wait time")
+		}
+	})
+}
+
+func TestUDPv5_topicE2E(t *testing.T) {
+	t.Parallel()
+
+	bootnode := startLocalhostV5(t, Config{})
+	defer bootnode.Close()
+	cfg := Config{Bootnodes: []*enode.Node{bootnode.Self()}}
+	advertiser := startLocalhostV5(t, cfg)
+	defer advertiser.Close()
+	searcher := startLocalhostV5(t, cfg)
+	defer searcher.Close()
+
+	topic := NewTopic("test")
+	advertiser.RegisterTopic(topic)
+	defer advertiser.StopRegisterTopic(topic)
+
+	// Wait until the ad is placed at the bootnode.
+	deadline := time.Now().Add(10 * time.Second)
+	for len(bootnode.topics.nodes(topic, bootnode.clock.Now(), 1)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("topic not registered at bootnode")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	it := searcher.TopicNodes(topic)
+	defer it.Close()
+
+	found := make(chan *enode.Node, 1)
+	go func() {
+		if it.Next() {
+			found <- it.Node()
+		}
+	}()
+	select {
+	case n := <-found:
+		if n.ID() != advertiser.Self().ID() {
+			t.Fatalf("wrong node found: %v", n.ID())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("advertising node not found")
+	}
+}
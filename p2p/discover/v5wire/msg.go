@@ -121,8 +121,9 @@ type (
 
 	// TICKET is the response to REQUESTTICKET.
 	Ticket struct {
-		ReqID  []byte
-		Ticket []byte
+		ReqID    []byte
+		Ticket   []byte
+		WaitTime uint // seconds until the ticket can be used
 	}
 
 	// REGTOPIC registers the sender in a topic queue using a ticket.
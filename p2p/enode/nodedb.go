@@ -21,6 +21,7 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"sync"
@@ -40,6 +41,8 @@ const (
 	dbVersionKey   = "version" // Version of the database to flush if changes
 	dbNodePrefix   = "n:"      // Identifier to prefix node entries with
 	dbLocalPrefix  = "local:"
+	dbScorePrefix  = "score:"  // Identifier to prefix peer reputation entries with
+	dbNetBanPrefix = "netban:" // Identifier to prefix network bans with, the full key is "netban:<CIDR>"
 	dbDiscoverRoot = "v4"
 	dbDiscv5Root   = "v5"
 
@@ -53,6 +56,13 @@ const (
 	// Local information is keyed by ID only, the full key is "local:<ID>:seq".
 	// Use localItemKey to create those keys.
 	dbLocalSeq = "seq"
+
+	// Reputation information is keyed by ID only, the full key is "score:<ID>:value".
+	// Use scoreItemKey to create those keys. They are kept out of the node entries
+	// as reputation needs to outlive the discovery expiration of a node.
+	dbScoreValue   = "value"
+	dbScoreUpdated = "updated"
+	dbScoreBan     = "ban"
 )
 
 const (
@@ -195,6 +205,24 @@ func localItemKey(id ID, field string) []byte {
 	return key
 }
 
+// scoreItemKey returns the key of a peer reputation item.
+func scoreItemKey(id ID, field string) []byte {
+	key := append([]byte(dbScorePrefix), id[:]...)
+	key = append(key, ':')
+	key = append(key, field...)
+	return key
+}
+
+// splitScoreItemKey returns the components of a key created by scoreItemKey.
+func splitScoreItemKey(key []byte) (id ID, field string) {
+	item := key[len(dbScorePrefix):]
+	if len(item) < len(id)+1 {
+		return ID{}, ""
+	}
+	copy(id[:], item[:len(id)])
+	return id, string(item[len(id)+1:])
+}
+
 // fetchInt64 retrieves an integer associated with a particular key.
 func (db *DB) fetchInt64(key []byte) int64 {
 	blob, err := db.lvl.Get(key, nil)
@@ -315,6 +343,8 @@ func (db *DB) expirer() {
 		select {
 		case <-tick.C:
 			db.expireNodes()
+			db.expireScores()
+			db.expireNetBans()
 		case <-db.quit:
 			return
 		}
@@ -360,6 +390,39 @@ func (db *DB) expireNodes() {
 	}
 }
 
+// expireScores deletes the reputation of all nodes which hasn't changed for
+// some time and which are not banned anymore.
+func (db *DB) expireScores() {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbScorePrefix)), nil)
+	defer it.Release()
+
+	now := time.Now()
+	for it.Next() {
+		id, field := splitScoreItemKey(it.Key())
+		switch field {
+		case dbScoreBan:
+			if until, _ := binary.Varint(it.Value()); !time.Unix(until, 0).After(now) {
+				db.lvl.Delete(it.Key(), nil)
+			}
+		case dbScoreUpdated:
+			updated, _ := binary.Varint(it.Value())
+			if now.Sub(time.Unix(updated, 0)) > dbNodeExpiration && !db.BannedUntil(id).After(now) {
+				deleteRange(db.lvl, scoreItemKey(id, ""))
+			}
+		}
+	}
+}
+
+// expireNetBans deletes all network bans which are over.
+func (db *DB) expireNetBans() {
+	now := time.Now()
+	for network, until := range db.NetBans() {
+		if !until.After(now) {
+			db.lvl.Delete([]byte(dbNetBanPrefix+network), nil)
+		}
+	}
+}
+
 // LastPingReceived retrieves the time of the last ping packet received from
 // a remote node.
 func (db *DB) LastPingReceived(id ID, ip net.IP) time.Time {
@@ -427,6 +490,78 @@ func (db *DB) UpdateFindFailsV5(id ID, ip net.IP, fails int) error {
 	return db.storeInt64(v5Key(id, ip, dbNodeFindFails), int64(fails))
 }
 
+// PeerScore retrieves the reputation score of a node along with the time it was
+// last updated.
+func (db *DB) PeerScore(id ID) (float64, time.Time) {
+	score := math.Float64frombits(db.fetchUint64(scoreItemKey(id, dbScoreValue)))
+	return score, time.Unix(db.fetchInt64(scoreItemKey(id, dbScoreUpdated)), 0)
+}
+
+// UpdatePeerScore stores the reputation score of a node.
+func (db *DB) UpdatePeerScore(id ID, score float64, updated time.Time) error {
+	if err := db.storeUint64(scoreItemKey(id, dbScoreValue), math.Float64bits(score)); err != nil {
+		return err
+	}
+	return db.storeInt64(scoreItemKey(id, dbScoreUpdated), updated.Unix())
+}
+
+// BannedUntil retrieves the time until which a node is banned. The zero time is
+// returned if the node was never banned.
+func (db *DB) BannedUntil(id ID) time.Time {
+	if until := db.fetchInt64(scoreItemKey(id, dbScoreBan)); until != 0 {
+		return time.Unix(until, 0)
+	}
+	return time.Time{}
+}
+
+// UpdateBannedUntil stores the time until which a node is banned. Storing the
+// zero time lifts the ban.
+func (db *DB) UpdateBannedUntil(id ID, until time.Time) error {
+	if until.IsZero() {
+		return db.lvl.Delete(scoreItemKey(id, dbScoreBan), nil)
+	}
+	return db.storeInt64(scoreItemKey(id, dbScoreBan), until.Unix())
+}
+
+// Bans retrieves the ban expiry times of all banned nodes, including bans which
+// are over but not yet expired from the database.
+func (db *DB) Bans() map[ID]time.Time {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbScorePrefix)), nil)
+	defer it.Release()
+
+	bans := make(map[ID]time.Time)
+	for it.Next() {
+		if id, field := splitScoreItemKey(it.Key()); field == dbScoreBan {
+			until, _ := binary.Varint(it.Value())
+			bans[id] = time.Unix(until, 0)
+		}
+	}
+	return bans
+}
+
+// NetBans retrieves the ban expiry times of all banned networks, keyed by the
+// network in CIDR notation.
+func (db *DB) NetBans() map[string]time.Time {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbNetBanPrefix)), nil)
+	defer it.Release()
+
+	bans := make(map[string]time.Time)
+	for it.Next() {
+		until, _ := binary.Varint(it.Value())
+		bans[string(it.Key()[len(dbNetBanPrefix):])] = time.Unix(until, 0)
+	}
+	return bans
+}
+
+// UpdateNetBan stores the time until which a network given in CIDR notation is
+// banned. Storing the zero time lifts the ban.
+func (db *DB) UpdateNetBan(network string, until time.Time) error {
+	if until.IsZero() {
+		return db.lvl.Delete([]byte(dbNetBanPrefix+network), nil)
+	}
+	return db.storeInt64([]byte(dbNetBanPrefix+network), until.Unix())
+}
+
 // LocalSeq retrieves the local record sequence counter.
 func (db *DB) localSeq(id ID) uint64 {
 	return db.fetchUint64(localItemKey(id, dbLocalSeq))
@@ -473,3 +473,79 @@ func TestDBExpireV5(t *testing.T) {
 	db.UpdateFindFailsV5(ID{}, ip, 4)
 	db.expireNodes()
 }
+
+func TestDBPeerScores(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var (
+		now   = time.Now()
+		stale = ID{1}
+		fresh = ID{2}
+		ban   = ID{3}
+	)
+	db.UpdatePeerScore(stale, -10, now.Add(-2*dbNodeExpiration))
+	db.UpdatePeerScore(fresh, 42.5, now)
+	db.UpdatePeerScore(ban, -200, now.Add(-2*dbNodeExpiration))
+	db.UpdateBannedUntil(ban, now.Add(time.Hour))
+
+	if score, updated := db.PeerScore(fresh); score != 42.5 || updated.Unix() != now.Unix() {
+		t.Errorf("score mismatch: have %v at %v, want %v at %v", score, updated, 42.5, now)
+	}
+	if until := db.BannedUntil(ban); until.Unix() != now.Add(time.Hour).Unix() {
+		t.Errorf("ban mismatch: have %v, want %v", until, now.Add(time.Hour))
+	}
+	// Stale scores are expired, unless the node is still banned
+	db.expireScores()
+	if score, _ := db.PeerScore(stale); score != 0 {
+		t.Errorf("stale score not expired")
+	}
+	if score, _ := db.PeerScore(fresh); score != 42.5 {
+		t.Errorf("fresh score expired")
+	}
+	if score, _ := db.PeerScore(ban); score != -200 {
+		t.Errorf("banned node's score expired")
+	}
+	// Lifting the ban lets the score expire
+	db.UpdateBannedUntil(ban, time.Time{})
+	if until := db.BannedUntil(ban); !until.IsZero() {
+		t.Errorf("ban not lifted: %v", until)
+	}
+	db.expireScores()
+	if score, _ := db.PeerScore(ban); score != 0 {
+		t.Errorf("unbanned score not expired")
+	}
+}
+
+func TestDBNetBans(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	now := time.Now()
+	db.UpdateNetBan("10.0.0.0/8", now.Add(time.Hour))
+	db.UpdateNetBan("192.168.1.0/24", now.Add(-time.Hour))
+	db.UpdateBannedUntil(ID{1}, now.Add(time.Hour))
+	db.UpdateBannedUntil(ID{2}, now.Add(-time.Hour))
+
+	bans := db.NetBans()
+	if len(bans) != 2 || bans["10.0.0.0/8"].Unix() != now.Add(time.Hour).Unix() {
+		t.Fatalf("network bans mismatch: %v", bans)
+	}
+	if nodes := db.Bans(); len(nodes) != 2 || nodes[ID{1}].Unix() != now.Add(time.Hour).Unix() {
+		t.Fatalf("node bans mismatch: %v", nodes)
+	}
+	// Bans which are over are expired
+	db.expireNetBans()
+	db.expireScores()
+	if bans := db.NetBans(); len(bans) != 1 {
+		t.Errorf("network ban not expired: %v", bans)
+	}
+	if nodes := db.Bans(); len(nodes) != 1 {
+		t.Errorf("node ban not expired: %v", nodes)
+	}
+	// Bans can be lifted
+	db.UpdateNetBan("10.0.0.0/8", time.Time{})
+	if bans := db.NetBans(); len(bans) != 0 {
+		t.Errorf("network ban not lifted: %v", bans)
+	}
+}
@@ -116,6 +116,10 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// scores tracks the reputation of the peer if set
+	scores *scoreTracker
+	group  string // Name of the peer group, empty if none configured
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -182,6 +186,30 @@ func (p *Peer) LocalAddr() net.Addr {
 	return p.rw.fd.LocalAddr()
 }
 
+// Report adjusts the reputation of the peer according to a behaviour observed by
+// a protocol handler. Peers whose reputation drops below the ban threshold are
+// disconnected and refused until the ban expires. Trusted and static peers are
+// never banned.
+func (p *Peer) Report(event ScoreEvent) {
+	if p.scores == nil {
+		return
+	}
+	score, ban := p.scores.report(p.ID(), event, !p.rw.is(trustedConn|staticDialedConn))
+	p.log.Trace("Adjusted peer reputation", "event", event, "score", score)
+	if ban {
+		p.log.Debug("Banning misbehaving peer", "score", score)
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
+// Score returns the current reputation of the peer.
+func (p *Peer) Score() float64 {
+	if p.scores == nil {
+		return 0
+	}
+	return p.scores.score(p.ID())
+}
+
 // Disconnect terminates the peer connection with the given reason.
 // It returns immediately and does not wait until the connection is closed.
 func (p *Peer) Disconnect(reason DiscReason) {
@@ -465,12 +493,14 @@ type PeerInfo struct {
 	ID      string   `json:"id"`            // Unique node identifier
 	Name    string   `json:"name"`          // Name of the node, including client type, version, OS, custom data
 	Caps    []string `json:"caps"`          // Protocols advertised by this peer
+	Score   float64  `json:"score"`         // Reputation of the peer
 	Network struct {
 		LocalAddress  string `json:"localAddress"`  // Local endpoint of the TCP data connection
 		RemoteAddress string `json:"remoteAddress"` // Remote endpoint of the TCP data connection
 		Inbound       bool   `json:"inbound"`
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
+		Group         string `json:"group,omitempty"` // Peer group of the node
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
@@ -488,6 +518,7 @@ func (p *Peer) Info() *PeerInfo {
 		ID:        p.ID().String(),
 		Name:      p.Fullname(),
 		Caps:      caps,
+		Score:     p.Score(),
 		Protocols: make(map[string]interface{}),
 	}
 	if p.Node().Seq() > 0 {
@@ -498,6 +529,7 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	info.Network.Group = p.group
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
@@ -0,0 +1,135 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// PeerGroup is a named set of nodes sharing a quota of peer slots, e.g. the
+// sentries of a node or the nodes of partners.
+type PeerGroup struct {
+	Name string
+
+	// Nodes are the members of the group. A group without members holds all
+	// nodes which aren't members of any other group.
+	Nodes []*enode.Node `toml:",omitempty"`
+
+	// MinSlots is the number of peer slots reserved for members of the group,
+	// which are not available to other peers. The dialer keeps connecting to
+	// members until the reserved slots are filled.
+	MinSlots int `toml:",omitempty"`
+
+	// MaxSlots is the maximum number of members connected at the same time.
+	// Zero means no limit.
+	MaxSlots int `toml:",omitempty"`
+}
+
+// peerGroups is the membership index of the configured peer groups.
+type peerGroups struct {
+	list     []*PeerGroup
+	members  map[enode.ID]*PeerGroup
+	fallback *PeerGroup // Group of the nodes without explicit membership
+}
+
+// newPeerGroups validates the peer group configuration and indexes the members.
+func newPeerGroups(groups []PeerGroup, maxPeers int) (*peerGroups, error) {
+	pg := &peerGroups{members: make(map[enode.ID]*PeerGroup)}
+
+	var (
+		names    = make(map[string]bool)
+		reserved int
+	)
+	for i := range groups {
+		g := &groups[i]
+		switch {
+		case g.Name == "":
+			return nil, fmt.Errorf("peer group %d has no name", i)
+		case names[g.Name]:
+			return nil, fmt.Errorf("duplicate peer group %q", g.Name)
+		case g.MinSlots < 0 || g.MaxSlots < 0:
+			return nil, fmt.Errorf("peer group %q has negative slot count", g.Name)
+		case g.MaxSlots > 0 && g.MinSlots > g.MaxSlots:
+			return nil, fmt.Errorf("peer group %q reserves more than its maximum slots", g.Name)
+		}
+		names[g.Name] = true
+		reserved += g.MinSlots
+
+		if len(g.Nodes) == 0 {
+			if pg.fallback != nil {
+				return nil, fmt.Errorf("peer groups %q and %q both have no members", pg.fallback.Name, g.Name)
+			}
+			pg.fallback = g
+		}
+		for _, n := range g.Nodes {
+			if other, ok := pg.members[n.ID()]; ok {
+				return nil, fmt.Errorf("node %v is member of peer groups %q and %q", n.ID(), other.Name, g.Name)
+			}
+			pg.members[n.ID()] = g
+		}
+		pg.list = append(pg.list, g)
+	}
+	if reserved > maxPeers {
+		return nil, fmt.Errorf("peer groups reserve %d slots, more than the %d peers allowed", reserved, maxPeers)
+	}
+	return pg, nil
+}
+
+// of returns the group of a node, or nil if it doesn't belong to any.
+func (pg *peerGroups) of(id enode.ID) *PeerGroup {
+	if pg == nil {
+		return nil
+	}
+	if g, ok := pg.members[id]; ok {
+		return g
+	}
+	return pg.fallback
+}
+
+// groups returns all configured groups.
+func (pg *peerGroups) groups() []*PeerGroup {
+	if pg == nil {
+		return nil
+	}
+	return pg.list
+}
+
+// groupSlots counts the connected members of each group.
+type groupSlots map[*PeerGroup]int
+
+// full returns whether the group has no more slots available.
+func (s groupSlots) full(g *PeerGroup) bool {
+	return g != nil && g.MaxSlots > 0 && s[g] >= g.MaxSlots
+}
+
+// reserving returns whether the group has unfilled reserved slots.
+func (s groupSlots) reserving(g *PeerGroup) bool {
+	return g != nil && s[g] < g.MinSlots
+}
+
+// unfilled returns the number of slots reserved by all groups except the given
+// one which are not yet filled by members.
+func (s groupSlots) unfilled(groups []*PeerGroup, except *PeerGroup) (n int) {
+	for _, g := range groups {
+		if g != except && s[g] < g.MinSlots {
+			n += g.MinSlots - s[g]
+		}
+	}
+	return n
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestPeerGroupsValidation(t *testing.T) {
+	var (
+		a = enode.SignNull(new(enr.Record), randomID())
+		b = enode.SignNull(new(enr.Record), randomID())
+	)
+	tests := []struct {
+		groups []PeerGroup
+		ok     bool
+	}{
+		{groups: []PeerGroup{{Name: "a", Nodes: []*enode.Node{a}, MinSlots: 2, MaxSlots: 3}, {Name: "rest", MaxSlots: 5}}, ok: true},
+		{groups: []PeerGroup{{Nodes: []*enode.Node{a}}}},
+		{groups: []PeerGroup{{Name: "a", Nodes: []*enode.Node{a}}, {Name: "a", Nodes: []*enode.Node{b}}}},
+		{groups: []PeerGroup{{Name: "a", Nodes: []*enode.Node{a}, MinSlots: -1}}},
+		{groups: []PeerGroup{{Name: "a", Nodes: []*enode.Node{a}, MinSlots: 3, MaxSlots: 2}}},
+		{groups: []PeerGroup{{Name: "a"}, {Name: "b"}}},
+		{groups: []PeerGroup{{Name: "a", Nodes: []*enode.Node{a}}, {Name: "b", Nodes: []*enode.Node{a, b}}}},
+		{groups: []PeerGroup{{Name: "a", Nodes: []*enode.Node{a}, MinSlots: 6}, {Name: "b", Nodes: []*enode.Node{b}, MinSlots: 5}}},
+	}
+	for i, test := range tests {
+		pg, err := newPeerGroups(test.groups, 10)
+		if (err == nil) != test.ok {
+			t.Errorf("test %d: wrong result: %v", i, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if g := pg.of(a.ID()); g == nil || g.Name != "a" {
+			t.Errorf("test %d: wrong group for member: %v", i, g)
+		}
+		if g := pg.of(randomID()); g == nil || g.Name != "rest" {
+			t.Errorf("test %d: wrong fallback group: %v", i, g)
+		}
+	}
+}
+
+func TestServerPeerGroups(t *testing.T) {
+	var (
+		remote   = newkey()
+		sentries = []*enode.Node{
+			enode.SignNull(new(enr.Record), randomID()),
+			enode.SignNull(new(enr.Record), randomID()),
+			enode.SignNull(new(enr.Record), randomID()),
+		}
+	)
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    5,
+			NoDial:      true,
+			NoDiscovery: true,
+			PeerGroups:  []PeerGroup{{Name: "sentries", Nodes: sentries, MinSlots: 2, MaxSlots: 2}},
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	// Other nodes can't use the slots reserved for the group
+	for i := 0; i < 3; i++ {
+		if err := srv.checkpoint(newconn(randomID()), srv.checkpointAddPeer); err != nil {
+			t.Fatalf("could not add conn: %v", err)
+		}
+	}
+	if err := srv.checkpoint(newconn(randomID()), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatalf("wrong error for reserved slot: %v", err)
+	}
+	// Members may use the reserved slot, but not exceed their maximum
+	for _, n := range sentries[:2] {
+		if err := srv.checkpoint(newconn(n.ID()), srv.checkpointAddPeer); err != nil {
+			t.Fatalf("member rejected: %v", err)
+		}
+	}
+	if err := srv.checkpoint(newconn(sentries[2].ID()), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatalf("wrong error for full group: %v", err)
+	}
+	for _, p := range srv.Peers() {
+		if want := srv.groups.of(p.ID()); want != nil && p.Info().Network.Group != want.Name {
+			t.Errorf("wrong group in peer info: %q", p.Info().Network.Group)
+		}
+	}
+}
+
+func TestServerInboundSubnetLimit(t *testing.T) {
+	remote := newkey()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:          newkey(),
+			MaxPeers:            10,
+			MaxInboundPerSubnet: 2,
+			NoDial:              true,
+			NoDiscovery:         true,
+			Logger:              testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(ip string) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		var r enr.Record
+		r.Set(enr.IP(net.ParseIP(ip)))
+		node := enode.SignNull(&r, randomID())
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	for _, ip := range []string{"1.2.3.4", "1.2.3.5", "192.168.0.1", "192.168.0.2", "192.168.0.3"} {
+		if err := srv.checkpoint(newconn(ip), srv.checkpointAddPeer); err != nil {
+			t.Fatalf("conn from %s rejected: %v", ip, err)
+		}
+	}
+	if err := srv.checkpoint(newconn("1.2.3.6"), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatalf("wrong error for full subnet: %v", err)
+	}
+	if err := srv.checkpoint(newconn("1.2.4.1"), srv.checkpointPostHandshake); err != nil {
+		t.Fatalf("conn from other subnet rejected: %v", err)
+	}
+}
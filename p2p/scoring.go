@@ -0,0 +1,206 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// Reputation defaults.
+	defaultScoreBanThreshold = -100
+	defaultScoreBanDuration  = time.Hour
+
+	// scoreHalfLife is the time it takes for a peer's score to decay to half,
+	// letting peers earn back their reputation and preventing long-lived peers
+	// from accumulating too much credit.
+	scoreHalfLife = time.Hour
+
+	// scoreMax is the highest reputation a peer can accumulate.
+	scoreMax = 100
+)
+
+// ScoreEvent is a peer behaviour observed by a protocol handler, which adjusts
+// the reputation of the peer.
+type ScoreEvent int
+
+const (
+	ScoreUsefulDelivery    ScoreEvent = iota // Peer delivered requested data
+	ScoreUselessDelivery                     // Peer delivered unrequested or stale data
+	ScoreTimeout                             // Peer failed to answer a request in time
+	ScoreProtocolViolation                   // Peer sent invalid data or broke the protocol
+)
+
+// scoreWeights are the reputation changes caused by the different events.
+var scoreWeights = [...]float64{
+	ScoreUsefulDelivery:    1,
+	ScoreUselessDelivery:   -2,
+	ScoreTimeout:           -10,
+	ScoreProtocolViolation: -40,
+}
+
+func (ev ScoreEvent) String() string {
+	switch ev {
+	case ScoreUsefulDelivery:
+		return "useful delivery"
+	case ScoreUselessDelivery:
+		return "useless delivery"
+	case ScoreTimeout:
+		return "timeout"
+	case ScoreProtocolViolation:
+		return "protocol violation"
+	default:
+		return fmt.Sprintf("unknown event %d", int(ev))
+	}
+}
+
+// peerScore is the decaying reputation of a node.
+type peerScore struct {
+	value   float64
+	updated time.Time
+}
+
+// at returns the score decayed until the given time.
+func (s peerScore) at(now time.Time) float64 {
+	elapsed := now.Sub(s.updated)
+	if elapsed <= 0 {
+		return s.value
+	}
+	return s.value * math.Exp2(-float64(elapsed)/float64(scoreHalfLife))
+}
+
+// scoreTracker maintains the reputation of nodes. The scores of connected peers
+// are cached in memory and persisted into the node database when they
+// disconnect, bans are persisted right away.
+type scoreTracker struct {
+	db          *enode.DB
+	threshold   float64
+	banDuration time.Duration
+	now         func() time.Time
+	log         log.Logger
+
+	lock   sync.Mutex
+	scores map[enode.ID]*peerScore // Scores of the connected peers
+}
+
+func newScoreTracker(db *enode.DB, threshold float64, banDuration time.Duration, log log.Logger) *scoreTracker {
+	if threshold == 0 {
+		threshold = defaultScoreBanThreshold
+	}
+	if banDuration == 0 {
+		banDuration = defaultScoreBanDuration
+	}
+	return &scoreTracker{
+		db:          db,
+		threshold:   threshold,
+		banDuration: banDuration,
+		now:         time.Now,
+		log:         log,
+		scores:      make(map[enode.ID]*peerScore),
+	}
+}
+
+// track starts caching the score of a newly connected peer.
+func (t *scoreTracker) track(id enode.ID) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	value, updated := t.db.PeerScore(id)
+	t.scores[id] = &peerScore{value: value, updated: updated}
+}
+
+// score returns the current reputation of a node.
+func (t *scoreTracker) score(id enode.ID) float64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if score, ok := t.scores[id]; ok {
+		return score.at(t.now())
+	}
+	value, updated := t.db.PeerScore(id)
+	return peerScore{value: value, updated: updated}.at(t.now())
+}
+
+// report adjusts the reputation of a node according to an observed event. If
+// the node may be banned and its score drops below the threshold, the ban is
+// persisted and true is returned.
+func (t *scoreTracker) report(id enode.ID, event ScoreEvent, bannable bool) (float64, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Late reports may arrive after the peer disconnected, update the database
+	// directly for those
+	now := t.now()
+	score, cached := t.scores[id]
+	if !cached {
+		value, updated := t.db.PeerScore(id)
+		score = &peerScore{value: value, updated: updated}
+	}
+	score.value = math.Min(score.at(now)+scoreWeights[event], scoreMax)
+	score.updated = now
+
+	if !cached {
+		t.db.UpdatePeerScore(id, score.value, score.updated)
+	}
+	if !bannable || score.value >= t.threshold {
+		return score.value, false
+	}
+	// Don't shorten longer bans, e.g. ones set by the operator
+	if until := now.Add(t.banDuration); until.After(t.db.BannedUntil(id)) {
+		if err := t.db.UpdateBannedUntil(id, until); err != nil {
+			t.log.Warn("Failed to persist peer ban", "id", id, "err", err)
+		}
+	}
+	return score.value, true
+}
+
+// reset clears the reputation of a node, e.g. when it's unbanned by the
+// operator, so that it isn't banned again by the next negative event.
+func (t *scoreTracker) reset(id enode.ID) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	if score, ok := t.scores[id]; ok {
+		score.value, score.updated = 0, now
+	}
+	return t.db.UpdatePeerScore(id, 0, now)
+}
+
+// flush persists the score of a disconnected peer and drops it from the cache.
+func (t *scoreTracker) flush(id enode.ID) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if score, ok := t.scores[id]; ok {
+		if err := t.db.UpdatePeerScore(id, score.value, score.updated); err != nil {
+			t.log.Warn("Failed to persist peer score", "id", id, "err", err)
+		}
+		delete(t.scores, id)
+	}
+}
+
+// banned returns whether the node is currently banned.
+func (t *scoreTracker) banned(id enode.ID) bool {
+	return t.db.BannedUntil(id).After(t.now())
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestScoreTracker(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	now := time.Unix(1000000, 0)
+	tracker := newScoreTracker(db, 0, 0, log.Root())
+	tracker.now = func() time.Time { return now }
+
+	// Scores of tracked peers are cached until flushed
+	id := randomID()
+	tracker.track(id)
+	for i := 0; i < 5; i++ {
+		tracker.report(id, ScoreUsefulDelivery, true)
+	}
+	if score := tracker.score(id); score != 5 {
+		t.Fatalf("score mismatch: have %v, want 5", score)
+	}
+	if score, _ := db.PeerScore(id); score != 0 {
+		t.Fatalf("score persisted before flush: %v", score)
+	}
+	tracker.flush(id)
+	if score, _ := db.PeerScore(id); score != 5 {
+		t.Fatalf("persisted score mismatch: have %v, want 5", score)
+	}
+	// Scores decay over time
+	now = now.Add(scoreHalfLife)
+	if score := tracker.score(id); score != 2.5 {
+		t.Fatalf("decayed score mismatch: have %v, want 2.5", score)
+	}
+	// Late reports for untracked peers go straight into the database
+	tracker.report(id, ScoreTimeout, true)
+	if score, _ := db.PeerScore(id); score != -7.5 {
+		t.Fatalf("persisted score mismatch: have %v, want -7.5", score)
+	}
+	// Scores are capped
+	tracker.track(id)
+	for i := 0; i < 2*scoreMax; i++ {
+		tracker.report(id, ScoreUsefulDelivery, true)
+	}
+	if score := tracker.score(id); score != scoreMax {
+		t.Fatalf("capped score mismatch: have %v, want %v", score, scoreMax)
+	}
+	// Protocol violations get the peer banned, unless it's exempt
+	other := randomID()
+	tracker.track(other)
+	for i := 0; i < 10; i++ {
+		if _, ban := tracker.report(other, ScoreProtocolViolation, false); ban {
+			t.Fatalf("exempt peer banned")
+		}
+	}
+	if tracker.banned(other) {
+		t.Fatalf("exempt peer banned")
+	}
+	tracker.flush(other)
+	db.UpdatePeerScore(other, 0, now)
+
+	tracker.track(other)
+	for i := 0; i < 2; i++ {
+		if _, ban := tracker.report(other, ScoreProtocolViolation, true); ban {
+			t.Fatalf("peer banned before reaching the threshold")
+		}
+	}
+	if _, ban := tracker.report(other, ScoreProtocolViolation, true); !ban {
+		t.Fatalf("peer not banned after reaching the threshold")
+	}
+	if !tracker.banned(other) {
+		t.Fatalf("ban not persisted")
+	}
+	now = now.Add(defaultScoreBanDuration + time.Second)
+	if tracker.banned(other) {
+		t.Fatalf("ban not expired")
+	}
+}
+
+func TestServerReputation(t *testing.T) {
+	remote := newkey()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    2,
+			NoDial:      true,
+			NoDiscovery: true,
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	events := make(chan *PeerEvent, 10)
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remote.PublicKey, fd, nil)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	waitDrop := func(id enode.ID) {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == PeerEventTypeDrop && ev.Peer == id {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("peer %v not dropped", id)
+			}
+		}
+	}
+	peer := func(id enode.ID) *Peer {
+		for _, p := range srv.Peers() {
+			if p.ID() == id {
+				return p
+			}
+		}
+		t.Fatalf("peer %v not connected", id)
+		return nil
+	}
+	// Fill up the peer set and mark one peer as slow
+	slow, good := randomID(), randomID()
+	for _, id := range []enode.ID{slow, good} {
+		if err := srv.checkpoint(newconn(id), srv.checkpointAddPeer); err != nil {
+			t.Fatalf("could not add conn: %v", err)
+		}
+	}
+	peer(slow).Report(ScoreTimeout)
+	peer(good).Report(ScoreUsefulDelivery)
+
+	// A fresh node should replace the slow peer, but not the good one
+	fresh := randomID()
+	if err := srv.checkpoint(newconn(fresh), srv.checkpointPostHandshake); err != nil {
+		t.Fatalf("fresh node rejected: %v", err)
+	}
+	waitDrop(slow)
+	if err := srv.checkpoint(newconn(fresh), srv.checkpointAddPeer); err != nil {
+		t.Fatalf("fresh node rejected: %v", err)
+	}
+	if err := srv.checkpoint(newconn(randomID()), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatalf("wrong error for full server: %v", err)
+	}
+	// Misbehaving peers get banned
+	p := peer(fresh)
+	for i := 0; i < 3; i++ {
+		p.Report(ScoreProtocolViolation)
+	}
+	waitDrop(fresh)
+	if score := p.Score(); score >= defaultScoreBanThreshold {
+		t.Errorf("score above ban threshold: %v", score)
+	}
+	if err := srv.checkpoint(newconn(fresh), srv.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Fatalf("wrong error for banned node: %v", err)
+	}
+}
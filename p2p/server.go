@@ -114,11 +114,28 @@ type Config struct {
 	// allowed to connect, even above the peer limit.
 	TrustedNodes []*enode.Node
 
+	// PeerGroups reserve and limit peer slots for sets of nodes. Members of
+	// groups with reserved slots are dialed like static nodes until the
+	// reserved slots are filled.
+	PeerGroups []PeerGroup `toml:",omitempty"`
+
+	// MaxInboundPerSubnet limits the number of inbound peers from the same /24
+	// (IPv4) or /64 (IPv6) network. LAN peers are exempt. Zero means no limit.
+	MaxInboundPerSubnet int `toml:",omitempty"`
+
 	// Connectivity can be restricted to certain IP networks.
 	// If this option is set to a non-nil value, only hosts which match one of the
 	// IP networks contained in the list are considered.
 	NetRestrict *netutil.Netlist `toml:",omitempty"`
 
+	// ScoreBanThreshold is the reputation below which misbehaving peers are
+	// disconnected and banned. Zero defaults to -100.
+	ScoreBanThreshold float64 `toml:",omitempty"`
+
+	// ScoreBanDuration is the time misbehaving peers are banned for. Zero
+	// defaults to one hour.
+	ScoreBanDuration time.Duration `toml:",omitempty"`
+
 	// NodeDatabase is the path to the database containing the previously seen
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
@@ -179,12 +196,18 @@ type Server struct {
 	log          log.Logger
 
 	nodedb    *enode.DB
+	scores    *scoreTracker
+	bans      *banList
+	groups    *peerGroups
 	localnode *enode.LocalNode
 	ntab      *discover.UDPv4
 	DiscV5    *discover.UDPv5
 	discmix   *enode.FairMix
 	dialsched *dialScheduler
 
+	trusted     map[enode.ID]bool // Trusted node set, shared by the run loop and the dialer
+	trustedLock sync.RWMutex
+
 	// Channels into the run loop.
 	quit                    chan struct{}
 	addtrusted              chan *enode.Node
@@ -197,6 +220,10 @@ type Server struct {
 
 	// State of run loop and listenLoop.
 	inboundHistory expHeap
+	evicted        map[enode.ID]bool      // Peers disconnected in favour of better reputed ones
+	groupPeers     groupSlots             // Connected members of each peer group
+	inboundNets4   netutil.DistinctNetSet // Networks of inbound IPv4 peers
+	inboundNets6   netutil.DistinctNetSet // Networks of inbound IPv6 peers
 }
 
 type peerOpFunc func(map[enode.ID]*Peer)
@@ -370,6 +397,119 @@ func (srv *Server) RemoveTrustedPeer(node *enode.Node) {
 	}
 }
 
+// BanNode prevents a node from connecting until the given time, or permanently
+// if the time is zero. The node is disconnected if it is currently connected.
+// Bans are persisted in the node database. Trusted nodes are exempt from node
+// bans, but not from network bans.
+func (srv *Server) BanNode(id enode.ID, until time.Time) error {
+	bans, err := srv.banList()
+	if err != nil {
+		return err
+	}
+	if err := bans.banNode(id, until); err != nil {
+		return err
+	}
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		if p := peers[id]; p != nil && !p.rw.is(trustedConn) {
+			p.Disconnect(DiscUselessPeer)
+		}
+	})
+	return nil
+}
+
+// UnbanNode lifts the ban of a node, regardless of whether it was banned using
+// BanNode or for misbehaving. The reputation of the node is reset as well.
+func (srv *Server) UnbanNode(id enode.ID) error {
+	bans, err := srv.banList()
+	if err != nil {
+		return err
+	}
+	if err := srv.scores.reset(id); err != nil {
+		return err
+	}
+	return bans.unbanNode(id)
+}
+
+// BanNetwork prevents all nodes within a network from connecting until the
+// given time, or permanently if the time is zero. Connected peers within the
+// network are disconnected.
+func (srv *Server) BanNetwork(network *net.IPNet, until time.Time) error {
+	bans, err := srv.banList()
+	if err != nil {
+		return err
+	}
+	if err := bans.banNetwork(network, until); err != nil {
+		return err
+	}
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		for _, p := range peers {
+			if bans.bannedIP(p.Node().IP()) {
+				p.Disconnect(DiscUselessPeer)
+			}
+		}
+	})
+	return nil
+}
+
+// UnbanNetwork lifts the ban of a network.
+func (srv *Server) UnbanNetwork(network *net.IPNet) error {
+	bans, err := srv.banList()
+	if err != nil {
+		return err
+	}
+	return bans.unbanNetwork(network)
+}
+
+// Bans returns all nodes and networks which are currently banned.
+func (srv *Server) Bans() ([]BanInfo, error) {
+	bans, err := srv.banList()
+	if err != nil {
+		return nil, err
+	}
+	return bans.list(), nil
+}
+
+// banList returns the ban list if the server is running.
+func (srv *Server) banList() (*banList, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return nil, errServerStopped
+	}
+	return srv.bans, nil
+}
+
+// setTrusted adds or removes a node from the trusted node set.
+func (srv *Server) setTrusted(id enode.ID, trusted bool) {
+	srv.trustedLock.Lock()
+	defer srv.trustedLock.Unlock()
+
+	if trusted {
+		srv.trusted[id] = true
+	} else {
+		delete(srv.trusted, id)
+	}
+}
+
+// isTrusted returns whether the node is in the trusted node set.
+func (srv *Server) isTrusted(id enode.ID) bool {
+	srv.trustedLock.RLock()
+	defer srv.trustedLock.RUnlock()
+
+	return srv.trusted[id]
+}
+
+// dialBanned returns whether dialing the node is prevented by a ban. The same
+// rules apply as for inbound connections: trusted nodes are exempt from node
+// bans, but not from network bans.
+func (srv *Server) dialBanned(n *enode.Node) bool {
+	if srv.bans.bannedIP(n.IP()) {
+		return true
+	}
+	return !srv.isTrusted(n.ID()) && srv.bans.bannedNode(n.ID())
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -467,8 +607,19 @@ func (srv *Server) Start() (err error) {
 	srv.checkpointAddPeer = make(chan *conn)
 	srv.addtrusted = make(chan *enode.Node)
 	srv.removetrusted = make(chan *enode.Node)
+	srv.trusted = make(map[enode.ID]bool, len(srv.TrustedNodes))
+	for _, n := range srv.TrustedNodes {
+		srv.trusted[n.ID()] = true
+	}
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
+	srv.evicted = make(map[enode.ID]bool)
+	srv.groupPeers = make(groupSlots)
+	srv.inboundNets4 = netutil.DistinctNetSet{Subnet: 24, Limit: uint(srv.MaxInboundPerSubnet)}
+	srv.inboundNets6 = netutil.DistinctNetSet{Subnet: 64, Limit: uint(srv.MaxInboundPerSubnet)}
+	if srv.groups, err = newPeerGroups(srv.PeerGroups, srv.MaxPeers); err != nil {
+		return err
+	}
 
 	if err := srv.setupLocalNode(); err != nil {
 		return err
@@ -503,6 +654,8 @@ func (srv *Server) setupLocalNode() error {
 		return err
 	}
 	srv.nodedb = db
+	srv.scores = newScoreTracker(db, srv.ScoreBanThreshold, srv.ScoreBanDuration, srv.log)
+	srv.bans = newBanList(db, srv.log)
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
 	// TODO: check conflicts
@@ -581,6 +734,7 @@ func (srv *Server) setupDiscovery() error {
 		cfg := discover.Config{
 			PrivateKey:  srv.PrivateKey,
 			NetRestrict: srv.NetRestrict,
+			Banned:      srv.bans.banned,
 			Bootnodes:   srv.BootstrapNodes,
 			Unhandled:   unhandled,
 			Log:         srv.log,
@@ -598,6 +752,7 @@ func (srv *Server) setupDiscovery() error {
 		cfg := discover.Config{
 			PrivateKey:  srv.PrivateKey,
 			NetRestrict: srv.NetRestrict,
+			Banned:      srv.bans.banned,
 			Bootnodes:   srv.BootstrapNodesV5,
 			Log:         srv.log,
 		}
@@ -621,6 +776,8 @@ func (srv *Server) setupDialScheduler() {
 		maxActiveDials: srv.MaxPendingPeers,
 		log:            srv.Logger,
 		netRestrict:    srv.NetRestrict,
+		banned:         srv.dialBanned,
+		groups:         srv.groups,
 		dialer:         srv.Dialer,
 		clock:          srv.clock,
 	}
@@ -701,13 +858,7 @@ func (srv *Server) run() {
 	var (
 		peers        = make(map[enode.ID]*Peer)
 		inboundCount = 0
-		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
 	)
-	// Put trusted nodes into a map to speed up checks.
-	// Trusted peers are loaded on startup or added via AddTrustedPeer RPC.
-	for _, n := range srv.TrustedNodes {
-		trusted[n.ID()] = true
-	}
 
 running:
 	for {
@@ -720,7 +871,7 @@ running:
 			// This channel is used by AddTrustedPeer to add a node
 			// to the trusted node set.
 			srv.log.Trace("Adding trusted node", "node", n)
-			trusted[n.ID()] = true
+			srv.setTrusted(n.ID(), true)
 			if p, ok := peers[n.ID()]; ok {
 				p.rw.set(trustedConn, true)
 			}
@@ -729,7 +880,7 @@ running:
 			// This channel is used by RemoveTrustedPeer to remove a node
 			// from the trusted node set.
 			srv.log.Trace("Removing trusted node", "node", n)
-			delete(trusted, n.ID())
+			srv.setTrusted(n.ID(), false)
 			if p, ok := peers[n.ID()]; ok {
 				p.rw.set(trustedConn, false)
 			}
@@ -742,7 +893,7 @@ running:
 		case c := <-srv.checkpointPostHandshake:
 			// A connection has passed the encryption handshake so
 			// the remote identity is known (but hasn't been verified yet).
-			if trusted[c.node.ID()] {
+			if srv.isTrusted(c.node.ID()) {
 				// Ensure that the trusted flag is set before checking against MaxPeers.
 				c.flags |= trustedConn
 			}
@@ -761,6 +912,10 @@ running:
 				srv.dialsched.peerAdded(c)
 				if p.Inbound() {
 					inboundCount++
+					srv.trackInboundNet(c.node, true)
+				}
+				if g := srv.groups.of(c.node.ID()); g != nil {
+					srv.groupPeers[g]++
 				}
 			}
 			c.cont <- err
@@ -769,10 +924,16 @@ running:
 			// A peer disconnected.
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			delete(peers, pd.ID())
+			delete(srv.evicted, pd.ID())
+			srv.scores.flush(pd.ID())
 			srv.log.Debug("Removing p2p peer", "peercount", len(peers), "id", pd.ID(), "duration", d, "req", pd.requested, "err", pd.err)
 			srv.dialsched.peerRemoved(pd.rw)
 			if pd.Inbound() {
 				inboundCount--
+				srv.trackInboundNet(pd.Node(), false)
+			}
+			if g := srv.groups.of(pd.ID()); g != nil {
+				srv.groupPeers[g]--
 			}
 		}
 	}
@@ -797,14 +958,33 @@ running:
 		p := <-srv.delpeer
 		p.log.Trace("<-delpeer (spindown)")
 		delete(peers, p.ID())
+		srv.scores.flush(p.ID())
 	}
 }
 
 func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
+	// Members of groups with unfilled reserved slots are not subject to the peer
+	// limits, everyone else can't use the slots reserved for the groups. Peers
+	// evicted in favour of better reputed ones don't take up slots anymore.
+	group := srv.groups.of(c.node.ID())
+	full := func() bool {
+		if srv.groupPeers.reserving(group) {
+			return false
+		}
+		evicted := len(srv.evicted)
+		if len(peers)-evicted+srv.groupPeers.unfilled(srv.groups.groups(), group) >= srv.MaxPeers {
+			return true
+		}
+		return c.is(inboundConn) && inboundCount-evicted >= srv.maxInboundConns()
+	}
 	switch {
-	case !c.is(trustedConn) && len(peers) >= srv.MaxPeers:
+	case srv.bans.bannedIP(c.node.IP()) || (!c.is(trustedConn) && srv.bans.bannedNode(c.node.ID())):
+		return DiscUselessPeer
+	case !c.is(trustedConn) && srv.groupPeers.full(group):
+		return DiscTooManyPeers
+	case !c.is(trustedConn) && c.is(inboundConn) && !srv.checkInboundNet(c.node):
 		return DiscTooManyPeers
-	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
+	case !c.is(trustedConn) && full() && !srv.evictForReputation(peers, c):
 		return DiscTooManyPeers
 	case peers[c.node.ID()] != nil:
 		return DiscAlreadyConnected
@@ -815,6 +995,78 @@ func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount in
 	}
 }
 
+// evictForReputation makes room for an inbound connection when the peer set is
+// full, disconnecting the inbound peer with the lowest reputation if the new
+// node is better reputed. It returns whether a peer was evicted.
+func (srv *Server) evictForReputation(peers map[enode.ID]*Peer, c *conn) bool {
+	if !c.is(inboundConn) || peers[c.node.ID()] != nil || c.node.ID() == srv.localnode.ID() {
+		return false
+	}
+	var (
+		victim *Peer
+		lowest = srv.scores.score(c.node.ID())
+	)
+	for id, p := range peers {
+		if srv.evicted[id] || !p.rw.is(inboundConn) || p.rw.is(trustedConn|staticDialedConn) {
+			continue
+		}
+		// Don't evict members filling the reserved slots of their group
+		if g := srv.groups.of(id); g != nil && srv.groupPeers[g] <= g.MinSlots {
+			continue
+		}
+		if score := p.Score(); score < lowest {
+			victim, lowest = p, score
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	victim.log.Debug("Evicting peer for better reputed one", "score", lowest, "new", c.node.ID())
+	srv.evicted[victim.ID()] = true
+	victim.Disconnect(DiscTooManyPeers)
+	return true
+}
+
+// inboundNets returns the set tracking the networks of inbound peers with the
+// same address family as the node, or nil if the node's network is not limited.
+func (srv *Server) inboundNets(n *enode.Node) *netutil.DistinctNetSet {
+	ip := n.IP()
+	switch {
+	case srv.MaxInboundPerSubnet == 0 || ip == nil || netutil.IsLAN(ip):
+		return nil
+	case ip.To4() != nil:
+		return &srv.inboundNets4
+	default:
+		return &srv.inboundNets6
+	}
+}
+
+// checkInboundNet returns whether an inbound connection from the node is within
+// the limit of peers from the same network.
+func (srv *Server) checkInboundNet(n *enode.Node) bool {
+	set := srv.inboundNets(n)
+	if set == nil {
+		return true
+	}
+	if !set.Add(n.IP()) {
+		return false
+	}
+	set.Remove(n.IP())
+	return true
+}
+
+// trackInboundNet adds or removes the network of an inbound peer from the set
+// of tracked networks.
+func (srv *Server) trackInboundNet(n *enode.Node, add bool) {
+	if set := srv.inboundNets(n); set != nil {
+		if add {
+			set.Add(n.IP())
+		} else {
+			set.Remove(n.IP())
+		}
+	}
+}
+
 func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 	// Drop connections with no matching protocols.
 	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
@@ -901,10 +1153,13 @@ func (srv *Server) checkInboundConn(remoteIP net.IP) error {
 	if remoteIP == nil {
 		return nil
 	}
-	// Reject connections that do not match NetRestrict.
+	// Reject connections that do not match NetRestrict or are banned.
 	if srv.NetRestrict != nil && !srv.NetRestrict.Contains(remoteIP) {
 		return fmt.Errorf("not whitelisted in NetRestrict")
 	}
+	if srv.bans.bannedIP(remoteIP) {
+		return errors.New("network banned")
+	}
 	// Reject Internet peers that try too often.
 	now := srv.clock.Now()
 	srv.inboundHistory.expire(now, nil)
@@ -1014,6 +1269,11 @@ func (srv *Server) checkpoint(c *conn, stage chan<- *conn) error {
 
 func (srv *Server) launchPeer(c *conn) *Peer {
 	p := newPeer(srv.log, c, srv.Protocols)
+	p.scores = srv.scores
+	if g := srv.groups.of(c.node.ID()); g != nil {
+		p.group = g.Name
+	}
+	srv.scores.track(c.node.ID())
 	if srv.EnableMsgEvents {
 		// If message events are enabled, pass the peerFeed
 		// to the peer.
@@ -118,6 +118,21 @@ func TestStreamErrors(t *testing.T) {
 		{"817F", calls{"Uint"}, nil, ErrCanonSize},
 		{"8180", calls{"Uint"}, nil, nil},
 
+		// Size-specific integer decoding.
+		{"C0", calls{"Uint64"}, nil, ErrExpectedString},
+		{"820100", calls{"Uint8"}, nil, errUintOverflow},
+		{"83010000", calls{"Uint16"}, nil, errUintOverflow},
+		{"850100000000", calls{"Uint32"}, nil, errUintOverflow},
+		{"89010000000000000000", calls{"Uint64"}, nil, errUintOverflow},
+		{"84FFFFFFFF", calls{"Uint32"}, nil, nil},
+		{"820001", calls{"Uint16"}, nil, ErrCanonInt},
+
+		// Big integers.
+		{"C0", calls{"BigInt"}, nil, ErrExpectedString},
+		{"820001", calls{"BigInt"}, nil, ErrCanonInt},
+		{"8133", calls{"BigInt"}, nil, ErrCanonSize},
+		{"89FFFFFFFFFFFFFFFFFF", calls{"BigInt"}, nil, nil},
+
 		// Non-valid boolean
 		{"02", calls{"Bool"}, nil, errors.New("rlp: invalid boolean value: 2")},
 
@@ -255,6 +270,67 @@ func TestStreamList(t *testing.T) {
 	}
 }
 
+func TestStreamReadBytes(t *testing.T) {
+	tests := []struct {
+		input string
+		size  int
+		err   string
+	}{
+		{input: "C0", size: 1, err: "rlp: expected String or Byte"},
+		{input: "04", size: 0, err: "rlp: input value has wrong size 1, want 0"},
+		{input: "04", size: 1},
+		{input: "04", size: 2, err: "rlp: input value has wrong size 1, want 2"},
+		{input: "820102", size: 0, err: "rlp: input value has wrong size 2, want 0"},
+		{input: "820102", size: 1, err: "rlp: input value has wrong size 2, want 1"},
+		{input: "820102", size: 2},
+		{input: "820102", size: 3, err: "rlp: input value has wrong size 2, want 3"},
+		{input: "8101", size: 1, err: "rlp: non-canonical size information"},
+	}
+	for _, test := range tests {
+		test := test
+		name := fmt.Sprintf("input_%s/size_%d", test.input, test.size)
+		t.Run(name, func(t *testing.T) {
+			s := NewStream(bytes.NewReader(unhex(test.input)), 0)
+			b := make([]byte, test.size)
+			err := s.ReadBytes(b)
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error %q", err)
+				}
+				if want := unhex(test.input); !bytes.HasSuffix(want, b) {
+					t.Errorf("wrong result %x, want suffix of %x", b, want)
+				}
+			} else if err == nil || err.Error() != test.err {
+				t.Errorf("wrong error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestStreamMoreDataInList(t *testing.T) {
+	s := NewStream(bytes.NewReader(unhex("C3C10180")), 0)
+	if s.MoreDataInList() {
+		t.Fatal("MoreDataInList returned true outside of list")
+	}
+	s.List()
+	if !s.MoreDataInList() {
+		t.Fatal("MoreDataInList returned false at start of list")
+	}
+	s.List()
+	s.Uint64()
+	if s.MoreDataInList() {
+		t.Fatal("MoreDataInList returned true at end of inner list")
+	}
+	s.ListEnd()
+	if !s.MoreDataInList() {
+		t.Fatal("MoreDataInList returned false before last element")
+	}
+	s.Bytes()
+	if s.MoreDataInList() {
+		t.Fatal("MoreDataInList returned true at end of list")
+	}
+}
+
 func TestStreamRaw(t *testing.T) {
 	tests := []struct {
 		input  string
@@ -357,6 +433,38 @@ type tailPrivateFields struct {
 	x, y bool   //lint:ignore U1000 unused fields required for testing purposes.
 }
 
+type optionalFields struct {
+	A uint
+	B uint `rlp:"optional"`
+	C uint `rlp:"optional"`
+}
+
+type optionalAndTailField struct {
+	A    uint
+	B    uint   `rlp:"optional"`
+	Tail []uint `rlp:"tail"`
+}
+
+type optionalBigIntField struct {
+	A uint
+	B *big.Int `rlp:"optional"`
+}
+
+type optionalPtrField struct {
+	A uint
+	B *[3]byte `rlp:"optional"`
+}
+
+type nonOptionalPtrField struct {
+	A uint
+	B *[3]byte
+}
+
+type invalidOptional1 struct {
+	A uint `rlp:"optional"`
+	B uint
+}
+
 type nilListUint struct {
 	X *uint `rlp:"nilList"`
 }
@@ -548,6 +656,68 @@ var decodeTests = []decodeTest{
 		error: `rlp: invalid struct tag "tail" for rlp.invalidTail2.B (field type is not slice)`,
 	},
 
+	// struct tag "optional"
+	{
+		input: "C101",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 0, 0},
+	},
+	{
+		input: "C20102",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 2, 0},
+	},
+	{
+		input: "C3010203",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 2, 3},
+	},
+	{
+		input: "C401020304",
+		ptr:   new(optionalFields),
+		error: "rlp: input list has too many elements for rlp.optionalFields",
+	},
+	{
+		input: "C101",
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1},
+	},
+	{
+		input: "C20102",
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1, B: 2, Tail: []uint{}},
+	},
+	{
+		input: "C401020304",
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1, B: 2, Tail: []uint{3, 4}},
+	},
+	{
+		input: "C101",
+		ptr:   new(optionalBigIntField),
+		value: optionalBigIntField{A: 1, B: nil},
+	},
+	{
+		input: "C20102",
+		ptr:   new(optionalBigIntField),
+		value: optionalBigIntField{A: 1, B: big.NewInt(2)},
+	},
+	{
+		input: "C101",
+		ptr:   new(optionalPtrField),
+		value: optionalPtrField{A: 1},
+	},
+	{
+		input: "C50183010203",
+		ptr:   new(optionalPtrField),
+		value: optionalPtrField{A: 1, B: &[3]byte{1, 2, 3}},
+	},
+	{
+		input: "C101",
+		ptr:   new(invalidOptional1),
+		error: `rlp: struct field rlp.invalidOptional1.B needs "optional" tag`,
+	},
+
 	// struct tag "-"
 	{
 		input: "C20102",
@@ -102,7 +102,8 @@ Signed integers, floating point numbers, maps, channels and functions cannot be
 
 Struct Tags
 
-Package rlp honours certain struct tags: "-", "tail", "nil", "nilList" and "nilString".
+Package rlp honours certain struct tags: "-", "tail", "nil", "nilList", "nilString" and
+"optional".
 
 The "-" tag ignores fields.
 
@@ -126,5 +127,18 @@ an empty RLP list.
 The choice of null value can be made explicit with the "nilList" and "nilString" struct
 tags. Using these tags encodes/decodes a Go nil pointer value as the kind of empty
 RLP value defined by the tag.
+
+The "optional" tag allows for a field to be missing from the input list. When decoding a
+list which ends before the field, the field and all subsequent fields are set to their
+zero value. When encoding, trailing optional fields holding the zero value are omitted.
+All fields following an optional field must also be optional or use the "tail" tag.
+
+
+Generated Encoders and Decoders
+
+The reflection-based encoder and decoder can be avoided for performance-sensitive types
+by generating EncodeRLP and DecodeRLP methods with the rlpgen tool in rlp/rlpgen. The
+generated methods honour the struct tags described above and produce the same encoding.
+They use EncoderBuffer, which can also be used to write custom encoders by hand.
 */
 package rlp
@@ -261,6 +261,19 @@ var encTests = []encTest{
 	{val: &tailRaw{A: 1, Tail: []RawValue{unhex("02")}}, output: "C20102"},
 	{val: &tailRaw{A: 1, Tail: []RawValue{}}, output: "C101"},
 	{val: &tailRaw{A: 1, Tail: nil}, output: "C101"},
+
+	// struct tag "optional"
+	{val: &optionalFields{}, output: "C180"},
+	{val: &optionalFields{1, 2, 3}, output: "C3010203"},
+	{val: &optionalFields{1, 0, 3}, output: "C3018003"},
+	{val: &optionalAndTailField{A: 1}, output: "C101"},
+	{val: &optionalAndTailField{A: 1, B: 2}, output: "C20102"},
+	{val: &optionalAndTailField{A: 1, Tail: []uint{5, 6}}, output: "C401800506"},
+	{val: &optionalBigIntField{A: 1}, output: "C101"},
+	{val: &optionalPtrField{A: 1}, output: "C101"},
+	{val: &optionalPtrField{A: 1, B: &[3]byte{1, 2, 3}}, output: "C50183010203"},
+	{val: &nonOptionalPtrField{A: 1}, output: "C20180"}, // encodes without the "optional" tag
+	{val: &invalidOptional1{}, error: `rlp: struct field rlp.invalidOptional1.B needs "optional" tag`},
 	{val: &hasIgnoredField{A: 1, B: 2, C: 3}, output: "C20103"},
 	{val: &intField{X: 3}, error: "rlp: type int is not RLP-serializable (struct field rlp.intField.X)"},
 
@@ -430,6 +443,94 @@ func TestEncodeToReaderReturnToPool(t *testing.T) {
 	wg.Wait()
 }
 
+func TestEncodeAppendToBytes(t *testing.T) {
+	buffer := make([]byte, 20)
+	runEncTests(t, func(val interface{}) ([]byte, error) {
+		w := NewEncoderBuffer(nil)
+		defer w.Flush()
+
+		err := Encode(w, val)
+		if err != nil {
+			return nil, err
+		}
+		output := w.AppendToBytes(buffer[:0])
+		return output, nil
+	})
+}
+
+func TestEncoderBuffer(t *testing.T) {
+	w := NewEncoderBuffer(nil)
+	outer := w.List()
+	w.WriteUint64(0)
+	w.WriteUint64(0x0400)
+	w.WriteBool(true)
+	w.WriteBool(false)
+	w.WriteString("a")
+	w.WriteString("dog")
+	w.WriteBytes([]byte{})
+	w.WriteBigInt(nil)
+	w.WriteBigInt(big.NewInt(0xFFFF))
+	inner := w.List()
+	w.Write(unhex("C101"))
+	w.ListEnd(inner)
+	w.ListEnd(outer)
+	output := w.ToBytes()
+	w.Flush()
+
+	want, _ := EncodeToBytes([]interface{}{
+		uint64(0), uint64(0x0400), true, false, "a", "dog", []byte{}, (*big.Int)(nil), big.NewInt(0xFFFF),
+		[]interface{}{[]uint{1}},
+	})
+	if !bytes.Equal(output, want) {
+		t.Fatalf("wrong output %x, want %x", output, want)
+	}
+}
+
+// encoderBufferStruct encodes itself using EncoderBuffer.
+type encoderBufferStruct struct {
+	A uint64
+	B []byte
+}
+
+func (s *encoderBufferStruct) EncodeRLP(w io.Writer) error {
+	buf := NewEncoderBuffer(w)
+	l := buf.List()
+	buf.WriteUint64(s.A)
+	buf.WriteBytes(s.B)
+	buf.ListEnd(l)
+	return buf.Flush()
+}
+
+func TestEncoderBufferInEncodeRLP(t *testing.T) {
+	type plain encoderBufferStruct
+	val := []*encoderBufferStruct{{1, []byte("foo")}, {0, nil}}
+	for i, fn := range []func(interface{}) ([]byte, error){
+		EncodeToBytes,
+		func(val interface{}) ([]byte, error) {
+			b := new(bytes.Buffer)
+			err := Encode(b, val)
+			return b.Bytes(), err
+		},
+	} {
+		output, err := fn(val)
+		if err != nil {
+			t.Fatalf("encoder %d: error %v", i, err)
+		}
+		want, _ := EncodeToBytes([]*plain{{1, []byte("foo")}, {0, nil}})
+		if !bytes.Equal(output, want) {
+			t.Fatalf("encoder %d: wrong output %x, want %x", i, output, want)
+		}
+	}
+	// Encoding into an io.Writer directly must also work.
+	b := new(bytes.Buffer)
+	if err := val[0].EncodeRLP(b); err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex("C50183666F6F"); !bytes.Equal(b.Bytes(), want) {
+		t.Fatalf("wrong output %x, want %x", b.Bytes(), want)
+	}
+}
+
 var sink interface{}
 
 func BenchmarkIntsize(b *testing.B) {
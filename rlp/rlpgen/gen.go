@@ -0,0 +1,827 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+const (
+	rlpPackagePath = "github.com/ethereum/go-ethereum/rlp"
+
+	// generatedMarker is the first line of every file written by rlpgen. Files
+	// carrying it are skipped when loading the input package.
+	generatedMarker = "// Code generated by rlpgen. DO NOT EDIT."
+)
+
+// buildContext keeps the type information needed to construct ops.
+type buildContext struct {
+	encoderIface *types.Interface
+	decoderIface *types.Interface
+	rawValueType types.Type
+
+	// inProgress tracks the named struct types currently being built
+	// to detect recursive types.
+	inProgress map[*types.Named]bool
+}
+
+func newBuildContext(rlpPkg *types.Package) (*buildContext, error) {
+	lookup := func(name string) (types.Type, error) {
+		obj := rlpPkg.Scope().Lookup(name)
+		if obj == nil {
+			return nil, fmt.Errorf("can't find %s in package %s", name, rlpPkg.Path())
+		}
+		return obj.Type(), nil
+	}
+	enc, err := lookup("Encoder")
+	if err != nil {
+		return nil, err
+	}
+	dec, err := lookup("Decoder")
+	if err != nil {
+		return nil, err
+	}
+	raw, err := lookup("RawValue")
+	if err != nil {
+		return nil, err
+	}
+	return &buildContext{
+		encoderIface: enc.Underlying().(*types.Interface),
+		decoderIface: dec.Underlying().(*types.Interface),
+		rawValueType: raw,
+		inProgress:   make(map[*types.Named]bool),
+	}, nil
+}
+
+// genContext collects imports and hands out temporary variable names while
+// generating code.
+type genContext struct {
+	inPackage   *types.Package
+	imports     map[string]struct{}
+	tempCounter int
+}
+
+func newGenContext(inPackage *types.Package) *genContext {
+	return &genContext{
+		inPackage: inPackage,
+		imports:   make(map[string]struct{}),
+	}
+}
+
+// resetTemp restarts temporary variable numbering, it is called for each
+// generated method.
+func (ctx *genContext) resetTemp() {
+	ctx.tempCounter = 0
+}
+
+// temp returns a fresh temporary variable name.
+func (ctx *genContext) temp() string {
+	v := fmt.Sprintf("_tmp%d", ctx.tempCounter)
+	ctx.tempCounter++
+	return v
+}
+
+func (ctx *genContext) addImport(path string) {
+	if path == ctx.inPackage.Path() {
+		return
+	}
+	ctx.imports[path] = struct{}{}
+}
+
+// importsList returns the sorted list of import paths.
+func (ctx *genContext) importsList() []string {
+	imp := make([]string, 0, len(ctx.imports))
+	for path := range ctx.imports {
+		imp = append(imp, path)
+	}
+	sort.Strings(imp)
+	return imp
+}
+
+// qualify is the types.Qualifier used for printing types.
+func (ctx *genContext) qualify(pkg *types.Package) string {
+	if pkg == ctx.inPackage || pkg.Path() == ctx.inPackage.Path() {
+		return ""
+	}
+	ctx.addImport(pkg.Path())
+	return pkg.Name()
+}
+
+// typeString returns the Go syntax of typ, registering the imports it needs.
+func (ctx *genContext) typeString(typ types.Type) string {
+	return types.TypeString(typ, ctx.qualify)
+}
+
+// rlp returns a qualified reference to the given identifier of package rlp.
+func (ctx *genContext) rlp(name string) string {
+	if ctx.inPackage.Path() == rlpPackagePath {
+		return name
+	}
+	ctx.addImport(rlpPackagePath)
+	return "rlp." + name
+}
+
+// op is a code generator for a single type.
+type op interface {
+	// genWrite creates the encoder. The generated code writes v, which can be
+	// any addressable Go expression, to the rlp.EncoderBuffer 'w'.
+	genWrite(ctx *genContext, v string) string
+
+	// genDecode creates the decoder. The generated code reads a value from the
+	// rlp.Stream 'dec'. It returns the code and an expression holding the result.
+	genDecode(ctx *genContext) (code string, result string)
+}
+
+// basicOp handles the basic types bool, uint* and string.
+type basicOp struct {
+	typ           types.Type
+	writeMethod   string     // EncoderBuffer method writing this type
+	writeArgType  types.Type // parameter type of writeMethod
+	decMethod     string     // Stream method reading this type
+	decResultType types.Type // return type of decMethod
+}
+
+func (bctx *buildContext) makeBasicOp(typ *types.Basic, named types.Type) (op, error) {
+	op := basicOp{typ: named}
+	kind := typ.Kind()
+	switch {
+	case kind == types.Bool:
+		op.writeMethod, op.writeArgType = "WriteBool", types.Typ[types.Bool]
+		op.decMethod, op.decResultType = "Bool", types.Typ[types.Bool]
+	case kind == types.Uint8:
+		op.writeMethod, op.writeArgType = "WriteUint64", types.Typ[types.Uint64]
+		op.decMethod, op.decResultType = "Uint8", types.Typ[types.Uint8]
+	case kind == types.Uint16:
+		op.writeMethod, op.writeArgType = "WriteUint64", types.Typ[types.Uint64]
+		op.decMethod, op.decResultType = "Uint16", types.Typ[types.Uint16]
+	case kind == types.Uint32:
+		op.writeMethod, op.writeArgType = "WriteUint64", types.Typ[types.Uint64]
+		op.decMethod, op.decResultType = "Uint32", types.Typ[types.Uint32]
+	case isUint(kind):
+		op.writeMethod, op.writeArgType = "WriteUint64", types.Typ[types.Uint64]
+		op.decMethod, op.decResultType = "Uint64", types.Typ[types.Uint64]
+	case kind == types.String:
+		op.writeMethod, op.writeArgType = "WriteString", types.Typ[types.String]
+		op.decMethod, op.decResultType = "Bytes", types.NewSlice(types.Typ[types.Byte])
+	default:
+		return nil, fmt.Errorf("rlp: type %v is not RLP-serializable", named)
+	}
+	return op, nil
+}
+
+func (op basicOp) genWrite(ctx *genContext, v string) string {
+	if !types.Identical(op.typ, op.writeArgType) {
+		v = fmt.Sprintf("%s(%s)", ctx.typeString(op.writeArgType), v)
+	}
+	return fmt.Sprintf("w.%s(%s)\n", op.writeMethod, v)
+}
+
+func (op basicOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		result  = resultV
+		b       bytes.Buffer
+	)
+	fmt.Fprintf(&b, "%s, err := dec.%s()\n", resultV, op.decMethod)
+	fmt.Fprintf(&b, "if err != nil {\nreturn err\n}\n")
+	if !types.Identical(op.typ, op.decResultType) {
+		result = fmt.Sprintf("%s(%s)", ctx.typeString(op.typ), resultV)
+	}
+	return b.String(), result
+}
+
+// byteArrayOp handles [N]byte.
+type byteArrayOp struct {
+	typ types.Type
+}
+
+func (op byteArrayOp) genWrite(ctx *genContext, v string) string {
+	return fmt.Sprintf("w.WriteBytes(%s[:])\n", v)
+}
+
+func (op byteArrayOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		b       bytes.Buffer
+	)
+	fmt.Fprintf(&b, "var %s %s\n", resultV, ctx.typeString(op.typ))
+	fmt.Fprintf(&b, "if err := dec.ReadBytes(%s[:]); err != nil {\nreturn err\n}\n", resultV)
+	return b.String(), resultV
+}
+
+// byteSliceOp handles []byte.
+type byteSliceOp struct {
+	typ types.Type
+}
+
+func (op byteSliceOp) genWrite(ctx *genContext, v string) string {
+	return fmt.Sprintf("w.WriteBytes(%s)\n", v)
+}
+
+func (op byteSliceOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		result  = resultV
+		b       bytes.Buffer
+	)
+	fmt.Fprintf(&b, "%s, err := dec.Bytes()\n", resultV)
+	fmt.Fprintf(&b, "if err != nil {\nreturn err\n}\n")
+	if !types.Identical(op.typ, types.NewSlice(types.Typ[types.Byte])) {
+		result = fmt.Sprintf("%s(%s)", ctx.typeString(op.typ), resultV)
+	}
+	return b.String(), result
+}
+
+// rawValueOp handles rlp.RawValue.
+type rawValueOp struct{}
+
+func (op rawValueOp) genWrite(ctx *genContext, v string) string {
+	return fmt.Sprintf("w.Write(%s)\n", v)
+}
+
+func (op rawValueOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		b       bytes.Buffer
+	)
+	fmt.Fprintf(&b, "%s, err := dec.Raw()\n", resultV)
+	fmt.Fprintf(&b, "if err != nil {\nreturn err\n}\n")
+	return b.String(), fmt.Sprintf("%s(%s)", ctx.rlp("RawValue"), resultV)
+}
+
+// bigIntOp handles big.Int and *big.Int.
+type bigIntOp struct {
+	pointer bool
+}
+
+func (op bigIntOp) genWrite(ctx *genContext, v string) string {
+	var b bytes.Buffer
+	if op.pointer {
+		fmt.Fprintf(&b, "if %s == nil {\n", v)
+		fmt.Fprintf(&b, "w.Write(%s)\n", ctx.rlp("EmptyString"))
+		fmt.Fprintf(&b, "} else {\n")
+		fmt.Fprintf(&b, "if %s.Sign() == -1 {\nreturn %s\n}\n", v, ctx.rlp("ErrNegativeBigInt"))
+		fmt.Fprintf(&b, "w.WriteBigInt(%s)\n", v)
+		fmt.Fprintf(&b, "}\n")
+	} else {
+		fmt.Fprintf(&b, "if %s.Sign() == -1 {\nreturn %s\n}\n", v, ctx.rlp("ErrNegativeBigInt"))
+		fmt.Fprintf(&b, "w.WriteBigInt(&%s)\n", v)
+	}
+	return b.String()
+}
+
+func (op bigIntOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		result  = resultV
+		b       bytes.Buffer
+	)
+	fmt.Fprintf(&b, "%s, err := dec.BigInt()\n", resultV)
+	fmt.Fprintf(&b, "if err != nil {\nreturn err\n}\n")
+	if !op.pointer {
+		result = "(*" + resultV + ")"
+	}
+	return b.String(), result
+}
+
+// encoderDecoderOp handles types implementing rlp.Encoder and rlp.Decoder.
+type encoderDecoderOp struct {
+	typ types.Type
+}
+
+func (op encoderDecoderOp) genWrite(ctx *genContext, v string) string {
+	return fmt.Sprintf("if err := %s.EncodeRLP(w); err != nil {\nreturn err\n}\n", v)
+}
+
+func (op encoderDecoderOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		b       bytes.Buffer
+	)
+	fmt.Fprintf(&b, "var %s %s\n", resultV, ctx.typeString(op.typ))
+	fmt.Fprintf(&b, "if err := %s.DecodeRLP(dec); err != nil {\nreturn err\n}\n", resultV)
+	return b.String(), resultV
+}
+
+// reflectOp handles empty interface values, which can only be encoded and
+// decoded through the reflection-based code in package rlp.
+type reflectOp struct {
+	typ types.Type
+}
+
+func (op reflectOp) genWrite(ctx *genContext, v string) string {
+	return fmt.Sprintf("if err := %s(w, %s); err != nil {\nreturn err\n}\n", ctx.rlp("Encode"), v)
+}
+
+func (op reflectOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		b       bytes.Buffer
+	)
+	fmt.Fprintf(&b, "var %s %s\n", resultV, ctx.typeString(op.typ))
+	fmt.Fprintf(&b, "if err := dec.Decode(&%s); err != nil {\nreturn err\n}\n", resultV)
+	return b.String(), resultV
+}
+
+// ptrOp handles pointer types.
+type ptrOp struct {
+	elemTyp types.Type
+	elem    op
+	nilOK   bool
+	nilKind nilKind
+}
+
+func (bctx *buildContext) makePtrOp(elemTyp types.Type, ts tags) (op, error) {
+	elemOp, err := bctx.makeOp(elemTyp, tags{})
+	if err != nil {
+		return nil, err
+	}
+	op := ptrOp{elemTyp: elemTyp, elem: elemOp}
+	if ts.nilOK {
+		op.nilOK = true
+		op.nilKind = ts.nilKind
+	} else {
+		op.nilKind = defaultNilKind(elemTyp)
+	}
+	return op, nil
+}
+
+func (op ptrOp) genWrite(ctx *genContext, v string) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "if %s == nil {\n", v)
+	fmt.Fprintf(&b, "w.Write(%s)\n", ctx.rlp("Empty"+op.nilKind.rlpName()))
+	fmt.Fprintf(&b, "} else {\n")
+	b.WriteString(op.elem.genWrite(ctx, "(*"+v+")"))
+	fmt.Fprintf(&b, "}\n")
+	return b.String()
+}
+
+func (op ptrOp) genDecode(ctx *genContext) (string, string) {
+	if !op.nilOK {
+		code, result := op.elem.genDecode(ctx)
+		ptrCode, ptr := op.addressOf(ctx, result)
+		return code + ptrCode, ptr
+	}
+
+	// Empty values of the nil kind decode as a nil pointer.
+	var (
+		resultV = ctx.temp()
+		kindV   = ctx.temp()
+		sizeV   = ctx.temp()
+		b       bytes.Buffer
+		typ     = types.NewPointer(op.elemTyp)
+		want    = ctx.rlp(op.nilKind.rlpName())
+	)
+	fmt.Fprintf(&b, "var %s %s\n", resultV, ctx.typeString(typ))
+	fmt.Fprintf(&b, "if %s, %s, err := dec.Kind(); err != nil {\n", kindV, sizeV)
+	fmt.Fprintf(&b, "return err\n")
+	fmt.Fprintf(&b, "} else if %s != %s && %s == 0 {\n", kindV, ctx.rlp("Byte"), sizeV)
+	fmt.Fprintf(&b, "if %s != %s {\n", kindV, want)
+	fmt.Fprintf(&b, "return fmt.Errorf(\"rlp: wrong kind of empty value (got %%v, want %%v) for %s\", %s, %s)\n", ctx.typeString(typ), kindV, want)
+	ctx.addImport("fmt")
+	fmt.Fprintf(&b, "}\n")
+	if op.nilKind == nilKindList {
+		fmt.Fprintf(&b, "if _, err := dec.List(); err != nil {\nreturn err\n}\n")
+		fmt.Fprintf(&b, "if err := dec.ListEnd(); err != nil {\nreturn err\n}\n")
+	} else {
+		fmt.Fprintf(&b, "if _, err := dec.Bytes(); err != nil {\nreturn err\n}\n")
+	}
+	fmt.Fprintf(&b, "} else {\n")
+	code, result := op.elem.genDecode(ctx)
+	b.WriteString(code)
+	ptrCode, ptr := op.addressOf(ctx, result)
+	b.WriteString(ptrCode)
+	fmt.Fprintf(&b, "%s = %s\n", resultV, ptr)
+	fmt.Fprintf(&b, "}\n")
+	return b.String(), resultV
+}
+
+// addressOf returns a pointer to the decoding result expr.
+func (op ptrOp) addressOf(ctx *genContext, expr string) (string, string) {
+	if token.IsIdentifier(expr) {
+		return "", "&" + expr
+	}
+	ptrV := ctx.temp()
+	code := fmt.Sprintf("%s := new(%s)\n*%s = %s\n", ptrV, ctx.typeString(op.elemTyp), ptrV, expr)
+	return code, ptrV
+}
+
+// sliceOp handles slices and arrays of non-byte elements.
+type sliceOp struct {
+	typ     types.Type
+	isArray bool
+	tail    bool // if set, elements are written into the enclosing list
+	elem    op
+}
+
+func (op sliceOp) genWrite(ctx *genContext, v string) string {
+	var (
+		listV = ctx.temp()
+		elemV = ctx.temp()
+		b     bytes.Buffer
+	)
+	if !op.tail {
+		fmt.Fprintf(&b, "%s := w.List()\n", listV)
+	}
+	fmt.Fprintf(&b, "for _, %s := range %s {\n", elemV, v)
+	b.WriteString(op.elem.genWrite(ctx, elemV))
+	fmt.Fprintf(&b, "}\n")
+	if !op.tail {
+		fmt.Fprintf(&b, "w.ListEnd(%s)\n", listV)
+	}
+	return b.String()
+}
+
+func (op sliceOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		b       bytes.Buffer
+	)
+	if op.isArray {
+		indexV := ctx.temp()
+		fmt.Fprintf(&b, "var %s %s\n", resultV, ctx.typeString(op.typ))
+		fmt.Fprintf(&b, "if _, err := dec.List(); err != nil {\nreturn err\n}\n")
+		fmt.Fprintf(&b, "for %s := range %s {\n", indexV, resultV)
+		b.WriteString(genCheckMoreData(ctx, "input list has too few elements for "+typeName(op.typ)))
+		code, result := op.elem.genDecode(ctx)
+		b.WriteString(code)
+		fmt.Fprintf(&b, "%s[%s] = %s\n", resultV, indexV, result)
+		fmt.Fprintf(&b, "}\n")
+		fmt.Fprintf(&b, "if err := dec.ListEnd(); err != nil {\nreturn err\n}\n")
+		return b.String(), resultV
+	}
+
+	// Slices decode as non-nil even when the input list is empty.
+	fmt.Fprintf(&b, "%s := %s{}\n", resultV, ctx.typeString(op.typ))
+	if !op.tail {
+		fmt.Fprintf(&b, "if _, err := dec.List(); err != nil {\nreturn err\n}\n")
+	}
+	fmt.Fprintf(&b, "for dec.MoreDataInList() {\n")
+	code, result := op.elem.genDecode(ctx)
+	b.WriteString(code)
+	fmt.Fprintf(&b, "%s = append(%s, %s)\n", resultV, resultV, result)
+	fmt.Fprintf(&b, "}\n")
+	if !op.tail {
+		fmt.Fprintf(&b, "if err := dec.ListEnd(); err != nil {\nreturn err\n}\n")
+	}
+	return b.String(), resultV
+}
+
+// genCheckMoreData creates a check for the end of the current list. It is placed
+// before each required list element. Running into the end of the list must not
+// return rlp.EOL, because the reflection-based decoder in package rlp treats EOL
+// returned by an element decoder as the end of the enclosing list.
+func genCheckMoreData(ctx *genContext, msg string) string {
+	ctx.addImport("errors")
+	return fmt.Sprintf("if !dec.MoreDataInList() {\nreturn errors.New(%q)\n}\n", "rlp: "+msg)
+}
+
+// structField is a single field of a struct.
+type structField struct {
+	name string
+	typ  types.Type
+	elem op
+}
+
+// structOp handles struct types.
+type structOp struct {
+	typ            types.Type
+	fields         []*structField
+	optionalFields []*structField // fields following the first "optional" field
+}
+
+func (bctx *buildContext) makeStructOp(typ types.Type, styp *types.Struct) (op, error) {
+	var (
+		op          = structOp{typ: typ}
+		name        = typeName(typ)
+		lastPublic  = lastPublicField(styp)
+		anyOptional = false
+	)
+	for i := 0; i < styp.NumFields(); i++ {
+		f := styp.Field(i)
+		if !f.Exported() {
+			continue
+		}
+		ts, err := parseTags(styp, name, i, lastPublic)
+		if err != nil {
+			return nil, err
+		}
+		if ts.ignored {
+			continue
+		}
+		// If any field has the "optional" tag, subsequent fields must also have it.
+		if ts.optional || ts.tail {
+			anyOptional = true
+		} else if anyOptional {
+			return nil, fmt.Errorf(`rlp: struct field %s.%s needs "optional" tag`, name, f.Name())
+		}
+		fop, err := bctx.makeOp(f.Type(), ts)
+		if err != nil {
+			return nil, fmt.Errorf("%v (struct field %s.%s)", err, name, f.Name())
+		}
+		field := &structField{name: f.Name(), typ: f.Type(), elem: fop}
+		if ts.optional || len(op.optionalFields) > 0 {
+			if !canCheckZero(f.Type()) {
+				return nil, fmt.Errorf("rlp: optional field %s.%s has unsupported type %v", name, f.Name(), f.Type())
+			}
+			op.optionalFields = append(op.optionalFields, field)
+		} else {
+			op.fields = append(op.fields, field)
+		}
+	}
+	return op, nil
+}
+
+func (op structOp) genWrite(ctx *genContext, v string) string {
+	var (
+		listV = ctx.temp()
+		b     bytes.Buffer
+	)
+	fmt.Fprintf(&b, "%s := w.List()\n", listV)
+	for _, field := range op.fields {
+		b.WriteString(field.elem.genWrite(ctx, v+"."+field.name))
+	}
+	op.writeOptionalFields(&b, ctx, v)
+	fmt.Fprintf(&b, "w.ListEnd(%s)\n", listV)
+	return b.String()
+}
+
+// writeOptionalFields generates the encoder for the optional fields. A field is
+// written if it or any subsequent field is non-zero.
+func (op structOp) writeOptionalFields(b *bytes.Buffer, ctx *genContext, v string) {
+	if len(op.optionalFields) == 0 {
+		return
+	}
+	zeroV := make([]string, len(op.optionalFields))
+	for i, field := range op.optionalFields {
+		zeroV[i] = ctx.temp()
+		fmt.Fprintf(b, "%s := %s\n", zeroV[i], nonZeroCheck(ctx, field.typ, v+"."+field.name))
+	}
+	for i, field := range op.optionalFields {
+		fmt.Fprintf(b, "if %s {\n", strings.Join(zeroV[i:], " || "))
+		b.WriteString(field.elem.genWrite(ctx, v+"."+field.name))
+		fmt.Fprintf(b, "}\n")
+	}
+}
+
+func (op structOp) genDecode(ctx *genContext) (string, string) {
+	var (
+		resultV = ctx.temp()
+		b       bytes.Buffer
+	)
+	fmt.Fprintf(&b, "var %s %s\n", resultV, ctx.typeString(op.typ))
+	fmt.Fprintf(&b, "{\n")
+	fmt.Fprintf(&b, "if _, err := dec.List(); err != nil {\nreturn err\n}\n")
+	for _, field := range op.fields {
+		fmt.Fprintf(&b, "// %s:\n", field.name)
+		b.WriteString(genCheckMoreData(ctx, "too few elements for "+typeName(op.typ)))
+		op.decodeField(&b, ctx, resultV, field)
+	}
+	op.decodeOptionalFields(&b, ctx, resultV)
+	fmt.Fprintf(&b, "if err := dec.ListEnd(); err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(&b, "}\n")
+	return b.String(), resultV
+}
+
+func (op structOp) decodeField(b *bytes.Buffer, ctx *genContext, resultV string, field *structField) {
+	code, result := field.elem.genDecode(ctx)
+	b.WriteString(code)
+	fmt.Fprintf(b, "%s.%s = %s\n", resultV, field.name, result)
+}
+
+// decodeOptionalFields generates the decoder for the optional fields. Each
+// field is only decoded if the input list has more elements, and the fields
+// following it are decoded within the same condition.
+func (op structOp) decodeOptionalFields(b *bytes.Buffer, ctx *genContext, resultV string) {
+	for _, field := range op.optionalFields {
+		if sop, ok := field.elem.(sliceOp); ok && sop.tail {
+			// The tail field consumes all remaining elements, it doesn't
+			// need a condition.
+			fmt.Fprintf(b, "{\n")
+		} else {
+			fmt.Fprintf(b, "if dec.MoreDataInList() {\n")
+		}
+		fmt.Fprintf(b, "// %s:\n", field.name)
+		op.decodeField(b, ctx, resultV, field)
+	}
+	for range op.optionalFields {
+		fmt.Fprintf(b, "}\n")
+	}
+}
+
+// nonZeroCheck returns an expression which is true when v is not the zero value
+// of typ, matching reflect.Value.IsZero.
+func nonZeroCheck(ctx *genContext, typ types.Type, v string) string {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Kind() == types.Bool:
+			return v
+		case t.Kind() == types.String:
+			return v + ` != ""`
+		default:
+			return v + " != 0"
+		}
+	case *types.Array, *types.Struct:
+		if isBigInt(typ) {
+			return v + ".Sign() != 0"
+		}
+		return fmt.Sprintf("%s != (%s{})", v, ctx.typeString(typ))
+	default:
+		return v + " != nil"
+	}
+}
+
+// canCheckZero reports whether nonZeroCheck supports typ.
+func canCheckZero(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Array, *types.Struct:
+		return isBigInt(typ) || types.Comparable(typ)
+	default:
+		return true
+	}
+}
+
+// typeName returns the name of typ as printed by package reflect.
+func typeName(typ types.Type) string {
+	return types.TypeString(typ, func(pkg *types.Package) string { return pkg.Name() })
+}
+
+// makeOp creates the op for typ. The order of the checks mirrors makeWriter and
+// makeDecoder in package rlp.
+func (bctx *buildContext) makeOp(typ types.Type, ts tags) (op, error) {
+	if types.Identical(typ, bctx.rawValueType) {
+		return rawValueOp{}, nil
+	}
+	if isBigInt(typ) {
+		return bigIntOp{}, nil
+	}
+	if ptr, ok := typ.(*types.Pointer); ok && isBigInt(ptr.Elem()) {
+		return bigIntOp{pointer: true}, nil
+	}
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+		return bctx.makePtrOp(ptr.Elem(), ts)
+	}
+	if op, ok, err := bctx.makeEncoderDecoderOp(typ); ok || err != nil {
+		return op, err
+	}
+
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		return bctx.makeBasicOp(t, typ)
+	case *types.Slice:
+		if isByte(t.Elem()) {
+			return byteSliceOp{typ}, nil
+		}
+		return bctx.makeSliceOp(typ, t.Elem(), false, ts)
+	case *types.Array:
+		if isByte(t.Elem()) {
+			return byteArrayOp{typ}, nil
+		}
+		return bctx.makeSliceOp(typ, t.Elem(), true, ts)
+	case *types.Struct:
+		named, ok := typ.(*types.Named)
+		if ok {
+			if bctx.inProgress[named] {
+				return nil, fmt.Errorf("rlp: recursive type %v is not supported", typeName(typ))
+			}
+			bctx.inProgress[named] = true
+			defer delete(bctx.inProgress, named)
+		}
+		return bctx.makeStructOp(typ, t)
+	case *types.Interface:
+		if t.NumMethods() != 0 {
+			return nil, fmt.Errorf("rlp: type %v is not RLP-serializable", typeName(typ))
+		}
+		return reflectOp{typ}, nil
+	}
+	return nil, fmt.Errorf("rlp: type %v is not RLP-serializable", typeName(typ))
+}
+
+func (bctx *buildContext) makeSliceOp(typ, elemTyp types.Type, isArray bool, ts tags) (op, error) {
+	elemOp, err := bctx.makeOp(elemTyp, tags{})
+	if err != nil {
+		return nil, err
+	}
+	return sliceOp{typ: typ, isArray: isArray, tail: ts.tail, elem: elemOp}, nil
+}
+
+// makeEncoderDecoderOp returns an op for types that implement rlp.Encoder and
+// rlp.Decoder. The boolean result is false if typ implements neither.
+func (bctx *buildContext) makeEncoderDecoderOp(typ types.Type) (op, bool, error) {
+	ptr := types.NewPointer(typ)
+	isEncoder := types.Implements(ptr, bctx.encoderIface)
+	isDecoder := types.Implements(ptr, bctx.decoderIface)
+	switch {
+	case isEncoder && isDecoder:
+		return encoderDecoderOp{typ}, true, nil
+	case isEncoder || isDecoder:
+		return nil, false, fmt.Errorf("rlp: type %v implements only one of rlp.Encoder and rlp.Decoder", typeName(typ))
+	default:
+		return nil, false, nil
+	}
+}
+
+// generate creates the RLP methods of the named struct type typeName in pkg.
+func generate(pkg, rlpPkg *types.Package, typeName string, encoder, decoder bool) ([]byte, error) {
+	obj := pkg.Scope().Lookup(typeName)
+	if obj == nil {
+		return nil, fmt.Errorf("no such type %s in package %s", typeName, pkg.Path())
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a named type", typeName)
+	}
+	styp, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct type", typeName)
+	}
+	bctx, err := newBuildContext(rlpPkg)
+	if err != nil {
+		return nil, err
+	}
+	bctx.inProgress[named] = true
+	op, err := bctx.makeStructOp(named, styp)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ctx  = newGenContext(pkg)
+		body bytes.Buffer
+	)
+	if encoder {
+		ctx.resetTemp()
+		ctx.addImport("io")
+		fmt.Fprintf(&body, "func (obj *%s) EncodeRLP(_w io.Writer) error {\n", typeName)
+		fmt.Fprintf(&body, "w := %s(_w)\n", ctx.rlp("NewEncoderBuffer"))
+		body.WriteString(op.genWrite(ctx, "obj"))
+		fmt.Fprintf(&body, "return w.Flush()\n")
+		fmt.Fprintf(&body, "}\n\n")
+	}
+	if decoder {
+		ctx.resetTemp()
+		fmt.Fprintf(&body, "func (obj *%s) DecodeRLP(dec *%s) error {\n", typeName, ctx.rlp("Stream"))
+		code, result := op.genDecode(ctx)
+		body.WriteString(code)
+		fmt.Fprintf(&body, "*obj = %s\n", result)
+		fmt.Fprintf(&body, "return nil\n")
+		fmt.Fprintf(&body, "}\n")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s\n\n", generatedMarker)
+	fmt.Fprintf(&out, "package %s\n\n", pkg.Name())
+	writeImports(&out, ctx.importsList())
+	out.Write(body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("can't format generated code: %v", err)
+	}
+	return src, nil
+}
+
+// writeImports writes the import declaration, with standard library packages
+// grouped before all others.
+func writeImports(out *bytes.Buffer, paths []string) {
+	var std, other []string
+	for _, path := range paths {
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	fmt.Fprintf(out, "import (\n")
+	for _, path := range std {
+		fmt.Fprintf(out, "%q\n", path)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		fmt.Fprintf(out, "\n")
+	}
+	for _, path := range other {
+		fmt.Fprintf(out, "%q\n", path)
+	}
+	fmt.Fprintf(out, ")\n\n")
+}
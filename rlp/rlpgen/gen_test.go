@@ -0,0 +1,131 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var tests = []string{"uints", "bytes", "bigint", "nil", "optional", "rawvalue", "nested"}
+
+// TestOutput checks the generated code against the files in testdata.
+func TestOutput(t *testing.T) {
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	rlpPkg, err := imp.Import(rlpPackagePath)
+	if err != nil {
+		t.Fatal("can't load package rlp:", err)
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test, func(t *testing.T) {
+			pkg := loadTestSource(t, fset, imp, filepath.Join("testdata", test+".in.txt"))
+			output, err := generate(pkg, rlpPkg, "Test", true, true)
+			if err != nil {
+				t.Fatal("generate error:", err)
+			}
+			want, err := ioutil.ReadFile(filepath.Join("testdata", test+".out.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(output, want) {
+				t.Fatalf("output mismatch, want:\n%s\ngot:\n%s", want, output)
+			}
+		})
+	}
+}
+
+var errorTests = []struct {
+	input string
+	err   string
+}{
+	{
+		input: "type Test struct { A int }",
+		err:   "rlp: type int is not RLP-serializable (struct field test.Test.A)",
+	},
+	{
+		input: "type Test struct { A uint `rlp:\"optional\"`; B uint }",
+		err:   `rlp: struct field test.Test.B needs "optional" tag`,
+	},
+	{
+		input: "type Test struct { A []uint `rlp:\"tail\"`; B uint }",
+		err:   `rlp: invalid struct tag "tail" for test.Test.A (must be on last field)`,
+	},
+	{
+		input: "type Test struct { A uint `rlp:\"nil\"` }",
+		err:   `rlp: invalid struct tag "nil" for test.Test.A (field is not a pointer)`,
+	},
+	{
+		input: "type Test struct { A []Test }",
+		err:   "rlp: recursive type test.Test is not supported (struct field test.Test.A)",
+	},
+	{
+		input: "type Test struct { A map[string]uint }",
+		err:   "rlp: type map[string]uint is not RLP-serializable (struct field test.Test.A)",
+	},
+}
+
+func TestErrors(t *testing.T) {
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	rlpPkg, err := imp.Import(rlpPackagePath)
+	if err != nil {
+		t.Fatal("can't load package rlp:", err)
+	}
+	for i, test := range errorTests {
+		f, err := parser.ParseFile(fset, "input.go", "package test\n"+test.input, 0)
+		if err != nil {
+			t.Fatalf("test %d: parse error: %v", i, err)
+		}
+		pkg, err := new(types.Config).Check("test", fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatalf("test %d: type error: %v", i, err)
+		}
+		_, err = generate(pkg, rlpPkg, "Test", true, true)
+		if err == nil {
+			t.Errorf("test %d: expected error %q", i, test.err)
+		} else if err.Error() != test.err {
+			t.Errorf("test %d: wrong error\n got: %v\nwant: %v", i, err, test.err)
+		}
+	}
+}
+
+func loadTestSource(t *testing.T, fset *token.FileSet, imp types.Importer, file string) *types.Package {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(fset, file, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal("parse error:", err)
+	}
+	conf := types.Config{Importer: imp}
+	pkg, err := conf.Check(strings.TrimSuffix(filepath.Base(file), ".in.txt"), fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal("type error:", err)
+	}
+	return pkg
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// rlpgen generates EncodeRLP and DecodeRLP methods for struct types, avoiding
+// the reflection-based encoder and decoder of package rlp. The generated code
+// honours the struct tags understood by package rlp.
+//
+// It is meant to be used with go:generate, for example:
+//
+//	//go:generate go run ../../rlp/rlpgen -type Header -out gen_header_rlp.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	dirFlag     = flag.String("dir", ".", "input package directory")
+	typeFlag    = flag.String("type", "", "type to generate methods for")
+	outputFlag  = flag.String("out", "-", "output file (default is stdout)")
+	encoderFlag = flag.Bool("encoder", true, "generate EncodeRLP")
+	decoderFlag = flag.Bool("decoder", true, "generate DecodeRLP")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "-type <name> [-dir <package dir>] [-out <file>]")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr, `
+Generates EncodeRLP and DecodeRLP methods for the given struct type.`)
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *typeFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: -type is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if !*encoderFlag && !*decoderFlag {
+		fatal(errors.New("nothing to generate, both -encoder and -decoder are disabled"))
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil).(types.ImporterFrom)
+	pkg, err := loadPackage(fset, imp, *dirFlag)
+	if err != nil {
+		fatal(err)
+	}
+	rlpPkg, err := imp.ImportFrom(rlpPackagePath, *dirFlag, 0)
+	if err != nil {
+		fatal(fmt.Errorf("can't load package rlp: %v", err))
+	}
+	code, err := generate(pkg, rlpPkg, *typeFlag, *encoderFlag, *decoderFlag)
+	if err != nil {
+		fatal(err)
+	}
+	if *outputFlag == "-" {
+		os.Stdout.Write(code)
+	} else if err := ioutil.WriteFile(*outputFlag, code, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
+}
+
+// loadPackage parses and type-checks the Go package in dir. Files generated by
+// rlpgen are left out, so the output doesn't depend on earlier runs.
+func loadPackage(fset *token.FileSet, imp types.Importer, dir string) (*types.Package, error) {
+	bpkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, name := range bpkg.GoFiles {
+		path := filepath.Join(dir, name)
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if isGenerated(src) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	// Type errors are tolerated because handwritten code in the package may
+	// refer to methods defined by the generated files that were left out.
+	conf := types.Config{Importer: imp, Error: func(error) {}}
+	pkg, _ := conf.Check(packagePath(bpkg), fset, files, nil)
+	if pkg == nil {
+		return nil, fmt.Errorf("can't type-check package in %s", dir)
+	}
+	return pkg, nil
+}
+
+// packagePath returns the import path of the package, falling back to its name
+// when the path can't be determined.
+func packagePath(bpkg *build.Package) string {
+	if bpkg.ImportPath != "" && bpkg.ImportPath != "." {
+		return bpkg.ImportPath
+	}
+	return bpkg.Name
+}
+
+// isGenerated reports whether src was written by rlpgen.
+func isGenerated(src []byte) bool {
+	line := src
+	if i := bytes.IndexByte(src, '\n'); i >= 0 {
+		line = src[:i]
+	}
+	return strings.TrimSpace(string(line)) == generatedMarker
+}
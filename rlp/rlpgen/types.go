@@ -0,0 +1,170 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+// tags represents the rlp struct tags of a field. They mirror the tags
+// understood by package rlp, see rlp/typecache.go.
+type tags struct {
+	// rlp:"nil" controls whether empty input results in a nil pointer.
+	// nilKind is the kind of empty value allowed for the field.
+	nilKind nilKind
+	nilOK   bool
+
+	// rlp:"optional" allows for a field to be missing in the input list.
+	optional bool
+
+	// rlp:"tail" controls whether this field swallows additional list elements.
+	tail bool
+
+	// rlp:"-" ignores fields.
+	ignored bool
+}
+
+type nilKind int
+
+const (
+	nilKindString nilKind = iota
+	nilKindList
+)
+
+// rlpName returns the name of the corresponding rlp.Kind constant.
+func (k nilKind) rlpName() string {
+	if k == nilKindList {
+		return "List"
+	}
+	return "String"
+}
+
+type tagError struct {
+	structName string
+	fieldName  string
+	tag        string
+	err        string
+}
+
+func (e tagError) Error() string {
+	return fmt.Sprintf("rlp: invalid struct tag %q for %s.%s (%s)", e.tag, e.structName, e.fieldName, e.err)
+}
+
+// parseTags parses the rlp tags of field i in struct type typ. lastPublic is the
+// index of the last exported field.
+func parseTags(typ *types.Struct, typeName string, i, lastPublic int) (tags, error) {
+	var (
+		f   = typ.Field(i)
+		ts  tags
+		tag = reflect.StructTag(typ.Tag(i)).Get("rlp")
+	)
+	for _, t := range strings.Split(tag, ",") {
+		switch t = strings.TrimSpace(t); t {
+		case "":
+		case "-":
+			ts.ignored = true
+		case "nil", "nilString", "nilList":
+			ts.nilOK = true
+			ptr, ok := f.Type().Underlying().(*types.Pointer)
+			if !ok {
+				return ts, tagError{typeName, f.Name(), t, "field is not a pointer"}
+			}
+			switch t {
+			case "nil":
+				ts.nilKind = defaultNilKind(ptr.Elem())
+			case "nilString":
+				ts.nilKind = nilKindString
+			case "nilList":
+				ts.nilKind = nilKindList
+			}
+		case "optional":
+			ts.optional = true
+			if ts.tail {
+				return ts, tagError{typeName, f.Name(), t, `also has "tail" tag`}
+			}
+		case "tail":
+			ts.tail = true
+			if i != lastPublic {
+				return ts, tagError{typeName, f.Name(), t, "must be on last field"}
+			}
+			if ts.optional {
+				return ts, tagError{typeName, f.Name(), t, `also has "optional" tag`}
+			}
+			if _, ok := f.Type().Underlying().(*types.Slice); !ok {
+				return ts, tagError{typeName, f.Name(), t, "field type is not slice"}
+			}
+		default:
+			return ts, fmt.Errorf("rlp: unknown struct tag %q on %s.%s", t, typeName, f.Name())
+		}
+	}
+	return ts, nil
+}
+
+// lastPublicField returns the index of the last exported field of typ.
+func lastPublicField(typ *types.Struct) int {
+	last := 0
+	for i := 0; i < typ.NumFields(); i++ {
+		if typ.Field(i).Exported() {
+			last = i
+		}
+	}
+	return last
+}
+
+// defaultNilKind returns the kind of empty value a nil pointer to typ encodes
+// as when no explicit nil kind is given. This matches rlp's defaultNilKind.
+func defaultNilKind(typ types.Type) nilKind {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		if isUint(t.Kind()) || t.Kind() == types.String || t.Kind() == types.Bool {
+			return nilKindString
+		}
+	case *types.Array:
+		if isByte(t.Elem()) {
+			return nilKindString
+		}
+	case *types.Slice:
+		if isByte(t.Elem()) {
+			return nilKindString
+		}
+	}
+	return nilKindList
+}
+
+// isBigInt reports whether typ is math/big.Int.
+func isBigInt(typ types.Type) bool {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return false
+	}
+	name := named.Obj()
+	return name.Pkg() != nil && name.Pkg().Path() == "math/big" && name.Name() == "Int"
+}
+
+// isByte reports whether typ is a uint8 type.
+func isByte(typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.Uint8
+}
+
+// isUint reports whether typ is one of the unsigned integer kinds supported by rlp.
+func isUint(kind types.BasicKind) bool {
+	return kind >= types.Uint && kind <= types.Uintptr
+}
@@ -126,9 +126,9 @@ type Metadata struct {
 	Origin    string `json:"Origin"`
 }
 
-func StartClefAccountManager(ksLocation string, nousb, lightKDF bool, scpath string) *accounts.Manager {
+func StartClefAccountManager(ksLocation string, nousb, lightKDF bool, scpath string, extra ...accounts.Backend) *accounts.Manager {
 	var (
-		backends []accounts.Backend
+		backends = append([]accounts.Backend{}, extra...)
 		n, p     = keystore.StandardScryptN, keystore.StandardScryptP
 	)
 	if lightKDF {
@@ -237,6 +237,7 @@ type (
 		Messages    []*NameValueType        `json:"messages"`
 		Callinfo    []ValidationInfo        `json:"call_info"`
 		Hash        hexutil.Bytes           `json:"hash"`
+		Domain      *TypedDataDomain        `json:"domain,omitempty"` // Domain of typed data requests
 		Meta        Metadata                `json:"meta"`
 	}
 	SignDataResponse struct {
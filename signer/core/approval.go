@@ -0,0 +1,540 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+var (
+	// ErrUnknownApproval is returned when voting on a request which is not
+	// (or no longer) awaiting approval.
+	ErrUnknownApproval = errors.New("unknown approval request")
+
+	// ErrNotApprover is returned when a vote or a listing of the pending requests
+	// is not signed by an approver.
+	ErrNotApprover = errors.New("not signed by an approver")
+
+	// ErrStaleListing is returned when a listing of the pending requests is signed
+	// for a time too far from the current one.
+	ErrStaleListing = errors.New("listing signature expired")
+
+	// ErrAlreadyVoted is returned when an approver votes twice on a request.
+	ErrAlreadyVoted = errors.New("approver already voted")
+)
+
+const (
+	// approvalsKey is the storage key of the requests awaiting approval.
+	approvalsKey = "approvals"
+
+	// approvalListWindow is the maximum difference between the time a listing
+	// of the pending requests is signed for and the current time.
+	approvalListWindow = 5 * time.Minute
+)
+
+// ApprovalConfig defines the accounts whose signing requests need the approval
+// of multiple parties: a request is approved once Threshold of the Approvers
+// voted in its favour, and rejected once that became impossible or the timeout
+// elapsed.
+type ApprovalConfig struct {
+	Accounts  []common.Address `json:"accounts"`
+	Approvers []common.Address `json:"approvers"`
+	Threshold int              `json:"threshold"`
+	Timeout   string           `json:"timeout"` // Time to collect the votes, e.g. "1h"
+}
+
+// ParseApprovalConfig decodes and validates a JSON encoded approval config.
+func ParseApprovalConfig(blob []byte) (*ApprovalConfig, error) {
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.DisallowUnknownFields()
+
+	config := new(ApprovalConfig)
+	if err := dec.Decode(config); err != nil {
+		return nil, err
+	}
+	if _, err := config.timeout(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// timeout validates the config, returning the parsed voting timeout.
+func (c *ApprovalConfig) timeout() (time.Duration, error) {
+	seen := make(map[common.Address]bool)
+	for _, approver := range c.Approvers {
+		if seen[approver] {
+			return 0, fmt.Errorf("duplicate approver %s", approver.Hex())
+		}
+		seen[approver] = true
+	}
+	if c.Threshold < 1 || c.Threshold > len(c.Approvers) {
+		return 0, fmt.Errorf("invalid threshold %d of %d approvers", c.Threshold, len(c.Approvers))
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", c.Timeout)
+	}
+	return timeout, nil
+}
+
+// ApprovalStatus is the state of a request in the approval workflow.
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+	ApprovalExpired  ApprovalStatus = "expired"
+)
+
+// ApprovalRequest is a signing request submitted to the approvers.
+type ApprovalRequest struct {
+	ID         string           `json:"id"`
+	Account    common.Address   `json:"account"`
+	Kind       string           `json:"kind"`    // Type of the request, "transaction" or "data"
+	Request    json.RawMessage  `json:"request"` // Signing request as presented to the UI
+	Digest     common.Hash      `json:"digest"`  // Hash of the data to sign, identifying resubmissions
+	Created    int64            `json:"created"`
+	Deadline   int64            `json:"deadline"`
+	Approvals  []common.Address `json:"approvals"`
+	Rejections []common.Address `json:"rejections"`
+	Status     ApprovalStatus   `json:"status"`
+}
+
+// voted returns whether the approver already voted on the request.
+func (req *ApprovalRequest) voted(approver common.Address) bool {
+	for _, addr := range append(req.Approvals, req.Rejections...) {
+		if addr == approver {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalAuditor records the steps of the approval workflow.
+type ApprovalAuditor interface {
+	ApprovalRequested(req *ApprovalRequest)
+	ApprovalVoted(req *ApprovalRequest, approver common.Address, approve bool)
+	ApprovalResolved(req *ApprovalRequest)
+}
+
+// pendingApproval is a request awaiting votes, along with the channel notifying
+// the requester about its resolution.
+type pendingApproval struct {
+	req      *ApprovalRequest
+	done     chan struct{} // Closed when the request is no longer pending
+	expiry   *time.Timer   // Timer expiring the request at its deadline
+	attached bool          // Whether a requester awaits the outcome, false if restored
+}
+
+// MultiApprovalUI is a UIClientAPI which submits the signing requests of the
+// configured accounts to a group of approvers, only approving them once enough
+// approvers voted in favour. Requests of other accounts are forwarded to the
+// next UI.
+type MultiApprovalUI struct {
+	next      UIClientAPI
+	config    *ApprovalConfig
+	timeout   time.Duration
+	accounts  map[common.Address]struct{}
+	approvers map[common.Address]struct{}
+	db        storage.Storage
+	audit     ApprovalAuditor
+
+	pending map[string]*pendingApproval
+	lock    sync.Mutex
+}
+
+// NewMultiApprovalUI creates a UI collecting the approvals of the configured
+// approvers. Requests awaiting approval are persisted into db. Requests left
+// over from a previous run keep collecting votes until their deadline, and are
+// resumed once their requester submits them again. The auditor is optional.
+func NewMultiApprovalUI(next UIClientAPI, config *ApprovalConfig, db storage.Storage, audit ApprovalAuditor) (*MultiApprovalUI, error) {
+	timeout, err := config.timeout()
+	if err != nil {
+		return nil, err
+	}
+	ui := &MultiApprovalUI{
+		next:      next,
+		config:    config,
+		timeout:   timeout,
+		accounts:  make(map[common.Address]struct{}),
+		approvers: make(map[common.Address]struct{}),
+		db:        db,
+		audit:     audit,
+		pending:   make(map[string]*pendingApproval),
+	}
+	for _, account := range config.Accounts {
+		ui.accounts[account] = struct{}{}
+	}
+	for _, approver := range config.Approvers {
+		ui.approvers[approver] = struct{}{}
+	}
+	if blob, err := db.Get(approvalsKey); err == nil {
+		var stored []*ApprovalRequest
+		if err := json.Unmarshal([]byte(blob), &stored); err != nil {
+			log.Warn("Failed to decode stored approval requests", "err", err)
+		}
+		now := time.Now()
+		for _, req := range stored {
+			if req.Status != ApprovalPending && req.Status != ApprovalApproved {
+				continue
+			}
+			deadline := time.Unix(req.Deadline, 0)
+			if !now.Before(deadline) {
+				req.Status = ApprovalExpired
+				log.Warn("Expired approval request of previous run", "id", req.ID, "account", req.Account)
+				if audit != nil {
+					audit.ApprovalResolved(req)
+				}
+				continue
+			}
+			p := &pendingApproval{req: req, done: make(chan struct{})}
+			if req.Status != ApprovalPending {
+				close(p.done)
+			}
+			p.expiry = time.AfterFunc(deadline.Sub(now), func() { ui.expire(p) })
+			ui.pending[req.ID] = p
+			log.Info("Restored approval request of previous run", "id", req.ID, "account", req.Account, "status", req.Status)
+		}
+		ui.persist()
+	}
+	return ui, nil
+}
+
+// persist stores the requests awaiting approval. The caller must hold the lock.
+func (ui *MultiApprovalUI) persist() {
+	if len(ui.pending) == 0 {
+		ui.db.Del(approvalsKey)
+		return
+	}
+	reqs := make([]*ApprovalRequest, 0, len(ui.pending))
+	for _, p := range ui.pending {
+		reqs = append(reqs, p.req)
+	}
+	blob, err := json.Marshal(reqs)
+	if err != nil {
+		log.Error("Failed to encode approval requests", "err", err)
+		return
+	}
+	ui.db.Put(approvalsKey, string(blob))
+}
+
+// resolve finalizes a request with the given status. An approved request which
+// was restored from a previous run is kept around until its requester submits
+// it again or it expires. The caller must hold the lock.
+func (ui *MultiApprovalUI) resolve(p *pendingApproval, status ApprovalStatus) {
+	if p.req.Status == ApprovalPending {
+		close(p.done)
+	}
+	p.req.Status = status
+	if status != ApprovalApproved || p.attached {
+		p.expiry.Stop()
+		delete(ui.pending, p.req.ID)
+	}
+	ui.persist()
+
+	if ui.audit != nil {
+		ui.audit.ApprovalResolved(p.req)
+	}
+}
+
+// expire resolves a request which reached its deadline.
+func (ui *MultiApprovalUI) expire(p *pendingApproval) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	if ui.pending[p.req.ID] == p {
+		ui.resolve(p, ApprovalExpired)
+	}
+}
+
+// await submits a request to the approvers, blocking until it's resolved. If
+// the same data was requested to be signed in a previous run and is still
+// awaiting approval, that request is resumed along with its votes instead.
+func (ui *MultiApprovalUI) await(account common.Address, kind string, request interface{}, content interface{}) (bool, error) {
+	blob, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return false, err
+	}
+	digest := crypto.Keccak256Hash([]byte(kind), account.Bytes(), data)
+
+	ui.lock.Lock()
+	var p *pendingApproval
+	for _, restored := range ui.pending {
+		if !restored.attached && restored.req.Account == account && restored.req.Kind == kind && restored.req.Digest == digest {
+			p = restored
+			break
+		}
+	}
+	if p != nil {
+		p.attached = true
+		status := p.req.Status
+		ui.lock.Unlock()
+
+		log.Info("Resumed approval request of previous run", "id", p.req.ID, "account", account, "status", status)
+	} else {
+		ui.lock.Unlock()
+
+		var id [16]byte
+		if _, err := rand.Read(id[:]); err != nil {
+			return false, err
+		}
+		now := time.Now()
+		p = &pendingApproval{
+			req: &ApprovalRequest{
+				ID:       hexutil.Encode(id[:]),
+				Account:  account,
+				Kind:     kind,
+				Request:  blob,
+				Digest:   digest,
+				Created:  now.Unix(),
+				Deadline: now.Add(ui.timeout).Unix(),
+				Status:   ApprovalPending,
+			},
+			done:     make(chan struct{}),
+			attached: true,
+		}
+		if ui.audit != nil {
+			ui.audit.ApprovalRequested(p.req)
+		}
+		ui.next.ShowInfo(fmt.Sprintf("Request %s of %s awaiting %d of %d approvals", p.req.ID, account.Hex(), ui.config.Threshold, len(ui.config.Approvers)))
+
+		// Only expose the request to the approvers once it's been announced
+		ui.lock.Lock()
+		p.expiry = time.AfterFunc(ui.timeout, func() { ui.expire(p) })
+		ui.pending[p.req.ID] = p
+		ui.persist()
+		ui.lock.Unlock()
+	}
+	<-p.done
+
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	// A resumed request might have been approved before being resubmitted
+	if ui.pending[p.req.ID] == p {
+		p.expiry.Stop()
+		delete(ui.pending, p.req.ID)
+		ui.persist()
+	}
+	return p.req.Status == ApprovalApproved, nil
+}
+
+// vote records the vote of an approver on a pending request, resolving it if
+// the outcome is decided.
+func (ui *MultiApprovalUI) vote(id string, approver common.Address, approve bool) (ApprovalStatus, error) {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	p := ui.pending[id]
+	if p == nil || p.req.Status != ApprovalPending {
+		return "", ErrUnknownApproval
+	}
+	if _, ok := ui.approvers[approver]; !ok {
+		return "", ErrNotApprover
+	}
+	if p.req.voted(approver) {
+		return "", ErrAlreadyVoted
+	}
+	if approve {
+		p.req.Approvals = append(p.req.Approvals, approver)
+	} else {
+		p.req.Rejections = append(p.req.Rejections, approver)
+	}
+	if ui.audit != nil {
+		ui.audit.ApprovalVoted(p.req, approver, approve)
+	}
+	switch {
+	case len(p.req.Approvals) >= ui.config.Threshold:
+		ui.resolve(p, ApprovalApproved)
+	case len(p.req.Rejections) > len(ui.config.Approvers)-ui.config.Threshold:
+		ui.resolve(p, ApprovalRejected)
+	default:
+		ui.persist()
+	}
+	return p.req.Status, nil
+}
+
+// requests returns copies of the requests awaiting approval.
+func (ui *MultiApprovalUI) requests() []*ApprovalRequest {
+	ui.lock.Lock()
+	defer ui.lock.Unlock()
+
+	reqs := make([]*ApprovalRequest, 0, len(ui.pending))
+	for _, p := range ui.pending {
+		req := *p.req
+		req.Approvals = append([]common.Address{}, p.req.Approvals...)
+		req.Rejections = append([]common.Address{}, p.req.Rejections...)
+		reqs = append(reqs, &req)
+	}
+	return reqs
+}
+
+func (ui *MultiApprovalUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
+	account := request.Transaction.From.Address()
+	if _, ok := ui.accounts[account]; !ok {
+		return ui.next.ApproveTx(request)
+	}
+	approved, err := ui.await(account, "transaction", request, request.Transaction)
+	if err != nil {
+		return SignTxResponse{Approved: false}, err
+	}
+	return SignTxResponse{Transaction: request.Transaction, Approved: approved}, nil
+}
+
+func (ui *MultiApprovalUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
+	account := request.Address.Address()
+	if _, ok := ui.accounts[account]; !ok {
+		return ui.next.ApproveSignData(request)
+	}
+	approved, err := ui.await(account, "data", request, struct {
+		ContentType string
+		Rawdata     []byte
+	}{request.ContentType, request.Rawdata})
+	if err != nil {
+		return SignDataResponse{Approved: false}, err
+	}
+	return SignDataResponse{Approved: approved}, nil
+}
+
+func (ui *MultiApprovalUI) ApproveListing(request *ListRequest) (ListResponse, error) {
+	return ui.next.ApproveListing(request)
+}
+
+func (ui *MultiApprovalUI) ApproveNewAccount(request *NewAccountRequest) (NewAccountResponse, error) {
+	return ui.next.ApproveNewAccount(request)
+}
+
+func (ui *MultiApprovalUI) ShowError(message string) {
+	ui.next.ShowError(message)
+}
+
+func (ui *MultiApprovalUI) ShowInfo(message string) {
+	ui.next.ShowInfo(message)
+}
+
+func (ui *MultiApprovalUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	ui.next.OnApprovedTx(tx)
+}
+
+func (ui *MultiApprovalUI) OnSignerStartup(info StartupInfo) {
+	ui.next.OnSignerStartup(info)
+}
+
+func (ui *MultiApprovalUI) OnInputRequired(info UserInputRequest) (UserInputResponse, error) {
+	return ui.next.OnInputRequired(info)
+}
+
+func (ui *MultiApprovalUI) RegisterUIServer(api *UIServerAPI) {
+	ui.next.RegisterUIServer(api)
+}
+
+// ApprovalVoteHash returns the hash an approver signs to vote on a request. It
+// is the EIP-191 personal message hash of "clef approval <id>: approve" or
+// "clef approval <id>: reject", so votes can be signed by any wallet.
+func ApprovalVoteHash(id string, approve bool) []byte {
+	verdict := "reject"
+	if approve {
+		verdict = "approve"
+	}
+	return accounts.TextHash([]byte(fmt.Sprintf("clef approval %s: %s", id, verdict)))
+}
+
+// ApprovalListHash returns the hash an approver signs to list the requests
+// awaiting approval at the given unix time. It is the EIP-191 personal message
+// hash of "clef approvals <timestamp>".
+func ApprovalListHash(timestamp int64) []byte {
+	return accounts.TextHash([]byte(fmt.Sprintf("clef approvals %d", timestamp)))
+}
+
+// recoverApprover returns the approver having signed the given hash.
+func (ui *MultiApprovalUI) recoverApprover(hash []byte, signature hexutil.Bytes) (common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("signature must be %d bytes long", crypto.SignatureLength)
+	}
+	sig := common.CopyBytes(signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27 // Transform yellow paper V from 27/28 to 0/1
+	}
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	approver := crypto.PubkeyToAddress(*pubkey)
+	if _, ok := ui.approvers[approver]; !ok {
+		return common.Address{}, ErrNotApprover
+	}
+	return approver, nil
+}
+
+// ApproverAPI is the API through which approvers list and vote on the signing
+// requests awaiting multi-party approval. It needs to be exposed on a separate
+// endpoint than the external API. All calls need to be signed by an approver.
+type ApproverAPI struct {
+	ui *MultiApprovalUI
+}
+
+// NewApproverAPI creates the approver API of a multi-party approval UI.
+func NewApproverAPI(ui *MultiApprovalUI) *ApproverAPI {
+	return &ApproverAPI{ui: ui}
+}
+
+// Pending returns the signing requests awaiting approval. The approver is
+// authenticated by the signature over ApprovalListHash of the current unix
+// time, give or take a few minutes.
+// Example call
+// {"jsonrpc":"2.0","method":"approver_pending","params":[1625000000, "0x.."], "id":1}
+func (api *ApproverAPI) Pending(timestamp int64, signature hexutil.Bytes) ([]*ApprovalRequest, error) {
+	if diff := time.Since(time.Unix(timestamp, 0)); diff > approvalListWindow || diff < -approvalListWindow {
+		return nil, ErrStaleListing
+	}
+	if _, err := api.ui.recoverApprover(ApprovalListHash(timestamp), signature); err != nil {
+		return nil, err
+	}
+	return api.ui.requests(), nil
+}
+
+// Vote casts the vote of an approver on a request awaiting approval, returning
+// the status of the request afterwards. The approver is authenticated by the
+// signature over ApprovalVoteHash.
+// Example call
+// {"jsonrpc":"2.0","method":"approver_vote","params":["0x..", true, "0x.."], "id":2}
+func (api *ApproverAPI) Vote(id string, approve bool, signature hexutil.Bytes) (ApprovalStatus, error) {
+	approver, err := api.ui.recoverApprover(ApprovalVoteHash(id, approve), signature)
+	if err != nil {
+		return "", err
+	}
+	return api.ui.vote(id, approver, approve)
+}
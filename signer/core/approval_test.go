@@ -0,0 +1,315 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// testApprovalAuditor counts the audited steps of the approval workflow.
+type testApprovalAuditor struct {
+	requested, voted, resolved int
+	lock                       sync.Mutex
+}
+
+func (a *testApprovalAuditor) ApprovalRequested(req *ApprovalRequest) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.requested++
+}
+func (a *testApprovalAuditor) ApprovalVoted(req *ApprovalRequest, approver common.Address, approve bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.voted++
+}
+func (a *testApprovalAuditor) ApprovalResolved(req *ApprovalRequest) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.resolved++
+}
+
+// approverVote signs a vote on a request with the given approver key.
+func approverVote(t *testing.T, api *ApproverAPI, key *ecdsa.PrivateKey, id string, approve bool) (ApprovalStatus, error) {
+	sig, err := crypto.Sign(ApprovalVoteHash(id, approve), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return api.Vote(id, approve, sig)
+}
+
+// approverPending lists the pending requests as the given approver.
+func approverPending(t *testing.T, api *ApproverAPI, key *ecdsa.PrivateKey) []*ApprovalRequest {
+	now := time.Now().Unix()
+	sig, err := crypto.Sign(ApprovalListHash(now), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs, err := api.Pending(now, sig)
+	if err != nil {
+		t.Fatalf("failed to list pending requests: %v", err)
+	}
+	return reqs
+}
+
+// awaitPending waits until the given number of requests await approval.
+func awaitPending(t *testing.T, api *ApproverAPI, key *ecdsa.PrivateKey, n int) []*ApprovalRequest {
+	for i := 0; i < 100; i++ {
+		if reqs := approverPending(t, api, key); len(reqs) == n {
+			return reqs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %d pending requests", n)
+	return nil
+}
+
+func newTestApprovalUI(t *testing.T, timeout string) (*MultiApprovalUI, *manualUI, *testApprovalAuditor, []*ecdsa.PrivateKey, string) {
+	d, err := ioutil.TempDir("", "eth-approval-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ApprovalConfig{
+		Accounts:  []common.Address{common.HexToAddress("0xdead")},
+		Threshold: 2,
+		Timeout:   timeout,
+	}
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+		config.Approvers = append(config.Approvers, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	var (
+		next    = &manualUI{}
+		auditor = new(testApprovalAuditor)
+		path    = filepath.Join(d, "approvals.json")
+	)
+	ui, err := NewMultiApprovalUI(next, config, storage.NewAESEncryptedStorage(path, []byte("AES256Key-32Characters1234567890")), auditor)
+	if err != nil {
+		t.Fatalf("failed to create approval UI: %v", err)
+	}
+	return ui, next, auditor, keys, path
+}
+
+func TestMultiApproval(t *testing.T) {
+	ui, next, auditor, keys, _ := newTestApprovalUI(t, "1h")
+	api := NewApproverAPI(ui)
+
+	// Requests of other accounts are forwarded
+	if res, _ := ui.ApproveTx(testPolicyTx("0x1", "0xbeef", 1, 1, "")); res.Approved || next.txs != 1 {
+		t.Fatalf("request of other account not forwarded")
+	}
+	// Requests of treasury accounts are approved once the threshold is met
+	result := make(chan bool)
+	go func() {
+		res, _ := ui.ApproveTx(testPolicyTx("0xdead", "0xbeef", 1, 1, ""))
+		result <- res.Approved
+	}()
+	id := awaitPending(t, api, keys[0], 1)[0].ID
+
+	outsider, _ := crypto.GenerateKey()
+	if _, err := approverVote(t, api, outsider, id, true); err != ErrNotApprover {
+		t.Fatalf("outsider vote error mismatch: have %v, want %v", err, ErrNotApprover)
+	}
+	if status, err := approverVote(t, api, keys[0], id, true); err != nil || status != ApprovalPending {
+		t.Fatalf("first vote mismatch: have %v/%v, want %v", status, err, ApprovalPending)
+	}
+	if _, err := approverVote(t, api, keys[0], id, true); err != ErrAlreadyVoted {
+		t.Fatalf("double vote error mismatch: have %v, want %v", err, ErrAlreadyVoted)
+	}
+	if len(approverPending(t, api, keys[2])[0].Approvals) != 1 {
+		t.Fatalf("vote not recorded")
+	}
+	if status, err := approverVote(t, api, keys[1], id, true); err != nil || status != ApprovalApproved {
+		t.Fatalf("second vote mismatch: have %v/%v, want %v", status, err, ApprovalApproved)
+	}
+	if approved := <-result; !approved {
+		t.Fatalf("request not approved")
+	}
+	if _, err := approverVote(t, api, keys[2], id, true); err != ErrUnknownApproval {
+		t.Fatalf("late vote error mismatch: have %v, want %v", err, ErrUnknownApproval)
+	}
+	// Requests are rejected once the threshold can't be met anymore
+	go func() {
+		res, _ := ui.ApproveSignData(&SignDataRequest{Address: common.NewMixedcaseAddress(common.HexToAddress("0xdead"))})
+		result <- res.Approved
+	}()
+	id = awaitPending(t, api, keys[0], 1)[0].ID
+
+	if status, _ := approverVote(t, api, keys[0], id, false); status != ApprovalPending {
+		t.Fatalf("first rejection mismatch: have %v, want %v", status, ApprovalPending)
+	}
+	if status, _ := approverVote(t, api, keys[1], id, false); status != ApprovalRejected {
+		t.Fatalf("second rejection mismatch: have %v, want %v", status, ApprovalRejected)
+	}
+	if approved := <-result; approved {
+		t.Fatalf("rejected request approved")
+	}
+	if auditor.requested != 2 || auditor.voted != 4 || auditor.resolved != 2 {
+		t.Errorf("audit mismatch: have %+v", auditor)
+	}
+}
+
+func TestMultiApprovalTimeout(t *testing.T) {
+	ui, _, auditor, keys, path := newTestApprovalUI(t, "50ms")
+	api := NewApproverAPI(ui)
+
+	result := make(chan bool)
+	go func() {
+		res, _ := ui.ApproveTx(testPolicyTx("0xdead", "0xbeef", 1, 1, ""))
+		result <- res.Approved
+	}()
+	id := awaitPending(t, api, keys[0], 1)[0].ID
+	approverVote(t, api, keys[0], id, true)
+
+	if approved := <-result; approved {
+		t.Fatalf("expired request approved")
+	}
+	if auditor.resolved != 1 || len(approverPending(t, api, keys[0])) != 0 {
+		t.Fatalf("request not expired")
+	}
+	// Requests left pending by a crash past their deadline are expired on startup
+	db := storage.NewAESEncryptedStorage(path, []byte("AES256Key-32Characters1234567890"))
+	db.Put(approvalsKey, `[{"id":"0x01","status":"pending"}]`)
+
+	auditor = new(testApprovalAuditor)
+	if _, err := NewMultiApprovalUI(&manualUI{}, ui.config, db, auditor); err != nil {
+		t.Fatal(err)
+	}
+	if auditor.resolved != 1 {
+		t.Fatalf("stale request not expired")
+	}
+	if _, err := db.Get(approvalsKey); err == nil {
+		t.Fatalf("stale request not removed")
+	}
+}
+
+func TestApproverPendingAuth(t *testing.T) {
+	ui, _, _, keys, _ := newTestApprovalUI(t, "1h")
+	api := NewApproverAPI(ui)
+
+	now := time.Now().Unix()
+	outsider, _ := crypto.GenerateKey()
+	sig, _ := crypto.Sign(ApprovalListHash(now), outsider)
+	if _, err := api.Pending(now, sig); err != ErrNotApprover {
+		t.Fatalf("outsider listing error mismatch: have %v, want %v", err, ErrNotApprover)
+	}
+	stale := now - int64(approvalListWindow/time.Second) - 60
+	sig, _ = crypto.Sign(ApprovalListHash(stale), keys[0])
+	if _, err := api.Pending(stale, sig); err != ErrStaleListing {
+		t.Fatalf("stale listing error mismatch: have %v, want %v", err, ErrStaleListing)
+	}
+	sig, _ = crypto.Sign(ApprovalListHash(now), keys[0])
+	if _, err := api.Pending(now+1, sig); err != ErrNotApprover {
+		t.Fatalf("mismatching listing error mismatch: have %v, want %v", err, ErrNotApprover)
+	}
+	if _, err := api.Pending(now, sig); err != nil {
+		t.Fatalf("approver listing failed: %v", err)
+	}
+}
+
+func TestMultiApprovalRestore(t *testing.T) {
+	ui, _, _, keys, path := newTestApprovalUI(t, "1h")
+	api := NewApproverAPI(ui)
+
+	// Submit two requests and vote on them, then restart
+	go ui.ApproveTx(testPolicyTx("0xdead", "0xbeef", 1, 1, ""))
+	awaitPending(t, api, keys[0], 1)
+	go ui.ApproveTx(testPolicyTx("0xdead", "0xbeef", 2, 1, ""))
+
+	reqs := awaitPending(t, api, keys[0], 2)
+	for _, req := range reqs {
+		approverVote(t, api, keys[0], req.ID, true)
+	}
+	db := storage.NewAESEncryptedStorage(path, []byte("AES256Key-32Characters1234567890"))
+	restarted, err := NewMultiApprovalUI(&manualUI{}, ui.config, db, new(testApprovalAuditor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api = NewApproverAPI(restarted)
+
+	restored := awaitPending(t, api, keys[1], 2)
+	for _, req := range restored {
+		if len(req.Approvals) != 1 || req.Status != ApprovalPending {
+			t.Fatalf("request %s not restored: %+v", req.ID, req)
+		}
+	}
+	// Approve the first request before it's resubmitted, it should be approved
+	// straight away afterwards
+	var first, second string
+	for _, req := range restored {
+		var signReq SignTxRequest
+		if err := json.Unmarshal(req.Request, &signReq); err != nil {
+			t.Fatal(err)
+		}
+		if signReq.Transaction.Value.ToInt().Uint64() == 1 {
+			first = req.ID
+		} else {
+			second = req.ID
+		}
+	}
+	if status, err := approverVote(t, api, keys[1], first, true); err != nil || status != ApprovalApproved {
+		t.Fatalf("vote on restored request mismatch: have %v/%v, want %v", status, err, ApprovalApproved)
+	}
+	if res, _ := restarted.ApproveTx(testPolicyTx("0xdead", "0xbeef", 1, 1, "")); !res.Approved {
+		t.Fatalf("resubmitted approved request not approved")
+	}
+	// Resubmit the second request, it should resume awaiting the missing vote
+	result := make(chan bool)
+	go func() {
+		res, _ := restarted.ApproveTx(testPolicyTx("0xdead", "0xbeef", 2, 1, ""))
+		result <- res.Approved
+	}()
+	if reqs := awaitPending(t, api, keys[1], 1); reqs[0].ID != second {
+		t.Fatalf("resubmitted request not resumed: have %s, want %s", reqs[0].ID, second)
+	}
+	if status, err := approverVote(t, api, keys[2], second, true); err != nil || status != ApprovalApproved {
+		t.Fatalf("vote on resumed request mismatch: have %v/%v, want %v", status, err, ApprovalApproved)
+	}
+	if approved := <-result; !approved {
+		t.Fatalf("resumed request not approved")
+	}
+	if len(approverPending(t, api, keys[0])) != 0 {
+		t.Fatalf("resolved requests still pending")
+	}
+}
+
+func TestParseApprovalConfigErrors(t *testing.T) {
+	tests := []string{
+		`{"approvers": ["0x0000000000000000000000000000000000000001"], "threshold": 2, "timeout": "1h"}`,
+		`{"approvers": ["0x0000000000000000000000000000000000000001"], "threshold": 0, "timeout": "1h"}`,
+		`{"approvers": ["0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000001"], "threshold": 1, "timeout": "1h"}`,
+		`{"approvers": ["0x0000000000000000000000000000000000000001"], "threshold": 1, "timeout": "never"}`,
+		`{"approvers": ["0x0000000000000000000000000000000000000001"], "threshold": 1, "timeout": "1h", "unknown": 1}`,
+	}
+	for i, blob := range tests {
+		if _, err := ParseApprovalConfig([]byte(blob)); err == nil {
+			t.Errorf("test %d: expected error for %s", i, blob)
+		}
+	}
+}
@@ -122,3 +122,33 @@ func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
 	l.Info("Configured", "audit log", path)
 	return &AuditLogger{l, api}, nil
 }
+
+// ApprovalAuditLogger records the multi-party approval workflow into the audit
+// log, next to the requests logged by the AuditLogger.
+type ApprovalAuditLogger struct {
+	log log.Logger
+}
+
+func (l *ApprovalAuditLogger) ApprovalRequested(req *ApprovalRequest) {
+	l.log.Info("Approval", "type", "request", "id", req.ID, "account", req.Account,
+		"kind", req.Kind, "request", string(req.Request), "deadline", req.Deadline)
+}
+
+func (l *ApprovalAuditLogger) ApprovalVoted(req *ApprovalRequest, approver common.Address, approve bool) {
+	l.log.Info("Approval", "type", "vote", "id", req.ID, "approver", approver, "approve", approve)
+}
+
+func (l *ApprovalAuditLogger) ApprovalResolved(req *ApprovalRequest) {
+	l.log.Info("Approval", "type", "result", "id", req.ID, "status", req.Status,
+		"approvals", req.Approvals, "rejections", req.Rejections)
+}
+
+func NewApprovalAuditLogger(path string) (*ApprovalAuditLogger, error) {
+	l := log.New("api", "approver")
+	handler, err := log.FileHandler(path, log.LogfmtFormat())
+	if err != nil {
+		return nil, err
+	}
+	l.SetHandler(handler)
+	return &ApprovalAuditLogger{l}, nil
+}
@@ -0,0 +1,564 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/storage"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SelectorResolver resolves 4-byte method selectors into human readable method
+// signatures, used to explain policy decisions.
+//
+// Use fourbyte.Database as an implementation.
+type SelectorResolver interface {
+	Selector(id []byte) (string, error)
+}
+
+// Policy is a declarative set of rules to automatically approve or reject
+// signing requests, defined per account. Requests from accounts without a
+// policy are left to the next UI for manual approval.
+type Policy struct {
+	Accounts map[common.Address]*AccountPolicy `json:"accounts"`
+}
+
+// AccountPolicy contains the rules applying to the signing requests of a single
+// account. A transaction is approved if it satisfies all configured rules and
+// rejected otherwise. Typed data is approved if its domain matches any of the
+// allowlisted ones and rejected otherwise.
+type AccountPolicy struct {
+	Recipients       []common.Address      `json:"recipients"`       // Allowed transaction recipients (empty = any)
+	Methods          []string              `json:"methods"`          // Allowed method signatures or 4-byte selectors (empty = any)
+	MaxGasPrice      *math.HexOrDecimal256 `json:"maxGasPrice"`      // Maximum gas price of transactions (nil = any)
+	SpendLimits      []SpendLimit          `json:"spendLimits"`      // Limits on the value sent over rolling time windows
+	TypedDataDomains []TypedDataDomainRule `json:"typedDataDomains"` // Allowed typed data domains (empty = manual approval)
+
+	selectors map[[4]byte]struct{}
+}
+
+// SpendLimit caps the total value sent by an account within a rolling window.
+type SpendLimit struct {
+	Amount *math.HexOrDecimal256 `json:"amount"` // Maximum value sent within the window
+	Window string                `json:"window"` // Length of the window, e.g. "24h"
+
+	window time.Duration
+}
+
+// TypedDataDomainRule matches the domain of typed data to sign. Unset fields
+// match any value.
+type TypedDataDomainRule struct {
+	Name              string                `json:"name"`
+	ChainId           *math.HexOrDecimal256 `json:"chainId"`
+	VerifyingContract *common.Address       `json:"verifyingContract"`
+}
+
+// ParsePolicy decodes and validates a JSON or YAML encoded policy. Documents
+// not starting with a '{' are treated as YAML.
+func ParsePolicy(blob []byte) (*Policy, error) {
+	if trimmed := bytes.TrimSpace(blob); len(trimmed) > 0 && trimmed[0] != '{' {
+		converted, err := yamlToJSON(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		blob = converted
+	}
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.DisallowUnknownFields()
+
+	policy := new(Policy)
+	if err := dec.Decode(policy); err != nil {
+		return nil, err
+	}
+	if err := policy.compile(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// yamlToJSON converts a YAML policy into its JSON form. All scalars are kept
+// as strings, so that large amounts don't lose precision and are decoded the
+// same way as their quoted JSON counterparts.
+func yamlToJSON(blob []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(blob, &doc); err != nil {
+		return nil, err
+	}
+	var convert func(node *yaml.Node) (interface{}, error)
+	convert = func(node *yaml.Node) (interface{}, error) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil, nil
+			}
+			return convert(node.Content[0])
+		case yaml.AliasNode:
+			return convert(node.Alias)
+		case yaml.MappingNode:
+			fields := make(map[string]interface{}, len(node.Content)/2)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i]
+				if key.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("line %d: non-scalar key", key.Line)
+				}
+				value, err := convert(node.Content[i+1])
+				if err != nil {
+					return nil, err
+				}
+				fields[key.Value] = value
+			}
+			return fields, nil
+		case yaml.SequenceNode:
+			items := make([]interface{}, 0, len(node.Content))
+			for _, child := range node.Content {
+				item, err := convert(child)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return items, nil
+		case yaml.ScalarNode:
+			if node.Tag == "!!null" {
+				return nil, nil
+			}
+			return node.Value, nil
+		default:
+			return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+		}
+	}
+	policy, err := convert(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(policy)
+}
+
+// compile validates the policy and precomputes the derived rule fields.
+func (p *Policy) compile() error {
+	for addr, acc := range p.Accounts {
+		if acc == nil {
+			return fmt.Errorf("accounts[%s]: empty policy", addr.Hex())
+		}
+		acc.selectors = make(map[[4]byte]struct{})
+		for _, method := range acc.Methods {
+			var selector [4]byte
+			if strings.HasPrefix(method, "0x") {
+				id, err := hexutil.Decode(method)
+				if err != nil || len(id) != 4 {
+					return fmt.Errorf("accounts[%s].methods: invalid selector %q", addr.Hex(), method)
+				}
+				copy(selector[:], id)
+			} else {
+				if !strings.HasSuffix(method, ")") || !strings.Contains(method, "(") {
+					return fmt.Errorf("accounts[%s].methods: invalid method signature %q", addr.Hex(), method)
+				}
+				copy(selector[:], crypto.Keccak256([]byte(strings.ReplaceAll(method, " ", ""))))
+			}
+			acc.selectors[selector] = struct{}{}
+		}
+		for i := range acc.SpendLimits {
+			limit := &acc.SpendLimits[i]
+			if limit.Amount == nil {
+				return fmt.Errorf("accounts[%s].spendLimits[%d]: missing amount", addr.Hex(), i)
+			}
+			window, err := time.ParseDuration(limit.Window)
+			if err != nil || window <= 0 {
+				return fmt.Errorf("accounts[%s].spendLimits[%d]: invalid window %q", addr.Hex(), i, limit.Window)
+			}
+			limit.window = window
+		}
+	}
+	return nil
+}
+
+// PolicyVerdict is the outcome of evaluating a request against a policy.
+type PolicyVerdict int
+
+const (
+	PolicyManual  PolicyVerdict = iota // No rule applies, defer to manual approval
+	PolicyApprove                      // Request satisfies the policy
+	PolicyReject                       // Request violates the policy
+)
+
+// String implements fmt.Stringer.
+func (v PolicyVerdict) String() string {
+	switch v {
+	case PolicyApprove:
+		return "approved"
+	case PolicyReject:
+		return "rejected"
+	default:
+		return "manual"
+	}
+}
+
+// PolicyDecision explains the verdict of a policy, naming the rule which
+// decided it.
+type PolicyDecision struct {
+	Verdict PolicyVerdict `json:"verdict"`
+	Rule    string        `json:"rule"`
+	Reason  string        `json:"reason"`
+}
+
+// String implements fmt.Stringer.
+func (d PolicyDecision) String() string {
+	return fmt.Sprintf("%v by %s: %s", d.Verdict, d.Rule, d.Reason)
+}
+
+// reservationTimeout is the time after which the value of an approved, but not
+// yet signed transaction stops counting towards the spend limits, e.g. because
+// the password was wrong and signing failed.
+const reservationTimeout = 5 * time.Minute
+
+// reservation identifies an approved transaction waiting to be signed.
+type reservation struct {
+	from  common.Address
+	nonce uint64
+}
+
+// reservedSpend is the value of an approved transaction, counted towards the
+// spend limits until the transaction is signed or the reservation expires.
+type reservedSpend struct {
+	value *big.Int
+	time  time.Time
+}
+
+// PolicyEngine evaluates signing requests against a policy, tracking the value
+// sent by each account in a spend ledger.
+type PolicyEngine struct {
+	policy   *Policy
+	ledger   *storage.SpendLedger
+	resolver SelectorResolver
+	now      func() time.Time
+
+	reserved map[reservation]reservedSpend // Approved transactions not yet signed
+	lock     sync.Mutex
+}
+
+// NewPolicyEngine creates an engine evaluating requests against the policy. The
+// ledger is used to enforce spend limits, if nil spends are only tracked in
+// memory. The resolver is optional.
+func NewPolicyEngine(policy *Policy, ledger *storage.SpendLedger, resolver SelectorResolver) (*PolicyEngine, error) {
+	if err := policy.compile(); err != nil {
+		return nil, err
+	}
+	if ledger == nil {
+		ledger = storage.NewSpendLedger(storage.NewEphemeralStorage())
+	}
+	return &PolicyEngine{
+		policy:   policy,
+		ledger:   ledger,
+		resolver: resolver,
+		now:      time.Now,
+		reserved: make(map[reservation]reservedSpend),
+	}, nil
+}
+
+// EvaluateTx evaluates a transaction signing request against the policy.
+func (e *PolicyEngine) EvaluateTx(req *SignTxRequest) PolicyDecision {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.evaluateTx(&req.Transaction)
+}
+
+func (e *PolicyEngine) evaluateTx(tx *SendTxArgs) PolicyDecision {
+	from := tx.From.Address()
+	acc := e.policy.Accounts[from]
+	if acc == nil {
+		return PolicyDecision{PolicyManual, "accounts", fmt.Sprintf("no policy for %s", from.Hex())}
+	}
+	var (
+		path  = fmt.Sprintf("accounts[%s]", from.Hex())
+		notes []string
+	)
+	reject := func(rule string, format string, args ...interface{}) PolicyDecision {
+		return PolicyDecision{PolicyReject, path + "." + rule, fmt.Sprintf(format, args...)}
+	}
+	// Check the recipient and the invoked method
+	if len(acc.Recipients) > 0 {
+		if tx.To == nil {
+			return reject("recipients", "contract creation not allowlisted")
+		}
+		to := tx.To.Address()
+		allowed := false
+		for _, recipient := range acc.Recipients {
+			if recipient == to {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return reject("recipients", "recipient %s not allowlisted", to.Hex())
+		}
+		notes = append(notes, fmt.Sprintf("recipient %s allowlisted", to.Hex()))
+	}
+	if data := tx.data(); len(acc.selectors) > 0 && len(data) > 0 {
+		if len(data) < 4 {
+			return reject("methods", "invalid method selector %#x", data)
+		}
+		var selector [4]byte
+		copy(selector[:], data)
+		if _, ok := acc.selectors[selector]; !ok {
+			return reject("methods", "method %s not allowlisted", e.method(selector))
+		}
+		notes = append(notes, fmt.Sprintf("method %s allowlisted", e.method(selector)))
+	}
+	// Check the gas price and the spend limits
+	if acc.MaxGasPrice != nil {
+		if price := tx.GasPrice.ToInt(); price.Cmp((*big.Int)(acc.MaxGasPrice)) > 0 {
+			return reject("maxGasPrice", "gas price %v above maximum %v", price, (*big.Int)(acc.MaxGasPrice))
+		}
+		notes = append(notes, "gas price within maximum")
+	}
+	var (
+		value   = tx.Value.ToInt()
+		pending = e.pendingSpend(from)
+	)
+	for i, limit := range acc.SpendLimits {
+		spent, err := e.ledger.Spent(from, e.now().Add(-limit.window))
+		if err != nil {
+			return reject(fmt.Sprintf("spendLimits[%d]", i), "spend ledger unavailable: %v", err)
+		}
+		if total := new(big.Int).Add(spent, value); total.Add(total, pending).Cmp((*big.Int)(limit.Amount)) > 0 {
+			return reject(fmt.Sprintf("spendLimits[%d]", i), "spending %v within %s exceeds limit %v", total, limit.Window, (*big.Int)(limit.Amount))
+		}
+		notes = append(notes, fmt.Sprintf("spend within %s limit", limit.Window))
+	}
+	if len(notes) == 0 {
+		notes = append(notes, "no rules configured")
+	}
+	return PolicyDecision{PolicyApprove, path, strings.Join(notes, ", ")}
+}
+
+// method returns the human readable form of a method selector.
+func (e *PolicyEngine) method(selector [4]byte) string {
+	if e.resolver != nil {
+		if signature, err := e.resolver.Selector(selector[:]); err == nil {
+			return fmt.Sprintf("%s (%#x)", signature, selector)
+		}
+	}
+	return fmt.Sprintf("%#x", selector)
+}
+
+// EvaluateSignData evaluates a data signing request against the policy. Only
+// typed data is covered by policies, everything else needs manual approval.
+func (e *PolicyEngine) EvaluateSignData(req *SignDataRequest) PolicyDecision {
+	from := req.Address.Address()
+	acc := e.policy.Accounts[from]
+	if acc == nil {
+		return PolicyDecision{PolicyManual, "accounts", fmt.Sprintf("no policy for %s", from.Hex())}
+	}
+	path := fmt.Sprintf("accounts[%s].typedDataDomains", from.Hex())
+	if req.ContentType != DataTyped.Mime || req.Domain == nil {
+		return PolicyDecision{PolicyManual, path, fmt.Sprintf("content type %s not covered by policies", req.ContentType)}
+	}
+	if len(acc.TypedDataDomains) == 0 {
+		return PolicyDecision{PolicyManual, path, "no typed data domains configured"}
+	}
+	for i, rule := range acc.TypedDataDomains {
+		if rule.matches(req.Domain) {
+			return PolicyDecision{PolicyApprove, fmt.Sprintf("%s[%d]", path, i), fmt.Sprintf("domain %q allowlisted", req.Domain.Name)}
+		}
+	}
+	return PolicyDecision{PolicyReject, path, fmt.Sprintf("domain %q not allowlisted", req.Domain.Name)}
+}
+
+// matches returns whether the typed data domain satisfies the rule.
+func (r *TypedDataDomainRule) matches(domain *TypedDataDomain) bool {
+	if r.Name != "" && r.Name != domain.Name {
+		return false
+	}
+	if r.ChainId != nil && (domain.ChainId == nil || (*big.Int)(r.ChainId).Cmp((*big.Int)(domain.ChainId)) != 0) {
+		return false
+	}
+	if r.VerifyingContract != nil && (!common.IsHexAddress(domain.VerifyingContract) || common.HexToAddress(domain.VerifyingContract) != *r.VerifyingContract) {
+		return false
+	}
+	return true
+}
+
+// pendingSpend sums up the value of the unexpired reservations of an account.
+func (e *PolicyEngine) pendingSpend(from common.Address) *big.Int {
+	pending := new(big.Int)
+	for id, spend := range e.reserved {
+		if id.from == from && e.now().Sub(spend.time) < reservationTimeout {
+			pending.Add(pending, spend.value)
+		}
+	}
+	return pending
+}
+
+// reserve counts the value of an approved transaction towards the spend limits
+// of its account until it's signed, preventing concurrent requests from
+// exceeding the limits. Reservations are only kept in memory, the spend ledger
+// is only updated once the transaction is signed.
+func (e *PolicyEngine) reserve(tx *SendTxArgs) {
+	now := e.now()
+	for id, spend := range e.reserved {
+		if now.Sub(spend.time) >= reservationTimeout {
+			delete(e.reserved, id)
+		}
+	}
+	from := tx.From.Address()
+	if acc := e.policy.Accounts[from]; acc == nil || len(acc.SpendLimits) == 0 || tx.Value.ToInt().Sign() == 0 {
+		return
+	}
+	e.reserved[reservation{from, uint64(tx.Nonce)}] = reservedSpend{new(big.Int).Set(tx.Value.ToInt()), now}
+}
+
+// recordSigned records the value of a signed transaction in the spend ledger,
+// releasing its reservation. Only the spends of accounts with spend limits are
+// tracked, older than the longest window of the account are dropped.
+func (e *PolicyEngine) recordSigned(tx *types.Transaction) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		log.Warn("Failed to derive sender of signed transaction", "err", err)
+		return
+	}
+	delete(e.reserved, reservation{from, tx.Nonce()})
+
+	acc := e.policy.Accounts[from]
+	if acc == nil || len(acc.SpendLimits) == 0 {
+		return
+	}
+	if tx.Value().Sign() > 0 {
+		if err := e.ledger.Record(from, tx.Value(), e.now()); err != nil {
+			log.Error("Failed to record signed transaction in spend ledger", "from", from, "nonce", tx.Nonce(), "err", err)
+		}
+	}
+	var retention time.Duration
+	for _, limit := range acc.SpendLimits {
+		if limit.window > retention {
+			retention = limit.window
+		}
+	}
+	if err := e.ledger.Prune(from, e.now().Add(-retention)); err != nil {
+		log.Warn("Failed to prune spend ledger", "account", from, "err", err)
+	}
+}
+
+// PolicyUI is a UIClientAPI evaluating signing requests against a policy,
+// forwarding requests not covered by the policy to the next UI.
+type PolicyUI struct {
+	next    UIClientAPI
+	engine  *PolicyEngine
+	explain bool // Whether to report the policy decisions to the next UI
+}
+
+// NewPolicyUI creates a UI which approves or rejects requests according to the
+// policy engine. In explain mode, the decision made for every request, along
+// with the rule deciding it, is shown to the user.
+func NewPolicyUI(next UIClientAPI, engine *PolicyEngine, explain bool) *PolicyUI {
+	return &PolicyUI{
+		next:    next,
+		engine:  engine,
+		explain: explain,
+	}
+}
+
+func (ui *PolicyUI) report(request string, decision PolicyDecision) {
+	log.Info("Policy evaluated", "request", request, "verdict", decision.Verdict, "rule", decision.Rule, "reason", decision.Reason)
+	if ui.explain {
+		ui.next.ShowInfo(fmt.Sprintf("Policy: %s %v", request, decision))
+	}
+}
+
+func (ui *PolicyUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
+	ui.engine.lock.Lock()
+	decision := ui.engine.evaluateTx(&request.Transaction)
+	if decision.Verdict == PolicyApprove {
+		ui.engine.reserve(&request.Transaction)
+	}
+	ui.engine.lock.Unlock()
+
+	ui.report("transaction", decision)
+	switch decision.Verdict {
+	case PolicyApprove:
+		return SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+	case PolicyReject:
+		return SignTxResponse{Approved: false}, nil
+	default:
+		return ui.next.ApproveTx(request)
+	}
+}
+
+func (ui *PolicyUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
+	decision := ui.engine.EvaluateSignData(request)
+
+	ui.report("data", decision)
+	switch decision.Verdict {
+	case PolicyApprove:
+		return SignDataResponse{Approved: true}, nil
+	case PolicyReject:
+		return SignDataResponse{Approved: false}, nil
+	default:
+		return ui.next.ApproveSignData(request)
+	}
+}
+
+func (ui *PolicyUI) ApproveListing(request *ListRequest) (ListResponse, error) {
+	return ui.next.ApproveListing(request)
+}
+
+func (ui *PolicyUI) ApproveNewAccount(request *NewAccountRequest) (NewAccountResponse, error) {
+	return ui.next.ApproveNewAccount(request)
+}
+
+func (ui *PolicyUI) ShowError(message string) {
+	ui.next.ShowError(message)
+}
+
+func (ui *PolicyUI) ShowInfo(message string) {
+	ui.next.ShowInfo(message)
+}
+
+func (ui *PolicyUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	ui.engine.recordSigned(tx.Tx)
+	ui.next.OnApprovedTx(tx)
+}
+
+func (ui *PolicyUI) OnSignerStartup(info StartupInfo) {
+	ui.next.OnSignerStartup(info)
+}
+
+func (ui *PolicyUI) OnInputRequired(info UserInputRequest) (UserInputResponse, error) {
+	return ui.next.OnInputRequired(info)
+}
+
+func (ui *PolicyUI) RegisterUIServer(api *UIServerAPI) {
+	ui.next.RegisterUIServer(api)
+}
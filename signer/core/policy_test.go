@@ -0,0 +1,345 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// manualUI is a UIClientAPI denying everything, recording the requests
+// forwarded to it for manual approval.
+type manualUI struct {
+	txs   int
+	datas int
+	infos []string
+}
+
+func (ui *manualUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
+	ui.txs++
+	return SignTxResponse{Approved: false}, nil
+}
+func (ui *manualUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
+	ui.datas++
+	return SignDataResponse{Approved: false}, nil
+}
+func (ui *manualUI) ApproveListing(request *ListRequest) (ListResponse, error) {
+	return ListResponse{}, nil
+}
+func (ui *manualUI) ApproveNewAccount(request *NewAccountRequest) (NewAccountResponse, error) {
+	return NewAccountResponse{}, nil
+}
+func (ui *manualUI) ShowError(message string)                     {}
+func (ui *manualUI) ShowInfo(message string)                      { ui.infos = append(ui.infos, message) }
+func (ui *manualUI) OnApprovedTx(tx ethapi.SignTransactionResult) {}
+func (ui *manualUI) OnSignerStartup(info StartupInfo)             {}
+func (ui *manualUI) OnInputRequired(info UserInputRequest) (UserInputResponse, error) {
+	return UserInputResponse{}, nil
+}
+func (ui *manualUI) RegisterUIServer(api *UIServerAPI) {}
+
+const testPolicy = `{
+	"accounts": {
+		"0x000000000000000000000000000000000000dead": {
+			"recipients": ["0x000000000000000000000000000000000000beef"],
+			"methods": ["transfer(address, uint256)", "0x095ea7b3"],
+			"maxGasPrice": "100",
+			"spendLimits": [{"amount": "1000", "window": "1h"}],
+			"typedDataDomains": [{"name": "Permit", "chainId": "1"}]
+		}
+	}
+}`
+
+func testPolicyTx(from, to string, value int64, gasPrice int64, data string) *SignTxRequest {
+	recipient := common.NewMixedcaseAddress(common.HexToAddress(to))
+	input := hexutil.Bytes(common.FromHex(data))
+	return &SignTxRequest{
+		Transaction: SendTxArgs{
+			From:     common.NewMixedcaseAddress(common.HexToAddress(from)),
+			To:       &recipient,
+			Value:    hexutil.Big(*big.NewInt(value)),
+			GasPrice: hexutil.Big(*big.NewInt(gasPrice)),
+			Data:     &input,
+		},
+	}
+}
+
+func TestPolicyTransactions(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	engine, err := NewPolicyEngine(policy, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	now := time.Unix(1000000, 0)
+	engine.now = func() time.Time { return now }
+
+	tests := []struct {
+		req     *SignTxRequest
+		verdict PolicyVerdict
+		rule    string
+	}{
+		// Accounts without policies need manual approval
+		{testPolicyTx("0x1", "0xbeef", 1, 1, ""), PolicyManual, "accounts"},
+		// Recipient and method allowlists
+		{testPolicyTx("0xdead", "0xbeef", 1, 1, ""), PolicyApprove, "accounts[0x000000000000000000000000000000000000dEaD]"},
+		{testPolicyTx("0xdead", "0xcafe", 1, 1, ""), PolicyReject, "accounts[0x000000000000000000000000000000000000dEaD].recipients"},
+		{testPolicyTx("0xdead", "0xbeef", 0, 1, "0xa9059cbb00"), PolicyApprove, "accounts[0x000000000000000000000000000000000000dEaD]"},
+		{testPolicyTx("0xdead", "0xbeef", 0, 1, "0x095ea7b3"), PolicyApprove, "accounts[0x000000000000000000000000000000000000dEaD]"},
+		{testPolicyTx("0xdead", "0xbeef", 0, 1, "0x23b872dd"), PolicyReject, "accounts[0x000000000000000000000000000000000000dEaD].methods"},
+		{testPolicyTx("0xdead", "0xbeef", 0, 1, "0x23"), PolicyReject, "accounts[0x000000000000000000000000000000000000dEaD].methods"},
+		// Gas price and spend limits
+		{testPolicyTx("0xdead", "0xbeef", 1, 101, ""), PolicyReject, "accounts[0x000000000000000000000000000000000000dEaD].maxGasPrice"},
+		{testPolicyTx("0xdead", "0xbeef", 1001, 1, ""), PolicyReject, "accounts[0x000000000000000000000000000000000000dEaD].spendLimits[0]"},
+	}
+	for i, tt := range tests {
+		decision := engine.EvaluateTx(tt.req)
+		if decision.Verdict != tt.verdict || decision.Rule != tt.rule {
+			t.Errorf("test %d: decision mismatch: have %v, want %v by %s", i, decision, tt.verdict, tt.rule)
+		}
+	}
+	// Approvals spend from the limit, which recovers after the window passes
+	next := &manualUI{}
+	ui := NewPolicyUI(next, engine, true)
+	if res, _ := ui.ApproveTx(testPolicyTx("0xdead", "0xbeef", 600, 1, "")); !res.Approved {
+		t.Fatalf("first spend not approved")
+	}
+	if res, _ := ui.ApproveTx(testPolicyTx("0xdead", "0xbeef", 600, 1, "")); res.Approved {
+		t.Fatalf("spend above limit approved")
+	}
+	if len(next.infos) != 2 || !strings.Contains(next.infos[1], "spendLimits[0]") {
+		t.Errorf("unexpected explanations: %v", next.infos)
+	}
+	now = now.Add(time.Hour + time.Second)
+	if res, _ := ui.ApproveTx(testPolicyTx("0xdead", "0xbeef", 600, 1, "")); !res.Approved {
+		t.Fatalf("spend after window not approved")
+	}
+	if res, _ := ui.ApproveTx(testPolicyTx("0x1", "0xbeef", 1, 1, "")); res.Approved || next.txs != 1 {
+		t.Fatalf("request without policy not forwarded for manual approval")
+	}
+}
+
+func TestPolicySignedTransactionLedger(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	policy := &Policy{Accounts: map[common.Address]*AccountPolicy{
+		from: {SpendLimits: []SpendLimit{{Amount: (*math.HexOrDecimal256)(big.NewInt(1000)), Window: "1h"}}},
+	}}
+	engine, err := NewPolicyEngine(policy, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	ui := NewPolicyUI(&manualUI{}, engine, false)
+
+	// Manually approved transactions count towards the limits once signed
+	signer := types.NewEIP155Signer(big.NewInt(1))
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(700), 21000, big.NewInt(1), nil), signer, key)
+	ui.OnApprovedTx(ethapi.SignTransactionResult{Tx: tx})
+
+	req := testPolicyTx(from.Hex(), "0xbeef", 400, 1, "")
+	req.Transaction.Nonce = 1
+	if decision := engine.EvaluateTx(req); decision.Verdict != PolicyReject {
+		t.Fatalf("spend above limit not rejected: %v", decision)
+	}
+	// Transactions approved by the policy are only counted once
+	req = testPolicyTx(from.Hex(), "0xbeef", 300, 1, "")
+	req.Transaction.Nonce = 1
+	if res, _ := ui.ApproveTx(req); !res.Approved {
+		t.Fatalf("spend within limit not approved")
+	}
+	tx, _ = types.SignTx(types.NewTransaction(1, common.Address{}, big.NewInt(300), 21000, big.NewInt(1), nil), signer, key)
+	ui.OnApprovedTx(ethapi.SignTransactionResult{Tx: tx})
+
+	if spent, err := engine.ledger.Spent(from, time.Time{}); err != nil || spent.Int64() != 1000 {
+		t.Errorf("spent amount mismatch: have %v (err %v), want 1000", spent, err)
+	}
+}
+
+func TestPolicyFailedSigning(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	policy := &Policy{Accounts: map[common.Address]*AccountPolicy{
+		from: {SpendLimits: []SpendLimit{{Amount: (*math.HexOrDecimal256)(big.NewInt(1000)), Window: "1h"}}},
+	}}
+	db := storage.NewEphemeralStorage()
+	engine, err := NewPolicyEngine(policy, storage.NewSpendLedger(db), nil)
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	now := time.Unix(1000000, 0)
+	engine.now = func() time.Time { return now }
+	ui := NewPolicyUI(&manualUI{}, engine, false)
+
+	// Approve a transaction which then fails to sign, it's never reported
+	if res, _ := ui.ApproveTx(testPolicyTx(from.Hex(), "0xbeef", 600, 1, "")); !res.Approved {
+		t.Fatalf("spend within limit not approved")
+	}
+	if spent, err := engine.ledger.Spent(from, time.Time{}); err != nil || spent.Sign() != 0 {
+		t.Errorf("unsigned spend recorded in ledger: have %v (err %v), want 0", spent, err)
+	}
+	// The reservation blocks concurrent spends until it expires
+	req := testPolicyTx(from.Hex(), "0xbeef", 600, 1, "")
+	req.Transaction.Nonce = 1
+	if res, _ := ui.ApproveTx(req); res.Approved {
+		t.Fatalf("spend above reserved limit approved")
+	}
+	now = now.Add(reservationTimeout)
+	if res, _ := ui.ApproveTx(req); !res.Approved {
+		t.Fatalf("spend after failed signing not approved")
+	}
+	if len(engine.reserved) != 1 {
+		t.Errorf("expired reservations not dropped: have %d, want 1", len(engine.reserved))
+	}
+	// Signed transactions of accounts without spend limits are not recorded
+	other, _ := crypto.GenerateKey()
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(700), 21000, big.NewInt(1), nil), types.NewEIP155Signer(big.NewInt(1)), other)
+	ui.OnApprovedTx(ethapi.SignTransactionResult{Tx: tx})
+
+	if _, err := db.Get("spend-" + crypto.PubkeyToAddress(other.PublicKey).Hex()); err == nil {
+		t.Errorf("spend of account without limits recorded")
+	}
+}
+
+func TestPolicyCorruptLedger(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	db := storage.NewEphemeralStorage()
+	db.Put("spend-"+common.HexToAddress("0xdead").Hex(), "corrupt")
+
+	engine, _ := NewPolicyEngine(policy, storage.NewSpendLedger(db), nil)
+	next := &manualUI{}
+	ui := NewPolicyUI(next, engine, false)
+
+	decision := engine.EvaluateTx(testPolicyTx("0xdead", "0xbeef", 1, 1, ""))
+	if decision.Verdict != PolicyReject || decision.Rule != "accounts[0x000000000000000000000000000000000000dEaD].spendLimits[0]" {
+		t.Errorf("decision mismatch: have %v, want rejection by spend limit", decision)
+	}
+	if res, _ := ui.ApproveTx(testPolicyTx("0xdead", "0xbeef", 1, 1, "")); res.Approved {
+		t.Errorf("spend approved with unreadable ledger")
+	}
+	if next.txs != 0 {
+		t.Errorf("request forwarded for manual approval")
+	}
+}
+
+func TestPolicyTypedData(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	engine, _ := NewPolicyEngine(policy, nil, nil)
+
+	request := func(from string, mime string, domain *TypedDataDomain) *SignDataRequest {
+		return &SignDataRequest{
+			ContentType: mime,
+			Address:     common.NewMixedcaseAddress(common.HexToAddress(from)),
+			Domain:      domain,
+		}
+	}
+	tests := []struct {
+		req     *SignDataRequest
+		verdict PolicyVerdict
+	}{
+		{request("0xdead", DataTyped.Mime, &TypedDataDomain{Name: "Permit", ChainId: math.NewHexOrDecimal256(1)}), PolicyApprove},
+		{request("0xdead", DataTyped.Mime, &TypedDataDomain{Name: "Permit", ChainId: math.NewHexOrDecimal256(5)}), PolicyReject},
+		{request("0xdead", DataTyped.Mime, &TypedDataDomain{Name: "Other", ChainId: math.NewHexOrDecimal256(1)}), PolicyReject},
+		{request("0xdead", TextPlain.Mime, nil), PolicyManual},
+		{request("0x1", DataTyped.Mime, &TypedDataDomain{Name: "Permit"}), PolicyManual},
+	}
+	for i, tt := range tests {
+		if decision := engine.EvaluateSignData(tt.req); decision.Verdict != tt.verdict {
+			t.Errorf("test %d: verdict mismatch: have %v, want %v", i, decision, tt.verdict)
+		}
+	}
+}
+
+func TestParsePolicyYAML(t *testing.T) {
+	blob := `
+accounts:
+  "0x000000000000000000000000000000000000dead":
+    recipients: [0x000000000000000000000000000000000000beef]
+    methods:
+      - transfer(address, uint256)
+      - "0x095ea7b3"
+    maxGasPrice: 100
+    spendLimits:
+      - amount: 100000000000000000000000
+        window: 1h
+    typedDataDomains:
+      - name: Permit
+        chainId: 1
+`
+	policy, err := ParsePolicy([]byte(blob))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	acc := policy.Accounts[common.HexToAddress("0xdead")]
+	if acc == nil {
+		t.Fatalf("account policy missing")
+	}
+	if len(acc.Recipients) != 1 || acc.Recipients[0] != common.HexToAddress("0xbeef") {
+		t.Errorf("recipients mismatch: %v", acc.Recipients)
+	}
+	if len(acc.selectors) != 2 {
+		t.Errorf("selector count mismatch: have %d, want 2", len(acc.selectors))
+	}
+	if (*big.Int)(acc.MaxGasPrice).Int64() != 100 {
+		t.Errorf("max gas price mismatch: have %v, want 100", (*big.Int)(acc.MaxGasPrice))
+	}
+	want, _ := new(big.Int).SetString("100000000000000000000000", 10)
+	if len(acc.SpendLimits) != 1 || (*big.Int)(acc.SpendLimits[0].Amount).Cmp(want) != 0 || acc.SpendLimits[0].window != time.Hour {
+		t.Errorf("spend limits mismatch: %+v", acc.SpendLimits)
+	}
+	if len(acc.TypedDataDomains) != 1 || acc.TypedDataDomains[0].Name != "Permit" {
+		t.Errorf("typed data domains mismatch: %+v", acc.TypedDataDomains)
+	}
+	// Unknown fields are rejected in YAML too
+	if _, err := ParsePolicy([]byte("accounts:\n  \"0x000000000000000000000000000000000000dead\":\n    unknown: true\n")); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	tests := []string{
+		`{"accounts": {"0x000000000000000000000000000000000000dead": {"methods": ["0x1234"]}}}`,
+		`{"accounts": {"0x000000000000000000000000000000000000dead": {"methods": ["transfer"]}}}`,
+		`{"accounts": {"0x000000000000000000000000000000000000dead": {"spendLimits": [{"amount": "1", "window": "forever"}]}}}`,
+		`{"accounts": {"0x000000000000000000000000000000000000dead": {"spendLimits": [{"window": "1h"}]}}}`,
+		`{"accounts": {"0x000000000000000000000000000000000000dead": {"unknown": true}}}`,
+	}
+	for i, blob := range tests {
+		if _, err := ParsePolicy([]byte(blob)); err == nil {
+			t.Errorf("test %d: expected error for %s", i, blob)
+		}
+	}
+}
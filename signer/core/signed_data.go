@@ -82,16 +82,42 @@ type Type struct {
 }
 
 func (t *Type) isArray() bool {
-	return strings.HasSuffix(t.Type, "[]")
+	return strings.HasSuffix(t.Type, "]")
 }
 
-// typeName returns the canonical name of the type. If the type is 'Person[]', then
-// this method returns 'Person'
+// typeName returns the canonical name of the type. If the type is 'Person[]' or
+// 'Person[2][]', then this method returns 'Person'
 func (t *Type) typeName() string {
-	if strings.HasSuffix(t.Type, "[]") {
-		return strings.TrimSuffix(t.Type, "[]")
+	return baseTypeName(t.Type)
+}
+
+// baseTypeName strips all array dimensions from a type.
+func baseTypeName(encType string) string {
+	if i := strings.Index(encType, "["); i >= 0 {
+		return encType[:i]
+	}
+	return encType
+}
+
+// splitArrayType splits the outermost dimension off an array type, returning
+// the element type and the length of the array, which is -1 for dynamic
+// arrays. E.g. 'uint8[2][]' is an array of 'uint8[2]' elements.
+func splitArrayType(encType string) (string, int, bool) {
+	if !strings.HasSuffix(encType, "]") {
+		return "", 0, false
+	}
+	i := strings.LastIndex(encType, "[")
+	if i <= 0 {
+		return "", 0, false
 	}
-	return t.Type
+	if i == len(encType)-2 {
+		return encType[:i], -1, true
+	}
+	length, err := strconv.Atoi(encType[i+1 : len(encType)-1])
+	if err != nil || length <= 0 {
+		return "", 0, false
+	}
+	return encType[:i], length, true
 }
 
 func (t *Type) isReferenceType() bool {
@@ -119,7 +145,10 @@ type TypedDataDomain struct {
 	Salt              string                `json:"salt"`
 }
 
-var typedDataReferenceTypeRegexp = regexp.MustCompile(`^[A-Z](\w*)(\[\])?$`)
+var (
+	typedDataReferenceTypeRegexp = regexp.MustCompile(`^[A-Z](\w*)(\[\d*\])*$`)
+	typedDataArraySuffixRegexp   = regexp.MustCompile(`^(\[([1-9]\d*)?\])*$`)
+)
 
 // sign receives a request and produces a signature
 //
@@ -324,25 +353,20 @@ func (api *SignerAPI) SignTypedData(ctx context.Context, addr common.MixedcaseAd
 // - the signature preimage (hash)
 func (api *SignerAPI) signTypedData(ctx context.Context, addr common.MixedcaseAddress,
 	typedData TypedData, validationMessages *ValidationMessages) (hexutil.Bytes, hexutil.Bytes, error) {
-	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	sighash, rawData, err := TypedDataAndHash(typedData)
 	if err != nil {
 		return nil, nil, err
 	}
-	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
-	if err != nil {
-		return nil, nil, err
-	}
-	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
-	sighash := crypto.Keccak256(rawData)
 	messages, err := typedData.Format()
 	if err != nil {
 		return nil, nil, err
 	}
 	req := &SignDataRequest{
 		ContentType: DataTyped.Mime,
-		Rawdata:     rawData,
+		Rawdata:     []byte(rawData),
 		Messages:    messages,
 		Hash:        sighash,
+		Domain:      &typedData.Domain,
 		Address:     addr}
 	if validationMessages != nil {
 		req.Callinfo = validationMessages.Messages
@@ -355,6 +379,23 @@ func (api *SignerAPI) signTypedData(ctx context.Context, addr common.MixedcaseAd
 	return signature, sighash, nil
 }
 
+// TypedDataAndHash calculates the EIP-712 signing hash of the typed data, i.e.
+// keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message)), along with
+// the preimage of the hash. It can be used to sign or verify typed data without
+// going through the signer.
+func TypedDataAndHash(typedData TypedData) ([]byte, string, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, "", err
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, "", err
+	}
+	rawData := fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash))
+	return crypto.Keccak256([]byte(rawData)), rawData, nil
+}
+
 // HashStruct generates a keccak256 hash of the encoding of the provided data
 func (typedData *TypedData) HashStruct(primaryType string, data TypedDataMessage) (hexutil.Bytes, error) {
 	encodedData, err := typedData.EncodeData(primaryType, data, 1)
@@ -375,6 +416,7 @@ func (typedData *TypedData) Dependencies(primaryType string, found []string) []s
 		return false
 	}
 
+	primaryType = baseTypeName(primaryType)
 	if includes(found, primaryType) {
 		return found
 	}
@@ -448,56 +490,49 @@ func (typedData *TypedData) EncodeData(primaryType string, data map[string]inter
 
 	// Add field contents. Structs and arrays have special handlers.
 	for _, field := range typedData.Types[primaryType] {
-		encType := field.Type
-		encValue := data[field.Name]
-		if encType[len(encType)-1:] == "]" {
-			arrayValue, ok := encValue.([]interface{})
-			if !ok {
-				return nil, dataMismatchError(encType, encValue)
-			}
-
-			arrayBuffer := bytes.Buffer{}
-			parsedType := strings.Split(encType, "[")[0]
-			for _, item := range arrayValue {
-				if typedData.Types[parsedType] != nil {
-					mapValue, ok := item.(map[string]interface{})
-					if !ok {
-						return nil, dataMismatchError(parsedType, item)
-					}
-					encodedData, err := typedData.EncodeData(parsedType, mapValue, depth+1)
-					if err != nil {
-						return nil, err
-					}
-					arrayBuffer.Write(encodedData)
-				} else {
-					bytesValue, err := typedData.EncodePrimitiveValue(parsedType, item, depth)
-					if err != nil {
-						return nil, err
-					}
-					arrayBuffer.Write(bytesValue)
-				}
-			}
+		encoded, err := typedData.encodeField(field.Type, data[field.Name], depth)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encoded)
+	}
+	return buffer.Bytes(), nil
+}
 
-			buffer.Write(crypto.Keccak256(arrayBuffer.Bytes()))
-		} else if typedData.Types[field.Type] != nil {
-			mapValue, ok := encValue.(map[string]interface{})
-			if !ok {
-				return nil, dataMismatchError(encType, encValue)
-			}
-			encodedData, err := typedData.EncodeData(field.Type, mapValue, depth+1)
-			if err != nil {
-				return nil, err
-			}
-			buffer.Write(crypto.Keccak256(encodedData))
-		} else {
-			byteValue, err := typedData.EncodePrimitiveValue(encType, encValue, depth)
+// encodeField generates the 32 byte encoding of a member value. Arrays are
+// encoded as the keccak256 hash of the concatenated encodings of their elements
+// and structs as their hashStruct.
+func (typedData *TypedData) encodeField(encType string, encValue interface{}, depth int) ([]byte, error) {
+	if elemType, length, ok := splitArrayType(encType); ok {
+		arrayValue, ok := encValue.([]interface{})
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		if length >= 0 && len(arrayValue) != length {
+			return nil, fmt.Errorf("provided array length %d doesn't match type '%s'", len(arrayValue), encType)
+		}
+		arrayBuffer := bytes.Buffer{}
+		for _, item := range arrayValue {
+			encoded, err := typedData.encodeField(elemType, item, depth+1)
 			if err != nil {
 				return nil, err
 			}
-			buffer.Write(byteValue)
+			arrayBuffer.Write(encoded)
 		}
+		return crypto.Keccak256(arrayBuffer.Bytes()), nil
 	}
-	return buffer.Bytes(), nil
+	if typedData.Types[encType] != nil {
+		mapValue, ok := encValue.(map[string]interface{})
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		encodedData, err := typedData.EncodeData(encType, mapValue, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(encodedData), nil
+	}
+	return typedData.EncodePrimitiveValue(encType, encValue, depth)
 }
 
 // Attempt to parse bytes in different formats: byte array, hex string, hexutil.Bytes.
@@ -534,14 +569,16 @@ func parseInteger(encType string, encValue interface{}) (*big.Int, error) {
 			lengthStr = strings.TrimPrefix(encType, "int")
 		}
 		atoiSize, err := strconv.Atoi(lengthStr)
-		if err != nil {
+		if err != nil || atoiSize < 8 || atoiSize > 256 || atoiSize%8 != 0 {
 			return nil, fmt.Errorf("invalid size on integer: %v", lengthStr)
 		}
 		length = atoiSize
 	}
 	switch v := encValue.(type) {
 	case *math.HexOrDecimal256:
-		b = (*big.Int)(v)
+		if v != nil {
+			b = new(big.Int).Set((*big.Int)(v))
+		}
 	case string:
 		var hexIntValue math.HexOrDecimal256
 		if err := hexIntValue.UnmarshalText([]byte(v)); err != nil {
@@ -560,11 +597,19 @@ func parseInteger(encType string, encValue interface{}) (*big.Int, error) {
 	if b == nil {
 		return nil, fmt.Errorf("invalid integer value %v/%v for type %v", encValue, reflect.TypeOf(encValue), encType)
 	}
-	if b.BitLen() > length {
-		return nil, fmt.Errorf("integer larger than '%v'", encType)
+	if !signed {
+		if b.Sign() == -1 {
+			return nil, fmt.Errorf("invalid negative value for unsigned type %v", encType)
+		}
+		if b.BitLen() > length {
+			return nil, fmt.Errorf("integer larger than '%v'", encType)
+		}
+		return b, nil
 	}
-	if !signed && b.Sign() == -1 {
-		return nil, fmt.Errorf("invalid negative value for unsigned type %v", encType)
+	// Signed integers range from -2^(length-1) to 2^(length-1)-1
+	limit := new(big.Int).Lsh(common.Big1, uint(length-1))
+	if b.Cmp(limit) >= 0 || b.Cmp(new(big.Int).Neg(limit)) < 0 {
+		return nil, fmt.Errorf("integer larger than '%v'", encType)
 	}
 	return b, nil
 }
@@ -609,7 +654,7 @@ func (typedData *TypedData) EncodePrimitiveValue(encType string, encValue interf
 		if err != nil {
 			return nil, fmt.Errorf("invalid size on bytes: %v", lengthStr)
 		}
-		if length < 0 || length > 32 {
+		if length < 1 || length > 32 {
 			return nil, fmt.Errorf("invalid size on bytes: %d", length)
 		}
 		if byteValue, ok := parseBytes(encValue); !ok || len(byteValue) != length {
@@ -755,51 +800,51 @@ func (typedData *TypedData) formatData(primaryType string, data map[string]inter
 
 	// Add field contents. Structs and arrays have special handlers.
 	for _, field := range typedData.Types[primaryType] {
-		encName := field.Name
-		encValue := data[encName]
-		item := &NameValueType{
-			Name: encName,
-			Typ:  field.Type,
+		item, err := typedData.formatField(field.Name, field.Type, data[field.Name])
+		if err != nil {
+			return nil, err
 		}
-		if field.isArray() {
-			arrayValue, _ := encValue.([]interface{})
-			parsedType := field.typeName()
-			for _, v := range arrayValue {
-				if typedData.Types[parsedType] != nil {
-					mapValue, _ := v.(map[string]interface{})
-					mapOutput, err := typedData.formatData(parsedType, mapValue)
-					if err != nil {
-						return nil, err
-					}
-					item.Value = mapOutput
-				} else {
-					primitiveOutput, err := formatPrimitiveValue(field.Type, encValue)
-					if err != nil {
-						return nil, err
-					}
-					item.Value = primitiveOutput
-				}
-			}
-		} else if typedData.Types[field.Type] != nil {
-			if mapValue, ok := encValue.(map[string]interface{}); ok {
-				mapOutput, err := typedData.formatData(field.Type, mapValue)
-				if err != nil {
-					return nil, err
-				}
-				item.Value = mapOutput
-			} else {
-				item.Value = "<nil>"
+		output = append(output, item)
+	}
+	return output, nil
+}
+
+// formatField formats a member value, listing the elements of arrays and the
+// members of structs as nested values.
+func (typedData *TypedData) formatField(name string, encType string, encValue interface{}) (*NameValueType, error) {
+	item := &NameValueType{
+		Name: name,
+		Typ:  encType,
+	}
+	if elemType, _, ok := splitArrayType(encType); ok {
+		arrayValue, _ := encValue.([]interface{})
+		elems := make([]*NameValueType, 0, len(arrayValue))
+		for i, v := range arrayValue {
+			elem, err := typedData.formatField(fmt.Sprintf("[%d]", i), elemType, v)
+			if err != nil {
+				return nil, err
 			}
-		} else {
-			primitiveOutput, err := formatPrimitiveValue(field.Type, encValue)
+			elems = append(elems, elem)
+		}
+		item.Value = elems
+	} else if typedData.Types[encType] != nil {
+		if mapValue, ok := encValue.(map[string]interface{}); ok {
+			mapOutput, err := typedData.formatData(encType, mapValue)
 			if err != nil {
 				return nil, err
 			}
-			item.Value = primitiveOutput
+			item.Value = mapOutput
+		} else {
+			item.Value = "<nil>"
 		}
-		output = append(output, item)
+	} else {
+		primitiveOutput, err := formatPrimitiveValue(encType, encValue)
+		if err != nil {
+			return nil, err
+		}
+		item.Value = primitiveOutput
 	}
-	return output, nil
+	return item, nil
 }
 
 func formatPrimitiveValue(encType string, encValue interface{}) (string, error) {
@@ -878,6 +923,9 @@ func (t Types) validate() error {
 			if typeKey == typeObj.Type {
 				return fmt.Errorf("type %q cannot reference itself", typeObj.Type)
 			}
+			if suffix := strings.TrimPrefix(typeObj.Type, typeObj.typeName()); !typedDataArraySuffixRegexp.MatchString(suffix) {
+				return fmt.Errorf("invalid array type %q", typeObj.Type)
+			}
 			if typeObj.isReferenceType() {
 				if _, exist := t[typeObj.typeName()]; !exist {
 					return fmt.Errorf("reference type %q is undefined", typeObj.Type)
@@ -893,117 +941,29 @@ func (t Types) validate() error {
 	return nil
 }
 
-// Checks if the primitive value is valid
+// Checks if the primitive value is valid. Arrays of any dimensions of valid
+// primitive types are valid too.
 func isPrimitiveTypeValid(primitiveType string) bool {
-	if primitiveType == "address" ||
-		primitiveType == "address[]" ||
-		primitiveType == "bool" ||
-		primitiveType == "bool[]" ||
-		primitiveType == "string" ||
-		primitiveType == "string[]" {
+	primitiveType = baseTypeName(primitiveType)
+	switch primitiveType {
+	case "address", "bool", "string", "bytes", "int", "uint":
 		return true
 	}
-	if primitiveType == "bytes" ||
-		primitiveType == "bytes[]" ||
-		primitiveType == "bytes1" ||
-		primitiveType == "bytes1[]" ||
-		primitiveType == "bytes2" ||
-		primitiveType == "bytes2[]" ||
-		primitiveType == "bytes3" ||
-		primitiveType == "bytes3[]" ||
-		primitiveType == "bytes4" ||
-		primitiveType == "bytes4[]" ||
-		primitiveType == "bytes5" ||
-		primitiveType == "bytes5[]" ||
-		primitiveType == "bytes6" ||
-		primitiveType == "bytes6[]" ||
-		primitiveType == "bytes7" ||
-		primitiveType == "bytes7[]" ||
-		primitiveType == "bytes8" ||
-		primitiveType == "bytes8[]" ||
-		primitiveType == "bytes9" ||
-		primitiveType == "bytes9[]" ||
-		primitiveType == "bytes10" ||
-		primitiveType == "bytes10[]" ||
-		primitiveType == "bytes11" ||
-		primitiveType == "bytes11[]" ||
-		primitiveType == "bytes12" ||
-		primitiveType == "bytes12[]" ||
-		primitiveType == "bytes13" ||
-		primitiveType == "bytes13[]" ||
-		primitiveType == "bytes14" ||
-		primitiveType == "bytes14[]" ||
-		primitiveType == "bytes15" ||
-		primitiveType == "bytes15[]" ||
-		primitiveType == "bytes16" ||
-		primitiveType == "bytes16[]" ||
-		primitiveType == "bytes17" ||
-		primitiveType == "bytes17[]" ||
-		primitiveType == "bytes18" ||
-		primitiveType == "bytes18[]" ||
-		primitiveType == "bytes19" ||
-		primitiveType == "bytes19[]" ||
-		primitiveType == "bytes20" ||
-		primitiveType == "bytes20[]" ||
-		primitiveType == "bytes21" ||
-		primitiveType == "bytes21[]" ||
-		primitiveType == "bytes22" ||
-		primitiveType == "bytes22[]" ||
-		primitiveType == "bytes23" ||
-		primitiveType == "bytes23[]" ||
-		primitiveType == "bytes24" ||
-		primitiveType == "bytes24[]" ||
-		primitiveType == "bytes25" ||
-		primitiveType == "bytes25[]" ||
-		primitiveType == "bytes26" ||
-		primitiveType == "bytes26[]" ||
-		primitiveType == "bytes27" ||
-		primitiveType == "bytes27[]" ||
-		primitiveType == "bytes28" ||
-		primitiveType == "bytes28[]" ||
-		primitiveType == "bytes29" ||
-		primitiveType == "bytes29[]" ||
-		primitiveType == "bytes30" ||
-		primitiveType == "bytes30[]" ||
-		primitiveType == "bytes31" ||
-		primitiveType == "bytes31[]" ||
-		primitiveType == "bytes32" ||
-		primitiveType == "bytes32[]" {
-		return true
-	}
-	if primitiveType == "int" ||
-		primitiveType == "int[]" ||
-		primitiveType == "int8" ||
-		primitiveType == "int8[]" ||
-		primitiveType == "int16" ||
-		primitiveType == "int16[]" ||
-		primitiveType == "int32" ||
-		primitiveType == "int32[]" ||
-		primitiveType == "int64" ||
-		primitiveType == "int64[]" ||
-		primitiveType == "int128" ||
-		primitiveType == "int128[]" ||
-		primitiveType == "int256" ||
-		primitiveType == "int256[]" {
-		return true
+	if strings.HasPrefix(primitiveType, "bytes") {
+		length, err := strconv.Atoi(strings.TrimPrefix(primitiveType, "bytes"))
+		return err == nil && length >= 1 && length <= 32 && !strings.HasPrefix(primitiveType, "bytes0")
 	}
-	if primitiveType == "uint" ||
-		primitiveType == "uint[]" ||
-		primitiveType == "uint8" ||
-		primitiveType == "uint8[]" ||
-		primitiveType == "uint16" ||
-		primitiveType == "uint16[]" ||
-		primitiveType == "uint32" ||
-		primitiveType == "uint32[]" ||
-		primitiveType == "uint64" ||
-		primitiveType == "uint64[]" ||
-		primitiveType == "uint128" ||
-		primitiveType == "uint128[]" ||
-		primitiveType == "uint256" ||
-		primitiveType == "uint256[]" {
-		return true
+	var size string
+	switch {
+	case strings.HasPrefix(primitiveType, "uint"):
+		size = strings.TrimPrefix(primitiveType, "uint")
+	case strings.HasPrefix(primitiveType, "int"):
+		size = strings.TrimPrefix(primitiveType, "int")
+	default:
+		return false
 	}
-	return false
+	bits, err := strconv.Atoi(size)
+	return err == nil && bits >= 8 && bits <= 256 && bits%8 == 0 && !strings.HasPrefix(size, "0")
 }
 
 // validate checks if the given domain is valid, i.e. contains at least
@@ -1012,7 +972,14 @@ func (domain *TypedDataDomain) validate() error {
 	if domain.ChainId == nil && len(domain.Name) == 0 && len(domain.Version) == 0 && len(domain.VerifyingContract) == 0 && len(domain.Salt) == 0 {
 		return errors.New("domain is undefined")
 	}
-
+	if len(domain.VerifyingContract) > 0 && !common.IsHexAddress(domain.VerifyingContract) {
+		return fmt.Errorf("invalid domain verifying contract %q", domain.VerifyingContract)
+	}
+	if len(domain.Salt) > 0 {
+		if salt, err := hexutil.Decode(domain.Salt); err != nil || len(salt) != 32 {
+			return fmt.Errorf("invalid domain salt %q, must be 32 bytes", domain.Salt)
+		}
+	}
 	return nil
 }
 
@@ -383,6 +383,68 @@ func TestJsonFiles(t *testing.T) {
 	}
 }
 
+// conformanceVector is a typed data test case with either the expected hashes or
+// the expected failure.
+type conformanceVector struct {
+	TypedData       core.TypedData `json:"typedData"`
+	EncodeType      string         `json:"encodeType"`
+	DomainSeparator hexutil.Bytes  `json:"domainSeparator"`
+	StructHash      hexutil.Bytes  `json:"structHash"`
+	SigningHash     hexutil.Bytes  `json:"signingHash"`
+	Error           string         `json:"error"`
+}
+
+// TestTypedDataConformance checks the typed data hashing against vectors computed
+// with an independent EIP-712 implementation.
+func TestTypedDataConformance(t *testing.T) {
+	corpusdir := path.Join("testdata", "conformance")
+	testfiles, err := ioutil.ReadDir(corpusdir)
+	if err != nil {
+		t.Fatalf("failed reading files: %v", err)
+	}
+	for _, fInfo := range testfiles {
+		data, err := ioutil.ReadFile(path.Join(corpusdir, fInfo.Name()))
+		if err != nil {
+			t.Fatalf("failed to read file %v: %v", fInfo.Name(), err)
+		}
+		var vector conformanceVector
+		if err := json.Unmarshal(data, &vector); err != nil {
+			t.Fatalf("file %v: json unmarshalling failed: %v", fInfo.Name(), err)
+		}
+		typedData := vector.TypedData
+
+		sighash, _, err := core.TypedDataAndHash(typedData)
+		if vector.Error != "" {
+			if err == nil || !strings.Contains(err.Error(), vector.Error) {
+				t.Errorf("file %v: error mismatch: have %v, want %q", fInfo.Name(), err, vector.Error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("file %v: hashing failed: %v", fInfo.Name(), err)
+			continue
+		}
+		if have := string(typedData.EncodeType(typedData.PrimaryType)); have != vector.EncodeType {
+			t.Errorf("file %v: encodeType mismatch: have %s, want %s", fInfo.Name(), have, vector.EncodeType)
+		}
+		domainSeparator, _ := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+		if !bytes.Equal(domainSeparator, vector.DomainSeparator) {
+			t.Errorf("file %v: domain separator mismatch: have %x, want %x", fInfo.Name(), domainSeparator, vector.DomainSeparator)
+		}
+		structHash, _ := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+		if !bytes.Equal(structHash, vector.StructHash) {
+			t.Errorf("file %v: struct hash mismatch: have %x, want %x", fInfo.Name(), structHash, vector.StructHash)
+		}
+		if !bytes.Equal(sighash, vector.SigningHash) {
+			t.Errorf("file %v: signing hash mismatch: have %x, want %x", fInfo.Name(), sighash, vector.SigningHash)
+		}
+		// Formatting must handle everything hashable
+		if _, err := typedData.Format(); err != nil {
+			t.Errorf("file %v: formatting failed: %v", fInfo.Name(), err)
+		}
+	}
+}
+
 // TestFuzzerFiles tests some files that have been found by fuzzing to cause
 // crashes or hangs.
 func TestFuzzerFiles(t *testing.T) {
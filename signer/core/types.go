@@ -86,13 +86,19 @@ func (args SendTxArgs) String() string {
 	return err.Error()
 }
 
-func (args *SendTxArgs) toTransaction() *types.Transaction {
-	var input []byte
+// data returns the input of the transaction, accepting both "data" and "input".
+func (args *SendTxArgs) data() []byte {
 	if args.Data != nil {
-		input = *args.Data
-	} else if args.Input != nil {
-		input = *args.Input
+		return *args.Data
+	}
+	if args.Input != nil {
+		return *args.Input
 	}
+	return nil
+}
+
+func (args *SendTxArgs) toTransaction() *types.Transaction {
+	input := args.data()
 	if args.To == nil {
 		return types.NewContractCreation(uint64(args.Nonce), (*big.Int)(&args.Value), uint64(args.Gas), (*big.Int)(&args.GasPrice), input)
 	}
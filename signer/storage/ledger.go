@@ -0,0 +1,139 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// spendEntry is a single spend recorded in the ledger.
+type spendEntry struct {
+	Time   int64        `json:"time"`
+	Amount *hexutil.Big `json:"amount"`
+}
+
+// SpendLedger keeps track of the amounts spent by accounts over time, persisting
+// them into a backing storage so that spend limits survive restarts.
+type SpendLedger struct {
+	db   Storage
+	lock sync.Mutex
+}
+
+// NewSpendLedger creates a spend ledger on top of the given storage.
+func NewSpendLedger(db Storage) *SpendLedger {
+	return &SpendLedger{db: db}
+}
+
+// Record adds a spend of the given amount by the account at the given time.
+func (l *SpendLedger) Record(account common.Address, amount *big.Int, at time.Time) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries, err := l.read(account)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, spendEntry{Time: at.Unix(), Amount: (*hexutil.Big)(new(big.Int).Set(amount))})
+	return l.write(account, entries)
+}
+
+// Spent returns the total amount spent by the account since the given time. An
+// error is returned if the spends of the account can't be retrieved, in which
+// case the caller must not assume that nothing was spent.
+func (l *SpendLedger) Spent(account common.Address, since time.Time) (*big.Int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries, err := l.read(account)
+	if err != nil {
+		return nil, err
+	}
+	total := new(big.Int)
+	for _, entry := range entries {
+		if entry.Time >= since.Unix() {
+			total.Add(total, entry.Amount.ToInt())
+		}
+	}
+	return total, nil
+}
+
+// Prune drops all spends of the account recorded before the given time.
+func (l *SpendLedger) Prune(account common.Address, before time.Time) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries, err := l.read(account)
+	if err != nil {
+		return err
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Time >= before.Unix() {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) != len(entries) {
+		return l.write(account, kept)
+	}
+	return nil
+}
+
+func ledgerKey(account common.Address) string {
+	return "spend-" + account.Hex()
+}
+
+// read retrieves the spends recorded for the account. Only a missing entry is
+// treated as an empty ledger, any other failure is returned as an error.
+func (l *SpendLedger) read(account common.Address) ([]spendEntry, error) {
+	blob, err := l.db.Get(ledgerKey(account))
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spend ledger of %s: %v", account.Hex(), err)
+	}
+	var entries []spendEntry
+	if err := json.Unmarshal([]byte(blob), &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode spend ledger of %s: %v", account.Hex(), err)
+	}
+	for i, entry := range entries {
+		if entry.Amount == nil {
+			return nil, fmt.Errorf("failed to decode spend ledger of %s: entry %d missing amount", account.Hex(), i)
+		}
+	}
+	return entries, nil
+}
+
+func (l *SpendLedger) write(account common.Address, entries []spendEntry) error {
+	if len(entries) == 0 {
+		l.db.Del(ledgerKey(account))
+		return nil
+	}
+	blob, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode spend ledger of %s: %v", account.Hex(), err)
+	}
+	l.db.Put(ledgerKey(account), string(blob))
+	return nil
+}
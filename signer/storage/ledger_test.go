@@ -0,0 +1,91 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSpendLedger(t *testing.T) {
+	d, err := ioutil.TempDir("", "eth-ledger-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		path    = filepath.Join(d, "ledger.json")
+		key     = []byte("AES256Key-32Characters1234567890")
+		account = common.HexToAddress("0xdead")
+		start   = time.Unix(1000, 0)
+	)
+	ledger := NewSpendLedger(NewAESEncryptedStorage(path, key))
+	for i, amount := range []int64{1, 2, 4} {
+		at := start.Add([]time.Duration{0, time.Minute, time.Hour}[i])
+		if err := ledger.Record(account, big.NewInt(amount), at); err != nil {
+			t.Fatalf("failed to record spend %d: %v", i, err)
+		}
+	}
+	checkSpent := func(ledger *SpendLedger, account common.Address, since time.Time, want int64) {
+		t.Helper()
+		spent, err := ledger.Spent(account, since)
+		if err != nil {
+			t.Fatalf("failed to retrieve spent amount: %v", err)
+		}
+		if spent.Int64() != want {
+			t.Errorf("spent mismatch: have %v, want %d", spent, want)
+		}
+	}
+	checkSpent(ledger, account, start, 7)
+	checkSpent(ledger, account, start.Add(time.Second), 6)
+	checkSpent(ledger, common.HexToAddress("0xbeef"), start, 0)
+
+	// Pruned entries are gone, also after reopening the ledger
+	if err := ledger.Prune(account, start.Add(2*time.Minute)); err != nil {
+		t.Fatalf("failed to prune ledger: %v", err)
+	}
+	ledger = NewSpendLedger(NewAESEncryptedStorage(path, key))
+	checkSpent(ledger, account, start, 4)
+}
+
+func TestSpendLedgerFailures(t *testing.T) {
+	var (
+		db      = NewEphemeralStorage()
+		ledger  = NewSpendLedger(db)
+		account = common.HexToAddress("0xdead")
+	)
+	// A corrupt ledger must not be mistaken for an empty one
+	db.Put(ledgerKey(account), "not json")
+	if _, err := ledger.Spent(account, time.Time{}); err == nil {
+		t.Errorf("corrupt ledger: expected error")
+	}
+	if err := ledger.Record(account, big.NewInt(1), time.Now()); err == nil {
+		t.Errorf("corrupt ledger: expected error on record")
+	}
+	if blob, _ := db.Get(ledgerKey(account)); blob != "not json" {
+		t.Errorf("corrupt ledger overwritten: %q", blob)
+	}
+	// An unreadable storage must not be mistaken for an empty one either
+	ledger = NewSpendLedger(&NoStorage{})
+	if _, err := ledger.Spent(account, time.Time{}); err == nil {
+		t.Errorf("unreadable ledger: expected error")
+	}
+}
@@ -94,6 +94,11 @@ type btHeaderMarshaling struct {
 	Timestamp  math.HexOrDecimal64
 }
 
+// Network returns the name of the fork the test is run on.
+func (t *BlockTest) Network() string {
+	return t.json.Network
+}
+
 func (t *BlockTest) Run(snapshotter bool) error {
 	config, ok := Forks[t.json.Network]
 	if !ok {
@@ -333,6 +333,16 @@ func (s *Sync) Pending() int {
 	return len(s.nodeReqs) + len(s.codeReqs)
 }
 
+// PendingNodes returns the number of trie nodes currently pending for download.
+func (s *Sync) PendingNodes() int {
+	return len(s.nodeReqs)
+}
+
+// PendingCodes returns the number of contract codes currently pending for download.
+func (s *Sync) PendingCodes() int {
+	return len(s.codeReqs)
+}
+
 // schedule inserts a new state retrieval request into the fetch queue. If there
 // is already a pending request for this node, the new request will be discarded
 // and only a parent reference added to the old one.
@@ -183,6 +183,11 @@ func TestIterativeDelayedSync(t *testing.T) {
 	queue := append(append([]common.Hash{}, nodes...), codes...)
 
 	for len(queue) > 0 {
+		// All the delayed requests must still be reported as pending, along with
+		// any processed nodes waiting for their children
+		if pending := sched.PendingNodes() + sched.PendingCodes(); pending < len(queue) || pending != sched.Pending() {
+			t.Fatalf("pending request count mismatch: have %d, want at least %d", pending, len(queue))
+		}
 		// Sync only half of the scheduled nodes
 		results := make([]SyncResult, len(queue)/2+1)
 		for i, hash := range queue[:len(results)] {